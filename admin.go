@@ -0,0 +1,703 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/rathil/rdi"
+	"github.com/spf13/cobra"
+
+	"github.com/mymmrac/lithium/pkg"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/deploy"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/quota"
+	"github.com/mymmrac/lithium/pkg/module/session"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/twofactor"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+// admin holds dependencies shared by admin commands
+type admin struct {
+	container           rdi.DI
+	tx                  db.Transaction
+	va                  *validator.Validate
+	userRepository      user.Repository
+	projectRepository   project.Repository
+	sessionRepository   session.Repository
+	twoFactorRepository twofactor.Repository
+	teamRepository      team.Repository
+}
+
+func adminCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Manage users and projects of the instance",
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return err
+			}
+			if output != "table" && output != "json" {
+				return fmt.Errorf("unknown output format %q, expected table or json", output)
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringP("output", "o", "table", "output format: table or json")
+
+	userCmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage users",
+	}
+	userCmd.AddCommand(
+		adminUserCreateCommand(),
+		adminUserResetPasswordCommand(),
+		adminUserResetTwoFactorCommand(),
+		adminUserListCommand(),
+	)
+
+	projectCmd := &cobra.Command{
+		Use:   "project",
+		Short: "Manage projects, project can be referenced by ID or sub-domain",
+	}
+	projectCmd.AddCommand(
+		adminProjectListCommand(),
+		adminProjectDisableCommand(true),
+		adminProjectDisableCommand(false),
+		adminProjectTransferCommand(),
+		adminProjectDeleteCommand(),
+	)
+
+	teamCmd := &cobra.Command{
+		Use:   "team",
+		Short: "Manage teams",
+	}
+	teamCmd.AddCommand(
+		adminTeamListCommand(),
+		adminTeamPlanCommand(),
+	)
+
+	cmd.AddCommand(userCmd, projectCmd, teamCmd)
+	return cmd
+}
+
+type userRow struct {
+	ID        id.ID     `json:"id"`
+	Email     string    `json:"email"`
+	Password  string    `json:"password,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (u userRow) columns() []string {
+	columns := []string{u.ID.String(), u.Email, u.CreatedAt.Local().Format(time.DateTime)}
+	if u.Password != "" {
+		columns = append(columns, u.Password)
+	}
+	return columns
+}
+
+func adminUserCreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create <email>",
+		Short: "Create user, password is generated and printed if not specified",
+		Args:  cobra.ExactArgs(1),
+		RunE: withAdmin(func(cmd *cobra.Command, args []string, a *admin) error {
+			email := args[0]
+			if err := a.va.Var(email, "email"); err != nil {
+				return fmt.Errorf("invalid email %q", email)
+			}
+
+			password, generated, err := passwordFlag(cmd, a.va)
+			if err != nil {
+				return err
+			}
+
+			hashedPassword, err := user.HashPassword(password)
+			if err != nil {
+				return fmt.Errorf("hash password: %w", err)
+			}
+
+			now := time.Now()
+			// Users created by admin are trusted, so email is not verified separately
+			model := &user.Model{
+				ID:              id.New(),
+				Email:           email,
+				Password:        hashedPassword,
+				EmailVerifiedAt: now,
+				CreatedAt:       now,
+				UpdatedAt:       now,
+			}
+			if err = a.userRepository.Create(cmd.Context(), model); err != nil {
+				if errors.Is(err, user.ErrAlreadyExists) {
+					return fmt.Errorf("email %q already used", email)
+				}
+				return fmt.Errorf("create user: %w", err)
+			}
+
+			row := userRow{ID: model.ID, Email: model.Email, CreatedAt: model.CreatedAt}
+			headers := []string{"ID", "EMAIL", "CREATED AT"}
+			if generated {
+				row.Password = password
+				headers = append(headers, "PASSWORD")
+			}
+			return printOutput(cmd, row, headers, [][]string{row.columns()})
+		}),
+	}
+	cmd.Flags().String("password", "", "user password (generated if empty)")
+	return cmd
+}
+
+func adminUserResetPasswordCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reset-password <email>",
+		Short: "Set new password for user, password is generated and printed if not specified",
+		Args:  cobra.ExactArgs(1),
+		RunE: withAdmin(func(cmd *cobra.Command, args []string, a *admin) error {
+			model, found, err := a.userRepository.GetByEmail(cmd.Context(), args[0])
+			if err != nil {
+				return fmt.Errorf("get user: %w", err)
+			}
+			if !found {
+				return fmt.Errorf("user %q not found", args[0])
+			}
+
+			password, generated, err := passwordFlag(cmd, a.va)
+			if err != nil {
+				return err
+			}
+
+			hashedPassword, err := user.HashPassword(password)
+			if err != nil {
+				return fmt.Errorf("hash password: %w", err)
+			}
+
+			ctx, err := a.tx.Begin(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("begin transaction: %w", err)
+			}
+			defer func() { _ = a.tx.Rollback(ctx) }()
+
+			if err = a.userRepository.UpdatePassword(ctx, model.ID, hashedPassword); err != nil {
+				return fmt.Errorf("update password: %w", err)
+			}
+
+			// Password change signs user out everywhere
+			if err = a.sessionRepository.DeleteByUserID(ctx, model.ID); err != nil {
+				return fmt.Errorf("delete sessions: %w", err)
+			}
+
+			if err = a.tx.Commit(ctx); err != nil {
+				return fmt.Errorf("commit transaction: %w", err)
+			}
+
+			row := userRow{ID: model.ID, Email: model.Email, CreatedAt: model.CreatedAt}
+			headers := []string{"ID", "EMAIL", "CREATED AT"}
+			if generated {
+				row.Password = password
+				headers = append(headers, "PASSWORD")
+			}
+			return printOutput(cmd, row, headers, [][]string{row.columns()})
+		}),
+	}
+	cmd.Flags().String("password", "", "new password (generated if empty)")
+	return cmd
+}
+
+func adminUserResetTwoFactorCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reset-two-factor <email>",
+		Short: "Disable two-factor authentication of user that lost access to it",
+		Args:  cobra.ExactArgs(1),
+		RunE: withAdmin(func(cmd *cobra.Command, args []string, a *admin) error {
+			model, found, err := a.userRepository.GetByEmail(cmd.Context(), args[0])
+			if err != nil {
+				return fmt.Errorf("get user: %w", err)
+			}
+			if !found {
+				return fmt.Errorf("user %q not found", args[0])
+			}
+
+			ctx, err := a.tx.Begin(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("begin transaction: %w", err)
+			}
+			defer func() { _ = a.tx.Rollback(ctx) }()
+
+			if err = a.twoFactorRepository.DeleteRecoveryCodesByUserID(ctx, model.ID); err != nil {
+				return fmt.Errorf("delete recovery codes: %w", err)
+			}
+
+			if err = a.twoFactorRepository.DeleteByUserID(ctx, model.ID); err != nil {
+				return fmt.Errorf("delete two-factor: %w", err)
+			}
+
+			if err = a.tx.Commit(ctx); err != nil {
+				return fmt.Errorf("commit transaction: %w", err)
+			}
+
+			row := userRow{ID: model.ID, Email: model.Email, CreatedAt: model.CreatedAt}
+			return printOutput(cmd, row, []string{"ID", "EMAIL", "CREATED AT"}, [][]string{row.columns()})
+		}),
+	}
+}
+
+func adminUserListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all users",
+		Args:  cobra.NoArgs,
+		RunE: withAdmin(func(cmd *cobra.Command, _ []string, a *admin) error {
+			models, err := a.userRepository.GetAll(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("get users: %w", err)
+			}
+
+			users := make([]userRow, len(models))
+			rows := make([][]string, len(models))
+			for i, model := range models {
+				users[i] = userRow{ID: model.ID, Email: model.Email, CreatedAt: model.CreatedAt}
+				rows[i] = users[i].columns()
+			}
+
+			return printOutput(cmd, users, []string{"ID", "EMAIL", "CREATED AT"}, rows)
+		}),
+	}
+}
+
+type projectRow struct {
+	ID        id.ID     `json:"id"`
+	Name      string    `json:"name"`
+	SubDomain string    `json:"subDomain"`
+	TeamID    id.ID     `json:"teamId"`
+	TeamName  string    `json:"teamName"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// projectHeaders are table headers of projectRow columns
+//
+//nolint:gochecknoglobals
+var projectHeaders = []string{"ID", "NAME", "SUB-DOMAIN", "TEAM", "DISABLED", "CREATED AT"}
+
+func (p projectRow) columns() []string {
+	return []string{
+		p.ID.String(), p.Name, p.SubDomain, p.TeamName, strconv.FormatBool(p.Disabled),
+		p.CreatedAt.Local().Format(time.DateTime),
+	}
+}
+
+func (a *admin) projectRow(ctx context.Context, model *project.Model) (projectRow, error) {
+	teamModel, found, err := a.teamRepository.GetByID(ctx, model.TeamID)
+	if err != nil {
+		return projectRow{}, fmt.Errorf("get team: %w", err)
+	}
+
+	row := projectRow{
+		ID:        model.ID,
+		Name:      model.Name,
+		SubDomain: model.SubDomain,
+		TeamID:    model.TeamID,
+		Disabled:  model.Disabled,
+		CreatedAt: model.CreatedAt,
+	}
+	if found {
+		row.TeamName = teamModel.Name
+	}
+	return row, nil
+}
+
+func adminProjectListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all projects",
+		Args:  cobra.NoArgs,
+		RunE: withAdmin(func(cmd *cobra.Command, _ []string, a *admin) error {
+			userEmail, err := cmd.Flags().GetString("user")
+			if err != nil {
+				return err
+			}
+
+			var models []project.Model
+			if userEmail != "" {
+				userModel, found, err := a.userRepository.GetByEmail(cmd.Context(), userEmail)
+				if err != nil {
+					return fmt.Errorf("get user: %w", err)
+				}
+				if !found {
+					return fmt.Errorf("user %q not found", userEmail)
+				}
+
+				members, err := a.teamRepository.GetMembersByUserID(cmd.Context(), userModel.ID)
+				if err != nil {
+					return fmt.Errorf("get team members: %w", err)
+				}
+				teamIDs := make([]id.ID, len(members))
+				for i, member := range members {
+					teamIDs[i] = member.TeamID
+				}
+
+				models, err = a.projectRepository.GetByTeamIDs(cmd.Context(), teamIDs)
+				if err != nil {
+					return fmt.Errorf("get projects: %w", err)
+				}
+			} else {
+				models, err = a.projectRepository.GetAll(cmd.Context())
+				if err != nil {
+					return fmt.Errorf("get projects: %w", err)
+				}
+			}
+
+			projects := make([]projectRow, len(models))
+			rows := make([][]string, len(models))
+			for i := range models {
+				projects[i], err = a.projectRow(cmd.Context(), &models[i])
+				if err != nil {
+					return err
+				}
+				rows[i] = projects[i].columns()
+			}
+
+			return printOutput(cmd, projects, projectHeaders, rows)
+		}),
+	}
+	cmd.Flags().String("user", "", "only list projects of teams where the user with this email is a member")
+	return cmd
+}
+
+func adminProjectDisableCommand(disable bool) *cobra.Command {
+	use, short := "enable <project>", "Enable previously disabled project"
+	if disable {
+		use, short = "disable <project>", "Disable project, its actions are no longer invoked"
+	}
+
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: withAdmin(func(cmd *cobra.Command, args []string, a *admin) error {
+			model, err := a.findProject(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			if err = a.projectRepository.UpdateDisabled(cmd.Context(), model.ID, disable); err != nil {
+				return fmt.Errorf("update project: %w", err)
+			}
+			model.Disabled = disable
+
+			row, err := a.projectRow(cmd.Context(), model)
+			if err != nil {
+				return err
+			}
+			return printOutput(cmd, row, projectHeaders, [][]string{row.columns()})
+		}),
+	}
+}
+
+func adminProjectTransferCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "transfer <project> <team-id>",
+		Short: "Move project to another team",
+		Args:  cobra.ExactArgs(2),
+		RunE: withAdmin(func(cmd *cobra.Command, args []string, a *admin) error {
+			model, err := a.findProject(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			teamID, err := id.Parse(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid team ID %q", args[1])
+			}
+			_, found, err := a.teamRepository.GetByID(cmd.Context(), teamID)
+			if err != nil {
+				return fmt.Errorf("get team: %w", err)
+			}
+			if !found {
+				return fmt.Errorf("team %q not found", args[1])
+			}
+
+			if err = a.projectRepository.UpdateTeam(cmd.Context(), model.ID, teamID); err != nil {
+				return fmt.Errorf("update project: %w", err)
+			}
+			model.TeamID = teamID
+
+			row, err := a.projectRow(cmd.Context(), model)
+			if err != nil {
+				return err
+			}
+			return printOutput(cmd, row, projectHeaders, [][]string{row.columns()})
+		}),
+	}
+}
+
+func adminProjectDeleteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <project>",
+		Short: "Delete project with all its actions, deploys and stored modules",
+		Args:  cobra.ExactArgs(1),
+		RunE: withAdmin(func(cmd *cobra.Command, args []string, a *admin) error {
+			confirmed, err := cmd.Flags().GetBool("yes")
+			if err != nil {
+				return err
+			}
+
+			model, err := a.findProject(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				return fmt.Errorf("deleting project %q (%s) can't be undone, pass --yes to confirm", model.Name, model.ID)
+			}
+
+			var deleted int
+			err = a.container.Invoke(func(projectDeleter deploy.ProjectDeleter) error {
+				deleted, err = projectDeleter.Delete(cmd.Context(), model.ID)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			row, err := a.projectRow(cmd.Context(), model)
+			if err != nil {
+				return err
+			}
+
+			type deleteResult struct {
+				projectRow
+
+				DeletedModules int `json:"deletedModules"`
+			}
+			return printOutput(cmd, deleteResult{projectRow: row, DeletedModules: deleted},
+				slices.Concat(projectHeaders, []string{"DELETED MODULES"}),
+				[][]string{append(row.columns(), strconv.Itoa(deleted))},
+			)
+		}),
+	}
+	cmd.Flags().Bool("yes", false, "confirm deletion")
+	return cmd
+}
+
+// findProject finds project by ID or sub-domain
+func (a *admin) findProject(ctx context.Context, ref string) (*project.Model, error) {
+	var (
+		model *project.Model
+		found bool
+		err   error
+	)
+	if projectID, parseErr := id.Parse(ref); parseErr == nil {
+		model, found, err = a.projectRepository.GetByID(ctx, projectID)
+	} else {
+		model, found, err = a.projectRepository.GetBySubDomain(ctx, ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get project: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("project %q not found", ref)
+	}
+	return model, nil
+}
+
+type teamRow struct {
+	ID        id.ID     `json:"id"`
+	Name      string    `json:"name"`
+	Owners    []string  `json:"owners"`
+	Members   int       `json:"members"`
+	Plan      string    `json:"plan"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var teamHeaders = []string{"ID", "NAME", "OWNERS", "MEMBERS", "PLAN", "CREATED AT"}
+
+func (t teamRow) columns() []string {
+	plan := t.Plan
+	if plan == "" {
+		plan = "-"
+	}
+	return []string{
+		t.ID.String(), t.Name, strings.Join(t.Owners, ", "), strconv.Itoa(t.Members), plan,
+		t.CreatedAt.Local().Format(time.DateTime),
+	}
+}
+
+func (a *admin) teamRow(ctx context.Context, model *team.Model) (teamRow, error) {
+	members, err := a.teamRepository.GetMembersByTeamID(ctx, model.ID)
+	if err != nil {
+		return teamRow{}, fmt.Errorf("get team members: %w", err)
+	}
+
+	row := teamRow{
+		ID:        model.ID,
+		Name:      model.Name,
+		Owners:    []string{},
+		Members:   len(members),
+		Plan:      model.Plan,
+		CreatedAt: model.CreatedAt,
+	}
+	for _, member := range members {
+		if member.Role == team.RoleOwner {
+			row.Owners = append(row.Owners, member.User.Email)
+		}
+	}
+	return row, nil
+}
+
+func adminTeamListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all teams with their owners",
+		Args:  cobra.NoArgs,
+		RunE: withAdmin(func(cmd *cobra.Command, _ []string, a *admin) error {
+			models, err := a.teamRepository.GetAll(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("get teams: %w", err)
+			}
+
+			teams := make([]teamRow, len(models))
+			rows := make([][]string, len(models))
+			for i := range models {
+				teams[i], err = a.teamRow(cmd.Context(), &models[i])
+				if err != nil {
+					return err
+				}
+				rows[i] = teams[i].columns()
+			}
+
+			return printOutput(cmd, teams, teamHeaders, rows)
+		}),
+	}
+}
+
+func adminTeamPlanCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "plan <team-id> [plan]",
+		Short: "Assign quota plan to team, team uses default plan if plan is omitted",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: withAdmin(func(cmd *cobra.Command, args []string, a *admin) error {
+			teamID, err := id.Parse(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid team ID %q", args[0])
+			}
+			model, found, err := a.teamRepository.GetByID(cmd.Context(), teamID)
+			if err != nil {
+				return fmt.Errorf("get team: %w", err)
+			}
+			if !found {
+				return fmt.Errorf("team %q not found", args[0])
+			}
+
+			var plan string
+			if len(args) == 2 {
+				plan = args[1]
+			}
+
+			if plan != "" {
+				err = a.container.Invoke(func(cfg quota.Config) error {
+					if _, ok := cfg.FindPlan(plan); !ok {
+						return fmt.Errorf("quota plan %q is not configured", plan)
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			if err = a.teamRepository.UpdatePlan(cmd.Context(), model.ID, plan); err != nil {
+				return fmt.Errorf("update team: %w", err)
+			}
+			model.Plan = plan
+
+			row, err := a.teamRow(cmd.Context(), model)
+			if err != nil {
+				return err
+			}
+			return printOutput(cmd, row, teamHeaders, [][]string{row.columns()})
+		}),
+	}
+}
+
+// passwordFlag returns password from flag or generates random one
+func passwordFlag(cmd *cobra.Command, va *validator.Validate) (string, bool, error) {
+	password, err := cmd.Flags().GetString("password")
+	if err != nil {
+		return "", false, err
+	}
+	if password == "" {
+		return rand.Text(), true, nil
+	}
+
+	// Same rules as for registration
+	if err = va.Var(password, "min=8,max=64"); err != nil {
+		return "", false, errors.New("password must be from 8 to 64 characters long")
+	}
+	return password, false, nil
+}
+
+// printOutput prints value as JSON or rows as table depending on output flag
+func printOutput(cmd *cobra.Command, value any, headers []string, rows [][]string) error {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(cmd.OutOrStdout())
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// withAdmin runs command with admin dependencies
+func withAdmin(fn func(cmd *cobra.Command, args []string, a *admin) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		v, err := newViper(cmd)
+		if err != nil {
+			return err
+		}
+
+		container := pkg.DI(ctx, v)
+		return container.Invoke(func(
+			tx db.Transaction, va *validator.Validate, userRepository user.Repository,
+			projectRepository project.Repository, sessionRepository session.Repository,
+			twoFactorRepository twofactor.Repository, teamRepository team.Repository,
+		) error {
+			defer func() { _ = tx.DB().Close() }()
+
+			return fn(cmd, args, &admin{
+				container:           container,
+				tx:                  tx,
+				va:                  va,
+				userRepository:      userRepository,
+				projectRepository:   projectRepository,
+				sessionRepository:   sessionRepository,
+				twoFactorRepository: twoFactorRepository,
+				teamRepository:      teamRepository,
+			})
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rathil/rdi"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+
+	actionHandler "github.com/mymmrac/lithium/pkg/handler/action"
+	authHandler "github.com/mymmrac/lithium/pkg/handler/auth"
+	"github.com/mymmrac/lithium/pkg/handler/invoker"
+	teamHandler "github.com/mymmrac/lithium/pkg/handler/team"
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/certificate"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/deploy"
+	"github.com/mymmrac/lithium/pkg/module/di"
+	"github.com/mymmrac/lithium/pkg/module/domain"
+	"github.com/mymmrac/lithium/pkg/module/mail"
+	"github.com/mymmrac/lithium/pkg/module/oidc"
+	"github.com/mymmrac/lithium/pkg/module/quota"
+	"github.com/mymmrac/lithium/pkg/module/routing"
+	"github.com/mymmrac/lithium/pkg/module/server"
+	"github.com/mymmrac/lithium/pkg/module/storage"
+	"github.com/mymmrac/lithium/pkg/module/throttle"
+)
+
+// configSection builds one configuration struct through DI
+type configSection struct {
+	name  string
+	build func(container rdi.DI) (any, error)
+}
+
+func section[T any](name string) configSection {
+	return configSection{
+		name: name,
+		build: func(container rdi.DI) (any, error) {
+			var cfg T
+			err := container.Invoke(func(value T) { cfg = value })
+			return cfg, err
+		},
+	}
+}
+
+// configSections lists every configuration struct of the application
+func configSections() []configSection {
+	return []configSection{
+		section[server.Config]("server"),
+		section[certificate.Config]("certificate"),
+		section[db.Config]("db"),
+		section[auth.Config]("auth"),
+		section[authHandler.Config]("auth-handler"),
+		section[throttle.Config]("throttle"),
+		section[mail.Config]("mail"),
+		section[oidc.Config]("oidc"),
+		section[storage.Config]("storage"),
+		section[deploy.Config]("deploy"),
+		section[quota.Config]("quota"),
+		section[routing.Config]("routing"),
+		section[domain.Config]("domain"),
+		section[invoker.Config]("invoker"),
+		section[actionHandler.Config]("action-handler"),
+		section[teamHandler.Config]("team-handler"),
+	}
+}
+
+func configCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect configuration",
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "validate",
+			Short: "Validate configuration and print all errors",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				_, err := buildConfigs(cmd)
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), "configuration is valid")
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "print",
+			Short: "Print effective configuration with secrets redacted",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				configs, err := buildConfigs(cmd)
+				if err != nil {
+					return err
+				}
+
+				encoder := yaml.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent(2)
+				if err = encoder.Encode(configs); err != nil {
+					return fmt.Errorf("encode config: %w", err)
+				}
+				return encoder.Close()
+			},
+		},
+	)
+
+	return cmd
+}
+
+// buildConfigs builds all configuration sections, errors of every section are collected and returned together
+func buildConfigs(cmd *cobra.Command) (map[string]any, error) {
+	v, err := newViper(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+	container := di.New(ctx, v)
+
+	var errs []error
+	configs := make(map[string]any)
+	for _, s := range configSections() {
+		cfg, err := s.build(container)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		configs[s.name] = redact(reflect.ValueOf(cfg))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return configs, nil
+}
+
+// redact converts config into a map, values of fields with `secret:"true"` tag are hidden, fields with
+// `secret:"url"` tag only have passwords in the URL hidden, durations are formatted as strings
+func redact(value reflect.Value) any {
+	if value.Type() == reflect.TypeFor[time.Duration]() {
+		return time.Duration(value.Int()).String()
+	}
+
+	if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct {
+		items := make([]any, value.Len())
+		for i := range value.Len() {
+			items[i] = redact(value.Index(i))
+		}
+		return items
+	}
+
+	if value.Kind() != reflect.Struct {
+		return value.Interface()
+	}
+
+	fields := make(map[string]any, value.NumField())
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		switch field.Tag.Get("secret") {
+		case "true":
+			if !fieldValue.IsZero() {
+				fields[field.Name] = "<redacted>"
+				continue
+			}
+		case "url":
+			if !fieldValue.IsZero() {
+				fields[field.Name] = redactURL(fieldValue.String())
+				continue
+			}
+		}
+
+		fields[field.Name] = redact(fieldValue)
+	}
+	return fields
+}
+
+// redactURL hides password of user info and values of query parameters that contain password, for example,
+// `sslpassword` in database connection string, URL that can't be parsed is hidden entirely
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "<redacted>"
+	}
+
+	query := u.Query()
+	redacted := false
+	for key := range query {
+		if strings.Contains(strings.ToLower(key), "password") {
+			query[key] = []string{"xxxxx"}
+			redacted = true
+		}
+	}
+	if redacted {
+		u.RawQuery = query.Encode()
+	}
+
+	return u.Redacted()
+}
@@ -13,13 +13,18 @@ import (
 
 	"github.com/mymmrac/lithium/pkg"
 	"github.com/mymmrac/lithium/pkg/handler/action"
+	"github.com/mymmrac/lithium/pkg/handler/audit"
 	"github.com/mymmrac/lithium/pkg/handler/auth"
+	"github.com/mymmrac/lithium/pkg/handler/domain"
 	"github.com/mymmrac/lithium/pkg/handler/project"
 	"github.com/mymmrac/lithium/pkg/handler/static"
+	"github.com/mymmrac/lithium/pkg/handler/team"
+	"github.com/mymmrac/lithium/pkg/handler/token"
 	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/deploy"
 	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/quota"
 	"github.com/mymmrac/lithium/pkg/module/runner"
-	_ "github.com/mymmrac/lithium/pkg/module/server"
 	_ "github.com/mymmrac/lithium/pkg/module/validator"
 	"github.com/mymmrac/lithium/pkg/module/version"
 )
@@ -32,6 +37,8 @@ func main() {
 		Version:      fmt.Sprintf("%s (%s), built at %s", version.Version(), version.Modified(), version.BuildTime()),
 		SilenceUsage: true,
 	}
+	cmd.PersistentFlags().String("config", "", "path to config file (YAML or TOML), environment variables take precedence")
+	cmd.AddCommand(migrateCommand(), configCommand(), adminCommand())
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
@@ -45,24 +52,25 @@ func run(cmd *cobra.Command, _ []string) error {
 	ctx, cancel := context.WithCancel(cmd.Context())
 	defer cancel()
 
-	v := viper.NewWithOptions()
-	v.AutomaticEnv()
-	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-
-	v.SetDefault("log-level", "info")
-	v.SetDefault("host", "")
-	v.SetDefault("port", 4251)
-
-	logger.SetLevel(v.GetString("log-level"))
+	v, err := newViper(cmd)
+	if err != nil {
+		return err
+	}
 
 	logger.Infow(ctx, "starting lithium", "version", version.Version())
-	err := pkg.DI(ctx, v).
+	err = pkg.DI(ctx, v).
 		Invoke(
 			db.RunMigrations,
 			static.RegisterHandlers,
 			auth.RegisterHandlers,
 			project.RegisterHandlers,
 			action.RegisterHandlers,
+			token.RegisterHandlers,
+			team.RegisterHandlers,
+			audit.RegisterHandlers,
+			domain.RegisterHandlers,
+			runner.AddServiceInvoker[deploy.Worker](),
+			runner.AddServiceInvoker[quota.Quota](),
 			runner.RunAndWait,
 		)
 	if err != nil {
@@ -72,3 +80,68 @@ func run(cmd *cobra.Command, _ []string) error {
 
 	return nil
 }
+
+// newViper loads configuration from defaults, config file (if specified) and environment variables.
+func newViper(cmd *cobra.Command) (*viper.Viper, error) {
+	v := viper.NewWithOptions()
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+
+	v.SetDefault("log-level", "info")
+	v.SetDefault("host", "")
+	v.SetDefault("port", 4251)
+	v.SetDefault("body-limit", 64*1024*1024)
+	v.SetDefault("read-timeout", "0s")
+	v.SetDefault("write-timeout", "0s")
+	v.SetDefault("idle-timeout", "0s")
+	v.SetDefault("invocation-host", "")
+	v.SetDefault("invocation-port", 0)
+	v.SetDefault("invocation-body-limit", 8*1024*1024)
+	v.SetDefault("invocation-read-timeout", "30s")
+	v.SetDefault("invocation-write-timeout", "30s")
+	v.SetDefault("invocation-idle-timeout", "60s")
+	v.SetDefault("tls-redirect-port", 0)
+	v.SetDefault("tls-reload-interval", "30s")
+	v.SetDefault("auto-migrate", true)
+	v.SetDefault("session-access-ttl", "15m")
+	v.SetDefault("session-refresh-ttl", "720h")
+	v.SetDefault("cookie-secure", false)
+	v.SetDefault("cookie-same-site", "lax")
+	v.SetDefault("public-url", "http://localhost:4251")
+	v.SetDefault("email-verification-required", false)
+	v.SetDefault("registration-enabled", true)
+	v.SetDefault("two-factor-required", false)
+	v.SetDefault("login-max-attempts", 10)
+	v.SetDefault("login-max-attempts-per-ip", 100)
+	v.SetDefault("login-lockout-duration", "15m")
+	v.SetDefault("throttle-store", "database")
+	v.SetDefault("password-hash-concurrency", 4)
+	v.SetDefault("mail-driver", "log")
+	v.SetDefault("mail-from", "Lithium <noreply@localhost>")
+	v.SetDefault("mail-path", "./data/mail")
+	v.SetDefault("smtp-port", 587)
+	v.SetDefault("smtp-tls", "starttls")
+	v.SetDefault("deploy-workers", 2)
+	v.SetDefault("deploy-lease-timeout", "10m")
+	v.SetDefault("module-max-size", 64*1024*1024)
+	v.SetDefault("reserved-subdomains", []string{"www"})
+	v.SetDefault("domain-verification-timeout", "10s")
+	v.SetDefault("domain-verification-allow-private", false)
+	v.SetDefault("storage-driver", "minio")
+	v.SetDefault("storage-path", "./data")
+
+	configFile, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return nil, err
+	}
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+		if err = v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+	}
+
+	logger.SetLevel(v.GetString("log-level"))
+
+	return v, nil
+}
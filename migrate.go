@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+func migrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database migrations",
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply all pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: withDB(func(cmd *cobra.Command, _ []string, bunDB *bun.DB) error {
+				group, err := db.Migrate(cmd.Context(), bunDB)
+				if err != nil {
+					return err
+				}
+				printGroup(cmd, "migrated", group)
+				return nil
+			}),
+		},
+		&cobra.Command{
+			Use:   "down",
+			Short: "Roll back the last migration group",
+			Args:  cobra.NoArgs,
+			RunE: withDB(func(cmd *cobra.Command, _ []string, bunDB *bun.DB) error {
+				group, err := db.Rollback(cmd.Context(), bunDB)
+				if err != nil {
+					return err
+				}
+				printGroup(cmd, "rolled back", group)
+				return nil
+			}),
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show applied and pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: withDB(func(cmd *cobra.Command, _ []string, bunDB *bun.DB) error {
+				migrator, err := db.NewMigrator(cmd.Context(), bunDB)
+				if err != nil {
+					return err
+				}
+
+				ms, err := migrator.MigrationsWithStatus(cmd.Context())
+				if err != nil {
+					return fmt.Errorf("migrations status: %w", err)
+				}
+
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				_, _ = fmt.Fprintln(w, "MIGRATION\tGROUP\tMIGRATED AT")
+				for _, m := range ms {
+					group, migratedAt := "pending", ""
+					if m.IsApplied() {
+						group = fmt.Sprintf("#%d", m.GroupID)
+						migratedAt = m.MigratedAt.Local().Format(time.DateTime)
+					}
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", m.String(), group, migratedAt)
+				}
+				return w.Flush()
+			}),
+		},
+		createMigrationCommand(),
+		&cobra.Command{
+			Use:   "mark-applied [migration...]",
+			Short: "Mark migrations as applied without running them, all pending if none specified",
+			RunE: withDB(func(cmd *cobra.Command, args []string, bunDB *bun.DB) error {
+				group, err := db.MarkApplied(cmd.Context(), bunDB, args)
+				if err != nil {
+					return err
+				}
+				printGroup(cmd, "marked as applied", group)
+				return nil
+			}),
+		},
+	)
+
+	return cmd
+}
+
+func createMigrationCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create empty portable migration files",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := cmd.Flags().GetString("dir")
+			if err != nil {
+				return err
+			}
+
+			files, err := db.CreateMigration(dir, args[0])
+			for _, file := range files {
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), "created", file)
+			}
+			return err
+		},
+	}
+	cmd.Flags().String("dir", "./migrations", "migrations directory in the source tree")
+	return cmd
+}
+
+// withDB runs command with connected database, only database related dependencies are created
+func withDB(fn func(cmd *cobra.Command, args []string, bunDB *bun.DB) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		v, err := newViper(cmd)
+		if err != nil {
+			return err
+		}
+
+		return di.New(ctx, v).Invoke(func(bunDB *bun.DB) error {
+			defer func() { _ = bunDB.Close() }()
+			return fn(cmd, args, bunDB)
+		})
+	}
+}
+
+func printGroup(cmd *cobra.Command, action string, group *migrate.MigrationGroup) {
+	if group.IsZero() {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "nothing to do, no migrations", action)
+		return
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s %s\n", action, group)
+}
@@ -0,0 +1,2 @@
+ALTER TABLE project
+    ADD COLUMN config JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+ALTER TABLE action
+    ADD COLUMN module_report JSONB;
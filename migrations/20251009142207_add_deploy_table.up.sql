@@ -0,0 +1,18 @@
+CREATE TABLE deploy
+(
+    id          BIGINT PRIMARY KEY,
+    action_id   BIGINT       NOT NULL REFERENCES action (id) ON DELETE RESTRICT,
+    status      VARCHAR(32)  NOT NULL,
+    error       TEXT         NOT NULL,
+    module_path TEXT         NOT NULL,
+    created_at  TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE INDEX deploy_action_id ON deploy (action_id);
+
+--bun:split
+
+CREATE INDEX deploy_status ON deploy (status);
@@ -0,0 +1,2 @@
+ALTER TABLE project
+    ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,26 @@
+CREATE TABLE api_token
+(
+    id           BIGINT PRIMARY KEY,
+    user_id      BIGINT       NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    project_id   BIGINT REFERENCES project (id) ON DELETE RESTRICT,
+    kind         VARCHAR(32)  NOT NULL,
+    name         TEXT         NOT NULL,
+    prefix       TEXT         NOT NULL,
+    hash         TEXT         NOT NULL,
+    scopes       JSONB        NOT NULL,
+    expires_at   TIMESTAMP(0),
+    last_used_at TIMESTAMP(0),
+    created_at   TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX api_token_hash ON api_token (hash);
+
+--bun:split
+
+CREATE INDEX api_token_user_id ON api_token (user_id);
+
+--bun:split
+
+CREATE INDEX api_token_project_id ON api_token (project_id);
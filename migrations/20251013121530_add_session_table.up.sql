@@ -0,0 +1,25 @@
+CREATE TABLE session
+(
+    id                    BIGINT PRIMARY KEY,
+    user_id               BIGINT       NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    refresh_hash          TEXT         NOT NULL,
+    previous_refresh_hash TEXT,
+    user_agent            TEXT         NOT NULL,
+    ip_address            TEXT         NOT NULL,
+    created_at            TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at          TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rotated_at            TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at            TIMESTAMP(0) NOT NULL
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX session_refresh_hash ON session (refresh_hash);
+
+--bun:split
+
+CREATE INDEX session_previous_refresh_hash ON session (previous_refresh_hash);
+
+--bun:split
+
+CREATE INDEX session_user_id ON session (user_id);
@@ -0,0 +1,6 @@
+DROP TABLE verification_token;
+
+--bun:split
+
+ALTER TABLE "user"
+    DROP COLUMN email_verified_at;
@@ -0,0 +1,28 @@
+ALTER TABLE "user"
+    ADD COLUMN email_verified_at TIMESTAMP(0);
+
+--bun:split
+
+-- Existing users are considered verified, so enabling required verification doesn't lock them out
+UPDATE "user"
+SET email_verified_at = created_at;
+
+--bun:split
+
+CREATE TABLE verification_token
+(
+    id         BIGINT PRIMARY KEY,
+    user_id    BIGINT       NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    purpose    VARCHAR(32)  NOT NULL,
+    hash       TEXT         NOT NULL,
+    expires_at TIMESTAMP(0) NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX verification_token_hash ON verification_token (hash);
+
+--bun:split
+
+CREATE INDEX verification_token_user_id ON verification_token (user_id);
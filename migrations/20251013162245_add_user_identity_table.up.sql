@@ -0,0 +1,17 @@
+CREATE TABLE user_identity
+(
+    id         BIGINT PRIMARY KEY,
+    user_id    BIGINT       NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    provider   TEXT         NOT NULL,
+    subject    TEXT         NOT NULL,
+    email      TEXT         NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX user_identity_provider_subject ON user_identity (provider, subject);
+
+--bun:split
+
+CREATE INDEX user_identity_user_id ON user_identity (user_id);
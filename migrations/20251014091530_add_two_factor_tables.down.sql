@@ -0,0 +1,5 @@
+DROP TABLE recovery_code;
+
+--bun:split
+
+DROP TABLE two_factor;
@@ -0,0 +1,22 @@
+CREATE TABLE two_factor
+(
+    user_id    BIGINT PRIMARY KEY REFERENCES "user" (id) ON DELETE RESTRICT,
+    secret     TEXT         NOT NULL,
+    enabled_at TIMESTAMP(0),
+    last_step  BIGINT       NOT NULL DEFAULT 0,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE TABLE recovery_code
+(
+    id         BIGINT PRIMARY KEY,
+    user_id    BIGINT       NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    hash       TEXT         NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX recovery_code_user_id_hash ON recovery_code (user_id, hash);
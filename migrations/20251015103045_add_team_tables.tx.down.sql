@@ -0,0 +1,18 @@
+DROP INDEX project_team_id;
+
+--bun:split
+
+ALTER TABLE project
+    DROP COLUMN team_id;
+
+--bun:split
+
+DROP TABLE team_invitation;
+
+--bun:split
+
+DROP TABLE team_member;
+
+--bun:split
+
+DROP TABLE team;
@@ -0,0 +1,21 @@
+-- Audit log has no foreign keys, so entries outlive deleted users, projects and actions
+CREATE TABLE audit_log
+(
+    id         BIGINT PRIMARY KEY,
+    actor_id   BIGINT,
+    token_id   BIGINT,
+    project_id BIGINT,
+    action_id  BIGINT,
+    operation  TEXT         NOT NULL,
+    diff       JSONB        NOT NULL,
+    ip_address TEXT         NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE INDEX audit_log_project_id ON audit_log (project_id, id);
+
+--bun:split
+
+CREATE INDEX audit_log_actor_id ON audit_log (actor_id, id);
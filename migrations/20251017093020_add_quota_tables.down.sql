@@ -0,0 +1,11 @@
+DROP TABLE invocation_usage;
+
+--bun:split
+
+ALTER TABLE action
+    DROP COLUMN module_size;
+
+--bun:split
+
+ALTER TABLE team
+    DROP COLUMN plan;
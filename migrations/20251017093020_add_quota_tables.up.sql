@@ -0,0 +1,19 @@
+ALTER TABLE team
+    ADD COLUMN plan TEXT NOT NULL DEFAULT '';
+
+--bun:split
+
+-- Sizes of previously uploaded modules are unknown, they are counted after the next deploy
+ALTER TABLE action
+    ADD COLUMN module_size BIGINT NOT NULL DEFAULT 0;
+
+--bun:split
+
+-- Usage has no foreign key, so it's kept after team is deleted
+CREATE TABLE invocation_usage
+(
+    team_id     BIGINT NOT NULL,
+    period      TEXT   NOT NULL,
+    invocations BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (team_id, period)
+);
@@ -0,0 +1,19 @@
+CREATE TABLE domain
+(
+    id          BIGINT PRIMARY KEY,
+    project_id  BIGINT       NOT NULL REFERENCES project (id) ON DELETE RESTRICT,
+    name        VARCHAR(253) NOT NULL,
+    token       TEXT         NOT NULL,
+    verified_at TIMESTAMP(0),
+    created_at  TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX domain_project_id_name ON domain (project_id, name);
+
+--bun:split
+
+-- The same domain can be added to many projects, but only one of them can verify it
+CREATE UNIQUE INDEX domain_name_verified ON domain (name) WHERE verified_at IS NOT NULL;
@@ -0,0 +1,12 @@
+ALTER TABLE domain
+    DROP COLUMN certificate_expires_at;
+
+--bun:split
+
+ALTER TABLE domain
+    DROP COLUMN certificate_key;
+
+--bun:split
+
+ALTER TABLE domain
+    DROP COLUMN certificate;
@@ -0,0 +1,13 @@
+ALTER TABLE domain
+    ADD COLUMN certificate TEXT NOT NULL DEFAULT '';
+
+--bun:split
+
+-- Private key is encrypted with `tls-encryption-key`
+ALTER TABLE domain
+    ADD COLUMN certificate_key TEXT NOT NULL DEFAULT '';
+
+--bun:split
+
+ALTER TABLE domain
+    ADD COLUMN certificate_expires_at TIMESTAMP(0);
@@ -0,0 +1,2 @@
+ALTER TABLE deploy
+    DROP COLUMN attempts;
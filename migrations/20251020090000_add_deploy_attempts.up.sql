@@ -0,0 +1,2 @@
+ALTER TABLE deploy
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+CREATE TABLE cache
+(
+    id         TEXT PRIMARY KEY,
+    value      TEXT      NOT NULL,
+    version    BIGINT    NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+--bun:split
+
+CREATE INDEX cache_expires_at ON cache (expires_at);
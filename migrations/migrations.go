@@ -0,0 +1,72 @@
+// Package migrations embeds SQL migrations. Migrations are portable and shared by every supported database, database
+// directory contains only migrations that can't be portable, they replace portable migrations with the same name.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// FS contains migrations, for example: 20250923193846_add_user_table.up.sql or
+// postgres/20250924194538_add_action_table.up.sql
+//
+//go:embed *.sql postgres/*.sql sqlite/*.sql
+var FS embed.FS //nolint:gochecknoglobals
+
+// Dialect returns migrations of the database with directory dir, portable migrations are replaced by migrations of the
+// database with the same name.
+func Dialect(dir string) fs.FS {
+	return &dialectFS{fsys: FS, dir: dir}
+}
+
+type dialectFS struct {
+	fsys fs.FS
+	dir  string
+}
+
+func (f *dialectFS) Open(name string) (fs.File, error) {
+	if name != "." && !strings.Contains(name, "/") {
+		file, err := f.fsys.Open(path.Join(f.dir, name))
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return f.fsys.Open(name)
+}
+
+// ReadDir lists only files, so directories of other databases are not visible.
+func (f *dialectFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries, err := fs.ReadDir(f.fsys, f.dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	portable, err := fs.ReadDir(f.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range portable {
+		if entry.IsDir() || slices.ContainsFunc(entries, func(e fs.DirEntry) bool {
+			return e.Name() == entry.Name()
+		}) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
@@ -0,0 +1,56 @@
+package migrations_test
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/mymmrac/lithium/migrations"
+)
+
+func TestDialect(t *testing.T) {
+	portable, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+
+	for _, dir := range []string{"postgres", "sqlite"} {
+		fsys := migrations.Dialect(dir)
+
+		entries, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			t.Fatalf("%s: read dir: %v", dir, err)
+		}
+
+		names := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			if entry.IsDir() || names[entry.Name()] {
+				t.Errorf("%s: unexpected entry: %s", dir, entry.Name())
+			}
+			names[entry.Name()] = true
+		}
+
+		overrides, err := fs.Glob(migrations.FS, dir+"/*.sql")
+		if err != nil {
+			t.Fatalf("%s: glob: %v", dir, err)
+		}
+		for _, override := range overrides {
+			name := strings.TrimPrefix(override, dir+"/")
+			if !names[name] {
+				t.Errorf("%s: missing migration: %s", dir, name)
+			}
+
+			expected, _ := fs.ReadFile(migrations.FS, override)
+			actual, err := fs.ReadFile(fsys, name)
+			if err != nil || string(actual) != string(expected) {
+				t.Errorf("%s: migration %s is not replaced: %v", dir, name, err)
+			}
+		}
+
+		for _, name := range portable {
+			if !names[name] {
+				t.Errorf("%s: missing portable migration: %s", dir, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+CREATE TABLE team
+(
+    id         BIGINT PRIMARY KEY,
+    name       TEXT         NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE TABLE team_member
+(
+    team_id    BIGINT       NOT NULL REFERENCES team (id) ON DELETE RESTRICT,
+    user_id    BIGINT       NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    role       VARCHAR(32)  NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, user_id)
+);
+
+--bun:split
+
+CREATE INDEX team_member_user_id ON team_member (user_id);
+
+--bun:split
+
+CREATE TABLE team_invitation
+(
+    id         BIGINT PRIMARY KEY,
+    team_id    BIGINT       NOT NULL REFERENCES team (id) ON DELETE RESTRICT,
+    email      TEXT         NOT NULL,
+    role       VARCHAR(32)  NOT NULL,
+    invited_by BIGINT       NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    expires_at TIMESTAMP(0) NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX team_invitation_team_id_email ON team_invitation (team_id, email);
+
+--bun:split
+
+CREATE INDEX team_invitation_email ON team_invitation (email);
+
+--bun:split
+
+-- Every project owner gets a personal team with the same ID, existing projects are moved there
+INSERT INTO team (id, name, created_at, updated_at)
+SELECT id, 'Personal', created_at, created_at
+FROM "user"
+WHERE id IN (SELECT owner_id FROM project);
+
+--bun:split
+
+INSERT INTO team_member (team_id, user_id, role, created_at)
+SELECT id, id, 'owner', created_at
+FROM team;
+
+--bun:split
+
+ALTER TABLE project
+    ADD COLUMN team_id BIGINT REFERENCES team (id) ON DELETE RESTRICT;
+
+--bun:split
+
+UPDATE project
+SET team_id = owner_id;
+
+--bun:split
+
+ALTER TABLE project
+    ALTER COLUMN team_id SET NOT NULL;
+
+--bun:split
+
+CREATE INDEX project_team_id ON project (team_id);
@@ -0,0 +1,17 @@
+CREATE TABLE action
+(
+    id          INTEGER PRIMARY KEY,
+    project_id  INTEGER   NOT NULL REFERENCES project (id) ON DELETE RESTRICT,
+    name        TEXT      NOT NULL,
+    path        TEXT      NOT NULL,
+    methods     TEXT      NOT NULL CHECK (json_valid(methods)),
+    "order"     INTEGER   NOT NULL,
+    module_path TEXT      NOT NULL,
+    config      TEXT      NOT NULL CHECK (json_valid(config)),
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE INDEX action_project_id ON action (project_id);
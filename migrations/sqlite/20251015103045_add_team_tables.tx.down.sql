@@ -0,0 +1,56 @@
+-- Project table is rebuilt without reference to team, see up migration
+PRAGMA defer_foreign_keys = ON;
+
+--bun:split
+
+CREATE TABLE project_old AS
+SELECT *
+FROM project;
+
+--bun:split
+
+DROP TABLE project;
+
+--bun:split
+
+CREATE TABLE project
+(
+    id         BIGINT PRIMARY KEY,
+    owner_id   BIGINT       NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    name       TEXT         NOT NULL,
+    sub_domain TEXT         NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    config     JSONB        NOT NULL DEFAULT '{}',
+    disabled   BOOLEAN      NOT NULL DEFAULT FALSE
+);
+
+--bun:split
+
+INSERT INTO project (id, owner_id, name, sub_domain, created_at, updated_at, config, disabled)
+SELECT id, owner_id, name, sub_domain, created_at, updated_at, config, disabled
+FROM project_old;
+
+--bun:split
+
+DROP TABLE project_old;
+
+--bun:split
+
+CREATE INDEX project_owner_id ON project (owner_id);
+
+--bun:split
+
+CREATE UNIQUE INDEX project_sub_domain ON project (sub_domain);
+
+--bun:split
+
+DROP TABLE team_invitation;
+
+--bun:split
+
+DROP TABLE team_member;
+
+--bun:split
+
+DROP TABLE team;
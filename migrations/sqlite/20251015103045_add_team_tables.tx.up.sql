@@ -0,0 +1,111 @@
+CREATE TABLE team
+(
+    id         INTEGER PRIMARY KEY,
+    name       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE TABLE team_member
+(
+    team_id    INTEGER   NOT NULL REFERENCES team (id) ON DELETE RESTRICT,
+    user_id    INTEGER   NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    role       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, user_id)
+);
+
+--bun:split
+
+CREATE INDEX team_member_user_id ON team_member (user_id);
+
+--bun:split
+
+CREATE TABLE team_invitation
+(
+    id         INTEGER PRIMARY KEY,
+    team_id    INTEGER   NOT NULL REFERENCES team (id) ON DELETE RESTRICT,
+    email      TEXT      NOT NULL,
+    role       TEXT      NOT NULL,
+    invited_by INTEGER   NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX team_invitation_team_id_email ON team_invitation (team_id, email);
+
+--bun:split
+
+CREATE INDEX team_invitation_email ON team_invitation (email);
+
+--bun:split
+
+-- Every project owner gets a personal team with the same ID, existing projects are moved there
+INSERT INTO team (id, name, created_at, updated_at)
+SELECT id, 'Personal', created_at, created_at
+FROM "user"
+WHERE id IN (SELECT owner_id FROM project);
+
+--bun:split
+
+INSERT INTO team_member (team_id, user_id, role, created_at)
+SELECT id, id, 'owner', created_at
+FROM team;
+
+--bun:split
+
+-- SQLite can't add reference to existing column, so project table is rebuilt. Dropping project removes rows referenced
+-- by other tables, deferred foreign keys are checked again when rows are copied back before commit.
+PRAGMA defer_foreign_keys = ON;
+
+--bun:split
+
+CREATE TABLE project_old AS
+SELECT *
+FROM project;
+
+--bun:split
+
+DROP TABLE project;
+
+--bun:split
+
+CREATE TABLE project
+(
+    id         BIGINT PRIMARY KEY,
+    owner_id   BIGINT       NOT NULL REFERENCES "user" (id) ON DELETE RESTRICT,
+    name       TEXT         NOT NULL,
+    sub_domain TEXT         NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    config     JSONB        NOT NULL DEFAULT '{}',
+    disabled   BOOLEAN      NOT NULL DEFAULT FALSE,
+    team_id    INTEGER      NOT NULL REFERENCES team (id) ON DELETE RESTRICT
+);
+
+--bun:split
+
+-- Every project is moved to personal team of its owner
+INSERT INTO project (id, owner_id, name, sub_domain, created_at, updated_at, config, disabled, team_id)
+SELECT id, owner_id, name, sub_domain, created_at, updated_at, config, disabled, owner_id
+FROM project_old;
+
+--bun:split
+
+DROP TABLE project_old;
+
+--bun:split
+
+CREATE INDEX project_owner_id ON project (owner_id);
+
+--bun:split
+
+CREATE UNIQUE INDEX project_sub_domain ON project (sub_domain);
+
+--bun:split
+
+CREATE INDEX project_team_id ON project (team_id);
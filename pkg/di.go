@@ -11,35 +11,93 @@ import (
 	"github.com/mymmrac/lithium/pkg/handler/invoker"
 	"github.com/mymmrac/lithium/pkg/handler/static"
 	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/audit"
 	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/certificate"
+	"github.com/mymmrac/lithium/pkg/module/deploy"
 	"github.com/mymmrac/lithium/pkg/module/di"
+	"github.com/mymmrac/lithium/pkg/module/domain"
+	"github.com/mymmrac/lithium/pkg/module/identity"
+	"github.com/mymmrac/lithium/pkg/module/mail"
+	"github.com/mymmrac/lithium/pkg/module/oidc"
 	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/quota"
+	"github.com/mymmrac/lithium/pkg/module/server"
+	"github.com/mymmrac/lithium/pkg/module/session"
 	"github.com/mymmrac/lithium/pkg/module/storage"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/throttle"
+	"github.com/mymmrac/lithium/pkg/module/token"
+	"github.com/mymmrac/lithium/pkg/module/twofactor"
 	"github.com/mymmrac/lithium/pkg/module/user"
+	"github.com/mymmrac/lithium/pkg/module/verification"
 	"github.com/mymmrac/lithium/pkg/module/version"
 )
 
 func DI(ctx context.Context, v *viper.Viper) rdi.DI {
 	return di.New(ctx, v).
-		MustProvide(func(v *validator.Validate, views static.Views, auth auth.Auth, invoker invoker.Invoker) *fiber.App {
-			app := fiber.New(fiber.Config{
-				AppName:         version.Name(),
-				Views:           views,
-				StructValidator: &FiberValidatorAdapter{v: v},
-				BodyLimit:       64 * 1024 * 1024,
-			})
-			app.Use(invoker.Middleware)
+		MustProvide(func(
+			cfg server.Config, v *validator.Validate, views static.Views, auth auth.Auth, invoker invoker.Invoker,
+		) *fiber.App {
+			fiberCfg := cfg.Management.FiberConfig()
+			fiberCfg.AppName = version.Name()
+			fiberCfg.Views = views
+			fiberCfg.StructValidator = &FiberValidatorAdapter{v: v}
+
+			app := fiber.New(fiberCfg)
+			if !cfg.Invocation.Enabled() {
+				app.Use(invoker.Middleware)
+			}
 			app.Use(auth.Middleware)
 			return app
 		}).
+		MustProvide(func(cfg server.Config, invoker invoker.Invoker) server.InvocationApp {
+			if !cfg.Invocation.Enabled() {
+				return server.InvocationApp{}
+			}
+
+			fiberCfg := cfg.Invocation.FiberConfig()
+			fiberCfg.AppName = version.Name()
+
+			app := fiber.New(fiberCfg)
+			app.Use(invoker.Middleware)
+			// Dashboard and management API are not served on invocation listener
+			app.Use(func(fiber.Ctx) error {
+				return fiber.ErrNotFound
+			})
+			return server.InvocationApp{App: app}
+		}).
 		MustProvide(static.LoadViews).
 		MustProvide(auth.NewAuth).
 		MustProvide(invoker.NewInvoker).
 		MustProvide(storage.NewStorage).
 		MustProvide(user.NewRepository).
 		MustProvide(project.NewRepository).
+		MustProvide(team.NewRepository).
+		MustProvide(authz.NewAuthz).
+		MustProvide(audit.NewRepository).
+		MustProvide(audit.NewLog).
 		MustProvide(action.NewRepository).
-		MustProvide(action.NewCache)
+		MustProvide(action.NewCache).
+		MustProvide(deploy.NewRepository).
+		MustProvide(token.NewRepository).
+		MustProvide(session.NewRepository).
+		MustProvide(verification.NewRepository).
+		MustProvide(mail.NewMailer).
+		MustProvide(identity.NewRepository).
+		MustProvide(oidc.NewProviders).
+		MustProvide(twofactor.NewRepository).
+		MustProvide(throttle.NewCache).
+		MustProvide(deploy.NewWorker).
+		MustProvide(deploy.NewProjectDeleter).
+		MustProvide(quota.NewRepository).
+		MustProvide(quota.NewQuota).
+		MustProvide(domain.NewRepository).
+		MustProvide(domain.NewResolver).
+		MustProvide(domain.NewVerifier).
+		MustProvide(certificate.NewCipher).
+		MustProvide(certificate.NewStore)
 }
 
 type FiberValidatorAdapter struct {
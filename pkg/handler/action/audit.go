@@ -0,0 +1,55 @@
+package action
+
+import (
+	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	"github.com/mymmrac/lithium/pkg/module/cors"
+)
+
+// actionState is recorded in audit log, values of secrets are redacted.
+type actionState struct {
+	Name          string            `json:"name"`
+	Path          string            `json:"path"`
+	Methods       []string          `json:"methods"`
+	Envs          map[string]string `json:"envs,omitempty"`
+	Secrets       map[string]string `json:"secrets,omitempty"`
+	Args          []string          `json:"args,omitempty"`
+	Network       bool              `json:"network,omitempty"`
+	MaxModuleSize int64             `json:"maxModuleSize,omitempty"`
+	CORS          *cors.Policy      `json:"cors,omitempty"`
+}
+
+// auditStates returns states of the action before and after operation, any of models can be nil.
+func auditStates(before, after *action.Model) (any, any) {
+	var beforeSecrets, afterSecrets map[string]string
+	if before != nil {
+		beforeSecrets = before.Config.Secrets
+	}
+	if after != nil {
+		afterSecrets = after.Config.Secrets
+	}
+	beforeSecrets, afterSecrets = audit.RedactSecrets(beforeSecrets, afterSecrets)
+
+	var beforeState, afterState any
+	if before != nil {
+		beforeState = newActionState(before, beforeSecrets)
+	}
+	if after != nil {
+		afterState = newActionState(after, afterSecrets)
+	}
+	return beforeState, afterState
+}
+
+func newActionState(model *action.Model, secrets map[string]string) actionState {
+	return actionState{
+		Name:          model.Name,
+		Path:          model.Path,
+		Methods:       model.Methods,
+		Envs:          model.Config.Envs,
+		Secrets:       secrets,
+		Args:          model.Config.Args,
+		Network:       model.Config.Network,
+		MaxModuleSize: model.Config.MaxModuleSize,
+		CORS:          model.Config.CORS,
+	}
+}
@@ -0,0 +1,220 @@
+package action
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/deploy"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+)
+
+const (
+	deploysLimit         = 20
+	deployEventsInterval = 500 * time.Millisecond
+	deployEventsTimeout  = 10 * time.Minute
+	// Keepalive comments detect disconnected clients, write to closed connection fails
+	deployEventsKeepalive = 15 * time.Second
+)
+
+type deployInfo struct {
+	ID        id.ID         `json:"id"`
+	Status    deploy.Status `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+func newDeployInfo(model *deploy.Model) deployInfo {
+	return deployInfo{
+		ID:        model.ID,
+		Status:    model.Status,
+		Error:     model.Error,
+		CreatedAt: model.CreatedAt,
+		UpdatedAt: model.UpdatedAt,
+	}
+}
+
+func (h *handler) getDeploysHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ProjectID id.ID `uri:"projectID" validate:"required"`
+		ID        id.ID `uri:"actionID"  validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "get deploys, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionProjectRead,
+	)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	model, found, err := h.actionRepository.GetByID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get action", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found || model.ProjectID != request.ProjectID {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	deploys, err := h.deployRepository.GetByActionID(fCtx, request.ID, deploysLimit)
+	if err != nil {
+		logger.Errorw(fCtx, "get deploys", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	response := make([]deployInfo, len(deploys))
+	for i := range deploys {
+		response[i] = newDeployInfo(&deploys[i])
+	}
+
+	return fCtx.JSON(response)
+}
+
+func (h *handler) getDeployHandler(fCtx fiber.Ctx) error {
+	deployModel, err := h.findDeploy(fCtx)
+	if err != nil {
+		return err
+	}
+
+	return fCtx.JSON(newDeployInfo(deployModel))
+}
+
+func (h *handler) deployEventsHandler(fCtx fiber.Ctx) error {
+	deployModel, err := h.findDeploy(fCtx)
+	if err != nil {
+		return err
+	}
+
+	// Request context can't be used after handler returns, stream writer runs after that
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(fCtx.Context()), deployEventsTimeout)
+
+	fCtx.Set(fiber.HeaderContentType, "text/event-stream")
+	fCtx.Set(fiber.HeaderCacheControl, "no-cache")
+	fCtx.Set(fiber.HeaderConnection, "keep-alive")
+
+	return fCtx.SendStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		ticker := time.NewTicker(deployEventsInterval)
+		defer ticker.Stop()
+
+		keepalive := time.NewTicker(deployEventsKeepalive)
+		defer keepalive.Stop()
+
+		var lastStatus deploy.Status
+		for {
+			if deployModel.Status != lastStatus {
+				if err = writeDeployEvent(w, deployModel); err != nil {
+					return
+				}
+				lastStatus = deployModel.Status
+			}
+
+			if deployModel.Status.Finished() {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-h.stopping:
+				return
+			case <-keepalive.C:
+				if err = writeKeepalive(w); err != nil {
+					return
+				}
+				continue
+			case <-ticker.C:
+			}
+
+			var found bool
+			deployModel, found, err = h.deployRepository.GetByID(ctx, deployModel.ID)
+			if err != nil || !found {
+				logger.Warnw(ctx, "get deploy", "found", found, "error", err)
+				return
+			}
+		}
+	})
+}
+
+func writeDeployEvent(w *bufio.Writer, deployModel *deploy.Model) error {
+	data, err := json.Marshal(newDeployInfo(deployModel))
+	if err != nil {
+		return fmt.Errorf("marshal deploy: %w", err)
+	}
+
+	if _, err = fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+		return fmt.Errorf("write event: %w", err)
+	}
+
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("flush event: %w", err)
+	}
+
+	return nil
+}
+
+func writeKeepalive(w *bufio.Writer) error {
+	if _, err := w.WriteString(":keepalive\n\n"); err != nil {
+		return fmt.Errorf("write keepalive: %w", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("flush keepalive: %w", err)
+	}
+
+	return nil
+}
+
+func (h *handler) findDeploy(fCtx fiber.Ctx) (*deploy.Model, error) {
+	var request struct {
+		ProjectID id.ID `uri:"projectID" validate:"required"`
+		ActionID  id.ID `uri:"actionID"  validate:"required"`
+		ID        id.ID `uri:"deployID"  validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "get deploy, bad request", "error", err)
+		return nil, fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionProjectRead,
+	)
+	if err != nil {
+		return nil, authz.Error(fCtx, err)
+	}
+
+	actionModel, found, err := h.actionRepository.GetByID(fCtx, request.ActionID)
+	if err != nil {
+		logger.Errorw(fCtx, "get action", "error", err)
+		return nil, fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found || actionModel.ProjectID != request.ProjectID {
+		return nil, fiber.NewError(fiber.StatusNotFound)
+	}
+
+	deployModel, found, err := h.deployRepository.GetByID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get deploy", "error", err)
+		return nil, fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found || deployModel.ActionID != request.ActionID {
+		return nil, fiber.NewError(fiber.StatusNotFound)
+	}
+
+	return deployModel, nil
+}
@@ -1,56 +1,79 @@
 package action
 
 import (
-	"io"
+	"context"
+	"errors"
+	"fmt"
 	"path"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
-	"github.com/mymmrac/wape"
 
 	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/audit"
 	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/cors"
 	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/deploy"
 	"github.com/mymmrac/lithium/pkg/module/id"
 	"github.com/mymmrac/lithium/pkg/module/logger"
-	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/quota"
 	"github.com/mymmrac/lithium/pkg/module/storage"
+	"github.com/mymmrac/lithium/pkg/module/token"
+	"github.com/mymmrac/lithium/pkg/module/wasm"
 )
 
 type handler struct {
-	cfg               Config
-	tx                db.Transaction
-	actionCache       action.Cache
-	actionRepository  action.Repository
-	projectRepository project.Repository
-	storage           storage.Storage
+	cfg              Config
+	tx               db.Transaction
+	actionCache      action.Cache
+	actionRepository action.Repository
+	authz            authz.Authz
+	storage          storage.Storage
+	deployRepository deploy.Repository
+	deployWorker     deploy.Worker
+	auditLog         audit.Log
+	quota            quota.Quota
+
+	// stopping is closed on shutdown, so streams don't keep server from stopping
+	stopping <-chan struct{}
 }
 
 func RegisterHandlers(
-	cfg Config, router fiber.Router, tx db.Transaction, actionCache action.Cache, actionRepository action.Repository,
-	projectRepository project.Repository, storage storage.Storage,
+	ctx context.Context, cfg Config, router fiber.Router, tx db.Transaction, actionCache action.Cache,
+	actionRepository action.Repository, authz authz.Authz, storage storage.Storage, deployRepository deploy.Repository,
+	deployWorker deploy.Worker, auditLog audit.Log, quota quota.Quota,
 ) {
 	h := &handler{
-		cfg:               cfg,
-		tx:                tx,
-		actionCache:       actionCache,
-		actionRepository:  actionRepository,
-		projectRepository: projectRepository,
-		storage:           storage,
+		cfg:              cfg,
+		tx:               tx,
+		actionCache:      actionCache,
+		actionRepository: actionRepository,
+		authz:            authz,
+		storage:          storage,
+		deployRepository: deployRepository,
+		deployWorker:     deployWorker,
+		auditLog:         auditLog,
+		quota:            quota,
+		stopping:         ctx.Done(),
 	}
 
 	api := router.Group("/api/project/:projectID/action", auth.RequireMiddleware)
 
-	api.Get("/", h.getAllHandler)
-	api.Post("/", h.createHandler)
-	api.Post("/order", h.updateActionOrderHandler)
-	api.Get("/:actionID", h.getHandler)
-	api.Put("/:actionID", h.updateHandler)
-	api.Put("/:actionID/upload", h.uploadHandler)
-	api.Put("/:actionID/config", h.updateConfigHandler)
-	api.Delete("/:actionID", h.deleteHandler)
+	api.Get("/", auth.RequireScope(token.ScopeRead), h.getAllHandler)
+	api.Post("/", auth.RequireScope(token.ScopeAdmin), h.createHandler)
+	api.Post("/order", auth.RequireScope(token.ScopeAdmin), h.updateActionOrderHandler)
+	api.Get("/:actionID", auth.RequireScope(token.ScopeRead), h.getHandler)
+	api.Put("/:actionID", auth.RequireScope(token.ScopeAdmin), h.updateHandler)
+	api.Put("/:actionID/upload", auth.RequireScope(token.ScopeDeploy), h.uploadHandler)
+	api.Get("/:actionID/deploy", auth.RequireScope(token.ScopeRead), h.getDeploysHandler)
+	api.Get("/:actionID/deploy/:deployID", auth.RequireScope(token.ScopeRead), h.getDeployHandler)
+	api.Get("/:actionID/deploy/:deployID/events", auth.RequireScope(token.ScopeRead), h.deployEventsHandler)
+	api.Put("/:actionID/config", auth.RequireScope(token.ScopeAdmin), h.updateConfigHandler)
+	api.Delete("/:actionID", auth.RequireScope(token.ScopeAdmin), h.deleteHandler)
 }
 
 func (h *handler) getAllHandler(fCtx fiber.Ctx) error {
@@ -63,13 +86,11 @@ func (h *handler) getAllHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
-	projectModel, found, err := h.projectRepository.GetByID(fCtx, request.ProjectID)
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionProjectRead,
+	)
 	if err != nil {
-		logger.Errorw(fCtx, "get project", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
-	if !found || projectModel.OwnerID != auth.MustUserFromContext(fCtx).ID {
-		return fiber.NewError(fiber.StatusNotFound)
+		return authz.Error(fCtx, err)
 	}
 
 	models, err := h.actionRepository.GetByProjectID(fCtx, request.ProjectID)
@@ -109,13 +130,11 @@ func (h *handler) getHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
-	projectModel, found, err := h.projectRepository.GetByID(fCtx, request.ProjectID)
+	projectModel, member, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionProjectRead,
+	)
 	if err != nil {
-		logger.Errorw(fCtx, "get project", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
-	if !found || projectModel.OwnerID != auth.MustUserFromContext(fCtx).ID {
-		return fiber.NewError(fiber.StatusNotFound)
+		return authz.Error(fCtx, err)
 	}
 
 	model, found, err := h.actionRepository.GetByID(fCtx, request.ID)
@@ -128,18 +147,40 @@ func (h *handler) getHandler(fCtx fiber.Ctx) error {
 	}
 
 	type actionConfig struct {
-		Envs    map[string]string `json:"envs,omitempty"`
-		Args    []string          `json:"args,omitempty"`
-		Network bool              `json:"network,omitempty"`
+		Envs          map[string]string `json:"envs,omitempty"`
+		Secrets       map[string]string `json:"secrets,omitempty"`
+		Args          []string          `json:"args,omitempty"`
+		Network       bool              `json:"network,omitempty"`
+		MaxModuleSize int64             `json:"maxModuleSize,omitempty"`
+		CORS          *cors.Policy      `json:"cors,omitempty"`
 	}
 
 	type actionInfo struct {
-		ID             id.ID        `json:"id"`
-		Name           string       `json:"name"`
-		Path           string       `json:"path"`
-		Methods        []string     `json:"methods"`
-		ModuleUploaded bool         `json:"moduleUploaded"`
-		Config         actionConfig `json:"config"`
+		ID             id.ID                 `json:"id"`
+		Name           string                `json:"name"`
+		Path           string                `json:"path"`
+		Methods        []string              `json:"methods"`
+		ModuleUploaded bool                  `json:"moduleUploaded"`
+		ModuleReport   *wasm.Report          `json:"moduleReport,omitempty"`
+		Config         actionConfig          `json:"config"`
+		EffectiveEnvs  map[string]action.Env `json:"effectiveEnvs"`
+		EffectiveCORS  *cors.Policy          `json:"effectiveCors,omitempty"`
+	}
+
+	secrets := model.Config.Secrets
+	effectiveEnvs := action.EffectiveEnvs(projectModel.Config, model.Config)
+	if !authz.Allows(member.Role, authz.PermissionSecretRead) {
+		// Only names of secrets are shown to members who can't read them
+		secrets = make(map[string]string, len(model.Config.Secrets))
+		for key := range model.Config.Secrets {
+			secrets[key] = ""
+		}
+		for key, env := range effectiveEnvs {
+			if env.Secret {
+				env.Value = ""
+				effectiveEnvs[key] = env
+			}
+		}
 	}
 
 	return fCtx.JSON(&actionInfo{
@@ -148,11 +189,17 @@ func (h *handler) getHandler(fCtx fiber.Ctx) error {
 		Path:           model.Path,
 		Methods:        model.Methods,
 		ModuleUploaded: model.ModulePath != "",
+		ModuleReport:   model.ModuleReport,
 		Config: actionConfig{
-			Envs:    model.Config.Envs,
-			Args:    model.Config.Args,
-			Network: model.Config.Network,
+			Envs:          model.Config.Envs,
+			Secrets:       secrets,
+			Args:          model.Config.Args,
+			Network:       model.Config.Network,
+			MaxModuleSize: model.Config.MaxModuleSize,
+			CORS:          model.Config.CORS,
 		},
+		EffectiveEnvs: effectiveEnvs,
+		EffectiveCORS: action.EffectiveCORS(projectModel.Config, model.Config),
 	})
 }
 
@@ -161,48 +208,80 @@ func (h *handler) createHandler(fCtx fiber.Ctx) error {
 		ProjectID id.ID    `uri:"projectID" validate:"required"`
 		Name      string   `json:"name"     validate:"alphanum_text,min=1,max=64"`
 		Path      string   `json:"path"     validate:"uri"`
-		Methods   []string `json:"methods"  validate:"gt=0,unique,dive,oneof=GET POST PUT PATCH DELETE"`
+		Methods   []string `json:"methods"  validate:"gt=0,unique,dive,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS CONNECT TRACE ANY"`
 	}
 
 	if err := fCtx.Bind().All(&request); err != nil {
 		logger.Warnw(fCtx, "create action, bad request", "error", err)
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
+	if len(request.Methods) > 1 && slices.Contains(request.Methods, action.MethodAny) {
+		return fiber.NewError(fiber.StatusBadRequest, "Method ANY can't be combined with other methods")
+	}
+
+	projectModel, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionActionWrite,
+	)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
 
-	projectModel, found, err := h.projectRepository.GetByID(fCtx, request.ProjectID)
+	ctx, err := h.tx.Begin(fCtx)
 	if err != nil {
-		logger.Errorw(fCtx, "get project", "error", err)
+		logger.Errorw(fCtx, "begin transaction", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
-	if !found || projectModel.OwnerID != auth.MustUserFromContext(fCtx).ID {
-		return fiber.NewError(fiber.StatusNotFound)
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	if err = h.quota.CheckActions(ctx, projectModel.TeamID, request.ProjectID); err != nil {
+		return quota.Error(fCtx, err)
 	}
 
-	count, err := h.actionRepository.CountByProjectID(fCtx, request.ProjectID)
+	models, err := h.actionRepository.GetByProjectID(ctx, request.ProjectID)
 	if err != nil {
-		logger.Errorw(fCtx, "get actions count", "error", err)
+		logger.Errorw(fCtx, "get actions", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
 	request.Name = strings.TrimSpace(request.Name)
 
 	now := time.Now()
-	err = h.actionRepository.Create(fCtx, &action.Model{
+	model := &action.Model{
 		ID:         id.New(),
 		ProjectID:  request.ProjectID,
 		Name:       request.Name,
 		Path:       request.Path,
 		Methods:    request.Methods,
-		Order:      count,
+		Order:      len(models),
 		ModulePath: "",
 		CreatedAt:  now,
 		UpdatedAt:  now,
-	})
-	if err != nil {
+	}
+
+	// New action is the last one, so it can only be shadowed by existing actions
+	if err = action.CheckConflict(append(models, *model), len(models)); err != nil {
+		return conflictError(fCtx, err)
+	}
+
+	if err = h.actionRepository.Create(ctx, model); err != nil {
 		logger.Errorw(fCtx, "create action", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
+	if err = h.tx.Commit(ctx); err != nil {
+		logger.Errorw(fCtx, "commit transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	before, after := auditStates(nil, model)
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationActionCreate,
+		ProjectID: model.ProjectID,
+		ActionID:  model.ID,
+		Before:    before,
+		After:     after,
+	})
+
 	return fCtx.JSON(fiber.Map{"ok": true})
 }
 
@@ -212,21 +291,22 @@ func (h *handler) updateHandler(fCtx fiber.Ctx) error {
 		ID        id.ID    `uri:"actionID"  validate:"required"`
 		Name      string   `json:"name"     validate:"alphanum_text,min=1,max=64"`
 		Path      string   `json:"path"     validate:"uri"`
-		Methods   []string `json:"methods"  validate:"gt=0,unique,dive,oneof=GET POST PUT PATCH DELETE"`
+		Methods   []string `json:"methods"  validate:"gt=0,unique,dive,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS CONNECT TRACE ANY"`
 	}
 
 	if err := fCtx.Bind().All(&request); err != nil {
 		logger.Warnw(fCtx, "update action, bad request", "error", err)
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
+	if len(request.Methods) > 1 && slices.Contains(request.Methods, action.MethodAny) {
+		return fiber.NewError(fiber.StatusBadRequest, "Method ANY can't be combined with other methods")
+	}
 
-	projectModel, found, err := h.projectRepository.GetByID(fCtx, request.ProjectID)
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionActionWrite,
+	)
 	if err != nil {
-		logger.Errorw(fCtx, "get project", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
-	if !found || projectModel.OwnerID != auth.MustUserFromContext(fCtx).ID {
-		return fiber.NewError(fiber.StatusNotFound)
+		return authz.Error(fCtx, err)
 	}
 
 	model, found, err := h.actionRepository.GetByID(fCtx, request.ID)
@@ -240,15 +320,45 @@ func (h *handler) updateHandler(fCtx fiber.Ctx) error {
 
 	request.Name = strings.TrimSpace(request.Name)
 
+	updated := *model
+	updated.Name, updated.Path, updated.Methods = request.Name, request.Path, request.Methods
+
+	models, err := h.actionRepository.GetByProjectID(fCtx, request.ProjectID)
+	if err != nil {
+		logger.Errorw(fCtx, "get actions", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	index := slices.IndexFunc(models, func(model action.Model) bool {
+		return model.ID == updated.ID
+	})
+	if index == -1 {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+	models[index] = updated
+	if err = action.CheckConflict(models, index); err != nil {
+		return conflictError(fCtx, err)
+	}
+
 	err = h.actionRepository.UpdateInfo(fCtx, request.ID, request.Name, request.Path, request.Methods)
 	if err != nil {
 		logger.Errorw(fCtx, "update action", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
+	before, after := auditStates(model, &updated)
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationActionUpdate,
+		ProjectID: model.ProjectID,
+		ActionID:  model.ID,
+		Before:    before,
+		After:     after,
+	})
+
 	return fCtx.JSON(fiber.Map{"ok": true})
 }
 
+// uploadHandler stores module of a new deploy. Request body is not streamed, so whole upload is buffered in memory up
+// to body limit of management listener before module size is checked.
 func (h *handler) uploadHandler(fCtx fiber.Ctx) error {
 	var request struct {
 		ProjectID id.ID `uri:"projectID" validate:"required"`
@@ -266,27 +376,11 @@ func (h *handler) uploadHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
-	moduleFile, err := moduleFileHeader.Open()
-	if err != nil {
-		logger.Warnw(fCtx, "upload action, bad request (open file)", "error", err)
-		return fiber.NewError(fiber.StatusBadRequest)
-	}
-	defer func() { _ = moduleFile.Close() }()
-
-	moduleData, err := io.ReadAll(moduleFile)
-	if err != nil {
-		logger.Errorw(fCtx, "upload action, read module file", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
-
-	projectModel, found, err := h.projectRepository.GetByID(fCtx, request.ProjectID)
+	projectModel, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionActionDeploy,
+	)
 	if err != nil {
-		logger.Errorw(fCtx, "get project", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
-	userID := auth.MustUserFromContext(fCtx).ID
-	if !found || projectModel.OwnerID != userID {
-		return fiber.NewError(fiber.StatusNotFound)
+		return authz.Error(fCtx, err)
 	}
 
 	model, found, err := h.actionRepository.GetByID(fCtx, request.ID)
@@ -298,79 +392,98 @@ func (h *handler) uploadHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound)
 	}
 
-	env := wape.NewEnvironment()
-	env.Modules = []wape.ModuleData{
-		{
-			Name: "main",
-			Data: moduleData,
-		},
-	}
-
-	env.NetworkEnabled = model.Config.Network
-
-	module, err := wape.NewPlugin(fCtx, env)
-	if err != nil {
-		logger.Warnw(fCtx, "instantiate module", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
+	sizeLimit := model.Config.ModuleSizeLimit(h.cfg.MaxModuleSize)
+	if moduleFileHeader.Size > sizeLimit {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("Module exceeds %d bytes", sizeLimit))
 	}
 
-	if !module.FunctionExists("handler") {
-		logger.Warnw(fCtx, "handler function doesn't exist")
-		return fiber.NewError(fiber.StatusBadRequest, "Handler function is missing")
+	if err = h.quota.CheckModule(fCtx, projectModel.TeamID, model, moduleFileHeader.Size); err != nil {
+		return quota.Error(fCtx, err)
 	}
 
-	ctx, err := h.tx.Begin(fCtx)
+	moduleFile, err := moduleFileHeader.Open()
 	if err != nil {
-		logger.Errorw(fCtx, "begin transaction", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
+		logger.Warnw(fCtx, "upload action, bad request (open file)", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
 	}
-	defer func() { _ = h.tx.Rollback(ctx) }()
+	defer func() { _ = moduleFile.Close() }()
 
-	modulePath := path.Join(userID.String(), request.ProjectID.String(), request.ID.String()+".wasm")
-	if err = h.actionRepository.UpdateModulePath(ctx, request.ID, modulePath); err != nil {
-		logger.Errorw(fCtx, "update action module path", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
+	deployID := id.New()
+	modulePath := path.Join(
+		projectModel.TeamID.String(), request.ProjectID.String(), request.ID.String(), deployID.String()+".wasm",
+	)
+	err = h.storage.Upload(fCtx, h.cfg.ModuleBucket, modulePath,
+		storage.LimitReader(moduleFile, sizeLimit), moduleFileHeader.Size, "application/wasm",
+	)
+	if err != nil {
+		if errors.Is(err, storage.ErrTooLarge) {
+			return fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("Module exceeds %d bytes", sizeLimit))
+		}
 
-	if err = h.storage.Upload(ctx, h.cfg.ModuleBucket, modulePath, moduleData); err != nil {
 		logger.Errorw(fCtx, "upload action module", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
-	if err = h.tx.Commit(ctx); err != nil {
-		logger.Errorw(fCtx, "commit transaction", "error", err)
+	now := time.Now()
+	err = h.deployRepository.Create(fCtx, &deploy.Model{
+		ID:         deployID,
+		ActionID:   model.ID,
+		Status:     deploy.StatusPending,
+		Error:      "",
+		ModulePath: modulePath,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		logger.Errorw(fCtx, "create deploy", "error", err)
+		if err = h.storage.Delete(fCtx, h.cfg.ModuleBucket, modulePath); err != nil {
+			logger.Warnw(fCtx, "delete action module", "path", modulePath, "error", err)
+		}
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
-	if err = h.actionCache.Remove(fCtx, request.ID); err != nil {
-		logger.Errorw(fCtx, "remove action from cache", "id", request.ID, "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
+	h.deployWorker.Enqueue(fCtx, deployID)
 
-	return fCtx.JSON(fiber.Map{"ok": true})
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationActionUpload,
+		ProjectID: model.ProjectID,
+		ActionID:  model.ID,
+		After: fiber.Map{
+			"deployId":   deployID,
+			"moduleSize": moduleFileHeader.Size,
+		},
+	})
+
+	return fCtx.Status(fiber.StatusAccepted).JSON(fiber.Map{"ok": true, "deployId": deployID})
 }
 
 func (h *handler) updateConfigHandler(fCtx fiber.Ctx) error {
 	var request struct {
-		ProjectID id.ID             `uri:"projectID" validate:"required"`
-		ID        id.ID             `uri:"actionID"  validate:"required"`
-		Envs      map[string]string `json:"envs"     validate:"-"`
-		Args      []string          `json:"args"     validate:"-"`
-		Network   bool              `json:"network"  validate:"-"`
+		ProjectID     id.ID             `uri:"projectID"     validate:"required"`
+		ID            id.ID             `uri:"actionID"      validate:"required"`
+		Envs          map[string]string `json:"envs"          validate:"-"`
+		Secrets       map[string]string `json:"secrets"       validate:"-"`
+		Args          []string          `json:"args"          validate:"-"`
+		Network       bool              `json:"network"       validate:"-"`
+		MaxModuleSize int64             `json:"maxModuleSize" validate:"min=0"`
+		CORS          cors.Update       `json:"cors"`
 	}
 
 	if err := fCtx.Bind().All(&request); err != nil {
 		logger.Warnw(fCtx, "upload action, bad request", "error", err)
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
+	if request.CORS.Policy != nil {
+		if err := request.CORS.Policy.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid CORS policy: "+err.Error())
+		}
+	}
 
-	projectModel, found, err := h.projectRepository.GetByID(fCtx, request.ProjectID)
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionActionWrite,
+	)
 	if err != nil {
-		logger.Errorw(fCtx, "get project", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
-	if !found || projectModel.OwnerID != auth.MustUserFromContext(fCtx).ID {
-		return fiber.NewError(fiber.StatusNotFound)
+		return authz.Error(fCtx, err)
 	}
 
 	model, found, err := h.actionRepository.GetByID(fCtx, request.ID)
@@ -382,10 +495,17 @@ func (h *handler) updateConfigHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusNotFound)
 	}
 
+	if !request.Network && model.ModuleReport != nil && model.ModuleReport.RequiresNetwork() {
+		return fiber.NewError(fiber.StatusBadRequest, "Uploaded module requires network access")
+	}
+
 	config := action.ModuleConfig{
-		Envs:    request.Envs,
-		Args:    request.Args,
-		Network: request.Network,
+		Envs:          request.Envs,
+		Secrets:       request.Secrets,
+		Args:          request.Args,
+		Network:       request.Network,
+		MaxModuleSize: request.MaxModuleSize,
+		CORS:          request.CORS.Apply(model.Config.CORS),
 	}
 
 	if err = h.actionRepository.UpdateConfig(fCtx, request.ID, config); err != nil {
@@ -398,13 +518,24 @@ func (h *handler) updateConfigHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
+	updated := *model
+	updated.Config = config
+	before, after := auditStates(model, &updated)
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationActionConfig,
+		ProjectID: model.ProjectID,
+		ActionID:  model.ID,
+		Before:    before,
+		After:     after,
+	})
+
 	return fCtx.JSON(fiber.Map{"ok": true})
 }
 
 func (h *handler) updateActionOrderHandler(fCtx fiber.Ctx) error {
 	var request struct {
 		ProjectID id.ID   `uri:"projectID" validate:"required"`
-		IDs       []id.ID `json:"ids"      validate:"gt=0,dive,required"`
+		IDs       []id.ID `json:"ids"      validate:"gt=0,unique,dive,required"`
 	}
 
 	if err := fCtx.Bind().All(&request); err != nil {
@@ -412,13 +543,11 @@ func (h *handler) updateActionOrderHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
-	projectModel, found, err := h.projectRepository.GetByID(fCtx, request.ProjectID)
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionActionWrite,
+	)
 	if err != nil {
-		logger.Errorw(fCtx, "get project", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
-	if !found || projectModel.OwnerID != auth.MustUserFromContext(fCtx).ID {
-		return fiber.NewError(fiber.StatusNotFound)
+		return authz.Error(fCtx, err)
 	}
 
 	models, err := h.actionRepository.GetByProjectID(fCtx, request.ProjectID)
@@ -433,13 +562,20 @@ func (h *handler) updateActionOrderHandler(fCtx fiber.Ctx) error {
 		)
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
-	for _, modelID := range request.IDs {
-		if !slices.ContainsFunc(models, func(model action.Model) bool {
+	ordered := make([]action.Model, len(request.IDs))
+	for i, modelID := range request.IDs {
+		index := slices.IndexFunc(models, func(model action.Model) bool {
 			return model.ID == modelID
-		}) {
+		})
+		if index == -1 {
 			logger.Warnw(fCtx, "update action order, unexpected action", "id", modelID)
 			return fiber.NewError(fiber.StatusBadRequest)
 		}
+		ordered[i] = models[index]
+	}
+
+	if err = action.CheckConflicts(ordered); err != nil {
+		return conflictError(fCtx, err)
 	}
 
 	err = h.actionRepository.UpdateOrder(fCtx, request.IDs)
@@ -448,6 +584,17 @@ func (h *handler) updateActionOrderHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
+	previousIDs := make([]id.ID, len(models))
+	for i, model := range models {
+		previousIDs[i] = model.ID
+	}
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationActionOrder,
+		ProjectID: request.ProjectID,
+		Before:    fiber.Map{"order": previousIDs},
+		After:     fiber.Map{"order": request.IDs},
+	})
+
 	return fCtx.JSON(fiber.Map{"ok": true})
 }
 
@@ -462,13 +609,11 @@ func (h *handler) deleteHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
-	projectModel, found, err := h.projectRepository.GetByID(fCtx, request.ProjectID)
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionActionWrite,
+	)
 	if err != nil {
-		logger.Errorw(fCtx, "get project", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
-	if !found || projectModel.OwnerID != auth.MustUserFromContext(fCtx).ID {
-		return fiber.NewError(fiber.StatusNotFound)
+		return authz.Error(fCtx, err)
 	}
 
 	model, found, err := h.actionRepository.GetByID(fCtx, request.ID)
@@ -487,6 +632,12 @@ func (h *handler) deleteHandler(fCtx fiber.Ctx) error {
 	}
 	defer func() { _ = h.tx.Rollback(ctx) }()
 
+	_, err = deploy.DeleteWithModules(ctx, h.deployRepository, h.storage, h.cfg.ModuleBucket, model.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "delete action deploys", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
 	if err = h.actionRepository.DeleteByID(ctx, model.ID); err != nil {
 		logger.Errorw(fCtx, "delete action", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
@@ -509,5 +660,28 @@ func (h *handler) deleteHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
+	before, after := auditStates(model, nil)
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationActionDelete,
+		ProjectID: model.ProjectID,
+		ActionID:  model.ID,
+		Before:    before,
+		After:     after,
+	})
+
 	return fCtx.JSON(fiber.Map{"ok": true})
 }
+
+// conflictError returns error response for route conflict between actions.
+func conflictError(fCtx fiber.Ctx, err error) error {
+	var conflictErr *action.ConflictError
+	if !errors.As(err, &conflictErr) {
+		logger.Errorw(fCtx, "check route conflicts", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Action %q conflicts with action %q (%s) on %s %s",
+		conflictErr.Action.Name, conflictErr.Conflict.Name, conflictErr.Conflict.ID, conflictErr.Method,
+		conflictErr.Conflict.Path,
+	))
+}
@@ -0,0 +1,262 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/token"
+)
+
+const (
+	entriesLimit     = 50
+	exportBatchLimit = 500
+	exportTimeout    = 10 * time.Minute
+)
+
+type handler struct {
+	auditRepository audit.Repository
+	authz           authz.Authz
+}
+
+func RegisterHandlers(router fiber.Router, auditRepository audit.Repository, authz authz.Authz) {
+	h := &handler{
+		auditRepository: auditRepository,
+		authz:           authz,
+	}
+
+	project := router.Group("/api/project/:projectID/audit", auth.RequireMiddleware)
+
+	project.Get("/", auth.RequireScope(token.ScopeAdmin), h.getProjectEntriesHandler)
+	project.Get("/export", auth.RequireScope(token.ScopeAdmin), h.exportProjectEntriesHandler)
+
+	// Own audit log includes sign-ins and security changes, so it's available only with session
+	user := router.Group("/api/user/audit", auth.RequireSessionMiddleware)
+
+	user.Get("/", h.getUserEntriesHandler)
+	user.Get("/export", h.exportUserEntriesHandler)
+}
+
+type entryInfo struct {
+	ID         id.ID           `json:"id"`
+	ActorID    id.ID           `json:"actorId,omitzero"`
+	ActorEmail string          `json:"actorEmail,omitempty"`
+	TokenID    id.ID           `json:"tokenId,omitzero"`
+	ProjectID  id.ID           `json:"projectId,omitzero"`
+	ActionID   id.ID           `json:"actionId,omitzero"`
+	Operation  audit.Operation `json:"operation"`
+	Diff       audit.Diff      `json:"diff"`
+	IPAddress  string          `json:"ipAddress"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+func newEntryInfo(model *audit.Model) entryInfo {
+	info := entryInfo{
+		ID:        model.ID,
+		ActorID:   model.ActorID,
+		TokenID:   model.TokenID,
+		ProjectID: model.ProjectID,
+		ActionID:  model.ActionID,
+		Operation: model.Operation,
+		Diff:      model.Diff,
+		IPAddress: model.IPAddress,
+		CreatedAt: model.CreatedAt,
+	}
+	if model.Actor != nil {
+		info.ActorEmail = model.Actor.Email
+	}
+	return info
+}
+
+type filterRequest struct {
+	Operation audit.Operation `query:"operation" validate:"max=64"`
+	ActorID   id.ID           `query:"actorId"   validate:"-"`
+	ActionID  id.ID           `query:"actionId"  validate:"-"`
+	Since     time.Time       `query:"since"     validate:"-"`
+	Until     time.Time       `query:"until"     validate:"-"`
+	Before    id.ID           `query:"before"    validate:"-"`
+	Limit     int             `query:"limit"     validate:"min=0,max=200"`
+}
+
+func (r *filterRequest) filter() audit.Filter {
+	return audit.Filter{
+		ActorID:   r.ActorID,
+		ActionID:  r.ActionID,
+		Operation: r.Operation,
+		Since:     r.Since,
+		Until:     r.Until,
+		Before:    r.Before,
+		Limit:     r.Limit,
+	}
+}
+
+// projectFilter returns filter of audit log of the project from request, if user can read it.
+func (h *handler) projectFilter(fCtx fiber.Ctx) (audit.Filter, error) {
+	var request struct {
+		ProjectID id.ID `uri:"projectID" validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "get project audit log, bad request", "error", err)
+		return audit.Filter{}, fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	var filterReq filterRequest
+	if err := fCtx.Bind().Query(&filterReq); err != nil {
+		logger.Warnw(fCtx, "get project audit log, bad request", "error", err)
+		return audit.Filter{}, fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionAuditRead,
+	)
+	if err != nil {
+		return audit.Filter{}, authz.Error(fCtx, err)
+	}
+
+	filter := filterReq.filter()
+	filter.ProjectID = request.ProjectID
+	return filter, nil
+}
+
+// userFilter returns filter of operations performed by current user from request.
+func (h *handler) userFilter(fCtx fiber.Ctx) (audit.Filter, error) {
+	var filterReq filterRequest
+	if err := fCtx.Bind().Query(&filterReq); err != nil {
+		logger.Warnw(fCtx, "get user audit log, bad request", "error", err)
+		return audit.Filter{}, fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	filter := filterReq.filter()
+	filter.ActorID = auth.MustUserFromContext(fCtx).ID
+	return filter, nil
+}
+
+func (h *handler) getProjectEntriesHandler(fCtx fiber.Ctx) error {
+	filter, err := h.projectFilter(fCtx)
+	if err != nil {
+		return err
+	}
+	return h.getEntries(fCtx, filter)
+}
+
+func (h *handler) exportProjectEntriesHandler(fCtx fiber.Ctx) error {
+	filter, err := h.projectFilter(fCtx)
+	if err != nil {
+		return err
+	}
+	return h.exportEntries(fCtx, filter, "audit-"+filter.ProjectID.String()+".jsonl")
+}
+
+func (h *handler) getUserEntriesHandler(fCtx fiber.Ctx) error {
+	filter, err := h.userFilter(fCtx)
+	if err != nil {
+		return err
+	}
+	return h.getEntries(fCtx, filter)
+}
+
+func (h *handler) exportUserEntriesHandler(fCtx fiber.Ctx) error {
+	filter, err := h.userFilter(fCtx)
+	if err != nil {
+		return err
+	}
+	return h.exportEntries(fCtx, filter, "audit.jsonl")
+}
+
+func (h *handler) getEntries(fCtx fiber.Ctx, filter audit.Filter) error {
+	if filter.Limit == 0 {
+		filter.Limit = entriesLimit
+	}
+
+	models, err := h.auditRepository.Get(fCtx, filter)
+	if err != nil {
+		logger.Errorw(fCtx, "get audit log", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	type entriesPage struct {
+		Entries []entryInfo `json:"entries"`
+		// Next is a cursor of the next page, it's absent on the last page
+		Next id.ID `json:"next,omitzero"`
+	}
+
+	response := entriesPage{
+		Entries: make([]entryInfo, len(models)),
+	}
+	for i := range models {
+		response.Entries[i] = newEntryInfo(&models[i])
+	}
+	if len(models) == filter.Limit {
+		response.Next = models[len(models)-1].ID
+	}
+
+	return fCtx.JSON(&response)
+}
+
+// exportEntries streams all entries matching filter as JSON lines, entries are loaded in batches, so export of large
+// audit log doesn't have to fit in memory.
+func (h *handler) exportEntries(fCtx fiber.Ctx, filter audit.Filter, filename string) error {
+	filter.Limit = exportBatchLimit
+
+	// Request context can't be used after handler returns, stream writer runs after that
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(fCtx.Context()), exportTimeout)
+
+	// Attachment sets content type by file extension, so it's overridden afterward
+	fCtx.Attachment(filename)
+	fCtx.Set(fiber.HeaderContentType, "application/x-ndjson")
+
+	return fCtx.SendStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		for {
+			models, err := h.auditRepository.Get(ctx, filter)
+			if err != nil {
+				logger.Errorw(ctx, "get audit log", "error", err)
+				return
+			}
+
+			for i := range models {
+				if err = writeEntry(w, &models[i]); err != nil {
+					logger.Warnw(ctx, "export audit log", "error", err)
+					return
+				}
+			}
+
+			if err = w.Flush(); err != nil {
+				logger.Warnw(ctx, "export audit log", "error", err)
+				return
+			}
+
+			if len(models) < filter.Limit {
+				return
+			}
+			filter.Before = models[len(models)-1].ID
+		}
+	})
+}
+
+func writeEntry(w *bufio.Writer, model *audit.Model) error {
+	data, err := json.Marshal(newEntryInfo(model))
+	if err != nil {
+		return fmt.Errorf("marshal entry: %w", err)
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return fmt.Errorf("write entry: %w", err)
+	}
+	if err = w.WriteByte('\n'); err != nil {
+		return fmt.Errorf("write entry: %w", err)
+	}
+
+	return nil
+}
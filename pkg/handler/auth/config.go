@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+type Config struct {
+	// PublicURL is used to build links sent in emails
+	PublicURL string `validate:"http_url"`
+	// EmailVerificationRequired forbids login until email is verified
+	EmailVerificationRequired bool `validate:"-"`
+	// RegistrationEnabled allows registration with email and password, OIDC providers can create users regardless
+	RegistrationEnabled bool `validate:"-"`
+	// TwoFactorRequired forces users to set up second factor before they can log in
+	TwoFactorRequired bool `validate:"-"`
+	// LoginMaxAttempts is number of failed logins per email after which login is locked out, after the first few
+	// failures each next attempt is delayed exponentially
+	LoginMaxAttempts int `validate:"min=1"`
+	// LoginMaxAttemptsPerIP is number of failed logins from one IP address after which login is locked out
+	LoginMaxAttemptsPerIP int `validate:"min=1"`
+	// LoginLockoutDuration can't be longer than throttle.MaxLockout
+	LoginLockoutDuration time.Duration `validate:"min=1m,max=24h"`
+	// PasswordHashConcurrency limits number of passwords hashed at the same time, since hashing is CPU and memory
+	// heavy
+	PasswordHashConcurrency int `validate:"min=1"`
+}
+
+func init() { //nolint:gochecknoinits
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+		cfg := Config{
+			PublicURL:                 strings.TrimSuffix(v.GetString("public-url"), "/"),
+			EmailVerificationRequired: v.GetBool("email-verification-required"),
+			RegistrationEnabled:       v.GetBool("registration-enabled"),
+			TwoFactorRequired:         v.GetBool("two-factor-required"),
+			LoginMaxAttempts:          v.GetInt("login-max-attempts"),
+			LoginMaxAttemptsPerIP:     v.GetInt("login-max-attempts-per-ip"),
+			LoginLockoutDuration:      v.GetDuration("login-lockout-duration"),
+			PasswordHashConcurrency:   v.GetInt("password-hash-concurrency"),
+		}
+		if err := va.Struct(cfg); err != nil {
+			return Config{}, err
+		}
+		return cfg, nil
+	})
+}
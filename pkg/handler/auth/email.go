@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	authm "github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/mail"
+	"github.com/mymmrac/lithium/pkg/module/user"
+	"github.com/mymmrac/lithium/pkg/module/verification"
+)
+
+// mailTimeout limits sending of emails in background
+const mailTimeout = time.Minute
+
+func (h *handler) verifyEmailHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		logger.Warnw(fCtx, "verify email, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	ctx, err := h.tx.Begin(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "begin transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	now := time.Now()
+	tokenModel, found, err := h.verificationRepository.Consume(
+		ctx, verification.PurposeEmailVerification, verification.HashToken(request.Token), now,
+	)
+	if err != nil {
+		logger.Errorw(ctx, "consume email verification token", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired link")
+	}
+
+	if err = h.userRepository.UpdateEmailVerified(ctx, tokenModel.UserID, now); err != nil {
+		logger.Errorw(ctx, "update email verified", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.tx.Commit(ctx); err != nil {
+		logger.Errorw(fCtx, "commit transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthEmailVerify, ActorID: tokenModel.UserID})
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) resendVerificationHandler(fCtx fiber.Ctx) error {
+	userModel, found, err := h.userRepository.GetByID(fCtx, authm.MustUserFromContext(fCtx).ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get user", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	if userModel.EmailVerified() {
+		return fCtx.JSON(fiber.Map{"ok": true})
+	}
+
+	if err = h.checkMailThrottle(fCtx, userModel.Email); err != nil {
+		return err
+	}
+
+	if err = h.sendVerificationEmail(fCtx, userModel); err != nil {
+		logger.Errorw(fCtx, "send verification email", "user-id", userModel.ID, "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthEmailVerifyResend})
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) sendVerificationEmail(ctx context.Context, userModel *user.Model) error {
+	token, err := h.issueToken(ctx, userModel.ID, verification.PurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	link := h.cfg.PublicURL + "/verify-email?token=" + url.QueryEscape(token)
+	return h.mailer.Send(ctx, mail.Message{
+		To:      userModel.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Welcome to Lithium!\n\nConfirm your email by opening the link below:\n%s\n\n"+
+			"The link is valid for %s. If you didn't create an account, ignore this email.\n",
+			link, verification.PurposeEmailVerification.TTL()),
+	})
+}
+
+func (h *handler) sendPasswordResetEmail(ctx context.Context, userModel *user.Model) error {
+	token, err := h.issueToken(ctx, userModel.ID, verification.PurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	link := h.cfg.PublicURL + "/reset-password?token=" + url.QueryEscape(token)
+	return h.mailer.Send(ctx, mail.Message{
+		To:      userModel.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone requested a password reset for your Lithium account.\n\n"+
+			"Set a new password by opening the link below:\n%s\n\n"+
+			"The link is valid for %s and can be used once. If it wasn't you, ignore this email.\n",
+			link, verification.PurposePasswordReset.TTL()),
+	})
+}
+
+// issueToken creates new token for purpose, previously issued tokens for the same purpose are invalidated.
+func (h *handler) issueToken(ctx context.Context, userID id.ID, purpose verification.Purpose) (string, error) {
+	now := time.Now()
+	if err := h.verificationRepository.DeleteExpired(ctx, now); err != nil {
+		logger.Warnw(ctx, "delete expired verification tokens", "error", err)
+	}
+
+	if err := h.verificationRepository.DeleteByUserID(ctx, userID, purpose); err != nil {
+		return "", fmt.Errorf("delete previous tokens: %w", err)
+	}
+
+	value, hash := verification.GenerateToken()
+	err := h.verificationRepository.Create(ctx, &verification.Model{
+		ID:        id.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		Hash:      hash,
+		ExpiresAt: now.Add(purpose.TTL()),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create token: %w", err)
+	}
+
+	return value, nil
+}
@@ -6,28 +6,120 @@ import (
 
 	"github.com/gofiber/fiber/v3"
 
+	"github.com/mymmrac/lithium/pkg/module/audit"
 	authm "github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/db"
 	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/identity"
 	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/mail"
+	"github.com/mymmrac/lithium/pkg/module/oidc"
+	"github.com/mymmrac/lithium/pkg/module/server"
+	"github.com/mymmrac/lithium/pkg/module/session"
+	"github.com/mymmrac/lithium/pkg/module/throttle"
+	"github.com/mymmrac/lithium/pkg/module/twofactor"
 	"github.com/mymmrac/lithium/pkg/module/user"
+	"github.com/mymmrac/lithium/pkg/module/verification"
 )
 
 type handler struct {
-	auth           authm.Auth
-	userRepository user.Repository
+	cfg                    Config
+	authCfg                authm.Config
+	serverCfg              server.Config
+	tx                     db.Transaction
+	auth                   authm.Auth
+	mailer                 mail.Mailer
+	userRepository         user.Repository
+	sessionRepository      session.Repository
+	verificationRepository verification.Repository
+	identityRepository     identity.Repository
+	providers              oidc.Providers
+	twoFactorRepository    twofactor.Repository
+	emailThrottle          throttle.Throttle
+	ipThrottle             throttle.Throttle
+	mailEmailThrottle      throttle.Throttle
+	mailIPThrottle         throttle.Throttle
+	passwordSlots          chan struct{}
+	auditLog               audit.Log
 }
 
-func RegisterHandlers(router fiber.Router, auth authm.Auth, userRepository user.Repository) error {
+func RegisterHandlers(
+	cfg Config, authCfg authm.Config, serverCfg server.Config, router fiber.Router, tx db.Transaction, auth authm.Auth,
+	mailer mail.Mailer, userRepository user.Repository, sessionRepository session.Repository,
+	verificationRepository verification.Repository, identityRepository identity.Repository, providers oidc.Providers,
+	twoFactorRepository twofactor.Repository, throttleCache throttle.Cache, auditLog audit.Log,
+) error {
 	h := &handler{
-		auth:           auth,
-		userRepository: userRepository,
+		cfg:                    cfg,
+		authCfg:                authCfg,
+		serverCfg:              serverCfg,
+		tx:                     tx,
+		auth:                   auth,
+		mailer:                 mailer,
+		userRepository:         userRepository,
+		sessionRepository:      sessionRepository,
+		verificationRepository: verificationRepository,
+		identityRepository:     identityRepository,
+		providers:              providers,
+		twoFactorRepository:    twoFactorRepository,
+		emailThrottle: throttle.New(throttleCache, "login-email", throttle.Policy{
+			FreeAttempts: loginFreeAttempts,
+			MaxAttempts:  cfg.LoginMaxAttempts,
+			Lockout:      cfg.LoginLockoutDuration,
+		}),
+		// Many users can share IP address, so it's only locked out without delaying attempts
+		ipThrottle: throttle.New(throttleCache, "login-ip", throttle.Policy{
+			FreeAttempts: cfg.LoginMaxAttemptsPerIP,
+			MaxAttempts:  cfg.LoginMaxAttemptsPerIP,
+			Lockout:      cfg.LoginLockoutDuration,
+		}),
+		mailEmailThrottle: throttle.New(throttleCache, "mail-email", throttle.Policy{
+			FreeAttempts: mailFreePerEmail,
+			MaxAttempts:  mailMaxPerEmail,
+			Lockout:      mailThrottleWindow,
+		}),
+		mailIPThrottle: throttle.New(throttleCache, "mail-ip", throttle.Policy{
+			FreeAttempts: mailMaxPerIP,
+			MaxAttempts:  mailMaxPerIP,
+			Lockout:      mailThrottleWindow,
+		}),
+		passwordSlots: make(chan struct{}, cfg.PasswordHashConcurrency),
+		auditLog:      auditLog,
 	}
 
 	api := router.Group("/api")
 
 	api.Post("/login", h.loginHandler)
+	api.Post("/login/two-factor", h.loginTwoFactorHandler)
+	api.Post("/login/two-factor/setup", h.loginTwoFactorSetupHandler)
+	api.Post("/login/two-factor/enable", h.loginTwoFactorEnableHandler)
 	api.Post("/register", h.registerHandler)
 	api.Post("/logout", authm.RequireMiddleware, h.logoutHandler)
+	api.Get("/user", authm.RequireSessionMiddleware, h.getUserHandler)
+
+	api.Post("/password/change", authm.RequireSessionMiddleware, h.changePasswordHandler)
+	api.Post("/password/forgot", h.forgotPasswordHandler)
+	api.Post("/password/reset", h.resetPasswordHandler)
+
+	api.Post("/email/verify", h.verifyEmailHandler)
+	api.Post("/email/verify/resend", authm.RequireSessionMiddleware, h.resendVerificationHandler)
+
+	router.Get("/auth/oidc/:provider", h.oidcLoginHandler)
+	router.Get("/auth/oidc/:provider/callback", h.oidcCallbackHandler)
+
+	twoFactorAPI := api.Group("/two-factor", authm.RequireSessionMiddleware)
+
+	twoFactorAPI.Get("/", h.getTwoFactorHandler)
+	twoFactorAPI.Post("/setup", h.setupTwoFactorHandler)
+	twoFactorAPI.Post("/enable", h.enableTwoFactorHandler)
+	twoFactorAPI.Post("/disable", h.disableTwoFactorHandler)
+	twoFactorAPI.Post("/recovery-codes", h.regenerateRecoveryCodesHandler)
+
+	sessionAPI := api.Group("/session", authm.RequireSessionMiddleware)
+
+	sessionAPI.Get("/", h.getSessionsHandler)
+	sessionAPI.Post("/revoke-all", h.revokeAllSessionsHandler)
+	sessionAPI.Delete("/:sessionID", h.revokeSessionHandler)
 
 	return nil
 }
@@ -42,32 +134,51 @@ func (h *handler) loginHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
+	attempt, err := h.startLoginAttempt(fCtx, request.Email)
+	if err != nil {
+		return err
+	}
+	defer attempt.finish()
+
 	userModel, found, err := h.userRepository.GetByEmail(fCtx, request.Email)
 	if err != nil {
 		logger.Errorw(fCtx, "get user by email", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 	if !found {
+		attempt.failed(0, "unknown email")
+		return fiber.NewError(fiber.StatusUnauthorized)
+	}
+	if !userModel.HasPassword() {
+		attempt.failed(userModel.ID, "password not set")
 		return fiber.NewError(fiber.StatusUnauthorized)
 	}
 
-	match, needsRehash, err := user.ComparePasswordAndHash(request.Password, userModel.Password)
+	match, needsRehash, err := h.comparePassword(fCtx, request.Password, userModel.Password)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError)
+		return passwordError(fCtx, "compare password", err)
 	}
 	if !match {
+		attempt.failed(userModel.ID, "invalid password")
 		return fiber.NewError(fiber.StatusUnauthorized)
 	}
+	attempt.succeeded()
+
 	if needsRehash {
-		logger.Warnw(fCtx, "user needs to rehash password", "user-id", userModel.ID)
+		h.rehashPassword(fCtx, userModel.ID, request.Password)
 	}
 
-	if err = h.auth.GenerateAndSetToken(fCtx, userModel); err != nil {
-		logger.Errorw(fCtx, "set token cookie", "error", err)
+	if h.cfg.EmailVerificationRequired && !userModel.EmailVerified() {
+		return fiber.NewError(fiber.StatusForbidden, "Email is not verified")
+	}
+
+	twoFactorStep, err := h.login(fCtx, userModel, loginMethodPassword)
+	if err != nil {
+		logger.Errorw(fCtx, "login", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
-	return fCtx.JSON(fiber.Map{"ok": true})
+	return fCtx.JSON(fiber.Map{"ok": true, "twoFactor": twoFactorStep})
 }
 
 func (h *handler) registerHandler(fCtx fiber.Ctx) error {
@@ -76,14 +187,17 @@ func (h *handler) registerHandler(fCtx fiber.Ctx) error {
 		Password string `json:"password" validate:"min=8,max=64"`
 	}
 
+	if !h.cfg.RegistrationEnabled {
+		return fiber.NewError(fiber.StatusForbidden, "Registration is disabled")
+	}
+
 	if err := fCtx.Bind().Body(&request); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
-	hashedPassword, err := user.HashPassword(request.Password)
+	hashedPassword, err := h.hashPassword(fCtx, request.Password)
 	if err != nil {
-		logger.Errorw(fCtx, "hash password", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
+		return passwordError(fCtx, "hash password", err)
 	}
 
 	now := time.Now()
@@ -105,15 +219,53 @@ func (h *handler) registerHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
-	if err = h.auth.GenerateAndSetToken(fCtx, userModel); err != nil {
-		logger.Errorw(fCtx, "set token cookie", "error", err)
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationAuthRegister,
+		ActorID:   userModel.ID,
+		After:     fiber.Map{"email": userModel.Email},
+	})
+
+	// Registration should not fail because of mail delivery, verification email can be resent later
+	if err = h.sendVerificationEmail(fCtx, userModel); err != nil {
+		logger.Errorw(fCtx, "send verification email", "user-id", userModel.ID, "error", err)
+	}
+
+	if h.cfg.EmailVerificationRequired {
+		return fCtx.JSON(fiber.Map{"ok": true, "verificationRequired": true})
+	}
+
+	twoFactorStep, err := h.login(fCtx, userModel, loginMethodPassword)
+	if err != nil {
+		logger.Errorw(fCtx, "login", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
-	return fCtx.JSON(fiber.Map{"ok": true})
+	return fCtx.JSON(fiber.Map{"ok": true, "twoFactor": twoFactorStep})
 }
 
 func (h *handler) logoutHandler(fCtx fiber.Ctx) error {
-	h.auth.ClearToken(fCtx)
+	if err := h.auth.EndSession(fCtx); err != nil {
+		logger.Errorw(fCtx, "end session", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthLogout})
 	return fCtx.Redirect().To("/")
 }
+
+func (h *handler) getUserHandler(fCtx fiber.Ctx) error {
+	userModel, found, err := h.userRepository.GetByID(fCtx, authm.MustUserFromContext(fCtx).ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get user", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	return fCtx.JSON(fiber.Map{
+		"id":            userModel.ID,
+		"email":         userModel.Email,
+		"emailVerified": userModel.EmailVerified(),
+	})
+}
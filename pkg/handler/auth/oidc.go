@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"golang.org/x/oauth2"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/identity"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/oidc"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	// oidcFlowTTL is how long user has to complete login at provider
+	oidcFlowTTL = 10 * time.Minute
+	// oidcErrorRedirect is shown when login with provider fails, details are only logged
+	oidcErrorRedirect = "/?error=oidc"
+)
+
+// oidcFlow holds login attempt details between redirect to provider and callback
+type oidcFlow struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func (h *handler) oidcRedirectURL(provider string) string {
+	return h.cfg.PublicURL + "/auth/oidc/" + provider + "/callback"
+}
+
+func (h *handler) oidcLoginHandler(fCtx fiber.Ctx) error {
+	flow := oidcFlow{
+		Provider: fCtx.Params("provider"),
+		State:    rand.Text(),
+		Nonce:    rand.Text(),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	redirectURL, err := h.providers.AuthCodeURL(
+		fCtx, flow.Provider, h.oidcRedirectURL(flow.Provider), flow.State, flow.Nonce, flow.Verifier,
+	)
+	if errors.Is(err, oidc.ErrProviderNotFound) {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+	if err != nil {
+		logger.Errorw(fCtx, "OIDC login", "provider", flow.Provider, "error", err)
+		return fCtx.Redirect().To(oidcErrorRedirect)
+	}
+
+	data, err := json.Marshal(flow)
+	if err != nil {
+		logger.Errorw(fCtx, "encode OIDC flow", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.setOIDCFlowCookie(fCtx, base64.RawURLEncoding.EncodeToString(data), time.Now().Add(oidcFlowTTL))
+	return fCtx.Redirect().To(redirectURL)
+}
+
+func (h *handler) oidcCallbackHandler(fCtx fiber.Ctx) error {
+	provider := fCtx.Params("provider")
+
+	var flow oidcFlow
+	data, err := base64.RawURLEncoding.DecodeString(fCtx.Cookies(oidcFlowCookie))
+	if err == nil {
+		err = json.Unmarshal(data, &flow)
+	}
+	h.setOIDCFlowCookie(fCtx, "", time.Now().Add(-time.Hour))
+	if err != nil || flow.Provider != provider || flow.State == "" ||
+		subtle.ConstantTimeCompare([]byte(flow.State), []byte(fCtx.Query("state"))) != 1 {
+		logger.Warnw(fCtx, "OIDC callback, invalid state", "provider", provider)
+		return fCtx.Redirect().To(oidcErrorRedirect)
+	}
+
+	if providerError := fCtx.Query("error"); providerError != "" {
+		logger.Warnw(fCtx, "OIDC callback, provider error", "provider", provider, "error", providerError,
+			"description", fCtx.Query("error_description"))
+		return fCtx.Redirect().To(oidcErrorRedirect)
+	}
+
+	claims, err := h.providers.Exchange(
+		fCtx, provider, h.oidcRedirectURL(provider), fCtx.Query("code"), flow.Nonce, flow.Verifier,
+	)
+	if err != nil {
+		logger.Warnw(fCtx, "OIDC callback, exchange", "provider", provider, "error", err)
+		return fCtx.Redirect().To(oidcErrorRedirect)
+	}
+
+	userModel, err := h.oidcUser(fCtx, provider, claims)
+	if err != nil {
+		logger.Errorw(fCtx, "OIDC callback, get user", "provider", provider, "error", err)
+		return fCtx.Redirect().To(oidcErrorRedirect)
+	}
+
+	twoFactorStep, err := h.login(fCtx, userModel, loginMethodOIDC+provider)
+	if err != nil {
+		logger.Errorw(fCtx, "login", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if twoFactorStep != "" {
+		return fCtx.Redirect().To("/?two-factor=" + twoFactorStep)
+	}
+
+	return fCtx.Redirect().To("/dashboard")
+}
+
+// oidcUser returns user linked to provider account, if there is no link, user with the same email is linked or new
+// user is created.
+func (h *handler) oidcUser(ctx context.Context, provider string, claims oidc.Claims) (*user.Model, error) {
+	ctx, err := h.tx.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	identityModel, found, err := h.identityRepository.GetBySubject(ctx, provider, claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("get identity: %w", err)
+	}
+	if found {
+		var userModel *user.Model
+		userModel, found, err = h.userRepository.GetByID(ctx, identityModel.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+		if !found {
+			return nil, fmt.Errorf("user %s of identity not found", identityModel.UserID)
+		}
+		return userModel, nil
+	}
+
+	now := time.Now()
+	userModel, found, err := h.userRepository.GetByEmail(ctx, claims.Email)
+	if err != nil {
+		return nil, fmt.Errorf("get user by email: %w", err)
+	}
+	if found {
+		// Provider verified email, so it's verified for us as well
+		if !userModel.EmailVerified() {
+			if err = h.userRepository.UpdateEmailVerified(ctx, userModel.ID, now); err != nil {
+				return nil, fmt.Errorf("update email verified: %w", err)
+			}
+			userModel.EmailVerifiedAt = now
+		}
+	} else {
+		// Users created by provider don't have password, it can be set with password reset
+		userModel = &user.Model{
+			ID:              id.New(),
+			Email:           claims.Email,
+			EmailVerifiedAt: now,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		if err = h.userRepository.Create(ctx, userModel); err != nil {
+			return nil, fmt.Errorf("create user: %w", err)
+		}
+	}
+
+	err = h.identityRepository.Create(ctx, &identity.Model{
+		ID:        id.New(),
+		UserID:    userModel.ID,
+		Provider:  provider,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create identity: %w", err)
+	}
+
+	if err = h.tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return userModel, nil
+}
+
+func (h *handler) setOIDCFlowCookie(fCtx fiber.Ctx, value string, expiresAt time.Time) {
+	fCtx.Cookie(&fiber.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    value,
+		Path:     "/auth/oidc/",
+		Expires:  expiresAt,
+		HTTPOnly: true,
+		Secure:   h.authCfg.CookieSecure,
+		// Lax regardless of configured SameSite: strict cookie isn't sent on redirect back from provider, and none isn't
+		// needed since that redirect is top-level navigation
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
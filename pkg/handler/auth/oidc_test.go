@@ -0,0 +1,224 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	authHandler "github.com/mymmrac/lithium/pkg/handler/auth"
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/identity"
+	"github.com/mymmrac/lithium/pkg/module/mail"
+	"github.com/mymmrac/lithium/pkg/module/oidc"
+	"github.com/mymmrac/lithium/pkg/module/oidc/oidctest"
+	"github.com/mymmrac/lithium/pkg/module/server"
+	"github.com/mymmrac/lithium/pkg/module/session"
+	"github.com/mymmrac/lithium/pkg/module/throttle"
+	"github.com/mymmrac/lithium/pkg/module/token"
+	"github.com/mymmrac/lithium/pkg/module/twofactor"
+	"github.com/mymmrac/lithium/pkg/module/user"
+	"github.com/mymmrac/lithium/pkg/module/verification"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		provider := oidctest.New(t, "lithium", "client-secret")
+
+		userRepository := user.NewRepository(tx)
+		identityRepository := identity.NewRepository(tx)
+		sessionRepository := session.NewRepository(tx)
+		auditRepository := audit.NewRepository(tx)
+
+		app := fiber.New()
+		authCfg := auth.Config{
+			JWTSecret:       "secretsecretsecretsecret",
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+			CookieSameSite:  "lax",
+		}
+		authModule := auth.NewAuth(authCfg, server.Config{}, token.NewRepository(tx), sessionRepository)
+		app.Use(authModule.Middleware)
+
+		err := authHandler.RegisterHandlers(
+			authHandler.Config{
+				PublicURL:               "http://lithium.test",
+				LoginMaxAttempts:        10,
+				LoginMaxAttemptsPerIP:   100,
+				LoginLockoutDuration:    time.Minute,
+				PasswordHashConcurrency: 1,
+			}, authCfg, server.Config{}, app, tx, authModule,
+			mail.NewLog(nil), userRepository, sessionRepository, verification.NewRepository(tx), identityRepository,
+			oidc.NewProviders(t.Context(), oidc.Config{Providers: []oidc.ProviderConfig{{
+				Name:           "test",
+				Issuer:         provider.Issuer(),
+				ClientID:       provider.ClientID,
+				ClientSecret:   provider.ClientSecret,
+				AllowedDomains: []string{"example.com"},
+			}}}),
+			twofactor.NewRepository(tx), throttle.NewCache(throttle.Config{Store: throttle.StoreDatabase}, tx),
+			audit.NewLog(server.Config{}, auditRepository),
+		)
+		if err != nil {
+			t.Fatalf("register handlers: %v", err)
+		}
+
+		// login starts flow at provider and returns where provider redirected back to
+		login := func(t *testing.T, idpUser oidctest.User) *http.Response {
+			t.Helper()
+
+			resp := request(t, app, "/auth/oidc/test", nil)
+			if resp.StatusCode != fiber.StatusSeeOther {
+				t.Fatalf("unexpected login status: %d", resp.StatusCode)
+			}
+
+			callback := provider.Authorize(t, resp.Header.Get("Location"), idpUser)
+			return request(t, app, callback, resp.Cookies())
+		}
+
+		t.Run("create user", func(t *testing.T) {
+			resp := login(t, oidctest.User{Subject: "1", Email: "new@example.com", EmailVerified: true})
+			assertLoggedIn(t, resp)
+
+			model, found, err := userRepository.GetByEmail(t.Context(), "new@example.com")
+			if err != nil || !found {
+				t.Fatalf("get user: found: %t, error: %v", found, err)
+			}
+			if !model.EmailVerified() || model.HasPassword() {
+				t.Errorf("unexpected user: %+v", model)
+			}
+
+			identityModel, found, err := identityRepository.GetBySubject(t.Context(), "test", "1")
+			if err != nil || !found {
+				t.Fatalf("get identity: found: %t, error: %v", found, err)
+			}
+			if identityModel.UserID != model.ID {
+				t.Errorf("unexpected identity: %+v", identityModel)
+			}
+
+			// Returning user is found by identity even if email at provider changed
+			assertLoggedIn(t, login(t, oidctest.User{Subject: "1", Email: "changed@example.com", EmailVerified: true}))
+			if _, found, err = userRepository.GetByEmail(t.Context(), "changed@example.com"); err != nil || found {
+				t.Fatalf("get changed user: found: %t, error: %v", found, err)
+			}
+		})
+
+		t.Run("link existing user", func(t *testing.T) {
+			now := time.Now()
+			existing := &user.Model{
+				ID:        id.New(),
+				Email:     "existing@example.com",
+				Password:  "hash",
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if err = userRepository.Create(t.Context(), existing); err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+
+			assertLoggedIn(t, login(t, oidctest.User{Subject: "2", Email: existing.Email, EmailVerified: true}))
+
+			identityModel, found, err := identityRepository.GetBySubject(t.Context(), "test", "2")
+			if err != nil || !found {
+				t.Fatalf("get identity: found: %t, error: %v", found, err)
+			}
+			if identityModel.UserID != existing.ID {
+				t.Errorf("identity linked to wrong user: %+v", identityModel)
+			}
+
+			model, _, err := userRepository.GetByID(t.Context(), existing.ID)
+			if err != nil || !model.EmailVerified() || model.Password != "hash" {
+				t.Errorf("unexpected user: %+v, error: %v", model, err)
+			}
+
+			entries, err := auditRepository.Get(t.Context(), audit.Filter{ActorID: existing.ID, Limit: 10})
+			if err != nil {
+				t.Fatalf("get audit log: %v", err)
+			}
+			if len(entries) != 1 || entries[0].Operation != audit.OperationAuthLogin ||
+				string(entries[0].Diff["method"].After) != `"oidc:test"` {
+				t.Errorf("unexpected audit log: %+v", entries)
+			}
+		})
+
+		t.Run("reject unverified email", func(t *testing.T) {
+			assertRejected(t, login(t, oidctest.User{Subject: "3", Email: "unverified@example.com"}))
+		})
+
+		t.Run("reject not allowed domain", func(t *testing.T) {
+			assertRejected(t, login(t, oidctest.User{Subject: "4", Email: "user@other.com", EmailVerified: true}))
+		})
+
+		t.Run("reject invalid state", func(t *testing.T) {
+			resp := request(t, app, "/auth/oidc/test", nil)
+			callback := provider.Authorize(t, resp.Header.Get("Location"), oidctest.User{
+				Subject: "5", Email: "state@example.com", EmailVerified: true,
+			})
+
+			// Callback without flow cookie, for example, started by someone else
+			assertRejected(t, request(t, app, callback, nil))
+
+			u, _ := url.Parse(callback)
+			query := u.Query()
+			query.Set("state", "invalid")
+			u.RawQuery = query.Encode()
+			assertRejected(t, request(t, app, u.String(), resp.Cookies()))
+		})
+
+		t.Run("unknown provider", func(t *testing.T) {
+			if resp := request(t, app, "/auth/oidc/unknown", nil); resp.StatusCode != fiber.StatusNotFound {
+				t.Errorf("unexpected status: %d", resp.StatusCode)
+			}
+		})
+	})
+}
+
+func request(t *testing.T, app *fiber.App, target string, cookies []*http.Cookie) *http.Response {
+	t.Helper()
+
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatalf("parse URL: %v", err)
+	}
+
+	req := httptest.NewRequest(fiber.MethodGet, u.RequestURI(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := app.Test(req, fiber.TestConfig{Timeout: 0})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	return resp
+}
+
+func assertLoggedIn(t *testing.T, resp *http.Response) {
+	t.Helper()
+
+	if location := resp.Header.Get("Location"); location != "/dashboard" {
+		t.Fatalf("unexpected redirect, status: %d, location: %q", resp.StatusCode, location)
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "token" && cookie.Value != "" {
+			return
+		}
+	}
+	t.Errorf("session cookie is not set")
+}
+
+func assertRejected(t *testing.T, resp *http.Response) {
+	t.Helper()
+
+	if location := resp.Header.Get("Location"); location != "/?error=oidc" {
+		t.Fatalf("unexpected redirect, status: %d, location: %q", resp.StatusCode, location)
+	}
+}
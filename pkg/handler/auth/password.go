@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	authm "github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/verification"
+)
+
+// rehashPassword updates password hash to current parameters, failure is not critical since old hash still works.
+func (h *handler) rehashPassword(fCtx fiber.Ctx, userID id.ID, password string) {
+	hashedPassword, err := h.hashPassword(fCtx, password)
+	if err != nil {
+		logger.Warnw(fCtx, "rehash password", "user-id", userID, "error", err)
+		return
+	}
+
+	if err = h.userRepository.UpdatePassword(fCtx, userID, hashedPassword); err != nil {
+		logger.Warnw(fCtx, "update rehashed password", "user-id", userID, "error", err)
+	}
+}
+
+func (h *handler) changePasswordHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		CurrentPassword string `json:"currentPassword" validate:"required"`
+		NewPassword     string `json:"newPassword"     validate:"min=8,max=64"`
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		logger.Warnw(fCtx, "change password, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	userModel, found, err := h.userRepository.GetByID(fCtx, authm.MustUserFromContext(fCtx).ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get user", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	if !userModel.HasPassword() {
+		return fiber.NewError(fiber.StatusForbidden, "Password is not set, use password reset to set it")
+	}
+
+	// Current password can be guessed with stolen session, so attempts are throttled the same way as login
+	attempt, err := h.startLoginAttempt(fCtx, userModel.Email)
+	if err != nil {
+		return err
+	}
+	defer attempt.finish()
+
+	match, _, err := h.comparePassword(fCtx, request.CurrentPassword, userModel.Password)
+	if err != nil {
+		return passwordError(fCtx, "compare password", err)
+	}
+	if !match {
+		attempt.failed(userModel.ID, "invalid current password")
+		return fiber.NewError(fiber.StatusForbidden, "Current password is incorrect")
+	}
+
+	hashedPassword, err := h.hashPassword(fCtx, request.NewPassword)
+	if err != nil {
+		return passwordError(fCtx, "hash password", err)
+	}
+
+	ctx, err := h.tx.Begin(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "begin transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	if err = h.updatePassword(ctx, userModel.ID, hashedPassword); err != nil {
+		logger.Errorw(ctx, "update password", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.tx.Commit(ctx); err != nil {
+		logger.Errorw(fCtx, "commit transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthPasswordChange})
+
+	// All sessions were revoked, but user who changed password stays signed in
+	if err = h.auth.StartSession(fCtx, userModel); err != nil {
+		logger.Errorw(fCtx, "start session", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) forgotPasswordHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		Email string `json:"email" validate:"email"`
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		logger.Warnw(fCtx, "forgot password, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	if err := h.checkMailThrottle(fCtx, request.Email); err != nil {
+		return err
+	}
+
+	userModel, found, err := h.userRepository.GetByEmail(fCtx, request.Email)
+	if err != nil {
+		logger.Errorw(fCtx, "get user by email", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	// Response is the same whether user exists or not and email is sent in background, so neither response nor its
+	// timing reveals whether user exists
+	if found {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(fCtx.Context()), mailTimeout)
+		go func() {
+			defer cancel()
+			if sendErr := h.sendPasswordResetEmail(ctx, userModel); sendErr != nil {
+				logger.Errorw(ctx, "send password reset email", "user-id", userModel.ID, "error", sendErr)
+			}
+		}()
+
+		// Request is not authenticated, but it's recorded as done by the user, so the user can see who requested it
+		h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthPasswordForgot, ActorID: userModel.ID})
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) resetPasswordHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		Token    string `json:"token"    validate:"required"`
+		Password string `json:"password" validate:"min=8,max=64"`
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		logger.Warnw(fCtx, "reset password, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	hashedPassword, err := h.hashPassword(fCtx, request.Password)
+	if err != nil {
+		return passwordError(fCtx, "hash password", err)
+	}
+
+	ctx, err := h.tx.Begin(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "begin transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	tokenModel, found, err := h.verificationRepository.Consume(
+		ctx, verification.PurposePasswordReset, verification.HashToken(request.Token), time.Now(),
+	)
+	if err != nil {
+		logger.Errorw(ctx, "consume password reset token", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired link")
+	}
+
+	userModel, found, err := h.userRepository.GetByID(ctx, tokenModel.UserID)
+	if err != nil {
+		logger.Errorw(ctx, "get user", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired link")
+	}
+
+	if err = h.updatePassword(ctx, userModel.ID, hashedPassword); err != nil {
+		logger.Errorw(ctx, "update password", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	// Reset link was delivered to email, so it's verified as well
+	if !userModel.EmailVerified() {
+		if err = h.userRepository.UpdateEmailVerified(ctx, userModel.ID, time.Now()); err != nil {
+			logger.Errorw(ctx, "update email verified", "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError)
+		}
+	}
+
+	if err = h.tx.Commit(ctx); err != nil {
+		logger.Errorw(fCtx, "commit transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthPasswordReset, ActorID: userModel.ID})
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+// updatePassword sets new password hash and signs user out everywhere, must be called in transaction.
+func (h *handler) updatePassword(ctx context.Context, userID id.ID, hashedPassword string) error {
+	if err := h.userRepository.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+
+	if err := h.sessionRepository.DeleteByUserID(ctx, userID); err != nil {
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+
+	if err := h.verificationRepository.DeleteByUserID(ctx, userID, verification.PurposePasswordReset); err != nil {
+		return fmt.Errorf("delete password reset tokens: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	authm "github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+)
+
+type sessionInfo struct {
+	ID         id.ID     `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+func (h *handler) getSessionsHandler(fCtx fiber.Ctx) error {
+	authUser := authm.MustUserFromContext(fCtx)
+	models, err := h.sessionRepository.GetByUserID(fCtx, authUser.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get sessions", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	now := time.Now()
+	response := make([]sessionInfo, 0, len(models))
+	for _, model := range models {
+		if model.Expired(now) {
+			continue
+		}
+
+		response = append(response, sessionInfo{
+			ID:         model.ID,
+			UserAgent:  model.UserAgent,
+			IPAddress:  model.IPAddress,
+			Current:    model.ID == authUser.SessionID,
+			CreatedAt:  model.CreatedAt,
+			LastUsedAt: model.LastUsedAt,
+			ExpiresAt:  model.ExpiresAt,
+		})
+	}
+
+	return fCtx.JSON(response)
+}
+
+func (h *handler) revokeSessionHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ID id.ID `uri:"sessionID" validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "revoke session, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	authUser := authm.MustUserFromContext(fCtx)
+	model, found, err := h.sessionRepository.GetByID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get session", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found || model.UserID != authUser.ID {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	if err = h.sessionRepository.DeleteByID(fCtx, request.ID); err != nil {
+		logger.Errorw(fCtx, "delete session", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if request.ID == authUser.SessionID {
+		h.auth.ClearCookies(fCtx)
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationAuthSessionRevoke,
+		Before:    fiber.Map{"sessionId": model.ID},
+	})
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) revokeAllSessionsHandler(fCtx fiber.Ctx) error {
+	if err := h.sessionRepository.DeleteByUserID(fCtx, authm.MustUserFromContext(fCtx).ID); err != nil {
+		logger.Errorw(fCtx, "delete sessions", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.auth.ClearCookies(fCtx)
+	h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthSessionRevokeAll})
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
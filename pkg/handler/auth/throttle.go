@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	"github.com/mymmrac/lithium/pkg/module/forwarded"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/throttle"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+const (
+	// loginFreeAttempts is number of failed logins per email that don't delay the next attempt
+	loginFreeAttempts = 3
+	// mailFreePerEmail is number of emails requested for an address that don't delay the next request
+	mailFreePerEmail = 2
+	// mailMaxPerEmail and mailMaxPerIP limit emails requested in mail throttle window
+	mailMaxPerEmail    = 5
+	mailMaxPerIP       = 20
+	mailThrottleWindow = time.Hour
+	// passwordSlotTimeout is how long request waits for password hashing before it's rejected
+	passwordSlotTimeout = 10 * time.Second
+)
+
+var errServerBusy = errors.New("too many passwords are hashed concurrently")
+
+// throttleKey returns key that login attempts for email are counted by.
+func throttleKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// clientIP returns address of client, addresses set by trusted proxies are used, so clients behind the same proxy are
+// not throttled together.
+func (h *handler) clientIP(fCtx fiber.Ctx) string {
+	return forwarded.FromContext(fCtx, h.serverCfg.TrustedProxies).IP.String()
+}
+
+// loginAttempt is counted by throttles before credentials are checked, so concurrent attempts can't exceed the limits.
+// Attempt that neither failed nor succeeded, for example, because of server error, is refunded by finish.
+type loginAttempt struct {
+	h     *handler
+	fCtx  fiber.Ctx
+	email string
+	ip    string
+	done  bool
+}
+
+// attemptBoth counts attempt for email and for IP address, attempt is counted by neither of them if one is throttled.
+// It returns how long to wait if attempts are throttled.
+func attemptBoth(
+	ctx context.Context, emailThrottle throttle.Throttle, email string, ipThrottle throttle.Throttle, ip string,
+) (time.Duration, error) {
+	wait, err := emailThrottle.Attempt(ctx, throttleKey(email))
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+
+	wait, err = ipThrottle.Attempt(ctx, ip)
+	if err != nil || wait > 0 {
+		if refundErr := emailThrottle.Refund(ctx, throttleKey(email)); refundErr != nil {
+			logger.Warnw(ctx, "refund attempt", "error", refundErr)
+		}
+	}
+	return wait, err
+}
+
+// throttledError returns error that asks client to retry after wait.
+func throttledError(fCtx fiber.Ctx, wait time.Duration, msg string) error {
+	fCtx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return fiber.NewError(fiber.StatusTooManyRequests, msg)
+}
+
+// startLoginAttempt counts login attempt for email and from IP address of request, it returns error if attempts are
+// throttled. Returned attempt must be finished.
+func (h *handler) startLoginAttempt(fCtx fiber.Ctx, email string) (*loginAttempt, error) {
+	ip := h.clientIP(fCtx)
+	wait, err := attemptBoth(fCtx, h.emailThrottle, email, h.ipThrottle, ip)
+	if err != nil {
+		logger.Errorw(fCtx, "check login throttle", "error", err)
+		return nil, fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if wait > 0 {
+		logger.Warnw(fCtx, "login throttled", "email", email, "ip", ip, "retry-after", wait)
+		return nil, throttledError(fCtx, wait, "Too many failed attempts, try again later")
+	}
+
+	return &loginAttempt{h: h, fCtx: fCtx, email: email, ip: ip, done: false}, nil
+}
+
+// checkMailThrottle counts email requested for address from IP address of request, it returns error if emails are
+// throttled. Emails are counted whether address belongs to user or not, so throttling doesn't reveal users.
+func (h *handler) checkMailThrottle(fCtx fiber.Ctx, email string) error {
+	ip := h.clientIP(fCtx)
+	wait, err := attemptBoth(fCtx, h.mailEmailThrottle, email, h.mailIPThrottle, ip)
+	if err != nil {
+		logger.Errorw(fCtx, "check mail throttle", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if wait > 0 {
+		logger.Warnw(fCtx, "mail throttled", "email", email, "ip", ip, "retry-after", wait)
+		return throttledError(fCtx, wait, "Too many emails requested, try again later")
+	}
+	return nil
+}
+
+// failed records that attempt failed, attempts to log in as existing user are also written to audit log of the user,
+// user ID is zero for unknown emails.
+func (a *loginAttempt) failed(userID id.ID, reason string) {
+	a.done = true
+
+	logger.Warnw(a.fCtx, "login failed", "email", a.email, "ip", a.ip, "reason", reason)
+
+	if userID != 0 {
+		a.h.auditLog.Record(a.fCtx, audit.Entry{
+			Operation: audit.OperationAuthLoginFailed,
+			ActorID:   userID,
+			After:     fiber.Map{"reason": reason},
+		})
+	}
+}
+
+// succeeded forgets failed logins for email, failures from IP address are kept, so attacker can't reset them by
+// logging into own account.
+func (a *loginAttempt) succeeded() {
+	a.done = true
+
+	if err := a.h.emailThrottle.Reset(a.fCtx, throttleKey(a.email)); err != nil {
+		logger.Warnw(a.fCtx, "reset login throttle", "error", err)
+	}
+	a.refund(a.h.ipThrottle, a.ip)
+}
+
+// finish refunds attempt if it neither failed nor succeeded.
+func (a *loginAttempt) finish() {
+	if a.done {
+		return
+	}
+	a.done = true
+
+	a.refund(a.h.emailThrottle, throttleKey(a.email))
+	a.refund(a.h.ipThrottle, a.ip)
+}
+
+func (a *loginAttempt) refund(th throttle.Throttle, key string) {
+	if err := th.Refund(a.fCtx, key); err != nil {
+		logger.Warnw(a.fCtx, "refund login attempt", "error", err)
+	}
+}
+
+// acquirePasswordSlot waits until password can be hashed, returned function must be called after hashing is done.
+func (h *handler) acquirePasswordSlot(ctx context.Context) (func(), error) {
+	timer := time.NewTimer(passwordSlotTimeout)
+	defer timer.Stop()
+
+	select {
+	case h.passwordSlots <- struct{}{}:
+		return func() { <-h.passwordSlots }, nil
+	case <-timer.C:
+		return nil, errServerBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (h *handler) hashPassword(ctx context.Context, password string) (string, error) {
+	release, err := h.acquirePasswordSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	hashedPassword, err := user.HashPassword(password)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return hashedPassword, nil
+}
+
+func (h *handler) comparePassword(ctx context.Context, password, encodedHash string) (bool, bool, error) {
+	release, err := h.acquirePasswordSlot(ctx)
+	if err != nil {
+		return false, false, err
+	}
+	defer release()
+
+	match, needsRehash, err := user.ComparePasswordAndHash(password, encodedHash)
+	if err != nil {
+		return false, false, fmt.Errorf("compare password: %w", err)
+	}
+	return match, needsRehash, nil
+}
+
+// passwordError converts error of password hashing to response error.
+func passwordError(fCtx fiber.Ctx, msg string, err error) error {
+	if errors.Is(err, errServerBusy) {
+		logger.Warnw(fCtx, msg, "error", err)
+		return fiber.NewError(fiber.StatusServiceUnavailable, "Server is busy, try again later")
+	}
+
+	logger.Errorw(fCtx, msg, "error", err)
+	return fiber.NewError(fiber.StatusInternalServerError)
+}
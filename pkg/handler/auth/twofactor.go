@@ -0,0 +1,567 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"rsc.io/qr"
+
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	authm "github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/twofactor"
+	"github.com/mymmrac/lithium/pkg/module/user"
+	"github.com/mymmrac/lithium/pkg/module/verification"
+)
+
+const (
+	twoFactorCookie = "two_factor"
+	twoFactorIssuer = "Lithium"
+
+	// Login steps that are required after password or identity provider check
+	twoFactorStepVerify = "verify"
+	twoFactorStepSetup  = "setup"
+
+	// Login methods recorded in audit log, identity provider login is recorded with provider name as suffix
+	loginMethodPassword  = "password"
+	loginMethodOIDC      = "oidc:"
+	loginMethodTwoFactor = "two-factor"
+)
+
+// login finishes login of user that passed the first factor. Session is started right away only if second factor
+// is not needed, otherwise login challenge is issued and returned step tells what user has to do next.
+func (h *handler) login(fCtx fiber.Ctx, userModel *user.Model, method string) (string, error) {
+	twoFactorModel, found, err := h.twoFactorRepository.GetByUserID(fCtx, userModel.ID)
+	if err != nil {
+		return "", fmt.Errorf("get two-factor: %w", err)
+	}
+
+	var step string
+	switch {
+	case found && twoFactorModel.Enabled():
+		step = twoFactorStepVerify
+	case h.cfg.TwoFactorRequired:
+		step = twoFactorStepSetup
+	default:
+		if err = h.auth.StartSession(fCtx, userModel); err != nil {
+			return "", fmt.Errorf("start session: %w", err)
+		}
+		h.recordLogin(fCtx, userModel.ID, method)
+		return "", nil
+	}
+
+	value, hash := verification.GenerateToken()
+	now := time.Now()
+	expiresAt := now.Add(verification.PurposeTwoFactorLogin.TTL())
+
+	err = h.verificationRepository.Create(fCtx, &verification.Model{
+		ID:        id.New(),
+		UserID:    userModel.ID,
+		Purpose:   verification.PurposeTwoFactorLogin,
+		Hash:      hash,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create login challenge: %w", err)
+	}
+
+	h.setTwoFactorCookie(fCtx, value, expiresAt)
+	return step, nil
+}
+
+// twoFactorChallenge returns user that started login, reports false if there is no valid login challenge.
+func (h *handler) twoFactorChallenge(fCtx fiber.Ctx) (*user.Model, bool, error) {
+	value := fCtx.Cookies(twoFactorCookie)
+	if value == "" {
+		return nil, false, nil
+	}
+
+	model, found, err := h.verificationRepository.GetByHash(
+		fCtx, verification.PurposeTwoFactorLogin, verification.HashToken(value),
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("get login challenge: %w", err)
+	}
+	if !found || model.Expired(time.Now()) {
+		return nil, false, nil
+	}
+
+	userModel, found, err := h.userRepository.GetByID(fCtx, model.UserID)
+	if err != nil {
+		return nil, false, fmt.Errorf("get user: %w", err)
+	}
+	return userModel, found, nil
+}
+
+// finishTwoFactorLogin consumes login challenge and starts session, reports false if challenge was already used.
+func (h *handler) finishTwoFactorLogin(fCtx fiber.Ctx, userModel *user.Model) (bool, error) {
+	hash := verification.HashToken(fCtx.Cookies(twoFactorCookie))
+	h.setTwoFactorCookie(fCtx, "", time.Now().Add(-time.Hour))
+
+	_, consumed, err := h.verificationRepository.Consume(fCtx, verification.PurposeTwoFactorLogin, hash, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("consume login challenge: %w", err)
+	}
+	if !consumed {
+		return false, nil
+	}
+
+	if err = h.auth.StartSession(fCtx, userModel); err != nil {
+		return false, fmt.Errorf("start session: %w", err)
+	}
+	h.recordLogin(fCtx, userModel.ID, loginMethodTwoFactor)
+	return true, nil
+}
+
+func (h *handler) recordLogin(fCtx fiber.Ctx, userID id.ID, method string) {
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationAuthLogin,
+		ActorID:   userID,
+		After:     fiber.Map{"method": method},
+	})
+}
+
+func (h *handler) loginTwoFactorHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	userModel, found, err := h.twoFactorChallenge(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "get login challenge", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusUnauthorized, "Login expired, log in again")
+	}
+
+	attempt, err := h.startLoginAttempt(fCtx, userModel.Email)
+	if err != nil {
+		return err
+	}
+	defer attempt.finish()
+
+	valid, err := h.verifyTwoFactor(fCtx, userModel.ID, request.Code, request.RecoveryCode)
+	if err != nil {
+		logger.Errorw(fCtx, "verify two-factor", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !valid {
+		attempt.failed(userModel.ID, "invalid two-factor code")
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid code")
+	}
+	attempt.succeeded()
+
+	finished, err := h.finishTwoFactorLogin(fCtx, userModel)
+	if err != nil {
+		logger.Errorw(fCtx, "finish login", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !finished {
+		return fiber.NewError(fiber.StatusUnauthorized, "Login expired, log in again")
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) loginTwoFactorSetupHandler(fCtx fiber.Ctx) error {
+	userModel, found, err := h.twoFactorChallenge(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "get login challenge", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusUnauthorized, "Login expired, log in again")
+	}
+
+	return h.setupTwoFactor(fCtx, userModel)
+}
+
+func (h *handler) loginTwoFactorEnableHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		Code string `json:"code"`
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	userModel, found, err := h.twoFactorChallenge(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "get login challenge", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusUnauthorized, "Login expired, log in again")
+	}
+
+	recoveryCodes, enabled, err := h.enableTwoFactor(fCtx, userModel.ID, request.Code)
+	if err != nil {
+		logger.Errorw(fCtx, "enable two-factor", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !enabled {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid code")
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthTwoFactorEnable, ActorID: userModel.ID})
+
+	finished, err := h.finishTwoFactorLogin(fCtx, userModel)
+	if err != nil {
+		logger.Errorw(fCtx, "finish login", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !finished {
+		return fiber.NewError(fiber.StatusUnauthorized, "Login expired, log in again")
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true, "recoveryCodes": recoveryCodes})
+}
+
+func (h *handler) getTwoFactorHandler(fCtx fiber.Ctx) error {
+	userID := authm.MustUserFromContext(fCtx).ID
+
+	model, found, err := h.twoFactorRepository.GetByUserID(fCtx, userID)
+	if err != nil {
+		logger.Errorw(fCtx, "get two-factor", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	recoveryCodes, err := h.twoFactorRepository.CountRecoveryCodes(fCtx, userID)
+	if err != nil {
+		logger.Errorw(fCtx, "count recovery codes", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{
+		"enabled":       found && model.Enabled(),
+		"required":      h.cfg.TwoFactorRequired,
+		"recoveryCodes": recoveryCodes,
+	})
+}
+
+func (h *handler) setupTwoFactorHandler(fCtx fiber.Ctx) error {
+	userModel, found, err := h.userRepository.GetByID(fCtx, authm.MustUserFromContext(fCtx).ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get user", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	return h.setupTwoFactor(fCtx, userModel)
+}
+
+func (h *handler) enableTwoFactorHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		Code string `json:"code"`
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	recoveryCodes, enabled, err := h.enableTwoFactor(fCtx, authm.MustUserFromContext(fCtx).ID, request.Code)
+	if err != nil {
+		logger.Errorw(fCtx, "enable two-factor", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !enabled {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid code")
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthTwoFactorEnable})
+
+	return fCtx.JSON(fiber.Map{"ok": true, "recoveryCodes": recoveryCodes})
+}
+
+func (h *handler) disableTwoFactorHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+
+	if h.cfg.TwoFactorRequired {
+		return fiber.NewError(fiber.StatusForbidden, "Two-factor authentication is required")
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	userModel, attempt, err := h.throttledTwoFactorUser(fCtx)
+	if err != nil {
+		return err
+	}
+	defer attempt.finish()
+	userID := userModel.ID
+
+	ctx, err := h.tx.Begin(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "begin transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	valid, err := h.verifyTwoFactor(ctx, userID, request.Code, request.RecoveryCode)
+	if err != nil {
+		logger.Errorw(ctx, "verify two-factor", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !valid {
+		attempt.failed(userModel.ID, "invalid two-factor code")
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid code")
+	}
+
+	if err = h.twoFactorRepository.DeleteRecoveryCodesByUserID(ctx, userID); err != nil {
+		logger.Errorw(ctx, "delete recovery codes", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.twoFactorRepository.DeleteByUserID(ctx, userID); err != nil {
+		logger.Errorw(ctx, "delete two-factor", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.tx.Commit(ctx); err != nil {
+		logger.Errorw(fCtx, "commit transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthTwoFactorDisable})
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) regenerateRecoveryCodesHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		Code string `json:"code"`
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	userModel, attempt, err := h.throttledTwoFactorUser(fCtx)
+	if err != nil {
+		return err
+	}
+	defer attempt.finish()
+	userID := userModel.ID
+
+	ctx, err := h.tx.Begin(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "begin transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	// Only code from authenticator app is accepted, so recovery codes can't be used to get new ones
+	valid, err := h.verifyTwoFactor(ctx, userID, request.Code, "")
+	if err != nil {
+		logger.Errorw(ctx, "verify two-factor", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !valid {
+		attempt.failed(userModel.ID, "invalid two-factor code")
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid code")
+	}
+
+	recoveryCodes, err := h.replaceRecoveryCodes(ctx, userID)
+	if err != nil {
+		logger.Errorw(ctx, "replace recovery codes", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.tx.Commit(ctx); err != nil {
+		logger.Errorw(fCtx, "commit transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{Operation: audit.OperationAuthRecoveryCodes})
+
+	return fCtx.JSON(fiber.Map{"ok": true, "recoveryCodes": recoveryCodes})
+}
+
+// throttledTwoFactorUser returns authenticated user if code checks are not throttled, codes can be guessed with stolen
+// session, so attempts are counted the same way as login.
+func (h *handler) throttledTwoFactorUser(fCtx fiber.Ctx) (*user.Model, *loginAttempt, error) {
+	userModel, found, err := h.userRepository.GetByID(fCtx, authm.MustUserFromContext(fCtx).ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get user", "error", err)
+		return nil, nil, fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound)
+	}
+
+	attempt, err := h.startLoginAttempt(fCtx, userModel.Email)
+	if err != nil {
+		return nil, nil, err
+	}
+	return userModel, attempt, nil
+}
+
+// setupTwoFactor generates new secret that has to be confirmed with code before second factor is enabled.
+func (h *handler) setupTwoFactor(fCtx fiber.Ctx, userModel *user.Model) error {
+	model, found, err := h.twoFactorRepository.GetByUserID(fCtx, userModel.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get two-factor", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if found && model.Enabled() {
+		return fiber.NewError(fiber.StatusConflict, "Two-factor authentication is already enabled")
+	}
+
+	secret := twofactor.GenerateSecret()
+	err = h.twoFactorRepository.Upsert(fCtx, &twofactor.Model{
+		UserID:    userModel.ID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		logger.Errorw(fCtx, "save two-factor", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	uri := twofactor.ProvisioningURI(twoFactorIssuer, userModel.Email, secret)
+	qrCode, err := qr.Encode(uri, qr.M)
+	if err != nil {
+		logger.Errorw(fCtx, "encode QR code", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{
+		"secret": secret,
+		"uri":    uri,
+		"qrCode": "data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCode.PNG()),
+	})
+}
+
+// enableTwoFactor confirms setup with code and returns new recovery codes, reports false if code is invalid or
+// there is no pending setup.
+func (h *handler) enableTwoFactor(ctx context.Context, userID id.ID, code string) ([]string, bool, error) {
+	ctx, err := h.tx.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	model, found, err := h.twoFactorRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, false, fmt.Errorf("get two-factor: %w", err)
+	}
+	if !found || model.Enabled() {
+		return nil, false, nil
+	}
+
+	now := time.Now()
+	step, valid := twofactor.Validate(model.Secret, code, now, model.LastStep)
+	if !valid {
+		return nil, false, nil
+	}
+
+	enabled, err := h.twoFactorRepository.Enable(ctx, userID, step, now)
+	if err != nil {
+		return nil, false, fmt.Errorf("enable two-factor: %w", err)
+	}
+	if !enabled {
+		return nil, false, nil
+	}
+
+	recoveryCodes, err := h.replaceRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err = h.tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return recoveryCodes, true, nil
+}
+
+// verifyTwoFactor checks code from authenticator app or, if code is empty, recovery code, each code can be used only
+// once.
+func (h *handler) verifyTwoFactor(ctx context.Context, userID id.ID, code, recoveryCode string) (bool, error) {
+	model, found, err := h.twoFactorRepository.GetByUserID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("get two-factor: %w", err)
+	}
+	if !found || !model.Enabled() {
+		return false, nil
+	}
+
+	if code == "" {
+		if recoveryCode == "" {
+			return false, nil
+		}
+
+		var consumed bool
+		consumed, err = h.twoFactorRepository.ConsumeRecoveryCode(ctx, userID, twofactor.HashRecoveryCode(recoveryCode))
+		if err != nil {
+			return false, fmt.Errorf("consume recovery code: %w", err)
+		}
+		return consumed, nil
+	}
+
+	step, valid := twofactor.Validate(model.Secret, code, time.Now(), model.LastStep)
+	if !valid {
+		return false, nil
+	}
+
+	// Code is valid only if it wasn't used concurrently
+	updated, err := h.twoFactorRepository.UpdateLastStep(ctx, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("update last step: %w", err)
+	}
+	return updated, nil
+}
+
+// replaceRecoveryCodes must be called in transaction, all unused recovery codes are replaced with new ones.
+func (h *handler) replaceRecoveryCodes(ctx context.Context, userID id.ID) ([]string, error) {
+	if err := h.twoFactorRepository.DeleteRecoveryCodesByUserID(ctx, userID); err != nil {
+		return nil, fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	now := time.Now()
+	values, hashes := twofactor.GenerateRecoveryCodes()
+	models := make([]twofactor.RecoveryCode, len(hashes))
+	for i, hash := range hashes {
+		models[i] = twofactor.RecoveryCode{
+			ID:        id.New(),
+			UserID:    userID,
+			Hash:      hash,
+			CreatedAt: now,
+		}
+	}
+
+	if err := h.twoFactorRepository.CreateRecoveryCodes(ctx, models); err != nil {
+		return nil, fmt.Errorf("create recovery codes: %w", err)
+	}
+
+	return values, nil
+}
+
+func (h *handler) setTwoFactorCookie(fCtx fiber.Ctx, value string, expiresAt time.Time) {
+	fCtx.Cookie(&fiber.Cookie{
+		Name:     twoFactorCookie,
+		Value:    value,
+		Path:     "/api/login/two-factor",
+		Expires:  expiresAt,
+		HTTPOnly: true,
+		Secure:   h.authCfg.CookieSecure,
+		SameSite: h.authCfg.CookieSameSite,
+	})
+}
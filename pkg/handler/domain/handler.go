@@ -0,0 +1,445 @@
+package domain
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/certificate"
+	"github.com/mymmrac/lithium/pkg/module/domain"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/routing"
+	"github.com/mymmrac/lithium/pkg/module/token"
+)
+
+type handler struct {
+	routingCfg       routing.Config
+	domainRepository domain.Repository
+	verifier         domain.Verifier
+	cipher           certificate.Cipher
+	certificateStore certificate.Store
+	authz            authz.Authz
+	auditLog         audit.Log
+}
+
+func RegisterHandlers(
+	routingCfg routing.Config, router fiber.Router, domainRepository domain.Repository, verifier domain.Verifier,
+	cipher certificate.Cipher, certificateStore certificate.Store, authz authz.Authz, auditLog audit.Log,
+) {
+	h := &handler{
+		routingCfg:       routingCfg,
+		domainRepository: domainRepository,
+		verifier:         verifier,
+		cipher:           cipher,
+		certificateStore: certificateStore,
+		authz:            authz,
+		auditLog:         auditLog,
+	}
+
+	api := router.Group("/api/project/:projectID/domain", auth.RequireMiddleware)
+
+	api.Get("/", auth.RequireScope(token.ScopeRead), h.getAllHandler)
+	api.Post("/", auth.RequireScope(token.ScopeAdmin), h.createHandler)
+	api.Post("/:domainID/verify", auth.RequireScope(token.ScopeAdmin), h.verifyHandler)
+	api.Delete("/:domainID", auth.RequireScope(token.ScopeAdmin), h.deleteHandler)
+	api.Put("/:domainID/certificate", auth.RequireScope(token.ScopeAdmin), h.uploadCertificateHandler)
+	api.Delete("/:domainID/certificate", auth.RequireScope(token.ScopeAdmin), h.deleteCertificateHandler)
+}
+
+type domainInfo struct {
+	ID         id.ID     `json:"id"`
+	Name       string    `json:"name"`
+	Verified   bool      `json:"verified"`
+	VerifiedAt time.Time `json:"verifiedAt,omitzero"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// Verification describes how to prove ownership of the domain, it's absent once domain is verified
+	Verification *verificationInfo `json:"verification,omitempty"`
+	// Certificate is absent if no certificate is uploaded, private key is never returned
+	Certificate *certificateInfo `json:"certificate,omitempty"`
+}
+
+type certificateInfo struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type verificationInfo struct {
+	DNS struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"dns"`
+	HTTP struct {
+		URL   string `json:"url"`
+		Token string `json:"token"`
+	} `json:"http"`
+}
+
+func newDomainInfo(model *domain.Model) domainInfo {
+	info := domainInfo{
+		ID:         model.ID,
+		Name:       model.Name,
+		Verified:   model.Verified(),
+		VerifiedAt: model.VerifiedAt,
+		CreatedAt:  model.CreatedAt,
+	}
+	if !model.Verified() {
+		info.Verification = &verificationInfo{}
+		info.Verification.DNS.Name = model.TXTRecordName()
+		info.Verification.DNS.Value = model.TXTRecordValue()
+		info.Verification.HTTP.URL = model.HTTPURL()
+		info.Verification.HTTP.Token = model.Token
+	}
+	if model.HasCertificate() {
+		info.Certificate = &certificateInfo{
+			ExpiresAt: model.CertificateExpiresAt,
+		}
+	}
+	return info
+}
+
+type domainState struct {
+	Name     string `json:"name"`
+	Verified bool   `json:"verified"`
+}
+
+func (h *handler) getAllHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ProjectID id.ID `uri:"projectID" validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "get domains, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionProjectRead,
+	)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	models, err := h.domainRepository.GetByProjectID(fCtx, request.ProjectID)
+	if err != nil {
+		logger.Errorw(fCtx, "get domains", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	response := make([]domainInfo, len(models))
+	for i := range models {
+		response[i] = newDomainInfo(&models[i])
+	}
+
+	return fCtx.JSON(response)
+}
+
+func (h *handler) createHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ProjectID id.ID  `uri:"projectID" validate:"required"`
+		Name      string `json:"name"     validate:"fqdn,max=253"`
+	}
+
+	if err := fCtx.Bind().All(&request); err != nil {
+		logger.Warnw(fCtx, "create domain, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionProjectWrite,
+	)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	request.Name = routing.Normalize(request.Name)
+	if h.routingCfg.Reserved(request.Name) {
+		return fiber.NewError(fiber.StatusBadRequest, "Domain is reserved")
+	}
+
+	models, err := h.domainRepository.GetByProjectID(fCtx, request.ProjectID)
+	if err != nil {
+		logger.Errorw(fCtx, "get domains", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	for _, model := range models {
+		if model.Name == request.Name {
+			return fiber.NewError(fiber.StatusConflict, "Domain is already added")
+		}
+	}
+
+	now := time.Now()
+	model := &domain.Model{
+		ID:        id.New(),
+		ProjectID: request.ProjectID,
+		Name:      request.Name,
+		Token:     rand.Text(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err = h.domainRepository.Create(fCtx, model); err != nil {
+		logger.Errorw(fCtx, "create domain", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationDomainAdd,
+		ProjectID: model.ProjectID,
+		After:     domainState{Name: model.Name},
+	})
+
+	return fCtx.JSON(newDomainInfo(model))
+}
+
+func (h *handler) verifyHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ProjectID id.ID         `uri:"projectID" validate:"required"`
+		ID        id.ID         `uri:"domainID"  validate:"required"`
+		Method    domain.Method `json:"method"   validate:"oneof=dns http"`
+	}
+
+	if err := fCtx.Bind().All(&request); err != nil {
+		logger.Warnw(fCtx, "verify domain, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionProjectWrite,
+	)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	model, found, err := h.domainRepository.GetByID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get domain", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found || model.ProjectID != request.ProjectID {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+	if model.Verified() {
+		return fCtx.JSON(newDomainInfo(model))
+	}
+
+	verified, found, err := h.domainRepository.GetVerifiedByName(fCtx, model.Name)
+	if err != nil {
+		logger.Errorw(fCtx, "get verified domain", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if found && verified.ID != model.ID {
+		return fiber.NewError(fiber.StatusConflict, "Domain is used by another project")
+	}
+
+	if err = h.verifier.Verify(fCtx, model, request.Method); err != nil {
+		if errors.Is(err, domain.ErrNotVerified) {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+		}
+
+		logger.Errorw(fCtx, "verify domain", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	model.VerifiedAt = time.Now()
+	if err = h.domainRepository.UpdateVerified(fCtx, model.ID, model.VerifiedAt); err != nil {
+		logger.Errorw(fCtx, "update domain", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationDomainVerify,
+		ProjectID: model.ProjectID,
+		Before:    domainState{Name: model.Name},
+		After:     fiber.Map{"name": model.Name, "verified": true, "method": request.Method},
+	})
+
+	return fCtx.JSON(newDomainInfo(model))
+}
+
+func (h *handler) deleteHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ProjectID id.ID `uri:"projectID" validate:"required"`
+		ID        id.ID `uri:"domainID"  validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "delete domain, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionProjectWrite,
+	)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	model, found, err := h.domainRepository.GetByID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get domain", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found || model.ProjectID != request.ProjectID {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	if err = h.domainRepository.DeleteByID(fCtx, model.ID); err != nil {
+		logger.Errorw(fCtx, "delete domain", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	h.certificateStore.Forget(fCtx, model.Name)
+
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationDomainRemove,
+		ProjectID: model.ProjectID,
+		Before:    domainState{Name: model.Name, Verified: model.Verified()},
+	})
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+type certificateState struct {
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// newCertificateState returns state of certificate of domain for audit log, it's nil if no certificate is uploaded.
+func newCertificateState(model *domain.Model) *certificateState {
+	if !model.HasCertificate() {
+		return nil
+	}
+	return &certificateState{
+		Name:      model.Name,
+		ExpiresAt: model.CertificateExpiresAt,
+	}
+}
+
+func (h *handler) uploadCertificateHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ProjectID   id.ID  `uri:"projectID"   validate:"required"`
+		ID          id.ID  `uri:"domainID"    validate:"required"`
+		Certificate string `json:"certificate" validate:"required,max=65536"`
+		PrivateKey  string `json:"privateKey"  validate:"required,max=16384"`
+	}
+
+	if err := fCtx.Bind().All(&request); err != nil {
+		logger.Warnw(fCtx, "upload certificate, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionProjectWrite,
+	)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	if !h.cipher.Enabled() {
+		return fiber.NewError(fiber.StatusNotImplemented, "Certificate upload is not configured")
+	}
+
+	model, found, err := h.domainRepository.GetByID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get domain", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found || model.ProjectID != request.ProjectID {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+	if !model.Verified() {
+		return fiber.NewError(fiber.StatusConflict, "Domain is not verified")
+	}
+
+	cert, err := certificate.Parse([]byte(request.Certificate), []byte(request.PrivateKey))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Invalid certificate or private key: "+err.Error())
+	}
+	if err = cert.Leaf.VerifyHostname(model.Name); err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Certificate is not issued for the domain")
+	}
+	if !time.Now().Before(cert.Leaf.NotAfter) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Certificate is expired")
+	}
+
+	encryptedKey, err := h.cipher.Encrypt([]byte(request.PrivateKey))
+	if err != nil {
+		logger.Errorw(fCtx, "encrypt private key", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	before := newCertificateState(model)
+	model.Certificate = strings.TrimSpace(request.Certificate)
+	model.CertificateKey = encryptedKey
+	model.CertificateExpiresAt = cert.Leaf.NotAfter
+	err = h.domainRepository.UpdateCertificate(
+		fCtx, model.ID, model.Certificate, model.CertificateKey, model.CertificateExpiresAt,
+	)
+	if err != nil {
+		logger.Errorw(fCtx, "update domain certificate", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	h.certificateStore.Forget(fCtx, model.Name)
+
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationDomainCertificateUpload,
+		ProjectID: model.ProjectID,
+		Before:    before,
+		After:     newCertificateState(model),
+	})
+
+	return fCtx.JSON(newDomainInfo(model))
+}
+
+func (h *handler) deleteCertificateHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ProjectID id.ID `uri:"projectID" validate:"required"`
+		ID        id.ID `uri:"domainID"  validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "delete certificate, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ProjectID, authz.PermissionProjectWrite,
+	)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	model, found, err := h.domainRepository.GetByID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get domain", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found || model.ProjectID != request.ProjectID {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+	if !model.HasCertificate() {
+		return fCtx.JSON(newDomainInfo(model))
+	}
+
+	before := newCertificateState(model)
+	if err = h.domainRepository.UpdateCertificate(fCtx, model.ID, "", "", time.Time{}); err != nil {
+		logger.Errorw(fCtx, "update domain certificate", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	h.certificateStore.Forget(fCtx, model.Name)
+
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationDomainCertificateRemove,
+		ProjectID: model.ProjectID,
+		Before:    before,
+	})
+
+	model.Certificate = ""
+	model.CertificateKey = ""
+	model.CertificateExpiresAt = time.Time{}
+	return fCtx.JSON(newDomainInfo(model))
+}
@@ -8,13 +8,15 @@ import (
 )
 
 type Config struct {
-	ModuleBucket string `validate:"required"`
+	ModuleBucket  string `validate:"required"`
+	MaxModuleSize int64  `validate:"min=1"`
 }
 
 func init() { //nolint:gochecknoinits
 	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
 		cfg := Config{
-			ModuleBucket: v.GetString("module-bucket"),
+			ModuleBucket:  v.GetString("module-bucket"),
+			MaxModuleSize: v.GetInt64("module-max-size"),
 		}
 		if err := va.Struct(cfg); err != nil {
 			return Config{}, err
@@ -3,17 +3,19 @@ package invoker
 import (
 	"context"
 	"fmt"
-	"maps"
-	"path"
-	"path/filepath"
 	"slices"
 
 	"github.com/gofiber/fiber/v3"
-	"github.com/mymmrac/wape"
 
 	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/cors"
+	"github.com/mymmrac/lithium/pkg/module/domain"
+	"github.com/mymmrac/lithium/pkg/module/forwarded"
 	"github.com/mymmrac/lithium/pkg/module/logger"
 	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/quota"
+	"github.com/mymmrac/lithium/pkg/module/routing"
+	"github.com/mymmrac/lithium/pkg/module/server"
 	"github.com/mymmrac/lithium/pkg/module/storage"
 	"github.com/mymmrac/lithium/pkg/plugin/protocol"
 )
@@ -24,48 +26,73 @@ type Invoker interface {
 
 type invoker struct {
 	cfg               Config
+	serverCfg         server.Config
+	routingCfg        routing.Config
 	storage           storage.Storage
 	actionCache       action.Cache
 	actionRepository  action.Repository
 	projectRepository project.Repository
+	domainRepository  domain.Repository
+	quota             quota.Quota
 }
 
 func NewInvoker(
-	cfg Config, storage storage.Storage, actionCache action.Cache, actionRepository action.Repository,
-	projectRepository project.Repository,
+	cfg Config, serverCfg server.Config, routingCfg routing.Config, storage storage.Storage, actionCache action.Cache,
+	actionRepository action.Repository, projectRepository project.Repository, domainRepository domain.Repository,
+	quota quota.Quota,
 ) Invoker {
 	return &invoker{
 		cfg:               cfg,
+		serverCfg:         serverCfg,
+		routingCfg:        routingCfg,
 		storage:           storage,
 		actionCache:       actionCache,
 		actionRepository:  actionRepository,
 		projectRepository: projectRepository,
+		domainRepository:  domainRepository,
+		quota:             quota,
 	}
 }
 
 func (i *invoker) Middleware(fCtx fiber.Ctx) error {
-	subDomains := fCtx.Subdomains()
-	if len(subDomains) == 0 || len(subDomains) > 1 {
-		return fCtx.Next()
-	}
+	client := forwarded.FromContext(fCtx, i.serverCfg.TrustedProxies)
+	hostname := routing.Normalize(client.Hostname())
+	route := i.routingCfg.Resolve(hostname)
 
-	subDomain := subDomains[0]
-	if len(subDomain) == 0 {
-		return fCtx.Next()
+	if route.Custom {
+		domainModel, found, err := i.domainRepository.GetVerifiedByName(fCtx, hostname)
+		if err != nil {
+			logger.Errorw(fCtx, "get domain by name", "domain", hostname, "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError)
+		}
+		if found {
+			return i.invoke(fCtx, domainModel.Project, client)
+		}
 	}
 
-	return i.invoke(fCtx, subDomain)
-}
+	if route.SubDomain != "" {
+		projectModel, found, err := i.projectRepository.GetBySubDomain(fCtx, route.SubDomain)
+		if err != nil {
+			logger.Errorw(fCtx, "get project by subdomain", "sub-domain", route.SubDomain, "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError)
+		}
+		if !found {
+			return fiber.NewError(fiber.StatusNotFound)
+		}
 
-func (i *invoker) invoke(fCtx fiber.Ctx, subDomain string) error {
-	projectModel, found, err := i.projectRepository.GetBySubDomain(fCtx, subDomain)
-	if err != nil {
-		logger.Errorw(fCtx, "get project by subdomain", "sub-domain", subDomain, "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
+		return i.invoke(fCtx, projectModel, client)
 	}
-	if !found {
+
+	if !route.Dashboard {
 		return fiber.NewError(fiber.StatusNotFound)
 	}
+	return fCtx.Next()
+}
+
+func (i *invoker) invoke(fCtx fiber.Ctx, projectModel *project.Model, client forwarded.Client) error {
+	if projectModel.Disabled {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "Project is disabled")
+	}
 
 	actions, err := i.actionRepository.GetByProjectID(fCtx, projectModel.ID)
 	if err != nil {
@@ -76,29 +103,80 @@ func (i *invoker) invoke(fCtx fiber.Ctx, subDomain string) error {
 		return fiber.NewError(fiber.StatusNotFound)
 	}
 
+	// Preflight is routed by requested method, so it's answered with policy of action that will serve the request.
+	// Without any policy, preflight is passed to actions as OPTIONS request, so modules can answer it.
+	preflight := cors.IsPreflight(fCtx) && corsConfigured(projectModel, actions)
+	if preflight {
+		fCtx.Request().Header.SetMethod(fCtx.Get(fiber.HeaderAccessControlRequestMethod))
+	}
+
 	app := fiber.New()
 	for _, actionModel := range actions {
-		app.Add(actionModel.Methods, actionModel.Path, func(fCtx fiber.Ctx) error {
-			return i.invokeAction(fCtx, actionModel)
-		})
+		app.Add(actionModel.RouteMethods(), actionModel.Path,
+			i.actionHandler(projectModel, actionModel, client, preflight, false),
+		)
+	}
+	// Derived HEAD routes are added after all explicit ones, so they never shadow explicit routes
+	for _, actionModel := range actions {
+		if actionModel.DerivesHead() {
+			app.Head(actionModel.Path, i.actionHandler(projectModel, actionModel, client, preflight, true))
+		}
 	}
 	app.Handler()(fCtx.RequestCtx())
 
 	return nil
 }
 
-func (i *invoker) invokeAction(fCtx fiber.Ctx, action action.Model) error {
+// actionHandler returns handler of action route, derived HEAD requests are passed to module as GET requests and body
+// of response is omitted by server.
+func (i *invoker) actionHandler(
+	projectModel *project.Model, actionModel action.Model, client forwarded.Client, preflight, derivedHead bool,
+) fiber.Handler {
+	return func(fCtx fiber.Ctx) error {
+		policy := action.EffectiveCORS(projectModel.Config, actionModel.Config)
+		if preflight {
+			return cors.Preflight(fCtx, policy, actionModel.AllowedMethods())
+		}
+
+		method := fCtx.Method()
+		if derivedHead {
+			method = fiber.MethodGet
+		}
+
+		err := i.invokeAction(fCtx, projectModel, actionModel, client, method)
+		// Errors are decorated too, so browser can read them
+		cors.Decorate(fCtx, policy)
+		return err
+	}
+}
+
+// corsConfigured reports whether project or any of its actions has CORS policy.
+func corsConfigured(projectModel *project.Model, actions []action.Model) bool {
+	return projectModel.Config.CORS != nil || slices.ContainsFunc(actions, func(model action.Model) bool {
+		return model.Config.CORS != nil
+	})
+}
+
+func (i *invoker) invokeAction(
+	fCtx fiber.Ctx, projectModel *project.Model, action action.Model, client forwarded.Client, method string,
+) error {
 	if action.ModulePath == "" {
 		return fiber.NewError(fiber.StatusNotImplemented)
 	}
 
+	release, err := i.quota.StartInvocation(fCtx, projectModel.TeamID, projectModel.ID)
+	if err != nil {
+		return quota.Error(fCtx, err)
+	}
+	defer release()
+
 	module, ok, err := i.actionCache.Get(fCtx, action.ID)
 	if err != nil {
 		logger.Errorw(fCtx, "get action module from cache", "id", action.ID, "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 	if !ok {
-		module, err = i.compileModule(fCtx, action)
+		module, err = i.compileModule(fCtx, projectModel, action)
 		if err != nil {
 			logger.Errorw(fCtx, "compile module", "id", action.ID, "error", err)
 			return fiber.NewError(fiber.StatusInternalServerError)
@@ -111,10 +189,14 @@ func (i *invoker) invokeAction(fCtx fiber.Ctx, action action.Model) error {
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
+	// Modules see request as it was made by client, forwarded headers are set only by the server
+	headers := fCtx.GetHeaders()
+	client.SetHeaders(headers)
+
 	request, err := (&protocol.Request{
-		URL:     string(fCtx.Request().URI().FullURI()),
-		Method:  fCtx.Method(),
-		Headers: fCtx.GetHeaders(),
+		URL:     client.Proto + "://" + client.Host + string(fCtx.Request().RequestURI()),
+		Method:  method,
+		Headers: headers,
 		Body:    string(fCtx.Body()),
 	}).Marshal()
 	if err != nil {
@@ -153,54 +235,19 @@ func (i *invoker) invokeAction(fCtx fiber.Ctx, action action.Model) error {
 	return nil
 }
 
-func (i *invoker) compileModule(ctx context.Context, model action.Model) (action.Module, error) {
-	moduleData, err := i.storage.Download(ctx, i.cfg.ModuleBucket, model.ModulePath)
+func (i *invoker) compileModule(
+	ctx context.Context, projectModel *project.Model, model action.Model,
+) (action.Module, error) {
+	moduleData, err := storage.ReadAll(
+		ctx, i.storage, i.cfg.ModuleBucket, model.ModulePath, model.Config.ModuleSizeLimit(i.cfg.MaxModuleSize),
+	)
 	if err != nil {
 		return action.Module{}, fmt.Errorf("download module: %w", err)
 	}
 
-	env := wape.NewEnvironment()
-	env.Modules = []wape.ModuleData{
-		{
-			Name: "main",
-			Data: moduleData,
-		},
-	}
-
-	env.EnvsMap = maps.Clone(model.Config.Envs)
-	env.Args = slices.Clone(model.Config.Args)
-
-	env.NetworkEnabled = model.Config.Network
-
-	env.NetworksAllowAll = true
-	env.NetworkAddressesAllowAll = true
-
-	env.WallTimeFromHost = true
-	env.NanoTimeFromHost = true
-	env.NanoSleepFromHost = true
-
-	env.RandSourceFromHost = true
-
-	if model.Config.Network {
-		const pluginCADir = "/certs"
-		const caFile = "/etc/ssl/certs/ca-certificates.crt"
-		if env.EnvsMap == nil {
-			env.EnvsMap = make(map[string]string, 1)
-		}
-		env.EnvsMap["LITHIUM_CA_CERT_FILE"] = path.Join(pluginCADir, filepath.Base(caFile))
-		env.FSAllowedPaths = map[string]string{
-			"ro:" + filepath.Dir(caFile): pluginCADir,
-		}
-	}
-
-	compiledPlugin, err := wape.NewCompiledPlugin(ctx, env)
+	module, err := action.Compile(ctx, projectModel, &model, moduleData)
 	if err != nil {
-		return action.Module{}, fmt.Errorf("compile plugin: %w", err)
-	}
-
-	module := action.Module{
-		CompiledPlugin:       compiledPlugin,
-		PluginInstanceConfig: env.MakePluginInstanceConfig(),
+		return action.Module{}, err
 	}
 
 	if err = i.actionCache.Set(ctx, model.ID, module); err != nil {
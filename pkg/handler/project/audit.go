@@ -0,0 +1,52 @@
+package project
+
+import (
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	"github.com/mymmrac/lithium/pkg/module/cors"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+)
+
+// projectState is recorded in audit log, values of secrets are redacted.
+type projectState struct {
+	TeamID    id.ID             `json:"teamId"`
+	Name      string            `json:"name"`
+	SubDomain string            `json:"subDomain"`
+	Disabled  bool              `json:"disabled"`
+	Envs      map[string]string `json:"envs,omitempty"`
+	Secrets   map[string]string `json:"secrets,omitempty"`
+	CORS      *cors.Policy      `json:"cors,omitempty"`
+}
+
+// auditStates returns states of the project before and after operation, any of models can be nil.
+func auditStates(before, after *project.Model) (any, any) {
+	var beforeSecrets, afterSecrets map[string]string
+	if before != nil {
+		beforeSecrets = before.Config.Secrets
+	}
+	if after != nil {
+		afterSecrets = after.Config.Secrets
+	}
+	beforeSecrets, afterSecrets = audit.RedactSecrets(beforeSecrets, afterSecrets)
+
+	var beforeState, afterState any
+	if before != nil {
+		beforeState = newProjectState(before, beforeSecrets)
+	}
+	if after != nil {
+		afterState = newProjectState(after, afterSecrets)
+	}
+	return beforeState, afterState
+}
+
+func newProjectState(model *project.Model, secrets map[string]string) projectState {
+	return projectState{
+		TeamID:    model.TeamID,
+		Name:      model.Name,
+		SubDomain: model.SubDomain,
+		Disabled:  model.Disabled,
+		Envs:      model.Config.Envs,
+		Secrets:   secrets,
+		CORS:      model.Config.CORS,
+	}
+}
@@ -1,76 +1,120 @@
 package project
 
 import (
+	"context"
 	"crypto/rand"
+	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 
 	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/audit"
 	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/cors"
 	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/deploy"
 	"github.com/mymmrac/lithium/pkg/module/id"
 	"github.com/mymmrac/lithium/pkg/module/logger"
 	"github.com/mymmrac/lithium/pkg/module/project"
-	"github.com/mymmrac/lithium/pkg/module/storage"
+	"github.com/mymmrac/lithium/pkg/module/quota"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/token"
 	"github.com/mymmrac/lithium/pkg/module/user"
 )
 
 type handler struct {
-	cfg               Config
 	tx                db.Transaction
 	userRepository    user.Repository
+	teamRepository    team.Repository
+	authz             authz.Authz
 	projectRepository project.Repository
 	actionCache       action.Cache
 	actionRepository  action.Repository
-	storage           storage.Storage
+	projectDeleter    deploy.ProjectDeleter
+	auditLog          audit.Log
+	quota             quota.Quota
 }
 
 func RegisterHandlers(
-	cfg Config, router fiber.Router, tx db.Transaction, userRepository user.Repository,
-	projectRepository project.Repository, actionCache action.Cache, actionRepository action.Repository,
-	storage storage.Storage,
+	router fiber.Router, tx db.Transaction, userRepository user.Repository, teamRepository team.Repository,
+	authz authz.Authz, projectRepository project.Repository, actionCache action.Cache, actionRepository action.Repository,
+	projectDeleter deploy.ProjectDeleter, auditLog audit.Log, quota quota.Quota,
 ) {
 	h := &handler{
-		cfg:               cfg,
 		tx:                tx,
 		userRepository:    userRepository,
+		teamRepository:    teamRepository,
+		authz:             authz,
 		projectRepository: projectRepository,
 		actionCache:       actionCache,
 		actionRepository:  actionRepository,
-		storage:           storage,
+		projectDeleter:    projectDeleter,
+		auditLog:          auditLog,
+		quota:             quota,
 	}
 
 	api := router.Group("/api/project", auth.RequireMiddleware)
 
-	api.Get("/", h.getAllHandler)
-	api.Post("/", h.createHandler)
-	api.Get("/:projectID", h.getHandler)
-	api.Put("/:projectID", h.updateHandler)
-	api.Delete("/:projectID", h.deleteHandler)
+	api.Get("/", auth.RequireScope(token.ScopeRead), h.getAllHandler)
+	api.Post("/", auth.RequireScope(token.ScopeAdmin), h.createHandler)
+	api.Get("/:projectID", auth.RequireScope(token.ScopeRead), h.getHandler)
+	api.Put("/:projectID", auth.RequireScope(token.ScopeAdmin), h.updateHandler)
+	api.Put("/:projectID/config", auth.RequireScope(token.ScopeAdmin), h.updateConfigHandler)
+	api.Delete("/:projectID", auth.RequireScope(token.ScopeAdmin), h.deleteHandler)
 }
 
 type projectInfo struct {
-	ID        id.ID  `json:"id"`
-	Name      string `json:"name"`
-	SubDomain string `json:"subDomain"`
+	ID        id.ID     `json:"id"`
+	TeamID    id.ID     `json:"teamId"`
+	Role      team.Role `json:"role"`
+	Name      string    `json:"name"`
+	SubDomain string    `json:"subDomain"`
+	Disabled  bool      `json:"disabled"`
+}
+
+func newProjectInfo(model *project.Model, role team.Role) projectInfo {
+	return projectInfo{
+		ID:        model.ID,
+		TeamID:    model.TeamID,
+		Role:      role,
+		Name:      model.Name,
+		SubDomain: model.SubDomain,
+		Disabled:  model.Disabled,
+	}
 }
 
 func (h *handler) getAllHandler(fCtx fiber.Ctx) error {
-	models, err := h.projectRepository.GetByOwnerID(fCtx, auth.MustUserFromContext(fCtx).ID)
+	authUser := auth.MustUserFromContext(fCtx)
+	members, err := h.teamRepository.GetMembersByUserID(fCtx, authUser.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get team members", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	roles := make(map[id.ID]team.Role, len(members))
+	teamIDs := make([]id.ID, len(members))
+	for i, member := range members {
+		roles[member.TeamID] = member.Role
+		teamIDs[i] = member.TeamID
+	}
+
+	models, err := h.projectRepository.GetByTeamIDs(fCtx, teamIDs)
 	if err != nil {
 		logger.Errorw(fCtx, "get projects", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
+	models = slices.DeleteFunc(models, func(model project.Model) bool {
+		return !authUser.AllowsProject(model.ID)
+	})
+
 	response := make([]projectInfo, len(models))
-	for i, model := range models {
-		response[i] = projectInfo{
-			ID:        model.ID,
-			Name:      model.Name,
-			SubDomain: model.SubDomain,
-		}
+	for i := range models {
+		response[i] = newProjectInfo(&models[i], roles[models[i].TeamID])
 	}
 
 	return fCtx.JSON(response)
@@ -86,25 +130,48 @@ func (h *handler) getHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
-	model, found, err := h.projectRepository.GetByID(fCtx, request.ID)
+	model, member, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ID, authz.PermissionProjectRead,
+	)
 	if err != nil {
-		logger.Errorw(fCtx, "get project", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
+		return authz.Error(fCtx, err)
 	}
-	if !found || model.OwnerID != auth.MustUserFromContext(fCtx).ID {
-		return fiber.NewError(fiber.StatusNotFound)
+
+	type projectConfig struct {
+		Envs    map[string]string `json:"envs,omitempty"`
+		Secrets map[string]string `json:"secrets,omitempty"`
+		CORS    *cors.Policy      `json:"cors,omitempty"`
 	}
 
-	return fCtx.JSON(&projectInfo{
-		ID:        model.ID,
-		Name:      model.Name,
-		SubDomain: model.SubDomain,
+	type projectDetails struct {
+		projectInfo
+
+		Config projectConfig `json:"config"`
+	}
+
+	secrets := model.Config.Secrets
+	if !authz.Allows(member.Role, authz.PermissionSecretRead) {
+		// Only names of secrets are shown to members who can't read them
+		secrets = make(map[string]string, len(model.Config.Secrets))
+		for key := range model.Config.Secrets {
+			secrets[key] = ""
+		}
+	}
+
+	return fCtx.JSON(&projectDetails{
+		projectInfo: newProjectInfo(model, member.Role),
+		Config: projectConfig{
+			Envs:    model.Config.Envs,
+			Secrets: secrets,
+			CORS:    model.Config.CORS,
+		},
 	})
 }
 
 func (h *handler) createHandler(fCtx fiber.Ctx) error {
 	var request struct {
-		Name string `json:"name" validate:"alphanum_text,min=1,max=64"`
+		Name   string `json:"name"   validate:"alphanum_text,min=1,max=64"`
+		TeamID id.ID  `json:"teamId" validate:"-"`
 	}
 
 	if err := fCtx.Bind().Body(&request); err != nil {
@@ -112,27 +179,104 @@ func (h *handler) createHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
+	userID := auth.MustUserFromContext(fCtx).ID
+	if request.TeamID != 0 {
+		if _, err := h.authz.Team(fCtx, userID, request.TeamID, authz.PermissionProjectWrite); err != nil {
+			return authz.Error(fCtx, err)
+		}
+	}
+
+	ctx, err := h.tx.Begin(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "begin transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	now := time.Now()
+	if request.TeamID == 0 {
+		request.TeamID, err = h.defaultTeam(ctx, userID, now)
+		if err != nil {
+			logger.Errorw(fCtx, "get default team", "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError)
+		}
+	}
+
+	if err = h.quota.CheckProjects(ctx, request.TeamID); err != nil {
+		return quota.Error(fCtx, err)
+	}
+
 	request.Name = strings.TrimSpace(request.Name)
 	subDomainReplacer := strings.NewReplacer(" ", "-", "_", "-")
 	subDomain := subDomainReplacer.Replace(strings.ToLower(request.Name)) + "-" + strings.ToLower(rand.Text()[:4])
 
-	now := time.Now()
-	err := h.projectRepository.Create(fCtx, &project.Model{
+	model := &project.Model{
 		ID:        id.New(),
-		OwnerID:   auth.MustUserFromContext(fCtx).ID,
+		TeamID:    request.TeamID,
+		OwnerID:   userID,
 		Name:      request.Name,
 		SubDomain: subDomain,
 		CreatedAt: now,
 		UpdatedAt: now,
-	})
-	if err != nil {
+	}
+	if err = h.projectRepository.Create(ctx, model); err != nil {
 		logger.Errorw(fCtx, "create project", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
+	if err = h.tx.Commit(ctx); err != nil {
+		logger.Errorw(fCtx, "commit transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	before, after := auditStates(nil, model)
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationProjectCreate,
+		ProjectID: model.ID,
+		Before:    before,
+		After:     after,
+	})
+
 	return fCtx.JSON(fiber.Map{"ok": true})
 }
 
+// defaultTeam returns the oldest team where user can create projects, personal team is created if there is none, so
+// projects can be created without choosing a team
+func (h *handler) defaultTeam(ctx context.Context, userID id.ID, now time.Time) (id.ID, error) {
+	members, err := h.teamRepository.GetMembersByUserID(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("get team members: %w", err)
+	}
+
+	for _, member := range members {
+		if authz.Allows(member.Role, authz.PermissionProjectWrite) {
+			return member.TeamID, nil
+		}
+	}
+
+	teamModel := &team.Model{
+		ID:        id.New(),
+		Name:      team.PersonalName,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err = h.teamRepository.Create(ctx, teamModel); err != nil {
+		return 0, fmt.Errorf("create team: %w", err)
+	}
+
+	err = h.teamRepository.CreateMember(ctx, &team.Member{
+		TeamID:    teamModel.ID,
+		UserID:    userID,
+		Role:      team.RoleOwner,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("create team member: %w", err)
+	}
+
+	return teamModel.ID, nil
+}
+
 func (h *handler) updateHandler(fCtx fiber.Ctx) error {
 	var request struct {
 		ID   id.ID  `uri:"projectID" validate:"required"`
@@ -144,13 +288,11 @@ func (h *handler) updateHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
-	model, found, err := h.projectRepository.GetByID(fCtx, request.ID)
+	model, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ID, authz.PermissionProjectWrite,
+	)
 	if err != nil {
-		logger.Errorw(fCtx, "get user", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
-	}
-	if !found || model.OwnerID != auth.MustUserFromContext(fCtx).ID {
-		return fiber.NewError(fiber.StatusNotFound)
+		return authz.Error(fCtx, err)
 	}
 
 	err = h.projectRepository.UpdateName(fCtx, request.ID, request.Name)
@@ -159,69 +301,110 @@ func (h *handler) updateHandler(fCtx fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
+	updated := *model
+	updated.Name = request.Name
+	before, after := auditStates(model, &updated)
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationProjectUpdate,
+		ProjectID: model.ID,
+		Before:    before,
+		After:     after,
+	})
+
 	return fCtx.JSON(fiber.Map{"ok": true})
 }
 
-func (h *handler) deleteHandler(fCtx fiber.Ctx) error {
+func (h *handler) updateConfigHandler(fCtx fiber.Ctx) error {
 	var request struct {
-		ID id.ID `uri:"projectID" validate:"required"`
+		ID      id.ID             `uri:"projectID" validate:"required"`
+		Envs    map[string]string `json:"envs"     validate:"-"`
+		Secrets map[string]string `json:"secrets"  validate:"-"`
+		CORS    cors.Update       `json:"cors"`
 	}
 
-	if err := fCtx.Bind().URI(&request); err != nil {
-		logger.Warnw(fCtx, "delete project, bad request", "error", err)
+	if err := fCtx.Bind().All(&request); err != nil {
+		logger.Warnw(fCtx, "update project config, bad request", "error", err)
 		return fiber.NewError(fiber.StatusBadRequest)
 	}
+	if request.CORS.Policy != nil {
+		if err := request.CORS.Policy.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid CORS policy: "+err.Error())
+		}
+	}
 
-	model, found, err := h.projectRepository.GetByID(fCtx, request.ID)
+	model, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ID, authz.PermissionProjectWrite,
+	)
 	if err != nil {
-		logger.Errorw(fCtx, "get user", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
+		return authz.Error(fCtx, err)
 	}
-	if !found || model.OwnerID != auth.MustUserFromContext(fCtx).ID {
-		return fiber.NewError(fiber.StatusNotFound)
+
+	config := project.Config{
+		Envs:    request.Envs,
+		Secrets: request.Secrets,
+		CORS:    request.CORS.Apply(model.Config.CORS),
 	}
 
-	ctx, err := h.tx.Begin(fCtx)
-	if err != nil {
-		logger.Errorw(fCtx, "begin transaction", "error", err)
+	if err = h.projectRepository.UpdateConfig(fCtx, request.ID, config); err != nil {
+		logger.Errorw(fCtx, "update project config", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
-	defer func() { _ = h.tx.Rollback(ctx) }()
 
-	actions, err := h.actionRepository.GetByProjectID(ctx, request.ID)
+	actions, err := h.actionRepository.GetByProjectID(fCtx, request.ID)
 	if err != nil {
-		logger.Errorw(ctx, "get project actions", "error", err)
+		logger.Errorw(fCtx, "get project actions", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
 	for _, actionModel := range actions {
-		if err = h.actionRepository.DeleteByID(ctx, actionModel.ID); err != nil {
-			logger.Errorw(ctx, "delete action", "error", err)
+		if err = h.actionCache.Remove(fCtx, actionModel.ID); err != nil {
+			logger.Errorw(fCtx, "remove action from cache", "id", actionModel.ID, "error", err)
 			return fiber.NewError(fiber.StatusInternalServerError)
 		}
+	}
 
-		if actionModel.ModulePath != "" {
-			if err = h.storage.Delete(ctx, h.cfg.ModuleBucket, actionModel.ModulePath); err != nil {
-				logger.Errorw(ctx, "delete action module", "error", err)
-				return fiber.NewError(fiber.StatusInternalServerError)
-			}
+	updated := *model
+	updated.Config = config
+	before, after := auditStates(model, &updated)
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationProjectConfig,
+		ProjectID: model.ID,
+		Before:    before,
+		After:     after,
+	})
 
-			if err = h.actionCache.Remove(fCtx, actionModel.ID); err != nil {
-				logger.Errorw(fCtx, "remove action from cache", "id", actionModel.ID, "error", err)
-				return fiber.NewError(fiber.StatusInternalServerError)
-			}
-		}
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) deleteHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ID id.ID `uri:"projectID" validate:"required"`
 	}
 
-	if err = h.projectRepository.DeleteByID(ctx, request.ID); err != nil {
-		logger.Errorw(ctx, "delete project", "error", err)
-		return fiber.NewError(fiber.StatusInternalServerError)
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "delete project, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
 	}
 
-	if err = h.tx.Commit(ctx); err != nil {
-		logger.Errorw(fCtx, "commit transaction", "error", err)
+	model, _, err := h.authz.Project(
+		fCtx, auth.MustUserFromContext(fCtx).ID, request.ID, authz.PermissionProjectDelete,
+	)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	if _, err = h.projectDeleter.Delete(fCtx, request.ID); err != nil {
+		logger.Errorw(fCtx, "delete project", "error", err)
 		return fiber.NewError(fiber.StatusInternalServerError)
 	}
 
+	before, after := auditStates(model, nil)
+	h.auditLog.Record(fCtx, audit.Entry{
+		Operation: audit.OperationProjectDelete,
+		ProjectID: model.ID,
+		Before:    before,
+		After:     after,
+	})
+
 	return fCtx.JSON(fiber.Map{"ok": true})
 }
@@ -0,0 +1,163 @@
+package project_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v3"
+
+	projectHandler "github.com/mymmrac/lithium/pkg/handler/project"
+	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/audit"
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/cors"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/server"
+	"github.com/mymmrac/lithium/pkg/module/session"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/token"
+	"github.com/mymmrac/lithium/pkg/module/user"
+	lithiumValidator "github.com/mymmrac/lithium/pkg/module/validator"
+)
+
+func TestUpdateConfig(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		userRepository := user.NewRepository(tx)
+		teamRepository := team.NewRepository(tx)
+		projectRepository := project.NewRepository(tx)
+		tokenRepository := token.NewRepository(tx)
+
+		va, err := lithiumValidator.NewValidate()
+		if err != nil {
+			t.Fatalf("new validate: %v", err)
+		}
+
+		app := fiber.New(fiber.Config{StructValidator: structValidator{v: va}})
+		app.Use(auth.NewAuth(auth.Config{
+			JWTSecret:       "secretsecretsecretsecret",
+			AccessTokenTTL:  time.Minute,
+			RefreshTokenTTL: time.Hour,
+		}, server.Config{}, tokenRepository, session.NewRepository(tx)).Middleware)
+
+		projectHandler.RegisterHandlers(
+			app, tx, userRepository, teamRepository, authz.NewAuthz(projectRepository, teamRepository),
+			projectRepository, action.NewCache(), action.NewRepository(tx), nil,
+			audit.NewLog(server.Config{}, audit.NewRepository(tx)), nil,
+		)
+
+		now := time.Now()
+		userModel := &user.Model{ID: id.New(), Email: "user@example.com", CreatedAt: now, UpdatedAt: now}
+		teamModel := &team.Model{ID: id.New(), Name: "Team", CreatedAt: now, UpdatedAt: now}
+		policy := &cors.Policy{AllowOrigins: []string{"https://example.com"}}
+		projectModel := &project.Model{
+			ID:        id.New(),
+			TeamID:    teamModel.ID,
+			OwnerID:   userModel.ID,
+			Name:      "Project",
+			SubDomain: "project",
+			Config:    project.Config{CORS: policy},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		value, prefix, hash := token.Generate()
+
+		if err = userRepository.Create(t.Context(), userModel); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err = teamRepository.Create(t.Context(), teamModel); err != nil {
+			t.Fatalf("create team: %v", err)
+		}
+		err = teamRepository.CreateMember(t.Context(), &team.Member{
+			TeamID: teamModel.ID, UserID: userModel.ID, Role: team.RoleOwner, CreatedAt: now,
+		})
+		if err != nil {
+			t.Fatalf("create member: %v", err)
+		}
+		if err = projectRepository.Create(t.Context(), projectModel); err != nil {
+			t.Fatalf("create project: %v", err)
+		}
+		err = tokenRepository.Create(t.Context(), &token.Model{
+			ID:        id.New(),
+			UserID:    userModel.ID,
+			Kind:      token.KindPersonal,
+			Name:      "Test",
+			Prefix:    prefix,
+			Hash:      hash,
+			Scopes:    []token.Scope{token.ScopeAdmin},
+			CreatedAt: now,
+		})
+		if err != nil {
+			t.Fatalf("create token: %v", err)
+		}
+
+		// update saves config and returns stored project
+		update := func(t *testing.T, body string, expectedStatus int) *project.Model {
+			t.Helper()
+
+			req := httptest.NewRequest(
+				fiber.MethodPut, "/api/project/"+projectModel.ID.String()+"/config", strings.NewReader(body),
+			)
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+value)
+
+			resp, err := app.Test(req, fiber.TestConfig{Timeout: 0})
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if resp.StatusCode != expectedStatus {
+				t.Fatalf("unexpected status: %d", resp.StatusCode)
+			}
+
+			model, _, err := projectRepository.GetByID(t.Context(), projectModel.ID)
+			if err != nil {
+				t.Fatalf("get project: %v", err)
+			}
+			return model
+		}
+
+		t.Run("keep policy", func(t *testing.T) {
+			model := update(t, `{"envs":{"KEY":"value"}}`, fiber.StatusOK)
+			if model.Config.Envs["KEY"] != "value" {
+				t.Errorf("envs not updated: %+v", model.Config)
+			}
+			if model.Config.CORS == nil || model.Config.CORS.AllowOrigins[0] != policy.AllowOrigins[0] {
+				t.Errorf("policy not kept: %+v", model.Config.CORS)
+			}
+		})
+
+		t.Run("reject invalid policy", func(t *testing.T) {
+			model := update(t, `{"cors":{"allowOrigins":["example.com"]}}`, fiber.StatusBadRequest)
+			if model.Config.CORS == nil || model.Config.CORS.AllowOrigins[0] != policy.AllowOrigins[0] {
+				t.Errorf("policy changed: %+v", model.Config.CORS)
+			}
+		})
+
+		t.Run("replace policy", func(t *testing.T) {
+			model := update(t, `{"cors":{"allowOrigins":["https://other.com"]}}`, fiber.StatusOK)
+			if model.Config.CORS == nil || model.Config.CORS.AllowOrigins[0] != "https://other.com" {
+				t.Errorf("policy not replaced: %+v", model.Config.CORS)
+			}
+		})
+
+		t.Run("remove policy", func(t *testing.T) {
+			if model := update(t, `{"cors":null}`, fiber.StatusOK); model.Config.CORS != nil {
+				t.Errorf("policy not removed: %+v", model.Config.CORS)
+			}
+		})
+	})
+}
+
+type structValidator struct {
+	v *validator.Validate
+}
+
+func (s structValidator) Validate(value any) error {
+	return s.v.Struct(value)
+}
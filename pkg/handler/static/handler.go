@@ -10,7 +10,9 @@ import (
 	"github.com/gofiber/fiber/v3/middleware/static"
 	"github.com/gofiber/template/html/v2"
 
+	authHandler "github.com/mymmrac/lithium/pkg/handler/auth"
 	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/oidc"
 )
 
 //go:embed views/*
@@ -31,13 +33,47 @@ func LoadViews() (Views, error) {
 	return views, nil
 }
 
-func RegisterHandlers(router fiber.Router) error {
+func RegisterHandlers(router fiber.Router, authCfg authHandler.Config, providers oidc.Providers) error {
+	type provider struct {
+		Name  string
+		Label string
+	}
+
+	loginProviders := make([]provider, 0, len(providers.List()))
+	for _, cfg := range providers.List() {
+		loginProviders = append(loginProviders, provider{Name: cfg.Name, Label: cfg.Label()})
+	}
+
 	router.Get("/", func(fCtx fiber.Ctx) error {
 		_, ok := auth.UserFromContext(fCtx)
 		if ok {
 			return fCtx.Redirect().To("/dashboard")
 		}
-		return fCtx.Render("index", nil, "layouts/main")
+
+		// Login with identity provider may continue with second factor
+		twoFactor := fCtx.Query("two-factor")
+		if twoFactor != "verify" && twoFactor != "setup" {
+			twoFactor = ""
+		}
+
+		return fCtx.Render("index", fiber.Map{
+			"Providers":           loginProviders,
+			"RegistrationEnabled": authCfg.RegistrationEnabled,
+			"OIDCError":           fCtx.Query("error") == "oidc",
+			"TwoFactor":           twoFactor,
+		}, "layouts/main")
+	})
+
+	router.Get("/verify-email", func(fCtx fiber.Ctx) error {
+		return fCtx.Render("verify-email", fiber.Map{
+			"Token": fCtx.Query("token"),
+		}, "layouts/main")
+	})
+
+	router.Get("/reset-password", func(fCtx fiber.Ctx) error {
+		return fCtx.Render("reset-password", fiber.Map{
+			"Token": fCtx.Query("token"),
+		}, "layouts/main")
 	})
 
 	router.Get("/dashboard", auth.RequireMiddleware, func(fCtx fiber.Ctx) error {
@@ -1,6 +1,8 @@
-package project
+package team
 
 import (
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
 
@@ -8,13 +10,14 @@ import (
 )
 
 type Config struct {
-	ModuleBucket string `validate:"required"`
+	// PublicURL is used to build links sent in invitation emails
+	PublicURL string `validate:"http_url"`
 }
 
 func init() { //nolint:gochecknoinits
 	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
 		cfg := Config{
-			ModuleBucket: v.GetString("module-bucket"),
+			PublicURL: strings.TrimSuffix(v.GetString("public-url"), "/"),
 		}
 		if err := va.Struct(cfg); err != nil {
 			return Config{}, err
@@ -0,0 +1,402 @@
+package team
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/mail"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/quota"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+type handler struct {
+	cfg               Config
+	tx                db.Transaction
+	authz             authz.Authz
+	mailer            mail.Mailer
+	teamRepository    team.Repository
+	userRepository    user.Repository
+	projectRepository project.Repository
+	quota             quota.Quota
+}
+
+func RegisterHandlers(
+	cfg Config, router fiber.Router, tx db.Transaction, authz authz.Authz, mailer mail.Mailer,
+	teamRepository team.Repository, userRepository user.Repository, projectRepository project.Repository,
+	quota quota.Quota,
+) {
+	h := &handler{
+		cfg:               cfg,
+		tx:                tx,
+		authz:             authz,
+		mailer:            mailer,
+		teamRepository:    teamRepository,
+		userRepository:    userRepository,
+		projectRepository: projectRepository,
+		quota:             quota,
+	}
+
+	// Membership can be managed only with session, API tokens are meant for projects
+	api := router.Group("/api/team", auth.RequireSessionMiddleware)
+
+	api.Get("/", h.getAllHandler)
+	api.Post("/", h.createHandler)
+	api.Get("/:teamID", h.getHandler)
+	api.Put("/:teamID", h.updateHandler)
+	api.Delete("/:teamID", h.deleteHandler)
+	api.Get("/:teamID/usage", h.getUsageHandler)
+	api.Put("/:teamID/member/:userID", h.updateMemberHandler)
+	api.Delete("/:teamID/member/:userID", h.deleteMemberHandler)
+	api.Post("/:teamID/invitation", h.inviteHandler)
+	api.Delete("/:teamID/invitation/:invitationID", h.revokeInvitationHandler)
+
+	invitationAPI := router.Group("/api/invitation", auth.RequireSessionMiddleware)
+
+	invitationAPI.Get("/", h.getInvitationsHandler)
+	invitationAPI.Post("/:invitationID/accept", h.acceptInvitationHandler)
+	invitationAPI.Delete("/:invitationID", h.declineInvitationHandler)
+}
+
+type teamInfo struct {
+	ID   id.ID     `json:"id"`
+	Name string    `json:"name"`
+	Role team.Role `json:"role"`
+}
+
+func (h *handler) getAllHandler(fCtx fiber.Ctx) error {
+	members, err := h.teamRepository.GetMembersByUserID(fCtx, auth.MustUserFromContext(fCtx).ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get team members", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	response := make([]teamInfo, len(members))
+	for i, member := range members {
+		response[i] = teamInfo{
+			ID:   member.TeamID,
+			Name: member.Team.Name,
+			Role: member.Role,
+		}
+	}
+
+	return fCtx.JSON(response)
+}
+
+func (h *handler) getHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ID id.ID `uri:"teamID" validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "get team, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	member, err := h.authz.Team(fCtx, auth.MustUserFromContext(fCtx).ID, request.ID, authz.PermissionProjectRead)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	model, found, err := h.teamRepository.GetByID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get team", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	members, err := h.teamRepository.GetMembersByTeamID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get team members", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	type memberInfo struct {
+		UserID    id.ID     `json:"userId"`
+		Email     string    `json:"email"`
+		Role      team.Role `json:"role"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+
+	type teamDetails struct {
+		teamInfo
+
+		Members     []memberInfo     `json:"members"`
+		Invitations []invitationInfo `json:"invitations,omitempty"`
+	}
+
+	response := teamDetails{
+		teamInfo: teamInfo{
+			ID:   model.ID,
+			Name: model.Name,
+			Role: member.Role,
+		},
+		Members: make([]memberInfo, len(members)),
+	}
+	for i, teamMember := range members {
+		response.Members[i] = memberInfo{
+			UserID:    teamMember.UserID,
+			Email:     teamMember.User.Email,
+			Role:      teamMember.Role,
+			CreatedAt: teamMember.CreatedAt,
+		}
+	}
+
+	// Pending invitations reveal emails of people outside the team, so only members who manage it can see them
+	if authz.Allows(member.Role, authz.PermissionTeamManage) {
+		invitations, err := h.teamRepository.GetInvitationsByTeamID(fCtx, request.ID)
+		if err != nil {
+			logger.Errorw(fCtx, "get team invitations", "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError)
+		}
+
+		response.Invitations = make([]invitationInfo, len(invitations))
+		for i := range invitations {
+			response.Invitations[i] = newInvitationInfo(&invitations[i])
+		}
+	}
+
+	return fCtx.JSON(&response)
+}
+
+func (h *handler) createHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		Name string `json:"name" validate:"alphanum_text,min=1,max=64"`
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		logger.Warnw(fCtx, "create team, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	ctx, err := h.tx.Begin(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "begin transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	now := time.Now()
+	model := &team.Model{
+		ID:        id.New(),
+		Name:      strings.TrimSpace(request.Name),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err = h.teamRepository.Create(ctx, model); err != nil {
+		logger.Errorw(fCtx, "create team", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	err = h.teamRepository.CreateMember(ctx, &team.Member{
+		TeamID:    model.ID,
+		UserID:    auth.MustUserFromContext(fCtx).ID,
+		Role:      team.RoleOwner,
+		CreatedAt: now,
+	})
+	if err != nil {
+		logger.Errorw(fCtx, "create team member", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.tx.Commit(ctx); err != nil {
+		logger.Errorw(fCtx, "commit transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true, "id": model.ID})
+}
+
+func (h *handler) updateHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ID   id.ID  `uri:"teamID" validate:"required"`
+		Name string `json:"name"  validate:"alphanum_text,min=1,max=64"`
+	}
+
+	if err := fCtx.Bind().All(&request); err != nil {
+		logger.Warnw(fCtx, "update team, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, err := h.authz.Team(fCtx, auth.MustUserFromContext(fCtx).ID, request.ID, authz.PermissionTeamManage)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	if err = h.teamRepository.UpdateName(fCtx, request.ID, strings.TrimSpace(request.Name)); err != nil {
+		logger.Errorw(fCtx, "update team", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) deleteHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ID id.ID `uri:"teamID" validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "delete team, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, err := h.authz.Team(fCtx, auth.MustUserFromContext(fCtx).ID, request.ID, authz.PermissionTeamDelete)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	projects, err := h.projectRepository.CountByTeamID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "count team projects", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if projects > 0 {
+		return fiber.NewError(fiber.StatusConflict, "Delete or move projects of the team first")
+	}
+
+	ctx, err := h.tx.Begin(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "begin transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	if err = h.teamRepository.DeleteInvitationsByTeamID(ctx, request.ID); err != nil {
+		logger.Errorw(fCtx, "delete team invitations", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.teamRepository.DeleteMembersByTeamID(ctx, request.ID); err != nil {
+		logger.Errorw(fCtx, "delete team members", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.teamRepository.DeleteByID(ctx, request.ID); err != nil {
+		logger.Errorw(fCtx, "delete team", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.tx.Commit(ctx); err != nil {
+		logger.Errorw(fCtx, "commit transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) updateMemberHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		TeamID id.ID     `uri:"teamID" validate:"required"`
+		UserID id.ID     `uri:"userID" validate:"required"`
+		Role   team.Role `json:"role"  validate:"oneof=owner admin developer viewer"`
+	}
+
+	if err := fCtx.Bind().All(&request); err != nil {
+		logger.Warnw(fCtx, "update team member, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	member, err := h.authz.Team(fCtx, auth.MustUserFromContext(fCtx).ID, request.TeamID, authz.PermissionTeamManage)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	target, found, err := h.teamRepository.GetMember(fCtx, request.TeamID, request.UserID)
+	if err != nil {
+		logger.Errorw(fCtx, "get team member", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	if !authz.CanAssign(member.Role, target.Role) || !authz.CanAssign(member.Role, request.Role) {
+		return fiber.NewError(fiber.StatusForbidden)
+	}
+
+	if target.Role == team.RoleOwner && request.Role != team.RoleOwner {
+		if err = h.requireAnotherOwner(fCtx, request.TeamID); err != nil {
+			return err
+		}
+	}
+
+	if err = h.teamRepository.UpdateMemberRole(fCtx, request.TeamID, request.UserID, request.Role); err != nil {
+		logger.Errorw(fCtx, "update team member", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) deleteMemberHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		TeamID id.ID `uri:"teamID" validate:"required"`
+		UserID id.ID `uri:"userID" validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "delete team member, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	// Any member can leave the team, removing others requires permission to manage their role
+	userID := auth.MustUserFromContext(fCtx).ID
+	member, err := h.authz.Team(fCtx, userID, request.TeamID, authz.PermissionProjectRead)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	target := member
+	if request.UserID != userID {
+		var found bool
+		target, found, err = h.teamRepository.GetMember(fCtx, request.TeamID, request.UserID)
+		if err != nil {
+			logger.Errorw(fCtx, "get team member", "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError)
+		}
+		if !found {
+			return fiber.NewError(fiber.StatusNotFound)
+		}
+
+		if !authz.CanAssign(member.Role, target.Role) {
+			return fiber.NewError(fiber.StatusForbidden)
+		}
+	}
+
+	if target.Role == team.RoleOwner {
+		if err = h.requireAnotherOwner(fCtx, request.TeamID); err != nil {
+			return err
+		}
+	}
+
+	if err = h.teamRepository.DeleteMember(fCtx, request.TeamID, request.UserID); err != nil {
+		logger.Errorw(fCtx, "delete team member", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+// requireAnotherOwner returns error if team has only one owner, so team is never left without owner
+func (h *handler) requireAnotherOwner(fCtx fiber.Ctx, teamID id.ID) error {
+	owners, err := h.teamRepository.CountMembersByRole(fCtx, teamID, team.RoleOwner)
+	if err != nil {
+		logger.Errorw(fCtx, "count team owners", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if owners <= 1 {
+		return fiber.NewError(fiber.StatusConflict, "Team must have at least one owner")
+	}
+	return nil
+}
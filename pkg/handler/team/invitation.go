@@ -0,0 +1,284 @@
+package team
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/mail"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+type invitationInfo struct {
+	ID        id.ID     `json:"id"`
+	TeamID    id.ID     `json:"teamId"`
+	TeamName  string    `json:"teamName,omitempty"`
+	Email     string    `json:"email"`
+	Role      team.Role `json:"role"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newInvitationInfo(model *team.Invitation) invitationInfo {
+	info := invitationInfo{
+		ID:        model.ID,
+		TeamID:    model.TeamID,
+		Email:     model.Email,
+		Role:      model.Role,
+		ExpiresAt: model.ExpiresAt,
+		CreatedAt: model.CreatedAt,
+	}
+	if model.Team != nil {
+		info.TeamName = model.Team.Name
+	}
+	return info
+}
+
+func (h *handler) inviteHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		TeamID id.ID     `uri:"teamID" validate:"required"`
+		Email  string    `json:"email" validate:"email,max=256"`
+		Role   team.Role `json:"role"  validate:"oneof=owner admin developer viewer"`
+	}
+
+	if err := fCtx.Bind().All(&request); err != nil {
+		logger.Warnw(fCtx, "invite team member, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	userID := auth.MustUserFromContext(fCtx).ID
+	member, err := h.authz.Team(fCtx, userID, request.TeamID, authz.PermissionTeamManage)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+	if !authz.CanAssign(member.Role, request.Role) {
+		return fiber.NewError(fiber.StatusForbidden)
+	}
+
+	teamModel, found, err := h.teamRepository.GetByID(fCtx, request.TeamID)
+	if err != nil {
+		logger.Errorw(fCtx, "get team", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	request.Email = strings.ToLower(strings.TrimSpace(request.Email))
+
+	invitee, found, err := h.userRepository.GetByEmail(fCtx, request.Email)
+	if err != nil {
+		logger.Errorw(fCtx, "get user", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if found {
+		_, isMember, err := h.teamRepository.GetMember(fCtx, request.TeamID, invitee.ID)
+		if err != nil {
+			logger.Errorw(fCtx, "get team member", "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError)
+		}
+		if isMember {
+			return fiber.NewError(fiber.StatusConflict, "User is already a member of the team")
+		}
+	}
+
+	now := time.Now()
+	invitation := &team.Invitation{
+		ID:        id.New(),
+		TeamID:    request.TeamID,
+		Email:     request.Email,
+		Role:      request.Role,
+		InvitedBy: userID,
+		ExpiresAt: now.Add(team.InvitationTTL),
+		CreatedAt: now,
+	}
+	if err = h.teamRepository.UpsertInvitation(fCtx, invitation); err != nil {
+		logger.Errorw(fCtx, "create team invitation", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.sendInvitationEmail(fCtx, teamModel, invitation); err != nil {
+		logger.Errorw(fCtx, "send invitation email", "team-id", request.TeamID, "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) sendInvitationEmail(ctx context.Context, teamModel *team.Model, invitation *team.Invitation) error {
+	return h.mailer.Send(ctx, mail.Message{
+		To:      invitation.Email,
+		Subject: "You are invited to join " + teamModel.Name,
+		Body: fmt.Sprintf("You are invited to join team %q on Lithium as %s.\n\n"+
+			"Log in or register with this email to accept the invitation:\n%s\n\n"+
+			"The invitation is valid for %s. If you don't want to join, ignore this email.\n",
+			teamModel.Name, invitation.Role, h.cfg.PublicURL+"/dashboard", team.InvitationTTL),
+	})
+}
+
+func (h *handler) revokeInvitationHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		TeamID id.ID `uri:"teamID"       validate:"required"`
+		ID     id.ID `uri:"invitationID" validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "revoke team invitation, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	member, err := h.authz.Team(fCtx, auth.MustUserFromContext(fCtx).ID, request.TeamID, authz.PermissionTeamManage)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	invitation, found, err := h.teamRepository.GetInvitationByID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get team invitation", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found || invitation.TeamID != request.TeamID {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+	if !authz.CanAssign(member.Role, invitation.Role) {
+		return fiber.NewError(fiber.StatusForbidden)
+	}
+
+	if err = h.teamRepository.DeleteInvitationByID(fCtx, request.ID); err != nil {
+		logger.Errorw(fCtx, "delete team invitation", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+func (h *handler) getInvitationsHandler(fCtx fiber.Ctx) error {
+	userModel, found, err := h.userRepository.GetByID(fCtx, auth.MustUserFromContext(fCtx).ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get user", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	invitations, err := h.teamRepository.GetInvitationsByEmail(fCtx, strings.ToLower(userModel.Email))
+	if err != nil {
+		logger.Errorw(fCtx, "get invitations", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	now := time.Now()
+	response := make([]invitationInfo, 0, len(invitations))
+	for i := range invitations {
+		if !invitations[i].Expired(now) {
+			response = append(response, newInvitationInfo(&invitations[i]))
+		}
+	}
+
+	return fCtx.JSON(response)
+}
+
+func (h *handler) acceptInvitationHandler(fCtx fiber.Ctx) error {
+	userModel, invitation, err := h.findInvitation(fCtx)
+	if err != nil {
+		return err
+	}
+
+	// Anyone can register with any email, so invitation is accepted only by proven owner of the email
+	if !userModel.EmailVerified() {
+		return fiber.NewError(fiber.StatusForbidden, "Verify your email to accept invitation")
+	}
+
+	_, isMember, err := h.teamRepository.GetMember(fCtx, invitation.TeamID, userModel.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get team member", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	ctx, err := h.tx.Begin(fCtx)
+	if err != nil {
+		logger.Errorw(fCtx, "begin transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	defer func() { _ = h.tx.Rollback(ctx) }()
+
+	if !isMember {
+		err = h.teamRepository.CreateMember(ctx, &team.Member{
+			TeamID:    invitation.TeamID,
+			UserID:    userModel.ID,
+			Role:      invitation.Role,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			logger.Errorw(fCtx, "create team member", "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError)
+		}
+	}
+
+	if err = h.teamRepository.DeleteInvitationByID(ctx, invitation.ID); err != nil {
+		logger.Errorw(fCtx, "delete team invitation", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if err = h.tx.Commit(ctx); err != nil {
+		logger.Errorw(fCtx, "commit transaction", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true, "teamId": invitation.TeamID})
+}
+
+func (h *handler) declineInvitationHandler(fCtx fiber.Ctx) error {
+	_, invitation, err := h.findInvitation(fCtx)
+	if err != nil {
+		return err
+	}
+
+	if err = h.teamRepository.DeleteInvitationByID(fCtx, invitation.ID); err != nil {
+		logger.Errorw(fCtx, "delete team invitation", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
+
+// findInvitation returns current user and pending invitation sent to email of the user
+func (h *handler) findInvitation(fCtx fiber.Ctx) (*user.Model, *team.Invitation, error) {
+	var request struct {
+		ID id.ID `uri:"invitationID" validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "find invitation, bad request", "error", err)
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	userModel, found, err := h.userRepository.GetByID(fCtx, auth.MustUserFromContext(fCtx).ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get user", "error", err)
+		return nil, nil, fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound)
+	}
+
+	invitation, found, err := h.teamRepository.GetInvitationByID(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get team invitation", "error", err)
+		return nil, nil, fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !found || invitation.Email != strings.ToLower(userModel.Email) || invitation.Expired(time.Now()) {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound)
+	}
+
+	return userModel, invitation, nil
+}
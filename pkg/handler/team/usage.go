@@ -0,0 +1,80 @@
+package team
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+)
+
+// limitInfo is consumption of a resource, max is absent if resource is unlimited
+type limitInfo struct {
+	Used int64 `json:"used"`
+	Max  int64 `json:"max,omitempty"`
+}
+
+func (h *handler) getUsageHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ID id.ID `uri:"teamID" validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "get team usage, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	_, err := h.authz.Team(fCtx, auth.MustUserFromContext(fCtx).ID, request.ID, authz.PermissionProjectRead)
+	if err != nil {
+		return authz.Error(fCtx, err)
+	}
+
+	usage, err := h.quota.Usage(fCtx, request.ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get team usage", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	type projectUsageInfo struct {
+		ID                   id.ID     `json:"id"`
+		Name                 string    `json:"name"`
+		Actions              limitInfo `json:"actions"`
+		Storage              int64     `json:"storage"`
+		ConcurrentExecutions limitInfo `json:"concurrentExecutions"`
+	}
+
+	type usageInfo struct {
+		Plan               string             `json:"plan,omitempty"`
+		Period             string             `json:"period"`
+		Projects           limitInfo          `json:"projects"`
+		Storage            limitInfo          `json:"storage"`
+		MonthlyInvocations limitInfo          `json:"monthlyInvocations"`
+		MaxModuleSize      int64              `json:"maxModuleSize,omitempty"`
+		ProjectUsages      []projectUsageInfo `json:"projectUsages"`
+	}
+
+	plan := usage.Plan
+	response := usageInfo{
+		Plan:               plan.Name,
+		Period:             usage.Period,
+		Projects:           limitInfo{Used: usage.Projects, Max: plan.MaxProjects},
+		Storage:            limitInfo{Used: usage.Storage, Max: plan.MaxStorage},
+		MonthlyInvocations: limitInfo{Used: usage.MonthlyInvocations, Max: plan.MaxMonthlyInvocations},
+		MaxModuleSize:      plan.MaxModuleSize,
+		ProjectUsages:      make([]projectUsageInfo, len(usage.ProjectUsages)),
+	}
+	for i, projectUsage := range usage.ProjectUsages {
+		response.ProjectUsages[i] = projectUsageInfo{
+			ID:      projectUsage.ProjectID,
+			Name:    projectUsage.Name,
+			Actions: limitInfo{Used: projectUsage.Actions, Max: plan.MaxActionsPerProject},
+			Storage: projectUsage.Storage,
+			ConcurrentExecutions: limitInfo{
+				Used: projectUsage.ConcurrentExecutions, Max: plan.MaxConcurrentExecutions,
+			},
+		}
+	}
+
+	return fCtx.JSON(&response)
+}
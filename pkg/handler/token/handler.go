@@ -0,0 +1,167 @@
+package token
+
+import (
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/token"
+)
+
+type handler struct {
+	tokenRepository token.Repository
+	authz           authz.Authz
+}
+
+func RegisterHandlers(router fiber.Router, tokenRepository token.Repository, authz authz.Authz) {
+	h := &handler{
+		tokenRepository: tokenRepository,
+		authz:           authz,
+	}
+
+	// Tokens can be managed only with session, so leaked token can't be used to issue new ones
+	api := router.Group("/api/token", auth.RequireSessionMiddleware)
+
+	api.Get("/", h.getAllHandler)
+	api.Post("/", h.createHandler)
+	api.Delete("/:tokenID", h.deleteHandler)
+}
+
+type tokenInfo struct {
+	ID         id.ID         `json:"id"`
+	Name       string        `json:"name"`
+	Kind       token.Kind    `json:"kind"`
+	Prefix     string        `json:"prefix"`
+	Scopes     []token.Scope `json:"scopes"`
+	ProjectID  id.ID         `json:"projectId,omitzero"`
+	ExpiresAt  time.Time     `json:"expiresAt,omitzero"`
+	LastUsedAt time.Time     `json:"lastUsedAt,omitzero"`
+	CreatedAt  time.Time     `json:"createdAt"`
+}
+
+func newTokenInfo(model *token.Model) tokenInfo {
+	return tokenInfo{
+		ID:         model.ID,
+		Name:       model.Name,
+		Kind:       model.Kind,
+		Prefix:     model.Prefix,
+		Scopes:     model.Scopes,
+		ProjectID:  model.ProjectID,
+		ExpiresAt:  model.ExpiresAt,
+		LastUsedAt: model.LastUsedAt,
+		CreatedAt:  model.CreatedAt,
+	}
+}
+
+func (h *handler) getAllHandler(fCtx fiber.Ctx) error {
+	models, err := h.tokenRepository.GetByUserID(fCtx, auth.MustUserFromContext(fCtx).ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get tokens", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	response := make([]tokenInfo, len(models))
+	for i := range models {
+		response[i] = newTokenInfo(&models[i])
+	}
+
+	return fCtx.JSON(response)
+}
+
+func (h *handler) createHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		Name      string        `json:"name"      validate:"alphanum_text,min=1,max=64"`
+		Kind      token.Kind    `json:"kind"      validate:"omitempty,oneof=personal service"`
+		Scopes    []token.Scope `json:"scopes"    validate:"min=1,unique,dive,oneof=read deploy admin"`
+		ProjectID id.ID         `json:"projectId" validate:"required_if=Kind service"`
+		ExpiresAt time.Time     `json:"expiresAt" validate:"-"`
+	}
+
+	if err := fCtx.Bind().Body(&request); err != nil {
+		logger.Warnw(fCtx, "create token, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	now := time.Now()
+	if !request.ExpiresAt.IsZero() && !request.ExpiresAt.After(now) {
+		return fiber.NewError(fiber.StatusBadRequest, "Expiry must be in the future")
+	}
+
+	if request.Kind == "" {
+		request.Kind = token.KindPersonal
+	}
+
+	authUser := auth.MustUserFromContext(fCtx)
+	if request.ProjectID != 0 {
+		// Tokens act on behalf of the user, so role in the team still applies, service tokens are part of
+		// project settings
+		permission := authz.PermissionProjectRead
+		if request.Kind == token.KindService {
+			permission = authz.PermissionProjectWrite
+		}
+
+		if _, _, err := h.authz.Project(fCtx, authUser.ID, request.ProjectID, permission); err != nil {
+			return authz.Error(fCtx, err)
+		}
+	}
+
+	value, prefix, hash := token.Generate()
+	model := &token.Model{
+		ID:        id.New(),
+		UserID:    authUser.ID,
+		ProjectID: request.ProjectID,
+		Kind:      request.Kind,
+		Name:      request.Name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    request.Scopes,
+		ExpiresAt: request.ExpiresAt,
+		CreatedAt: now,
+	}
+	if err := h.tokenRepository.Create(fCtx, model); err != nil {
+		logger.Errorw(fCtx, "create token", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	// Token value is returned only once, it can't be recovered later
+	return fCtx.JSON(struct {
+		tokenInfo
+
+		Token string `json:"token"`
+	}{
+		tokenInfo: newTokenInfo(model),
+		Token:     value,
+	})
+}
+
+func (h *handler) deleteHandler(fCtx fiber.Ctx) error {
+	var request struct {
+		ID id.ID `uri:"tokenID" validate:"required"`
+	}
+
+	if err := fCtx.Bind().URI(&request); err != nil {
+		logger.Warnw(fCtx, "delete token, bad request", "error", err)
+		return fiber.NewError(fiber.StatusBadRequest)
+	}
+
+	models, err := h.tokenRepository.GetByUserID(fCtx, auth.MustUserFromContext(fCtx).ID)
+	if err != nil {
+		logger.Errorw(fCtx, "get tokens", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+	if !slices.ContainsFunc(models, func(model token.Model) bool { return model.ID == request.ID }) {
+		return fiber.NewError(fiber.StatusNotFound)
+	}
+
+	if err = h.tokenRepository.DeleteByID(fCtx, request.ID); err != nil {
+		logger.Errorw(fCtx, "delete token", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	return fCtx.JSON(fiber.Map{"ok": true})
+}
@@ -0,0 +1,57 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/mymmrac/wape"
+
+	"github.com/mymmrac/lithium/pkg/module/project"
+)
+
+// Compile compiles module data into a ready-to-instantiate module using project and action configuration.
+func Compile(ctx context.Context, projectModel *project.Model, model *Model, moduleData []byte) (Module, error) {
+	env := wape.NewEnvironment()
+	env.Modules = []wape.ModuleData{
+		{
+			Name: "main",
+			Data: moduleData,
+		},
+	}
+
+	env.EnvsMap = EffectiveEnvsMap(projectModel.Config, model.Config)
+	env.Args = slices.Clone(model.Config.Args)
+
+	env.NetworkEnabled = model.Config.Network
+
+	env.NetworksAllowAll = true
+	env.NetworkAddressesAllowAll = true
+
+	env.WallTimeFromHost = true
+	env.NanoTimeFromHost = true
+	env.NanoSleepFromHost = true
+
+	env.RandSourceFromHost = true
+
+	if model.Config.Network {
+		const pluginCADir = "/certs"
+		const caFile = "/etc/ssl/certs/ca-certificates.crt"
+		env.EnvsMap["LITHIUM_CA_CERT_FILE"] = path.Join(pluginCADir, filepath.Base(caFile))
+		env.FSAllowedPaths = map[string]string{
+			"ro:" + filepath.Dir(caFile): pluginCADir,
+		}
+	}
+
+	compiledPlugin, err := wape.NewCompiledPlugin(ctx, env)
+	if err != nil {
+		return Module{}, fmt.Errorf("compile plugin: %w", err)
+	}
+
+	return Module{
+		CompiledPlugin:       compiledPlugin,
+		PluginInstanceConfig: env.MakePluginInstanceConfig(),
+	}, nil
+}
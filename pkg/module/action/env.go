@@ -0,0 +1,45 @@
+package action
+
+import (
+	"github.com/mymmrac/lithium/pkg/module/project"
+)
+
+// Env represents an effective environment variable of the module.
+type Env struct {
+	Value     string `json:"value"`
+	Secret    bool   `json:"secret"`
+	Inherited bool   `json:"inherited"`
+}
+
+// EffectiveEnvs merges project-level and action-level environment variables and secrets, action-level values
+// override project-level ones.
+func EffectiveEnvs(projectConfig project.Config, config ModuleConfig) map[string]Env {
+	envs := make(map[string]Env,
+		len(projectConfig.Envs)+len(projectConfig.Secrets)+len(config.Envs)+len(config.Secrets),
+	)
+
+	for key, value := range projectConfig.Envs {
+		envs[key] = Env{Value: value, Secret: false, Inherited: true}
+	}
+	for key, value := range projectConfig.Secrets {
+		envs[key] = Env{Value: value, Secret: true, Inherited: true}
+	}
+	for key, value := range config.Envs {
+		envs[key] = Env{Value: value, Secret: false, Inherited: false}
+	}
+	for key, value := range config.Secrets {
+		envs[key] = Env{Value: value, Secret: true, Inherited: false}
+	}
+
+	return envs
+}
+
+// EffectiveEnvsMap returns values of effective environment variables of the module.
+func EffectiveEnvsMap(projectConfig project.Config, config ModuleConfig) map[string]string {
+	envs := EffectiveEnvs(projectConfig, config)
+	values := make(map[string]string, len(envs))
+	for key, env := range envs {
+		values[key] = env.Value
+	}
+	return values
+}
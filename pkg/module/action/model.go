@@ -5,26 +5,50 @@ import (
 
 	"github.com/uptrace/bun"
 
+	"github.com/mymmrac/lithium/pkg/module/cors"
 	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/wasm"
 )
 
 type Model struct {
 	bun.BaseModel `bun:"table:action"`
 
-	ID         id.ID        `bun:"id,pk"`
-	ProjectID  id.ID        `bun:"project_id"`
-	Name       string       `bun:"name"`
-	Path       string       `bun:"path"`
-	Methods    []string     `bun:"methods,array"`
-	Order      int          `bun:"order"`
-	ModulePath string       `bun:"module_path"`
-	Config     ModuleConfig `bun:"config,type:jsonb"`
-	CreatedAt  time.Time    `bun:"created_at"`
-	UpdatedAt  time.Time    `bun:"updated_at"`
+	ID           id.ID        `bun:"id,pk"`
+	ProjectID    id.ID        `bun:"project_id"`
+	Name         string       `bun:"name"`
+	Path         string       `bun:"path"`
+	Methods      []string     `bun:"methods,array"`
+	Order        int          `bun:"order"`
+	ModulePath   string       `bun:"module_path"`
+	ModuleSize   int64        `bun:"module_size"`
+	ModuleReport *wasm.Report `bun:"module_report,type:jsonb"`
+	Config       ModuleConfig `bun:"config,type:jsonb"`
+	CreatedAt    time.Time    `bun:"created_at"`
+	UpdatedAt    time.Time    `bun:"updated_at"`
 }
 
 type ModuleConfig struct {
-	Envs    map[string]string `json:"envs,omitempty"`
-	Args    []string          `json:"args,omitempty"`
-	Network bool              `json:"network,omitempty"`
+	Envs          map[string]string `json:"envs,omitempty"`
+	Secrets       map[string]string `json:"secrets,omitempty"`
+	Args          []string          `json:"args,omitempty"`
+	Network       bool              `json:"network,omitempty"`
+	MaxModuleSize int64             `json:"maxModuleSize,omitempty"`
+	CORS          *cors.Policy      `json:"cors,omitempty"`
+}
+
+// ModuleSizeLimit returns maximum module size in bytes, action-level limit can only lower the global one.
+func (c ModuleConfig) ModuleSizeLimit(globalLimit int64) int64 {
+	if c.MaxModuleSize > 0 && c.MaxModuleSize < globalLimit {
+		return c.MaxModuleSize
+	}
+	return globalLimit
+}
+
+// EffectiveCORS returns CORS policy of action, action-level policy replaces project-level one.
+func EffectiveCORS(projectConfig project.Config, config ModuleConfig) *cors.Policy {
+	if config.CORS != nil {
+		return config.CORS
+	}
+	return projectConfig.CORS
 }
@@ -6,10 +6,11 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun"
 
 	"github.com/mymmrac/lithium/pkg/module/db"
 	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/wasm"
 )
 
 type Repository interface {
@@ -19,8 +20,11 @@ type Repository interface {
 	GetByProjectID(ctx context.Context, projectID id.ID) ([]Model, error)
 	DeleteByID(ctx context.Context, id id.ID) error
 	CountByProjectID(ctx context.Context, projectID id.ID) (int, error)
+	SumModuleSizeByProjectIDs(ctx context.Context, projectIDs []id.ID) (int64, error)
 	UpdateOrder(ctx context.Context, ids []id.ID) error
-	UpdateModulePath(ctx context.Context, id id.ID, modulePath string) error
+	UpdateModule(
+		ctx context.Context, id id.ID, previousModulePath, modulePath string, moduleSize int64, report *wasm.Report,
+	) (bool, error)
 	UpdateConfig(ctx context.Context, id id.ID, config ModuleConfig) error
 }
 
@@ -43,12 +47,11 @@ func (r *repository) Create(ctx context.Context, model *Model) error {
 }
 
 func (r *repository) UpdateInfo(ctx context.Context, id id.ID, name, path string, methods []string) error {
+	// Model columns are used instead of raw values, so methods are encoded according to the database dialect
 	_, err := r.tx.Extract(ctx).NewUpdate().
-		Model((*Model)(nil)).
-		Set("name = ?", name).
-		Set("path = ?", path).
-		Set("methods = ?", pgdialect.Array(methods)).
-		Where("id = ?", id).
+		Model(&Model{ID: id, Name: name, Path: path, Methods: methods}).
+		Column("name", "path", "methods").
+		WherePK().
 		Exec(ctx)
 	if err != nil {
 		return err
@@ -102,6 +105,24 @@ func (r *repository) CountByProjectID(ctx context.Context, projectID id.ID) (int
 	return count, nil
 }
 
+// SumModuleSizeByProjectIDs returns total size of active modules of actions of projects.
+func (r *repository) SumModuleSizeByProjectIDs(ctx context.Context, projectIDs []id.ID) (int64, error) {
+	if len(projectIDs) == 0 {
+		return 0, nil
+	}
+
+	var size int64
+	err := r.tx.Extract(ctx).NewSelect().
+		Model((*Model)(nil)).
+		ColumnExpr("COALESCE(SUM(module_size), 0)").
+		Where("project_id IN (?)", bun.In(projectIDs)).
+		Scan(ctx, &size)
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
 func (r *repository) UpdateOrder(ctx context.Context, ids []id.ID) error {
 	ctx, err := r.tx.Begin(ctx)
 	if err != nil {
@@ -128,16 +149,27 @@ func (r *repository) UpdateOrder(ctx context.Context, ids []id.ID) error {
 	return nil
 }
 
-func (r *repository) UpdateModulePath(ctx context.Context, id id.ID, modulePath string) error {
-	_, err := r.tx.Extract(ctx).NewUpdate().
+// UpdateModule replaces module of action, reports false if module path was changed from previousModulePath.
+func (r *repository) UpdateModule(
+	ctx context.Context, id id.ID, previousModulePath, modulePath string, moduleSize int64, report *wasm.Report,
+) (bool, error) {
+	result, err := r.tx.Extract(ctx).NewUpdate().
 		Model((*Model)(nil)).
 		Set("module_path = ?", modulePath).
+		Set("module_size = ?", moduleSize).
+		Set("module_report = ?", report).
 		Where("id = ?", id).
+		Where("module_path = ?", previousModulePath).
 		Exec(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
-	return nil
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
 }
 
 func (r *repository) UpdateConfig(ctx context.Context, id id.ID, config ModuleConfig) error {
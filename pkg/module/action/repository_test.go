@@ -0,0 +1,149 @@
+package action_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/user"
+	"github.com/mymmrac/lithium/pkg/module/wasm"
+)
+
+func TestRepository(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		projectID := createProject(t, tx)
+		repository := action.NewRepository(tx)
+
+		now := time.Now()
+		var ids []id.ID
+		for i, name := range []string{"first", "second", "third"} {
+			model := &action.Model{
+				ID:        id.New(),
+				ProjectID: projectID,
+				Name:      name,
+				Path:      "/" + name,
+				Methods:   []string{"GET"},
+				Order:     i,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if err := repository.Create(t.Context(), model); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			ids = append(ids, model.ID)
+		}
+
+		count, err := repository.CountByProjectID(t.Context(), projectID)
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		if count != 3 {
+			t.Errorf("unexpected count: %d", count)
+		}
+
+		methods := []string{"GET", "POST", "DELETE"}
+		if err = repository.UpdateInfo(t.Context(), ids[0], "renamed", "/renamed/:id", methods); err != nil {
+			t.Fatalf("update info: %v", err)
+		}
+
+		config := action.ModuleConfig{
+			Envs:    map[string]string{"A": "1"},
+			Args:    []string{"--flag"},
+			Network: true,
+		}
+		if err = repository.UpdateConfig(t.Context(), ids[0], config); err != nil {
+			t.Fatalf("update config: %v", err)
+		}
+
+		report := &wasm.Report{Size: 42, Exports: []wasm.Export{{Name: "handler", Kind: wasm.KindFunction}}}
+		updated, err := repository.UpdateModule(t.Context(), ids[0], "", "module.wasm", 1024, report)
+		if err != nil || !updated {
+			t.Fatalf("update module: updated: %t, error: %v", updated, err)
+		}
+		if updated, err = repository.UpdateModule(t.Context(), ids[0], "", "other.wasm", 1024, report); err != nil || updated {
+			t.Fatalf("update changed module: updated: %t, error: %v", updated, err)
+		}
+
+		got, found, err := repository.GetByID(t.Context(), ids[0])
+		if err != nil || !found {
+			t.Fatalf("get by id: found: %t, error: %v", found, err)
+		}
+		if got.Name != "renamed" || got.Path != "/renamed/:id" || !slices.Equal(got.Methods, methods) {
+			t.Errorf("unexpected info: %+v", got)
+		}
+		if got.Config.Envs["A"] != "1" || !slices.Equal(got.Config.Args, config.Args) || !got.Config.Network {
+			t.Errorf("unexpected config: %+v", got.Config)
+		}
+		if got.ModulePath != "module.wasm" || got.ModuleReport == nil || !got.ModuleReport.HasExport("handler", wasm.KindFunction) {
+			t.Errorf("unexpected module: %q, %+v", got.ModulePath, got.ModuleReport)
+		}
+
+		if err = repository.UpdateOrder(t.Context(), []id.ID{ids[2], ids[0], ids[1]}); err != nil {
+			t.Fatalf("update order: %v", err)
+		}
+
+		models, err := repository.GetByProjectID(t.Context(), projectID)
+		if err != nil {
+			t.Fatalf("get by project: %v", err)
+		}
+		var order []id.ID
+		for _, model := range models {
+			order = append(order, model.ID)
+		}
+		if !slices.Equal(order, []id.ID{ids[2], ids[0], ids[1]}) {
+			t.Errorf("unexpected order: %v", order)
+		}
+
+		if err = repository.DeleteByID(t.Context(), ids[1]); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, found, err = repository.GetByID(t.Context(), ids[1]); err != nil || found {
+			t.Fatalf("get deleted: found: %t, error: %v", found, err)
+		}
+	})
+}
+
+func createProject(t *testing.T, tx db.Transaction) id.ID {
+	t.Helper()
+
+	owner := &user.Model{
+		ID:        id.New(),
+		Email:     "owner@example.com",
+		Password:  "hash",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := user.NewRepository(tx).Create(t.Context(), owner); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	teamModel := &team.Model{
+		ID:        id.New(),
+		Name:      "Team",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := team.NewRepository(tx).Create(t.Context(), teamModel); err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+
+	model := &project.Model{
+		ID:        id.New(),
+		TeamID:    teamModel.ID,
+		OwnerID:   owner.ID,
+		Name:      "Project",
+		SubDomain: "project",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := project.NewRepository(tx).Create(t.Context(), model); err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	return model.ID
+}
@@ -0,0 +1,200 @@
+package action
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// MethodAny matches requests with any method, it can't be combined with other methods.
+const MethodAny = "ANY"
+
+// Methods that actions can handle
+var Methods = []string{
+	fiber.MethodGet,
+	fiber.MethodHead,
+	fiber.MethodPost,
+	fiber.MethodPut,
+	fiber.MethodPatch,
+	fiber.MethodDelete,
+	fiber.MethodOptions,
+	fiber.MethodConnect,
+	fiber.MethodTrace,
+}
+
+// RouteMethods returns methods that action handles explicitly, [MethodAny] is expanded to all methods.
+func (m *Model) RouteMethods() []string {
+	if slices.Contains(m.Methods, MethodAny) {
+		return Methods
+	}
+	return m.Methods
+}
+
+// DerivesHead reports whether HEAD requests are handled as GET requests without body, explicit HEAD routes of all
+// actions take precedence over derived ones.
+func (m *Model) DerivesHead() bool {
+	methods := m.RouteMethods()
+	return slices.Contains(methods, fiber.MethodGet) && !slices.Contains(methods, fiber.MethodHead)
+}
+
+// AllowedMethods returns all methods that action handles, including derived HEAD.
+func (m *Model) AllowedMethods() []string {
+	if m.DerivesHead() {
+		return append(slices.Clone(m.RouteMethods()), fiber.MethodHead)
+	}
+	return m.RouteMethods()
+}
+
+// ConflictError is returned when an action can't be reached for method, because the action before it matches the
+// same requests.
+type ConflictError struct {
+	// Action that was checked
+	Action Model
+	// Conflict is the other action
+	Conflict Model
+	Method   string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("action %q conflicts with action %q on %s %s",
+		e.Action.Name, e.Conflict.Name, e.Method, e.Conflict.Path)
+}
+
+// CheckConflict returns [ConflictError] if action at index conflicts with any other action, actions must be in
+// routing order.
+func CheckConflict(actions []Model, index int) error {
+	for i := range actions {
+		if i == index {
+			continue
+		}
+
+		first, second := i, index
+		if index < i {
+			first, second = index, i
+		}
+		if method, ok := shadows(&actions[first], &actions[second]); ok {
+			return &ConflictError{Action: actions[index], Conflict: actions[i], Method: method}
+		}
+	}
+	return nil
+}
+
+// CheckConflicts returns [ConflictError] for the first action that conflicts with an action before it, actions must
+// be in routing order.
+func CheckConflicts(actions []Model) error {
+	for i := range actions {
+		for j := range i {
+			if method, ok := shadows(&actions[j], &actions[i]); ok {
+				return &ConflictError{Action: actions[i], Conflict: actions[j], Method: method}
+			}
+		}
+	}
+	return nil
+}
+
+// shadows reports whether the first action matches all requests of the second one for some method, so the second
+// action never handles them.
+func shadows(first, second *Model) (string, bool) {
+	secondMethods := second.RouteMethods()
+	for _, method := range first.RouteMethods() {
+		if slices.Contains(secondMethods, method) && pathCovers(first.Path, second.Path) {
+			return method, true
+		}
+	}
+	return "", false
+}
+
+// paramRegexp matches segments that are a single parameter, like `:id`, `:id?` or `:id<int>`
+var paramRegexp = regexp.MustCompile(`^:\w+(<[^>]*>)?\??$`)
+
+type segmentKind int
+
+const (
+	segmentStatic segmentKind = iota
+	segmentParam
+	segmentOptionalParam
+	segmentWildcard
+	// segmentOther is a segment with several parameters or escaped characters, it's compared as is
+	segmentOther
+)
+
+type segment struct {
+	kind segmentKind
+	// value is normalized segment, parameter names are omitted, since they don't affect routing
+	value string
+	// constrained parameters match only some values
+	constrained bool
+}
+
+// parsePath splits path into segments the same way as router does, paths are case-insensitive and trailing slash is
+// ignored.
+func parsePath(path string) []segment {
+	path = strings.Trim(strings.ToLower(path), "/")
+	if path == "" {
+		return nil
+	}
+
+	parts := strings.Split(path, "/")
+	segments := make([]segment, len(parts))
+	for i, part := range parts {
+		switch {
+		case part == "*" || part == "+":
+			segments[i] = segment{kind: segmentWildcard, value: part}
+		case paramRegexp.MatchString(part):
+			constraint := ""
+			if start := strings.IndexByte(part, '<'); start != -1 {
+				constraint = part[start : strings.IndexByte(part, '>')+1]
+			}
+			if strings.HasSuffix(part, "?") {
+				segments[i] = segment{kind: segmentOptionalParam, value: ":" + constraint + "?"}
+			} else {
+				segments[i] = segment{kind: segmentParam, value: ":" + constraint}
+			}
+			segments[i].constrained = constraint != ""
+		case strings.ContainsAny(part, `:*+\`):
+			segments[i] = segment{kind: segmentOther, value: part}
+		default:
+			segments[i] = segment{kind: segmentStatic, value: part}
+		}
+	}
+	return segments
+}
+
+// pathCovers reports whether path of the first route matches all requests matched by path of the second one. It errs
+// on the side of no conflict, complex patterns are treated as conflicting only if they are the same.
+func pathCovers(first, second string) bool {
+	return segmentsCover(parsePath(first), parsePath(second))
+}
+
+func segmentsCover(first, second []segment) bool {
+	if len(first) == 0 {
+		return len(second) == 0
+	}
+
+	current := first[0]
+	if len(second) == 0 {
+		// Trailing optional segments match empty path
+		return (current.kind == segmentOptionalParam || current.value == "*") && segmentsCover(first[1:], nil)
+	}
+
+	// Trailing wildcard matches the rest of the path, `+` requires at least one segment
+	if current.kind == segmentWildcard && len(first) == 1 {
+		return current.value == "*" || (second[0].kind != segmentOptionalParam && second[0].value != "*")
+	}
+
+	other := second[0]
+	switch {
+	case current.value == other.value:
+	case (current.kind == segmentParam || current.kind == segmentOptionalParam) && !current.constrained:
+		if other.kind == segmentWildcard || (other.kind == segmentOptionalParam && current.kind == segmentParam) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	return segmentsCover(first[1:], second[1:])
+}
@@ -0,0 +1,162 @@
+package action_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mymmrac/lithium/pkg/module/action"
+)
+
+func TestCheckConflicts(t *testing.T) {
+	type route struct {
+		path    string
+		methods []string
+	}
+
+	tests := []struct {
+		name           string
+		routes         []route
+		expectedAction string
+		expectedMethod string
+	}{
+		{
+			name:   "different paths",
+			routes: []route{{"/users", []string{"GET"}}, {"/teams", []string{"GET"}}},
+		},
+		{
+			name:   "different methods",
+			routes: []route{{"/users", []string{"GET"}}, {"/users", []string{"POST"}}},
+		},
+		{
+			name:           "same route",
+			routes:         []route{{"/users", []string{"GET", "POST"}}, {"/Users/", []string{"POST"}}},
+			expectedAction: "1",
+			expectedMethod: "POST",
+		},
+		{
+			name:           "any method",
+			routes:         []route{{"/users", []string{"DELETE"}}, {"/users", []string{"ANY"}}},
+			expectedAction: "1",
+			expectedMethod: "DELETE",
+		},
+		{
+			name:   "explicit head",
+			routes: []route{{"/users", []string{"GET"}}, {"/users", []string{"HEAD"}}},
+		},
+		{
+			name:           "same parameters",
+			routes:         []route{{"/users/:id", []string{"GET"}}, {"/users/:name", []string{"GET"}}},
+			expectedAction: "1",
+			expectedMethod: "GET",
+		},
+		{
+			name:           "parameter before static",
+			routes:         []route{{"/users/:id", []string{"GET"}}, {"/users/me", []string{"GET"}}},
+			expectedAction: "1",
+			expectedMethod: "GET",
+		},
+		{
+			name:   "static before parameter",
+			routes: []route{{"/users/me", []string{"GET"}}, {"/users/:id", []string{"GET"}}},
+		},
+		{
+			name:   "constrained parameter",
+			routes: []route{{"/users/:id<int>", []string{"GET"}}, {"/users/me", []string{"GET"}}},
+		},
+		{
+			name:           "optional parameter",
+			routes:         []route{{"/users/:id?", []string{"GET"}}, {"/users", []string{"GET"}}},
+			expectedAction: "1",
+			expectedMethod: "GET",
+		},
+		{
+			name:   "required before optional parameter",
+			routes: []route{{"/users/:id", []string{"GET"}}, {"/users/:id?", []string{"GET"}}},
+		},
+		{
+			name:           "wildcard before static",
+			routes:         []route{{"/api/*", []string{"ANY"}}, {"/api/users/:id", []string{"PUT"}}},
+			expectedAction: "1",
+			expectedMethod: "PUT",
+		},
+		{
+			name:   "static before wildcard",
+			routes: []route{{"/api/users/:id", []string{"PUT"}}, {"/api/*", []string{"ANY"}}},
+		},
+		{
+			name:   "multiple parameters in segment",
+			routes: []route{{"/files/:name.:ext", []string{"GET"}}, {"/files/:name-:version", []string{"GET"}}},
+		},
+	}
+	for _, tt := range tests {
+		actions := make([]action.Model, len(tt.routes))
+		for i, r := range tt.routes {
+			actions[i] = action.Model{Name: string(rune('0' + i)), Path: r.path, Methods: r.methods, Order: i}
+		}
+
+		err := action.CheckConflicts(actions)
+		if tt.expectedAction == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+
+		var conflictErr *action.ConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Errorf("%s: expected conflict, got %v", tt.name, err)
+			continue
+		}
+		if conflictErr.Action.Name != tt.expectedAction || conflictErr.Method != tt.expectedMethod {
+			t.Errorf("%s: unexpected conflict: %v", tt.name, conflictErr)
+		}
+	}
+}
+
+func TestCheckConflict(t *testing.T) {
+	actions := []action.Model{
+		{Name: "users", Path: "/users/:id", Methods: []string{"GET"}},
+		{Name: "me", Path: "/users/me", Methods: []string{"POST"}},
+		{Name: "teams", Path: "/teams", Methods: []string{"GET"}},
+	}
+	if err := action.CheckConflict(actions, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Updated action conflicts with the action before it
+	actions[1].Methods = []string{"GET", "POST"}
+	var conflictErr *action.ConflictError
+	if err := action.CheckConflict(actions, 1); !errors.As(err, &conflictErr) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if conflictErr.Action.Name != "me" || conflictErr.Conflict.Name != "users" {
+		t.Errorf("unexpected conflict: %v", conflictErr)
+	}
+
+	// Updated action conflicts with the action after it
+	actions[0].Path = "/:resource"
+	actions[1].Methods = []string{"POST"}
+	if err := action.CheckConflict(actions, 0); !errors.As(err, &conflictErr) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if conflictErr.Action.Name != "users" || conflictErr.Conflict.Name != "teams" {
+		t.Errorf("unexpected conflict: %v", conflictErr)
+	}
+}
+
+func TestModelMethods(t *testing.T) {
+	model := action.Model{Methods: []string{action.MethodAny}}
+	if len(model.RouteMethods()) != len(action.Methods) || model.DerivesHead() {
+		t.Errorf("unexpected methods of any: %v", model.RouteMethods())
+	}
+
+	model.Methods = []string{"GET", "POST"}
+	if !model.DerivesHead() {
+		t.Error("expected derived head")
+	}
+
+	model.Methods = []string{"GET", "HEAD"}
+	if model.DerivesHead() {
+		t.Error("unexpected derived head")
+	}
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/auth"
+	"github.com/mymmrac/lithium/pkg/module/forwarded"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/server"
+)
+
+// Entry describes operation to record, before and after are states that are compared into diff.
+type Entry struct {
+	Operation Operation
+	// ActorID is required only if user is not authenticated yet, authenticated user is used by default
+	ActorID   id.ID
+	ProjectID id.ID
+	ActionID  id.ID
+	Before    any
+	After     any
+}
+
+type Log interface {
+	// Record appends entry to audit log, it must be called after operation is committed and doesn't fail the
+	// request, so failures are only logged
+	Record(fCtx fiber.Ctx, entry Entry)
+}
+
+type log struct {
+	serverCfg  server.Config
+	repository Repository
+}
+
+func NewLog(serverCfg server.Config, repository Repository) Log {
+	return &log{
+		serverCfg:  serverCfg,
+		repository: repository,
+	}
+}
+
+func (l *log) Record(fCtx fiber.Ctx, entry Entry) {
+	if err := l.record(fCtx, entry); err != nil {
+		logger.Errorw(fCtx, "record audit log", "operation", entry.Operation, "error", err)
+	}
+}
+
+func (l *log) record(fCtx fiber.Ctx, entry Entry) error {
+	diff, err := NewDiff(entry.Before, entry.After)
+	if err != nil {
+		return fmt.Errorf("diff: %w", err)
+	}
+
+	model := &Model{
+		ID:        id.New(),
+		ActorID:   entry.ActorID,
+		ProjectID: entry.ProjectID,
+		ActionID:  entry.ActionID,
+		Operation: entry.Operation,
+		Diff:      diff,
+		IPAddress: forwarded.FromContext(fCtx, l.serverCfg.TrustedProxies).IP.String(),
+		CreatedAt: time.Now(),
+	}
+
+	if authUser, ok := auth.UserFromContext(fCtx); ok {
+		if model.ActorID == 0 {
+			model.ActorID = authUser.ID
+		}
+		if model.ActorID == authUser.ID {
+			model.TokenID = authUser.TokenID
+		}
+	}
+
+	if err = l.repository.Create(fCtx, model); err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+// Operation recorded in audit log, operations are grouped by prefix before the first dot.
+type Operation string
+
+// Operations
+const (
+	OperationProjectCreate Operation = "project.create"
+	OperationProjectUpdate Operation = "project.update"
+	OperationProjectConfig Operation = "project.config"
+	OperationProjectDelete Operation = "project.delete"
+
+	OperationActionCreate Operation = "action.create"
+	OperationActionUpdate Operation = "action.update"
+	OperationActionOrder  Operation = "action.order"
+	OperationActionUpload Operation = "action.upload"
+	OperationActionConfig Operation = "action.config"
+	OperationActionDelete Operation = "action.delete"
+
+	OperationDomainAdd    Operation = "domain.add"
+	OperationDomainVerify Operation = "domain.verify"
+	OperationDomainRemove Operation = "domain.remove"
+
+	OperationDomainCertificateUpload Operation = "domain.certificate_upload"
+	OperationDomainCertificateRemove Operation = "domain.certificate_remove"
+
+	OperationAuthRegister          Operation = "auth.register"
+	OperationAuthLogin             Operation = "auth.login"
+	OperationAuthLoginFailed       Operation = "auth.login_failed"
+	OperationAuthLogout            Operation = "auth.logout"
+	OperationAuthPasswordChange    Operation = "auth.password_change"
+	OperationAuthPasswordForgot    Operation = "auth.password_forgot"
+	OperationAuthPasswordReset     Operation = "auth.password_reset"
+	OperationAuthEmailVerify       Operation = "auth.email_verify"
+	OperationAuthEmailVerifyResend Operation = "auth.email_verify_resend"
+	OperationAuthTwoFactorEnable   Operation = "auth.two_factor_enable"
+	OperationAuthTwoFactorDisable  Operation = "auth.two_factor_disable"
+	OperationAuthRecoveryCodes     Operation = "auth.recovery_codes"
+	OperationAuthSessionRevoke     Operation = "auth.session_revoke"
+	OperationAuthSessionRevokeAll  Operation = "auth.session_revoke_all"
+)
+
+type Model struct {
+	bun.BaseModel `bun:"table:audit_log"`
+
+	ID        id.ID     `bun:"id,pk"`
+	ActorID   id.ID     `bun:"actor_id,nullzero"`
+	TokenID   id.ID     `bun:"token_id,nullzero"`
+	ProjectID id.ID     `bun:"project_id,nullzero"`
+	ActionID  id.ID     `bun:"action_id,nullzero"`
+	Operation Operation `bun:"operation"`
+	Diff      Diff      `bun:"diff,type:jsonb"`
+	IPAddress string    `bun:"ip_address"`
+	CreatedAt time.Time `bun:"created_at"`
+
+	Actor *user.Model `bun:"rel:belongs-to,join:actor_id=id"`
+}
+
+// Change of one field, before is absent for created and after for removed fields.
+type Change struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// Diff holds changes by field name.
+type Diff map[string]Change
+
+// NewDiff compares top-level JSON fields of states before and after operation, only changed fields are kept. Any of
+// states can be nil, so creation and deletion keep all fields.
+func NewDiff(before, after any) (Diff, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, fmt.Errorf("before: %w", err)
+	}
+
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, fmt.Errorf("after: %w", err)
+	}
+
+	diff := make(Diff)
+	for name, value := range beforeFields {
+		if afterValue, ok := afterFields[name]; !ok || !bytes.Equal(value, afterValue) {
+			diff[name] = Change{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			diff[name] = Change{After: value}
+		}
+	}
+
+	return diff, nil
+}
+
+func jsonFields(state any) (map[string]json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	// Fields that are omitted and null are the same
+	for name, value := range fields {
+		if string(value) == "null" {
+			delete(fields, name)
+		}
+	}
+
+	return fields, nil
+}
+
+const (
+	// RedactedValue replaces secret values in audit log
+	RedactedValue = "[redacted]"
+	// ChangedValue replaces secret values that were changed by operation
+	ChangedValue = "[changed]"
+)
+
+// RedactSecrets hides values of secrets before and after operation, names of secrets are kept and changed secrets are
+// marked, so diff shows which secrets were changed without revealing them.
+func RedactSecrets(before, after map[string]string) (map[string]string, map[string]string) {
+	var redactedBefore, redactedAfter map[string]string
+	if before != nil {
+		redactedBefore = make(map[string]string, len(before))
+		for name := range before {
+			redactedBefore[name] = RedactedValue
+		}
+	}
+	if after != nil {
+		redactedAfter = make(map[string]string, len(after))
+		for name, value := range after {
+			if previous, ok := before[name]; ok && previous != value {
+				redactedAfter[name] = ChangedValue
+			} else {
+				redactedAfter[name] = RedactedValue
+			}
+		}
+	}
+	return redactedBefore, redactedAfter
+}
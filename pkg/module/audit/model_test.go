@@ -0,0 +1,90 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"maps"
+	"testing"
+
+	"github.com/mymmrac/lithium/pkg/module/audit"
+)
+
+func TestNewDiff(t *testing.T) {
+	type state struct {
+		Name    string            `json:"name"`
+		Path    string            `json:"path"`
+		Secrets map[string]string `json:"secrets,omitempty"`
+	}
+
+	tests := []struct {
+		name          string
+		before, after any
+		expected      string
+	}{
+		{
+			name:     "create",
+			after:    state{Name: "a", Path: "/"},
+			expected: `{"name":{"after":"a"},"path":{"after":"/"}}`,
+		},
+		{
+			name:     "update",
+			before:   state{Name: "a", Path: "/"},
+			after:    state{Name: "b", Path: "/"},
+			expected: `{"name":{"before":"a","after":"b"}}`,
+		},
+		{
+			name:     "add and remove field",
+			before:   map[string]any{"name": "a", "secrets": map[string]string{"KEY": audit.RedactedValue}},
+			after:    state{Name: "a", Path: "/"},
+			expected: `{"path":{"after":"/"},"secrets":{"before":{"KEY":"[redacted]"}}}`,
+		},
+		{
+			name:     "delete",
+			before:   state{Name: "a", Path: "/"},
+			expected: `{"name":{"before":"a"},"path":{"before":"/"}}`,
+		},
+		{
+			name:     "nothing",
+			expected: `{}`,
+		},
+	}
+	for _, tt := range tests {
+		diff, err := audit.NewDiff(tt.before, tt.after)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+
+		actual, err := json.Marshal(diff)
+		if err != nil {
+			t.Fatalf("marshal diff: %v", err)
+		}
+		if string(actual) != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	before, after := audit.RedactSecrets(
+		map[string]string{"KEPT": "1", "CHANGED": "2", "REMOVED": "3"},
+		map[string]string{"KEPT": "1", "CHANGED": "20", "ADDED": "4"},
+	)
+
+	expectedBefore := map[string]string{
+		"KEPT": audit.RedactedValue, "CHANGED": audit.RedactedValue, "REMOVED": audit.RedactedValue,
+	}
+	if !maps.Equal(before, expectedBefore) {
+		t.Errorf("unexpected before: %v", before)
+	}
+
+	expectedAfter := map[string]string{
+		"KEPT": audit.RedactedValue, "CHANGED": audit.ChangedValue, "ADDED": audit.RedactedValue,
+	}
+	if !maps.Equal(after, expectedAfter) {
+		t.Errorf("unexpected after: %v", after)
+	}
+
+	if before, after = audit.RedactSecrets(nil, nil); before != nil || after != nil {
+		t.Errorf("unexpected redacted nil secrets: %v, %v", before, after)
+	}
+}
@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+// Filter of audit log entries, zero fields are not used.
+type Filter struct {
+	ActorID   id.ID
+	ProjectID id.ID
+	ActionID  id.ID
+	// Operation matches exact operation or, if it ends with a dot, all operations of the group
+	Operation Operation
+	Since     time.Time
+	Until     time.Time
+	// Before is ID of the last entry of the previous page
+	Before id.ID
+	Limit  int
+}
+
+// Repository of audit log is append-only, entries are never changed or deleted.
+type Repository interface {
+	Create(ctx context.Context, model *Model) error
+	// Get returns entries matching filter, newest first
+	Get(ctx context.Context, filter Filter) ([]Model, error)
+}
+
+type repository struct {
+	tx db.Transaction
+}
+
+func NewRepository(tx db.Transaction) Repository {
+	return &repository{
+		tx: tx,
+	}
+}
+
+func (r *repository) Create(ctx context.Context, model *Model) error {
+	_, err := r.tx.Extract(ctx).NewInsert().Model(model).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) Get(ctx context.Context, filter Filter) ([]Model, error) {
+	var models []Model
+	query := r.tx.Extract(ctx).NewSelect().
+		Model(&models).
+		Relation("Actor")
+
+	if filter.ActorID != 0 {
+		query = query.Where("?TableAlias.actor_id = ?", filter.ActorID)
+	}
+	if filter.ProjectID != 0 {
+		query = query.Where("?TableAlias.project_id = ?", filter.ProjectID)
+	}
+	if filter.ActionID != 0 {
+		query = query.Where("?TableAlias.action_id = ?", filter.ActionID)
+	}
+	if filter.Operation != "" {
+		if group, ok := strings.CutSuffix(string(filter.Operation), "."); ok {
+			query = query.Where("?TableAlias.operation LIKE ?", group+".%")
+		} else {
+			query = query.Where("?TableAlias.operation = ?", filter.Operation)
+		}
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("?TableAlias.created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("?TableAlias.created_at < ?", filter.Until)
+	}
+	if filter.Before != 0 {
+		query = query.Where("?TableAlias.id < ?", filter.Before)
+	}
+
+	err := query.
+		OrderExpr("?TableAlias.id DESC").
+		Limit(filter.Limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
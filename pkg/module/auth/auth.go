@@ -4,17 +4,32 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/golang-jwt/jwt/v5"
 
+	"github.com/mymmrac/lithium/pkg/module/forwarded"
 	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/server"
+	"github.com/mymmrac/lithium/pkg/module/session"
+	"github.com/mymmrac/lithium/pkg/module/token"
 	"github.com/mymmrac/lithium/pkg/module/user"
 )
 
-const tokenCookie = "token"
+const (
+	tokenCookie   = "token"
+	refreshCookie = "refresh_token"
+	bearerPrefix  = "Bearer "
+	// lastUsedPrecision limits how often API token or session last used time is written to database
+	lastUsedPrecision = time.Minute
+	// refreshReuseGrace is how long previous refresh token is accepted after rotation
+	refreshReuseGrace = 30 * time.Second
+	maxUserAgentLen   = 512
+)
 
 var userKey = userKeyType{} //nolint:gochecknoglobals
 
@@ -22,6 +37,27 @@ type userKeyType struct{}
 
 type User struct {
 	ID id.ID
+
+	// SessionID is set only if user authenticated with session cookies
+	SessionID id.ID
+	// TokenID is set only if user authenticated with API token
+	TokenID id.ID
+	// ProjectID is set only if API token is limited to one project
+	ProjectID id.ID
+	Scopes    []token.Scope
+}
+
+// Allows reports whether user can perform action that requires scope, session users are not limited by scopes.
+func (u User) Allows(scope token.Scope) bool {
+	if u.TokenID == 0 {
+		return true
+	}
+	return slices.ContainsFunc(u.Scopes, func(granted token.Scope) bool { return granted.Includes(scope) })
+}
+
+// AllowsProject reports whether user can access project, only project limited API tokens are restricted.
+func (u User) AllowsProject(projectID id.ID) bool {
+	return u.ProjectID == 0 || u.ProjectID == projectID
 }
 
 func UserFromContext(ctx context.Context) (User, bool) {
@@ -53,72 +89,299 @@ func RequireMiddleware(fCtx fiber.Ctx) error {
 	return fCtx.SendStatus(fiber.StatusUnauthorized)
 }
 
+// RequireScope returns middleware that requires user to be authenticated and to have scope, if API token is limited to
+// one project, only routes of this project are allowed (and listing of projects with read scope).
+func RequireScope(scope token.Scope) fiber.Handler {
+	return func(fCtx fiber.Ctx) error {
+		authUser, ok := UserFromContext(fCtx)
+		if !ok {
+			return fCtx.SendStatus(fiber.StatusUnauthorized)
+		}
+
+		if !authUser.Allows(scope) {
+			return fiber.NewError(fiber.StatusForbidden)
+		}
+
+		if authUser.ProjectID != 0 {
+			projectIDParam := fCtx.Params("projectID")
+			if projectIDParam == "" {
+				if scope != token.ScopeRead {
+					return fiber.NewError(fiber.StatusForbidden)
+				}
+			} else if projectID, err := id.Parse(projectIDParam); err != nil || !authUser.AllowsProject(projectID) {
+				return fiber.NewError(fiber.StatusNotFound)
+			}
+		}
+
+		return fCtx.Next()
+	}
+}
+
+// RequireSessionMiddleware requires user to be authenticated with session, API tokens are rejected.
+func RequireSessionMiddleware(fCtx fiber.Ctx) error {
+	authUser, ok := UserFromContext(fCtx)
+	if !ok {
+		return fCtx.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	if authUser.SessionID == 0 {
+		return fiber.NewError(fiber.StatusForbidden)
+	}
+
+	return fCtx.Next()
+}
+
 type Auth interface {
 	Middleware(fCtx fiber.Ctx) error
-	GenerateAndSetToken(fCtx fiber.Ctx, userModel *user.Model) error
-	ClearToken(fCtx fiber.Ctx)
+	StartSession(fCtx fiber.Ctx, userModel *user.Model) error
+	EndSession(fCtx fiber.Ctx) error
+	ClearCookies(fCtx fiber.Ctx)
 }
 
 type auth struct {
-	cfg Config
+	cfg               Config
+	serverCfg         server.Config
+	tokenRepository   token.Repository
+	sessionRepository session.Repository
 }
 
-func NewAuth(cfg Config) Auth {
+func NewAuth(
+	cfg Config, serverCfg server.Config, tokenRepository token.Repository, sessionRepository session.Repository,
+) Auth {
 	return &auth{
-		cfg: cfg,
+		cfg:               cfg,
+		serverCfg:         serverCfg,
+		tokenRepository:   tokenRepository,
+		sessionRepository: sessionRepository,
 	}
 }
 
 func (a *auth) Middleware(fCtx fiber.Ctx) error {
-	token := fCtx.Cookies(tokenCookie)
-	if token == "" {
-		return fCtx.Next()
+	if header := fCtx.Get(fiber.HeaderAuthorization); strings.HasPrefix(header, bearerPrefix) {
+		return a.apiTokenMiddleware(fCtx, strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix)))
 	}
 
+	if accessToken := fCtx.Cookies(tokenCookie); accessToken != "" {
+		authUser, ok, err := a.authenticateAccessToken(fCtx, accessToken)
+		if err != nil {
+			logger.Errorw(fCtx, "authenticate access token", "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError)
+		}
+		if ok {
+			fCtx.Locals(userKey, authUser)
+			return fCtx.Next()
+		}
+	}
+
+	if refreshToken := fCtx.Cookies(refreshCookie); refreshToken != "" {
+		authUser, ok, err := a.refreshSession(fCtx, refreshToken)
+		if err != nil {
+			logger.Errorw(fCtx, "refresh session", "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError)
+		}
+		if ok {
+			fCtx.Locals(userKey, authUser)
+			return fCtx.Next()
+		}
+	}
+
+	if fCtx.Cookies(tokenCookie) != "" || fCtx.Cookies(refreshCookie) != "" {
+		a.ClearCookies(fCtx)
+	}
+	return fCtx.Next()
+}
+
+// authenticateAccessToken validates access token and checks that its session wasn't revoked.
+func (a *auth) authenticateAccessToken(fCtx fiber.Ctx, accessToken string) (User, bool, error) {
 	var claims user.Claims
-	parsedToken, err := jwt.ParseWithClaims(token, &claims, func(_ *jwt.Token) (any, error) {
+	parsedToken, err := jwt.ParseWithClaims(accessToken, &claims, func(_ *jwt.Token) (any, error) {
 		return []byte(a.cfg.JWTSecret), nil
 	}, jwt.WithExpirationRequired(), jwt.WithIssuedAt(), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !parsedToken.Valid {
+		return User{}, false, nil //nolint:nilerr
+	}
+
+	model, found, err := a.sessionRepository.GetByID(fCtx, claims.SessionID)
 	if err != nil {
-		return fCtx.Next()
+		return User{}, false, fmt.Errorf("get session: %w", err)
 	}
 
-	if !parsedToken.Valid {
-		return fCtx.Next()
+	now := time.Now()
+	if !found || model.UserID != claims.UserID || model.Expired(now) {
+		return User{}, false, nil
+	}
+
+	if now.Sub(model.LastUsedAt) >= lastUsedPrecision {
+		if err = a.sessionRepository.UpdateLastUsed(fCtx, model.ID, now); err != nil {
+			logger.Warnw(fCtx, "update session last used", "id", model.ID, "error", err)
+		}
+	}
+
+	return User{
+		ID:        model.UserID,
+		SessionID: model.ID,
+	}, true, nil
+}
+
+// refreshSession rotates refresh token and issues new access token. Reuse of already rotated refresh token revokes
+// the whole session, since token was most likely stolen, except for a short grace period that allows concurrent
+// requests made with the same refresh token.
+func (a *auth) refreshSession(fCtx fiber.Ctx, refreshToken string) (User, bool, error) {
+	now := time.Now()
+	hash := session.HashRefreshToken(refreshToken)
+
+	model, found, err := a.sessionRepository.GetByRefreshHash(fCtx, hash)
+	if err != nil {
+		return User{}, false, fmt.Errorf("get session: %w", err)
+	}
+	if found {
+		if model.Expired(now) {
+			return User{}, false, nil
+		}
+
+		newRefreshToken, newHash := session.GenerateRefreshToken()
+		expiresAt := now.Add(a.cfg.RefreshTokenTTL)
+
+		var rotated bool
+		rotated, err = a.sessionRepository.Rotate(fCtx, model.ID, hash, newHash, now, expiresAt)
+		if err != nil {
+			return User{}, false, fmt.Errorf("rotate session: %w", err)
+		}
+		if rotated {
+			if err = a.setSessionCookies(fCtx, model, newRefreshToken, expiresAt, now); err != nil {
+				return User{}, false, err
+			}
+			return User{ID: model.UserID, SessionID: model.ID}, true, nil
+		}
+	}
+
+	model, found, err = a.sessionRepository.GetByPreviousRefreshHash(fCtx, hash)
+	if err != nil {
+		return User{}, false, fmt.Errorf("get session: %w", err)
+	}
+	if !found {
+		return User{}, false, nil
+	}
+
+	if now.Sub(model.RotatedAt) > refreshReuseGrace || model.Expired(now) {
+		logger.Warnw(fCtx, "refresh token reuse detected, revoking session", "id", model.ID, "user-id", model.UserID)
+		if err = a.sessionRepository.DeleteByID(fCtx, model.ID); err != nil {
+			return User{}, false, fmt.Errorf("delete session: %w", err)
+		}
+		return User{}, false, nil
+	}
+
+	// Refresh cookie is not updated, concurrent request that rotated session already did it
+	if err = a.setAccessCookie(fCtx, model, now); err != nil {
+		return User{}, false, err
+	}
+	return User{ID: model.UserID, SessionID: model.ID}, true, nil
+}
+
+func (a *auth) apiTokenMiddleware(fCtx fiber.Ctx, value string) error {
+	if !token.LooksLikeToken(value) {
+		return fCtx.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	model, found, err := a.tokenRepository.GetByHash(fCtx, token.Hash(value))
+	if err != nil {
+		logger.Errorw(fCtx, "get API token", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	now := time.Now()
+	if !found || model.Expired(now) {
+		return fCtx.SendStatus(fiber.StatusUnauthorized)
+	}
+
+	if now.Sub(model.LastUsedAt) >= lastUsedPrecision {
+		if err = a.tokenRepository.UpdateLastUsed(fCtx, model.ID, now); err != nil {
+			logger.Warnw(fCtx, "update API token last used", "id", model.ID, "error", err)
+		}
 	}
 
 	fCtx.Locals(userKey, User{
-		ID: claims.UserID,
+		ID:        model.UserID,
+		TokenID:   model.ID,
+		ProjectID: model.ProjectID,
+		Scopes:    model.Scopes,
 	})
 	return fCtx.Next()
 }
 
-func (a *auth) GenerateAndSetToken(fCtx fiber.Ctx, userModel *user.Model) error {
-	expiresAt := time.Now().Add(time.Hour)
-	token, err := a.generateJWT(userModel, expiresAt)
+func (a *auth) StartSession(fCtx fiber.Ctx, userModel *user.Model) error {
+	now := time.Now()
+	refreshToken, hash := session.GenerateRefreshToken()
+
+	userAgent := fCtx.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > maxUserAgentLen {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLen], "")
+	}
+
+	model := &session.Model{
+		ID:          id.New(),
+		UserID:      userModel.ID,
+		RefreshHash: hash,
+		UserAgent:   userAgent,
+		IPAddress:   forwarded.FromContext(fCtx, a.serverCfg.TrustedProxies).IP.String(),
+		CreatedAt:   now,
+		LastUsedAt:  now,
+		RotatedAt:   now,
+		ExpiresAt:   now.Add(a.cfg.RefreshTokenTTL),
+	}
+	if err := a.sessionRepository.Create(fCtx, model); err != nil {
+		return fmt.Errorf("create session: %w", err)
+	}
+
+	if err := a.sessionRepository.DeleteExpired(fCtx, now); err != nil {
+		logger.Warnw(fCtx, "delete expired sessions", "error", err)
+	}
+
+	return a.setSessionCookies(fCtx, model, refreshToken, model.ExpiresAt, now)
+}
+
+func (a *auth) EndSession(fCtx fiber.Ctx) error {
+	if authUser, ok := UserFromContext(fCtx); ok && authUser.SessionID != 0 {
+		if err := a.sessionRepository.DeleteByID(fCtx, authUser.SessionID); err != nil {
+			return fmt.Errorf("delete session: %w", err)
+		}
+	}
+
+	a.ClearCookies(fCtx)
+	return nil
+}
+
+func (a *auth) setSessionCookies(
+	fCtx fiber.Ctx, model *session.Model, refreshToken string, refreshExpiresAt, now time.Time,
+) error {
+	if err := a.setAccessCookie(fCtx, model, now); err != nil {
+		return err
+	}
+	a.setCookie(fCtx, refreshCookie, refreshToken, refreshExpiresAt)
+	return nil
+}
+
+func (a *auth) setAccessCookie(fCtx fiber.Ctx, model *session.Model, now time.Time) error {
+	expiresAt := now.Add(a.cfg.AccessTokenTTL)
+	accessToken, err := a.generateJWT(model, now, expiresAt)
 	if err != nil {
 		return fmt.Errorf("generate JWT token: %w", err)
 	}
 
-	fCtx.Cookie(&fiber.Cookie{
-		Name:     tokenCookie,
-		Value:    token,
-		HTTPOnly: true,
-		Expires:  expiresAt,
-	})
-
+	a.setCookie(fCtx, tokenCookie, accessToken, expiresAt)
 	return nil
 }
 
-func (a *auth) generateJWT(userModel *user.Model, expiresAt time.Time) (string, error) {
-	now := jwt.NewNumericDate(time.Now())
+func (a *auth) generateJWT(model *session.Model, now, expiresAt time.Time) (string, error) {
+	issuedAt := jwt.NewNumericDate(now)
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, user.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			NotBefore: now,
-			IssuedAt:  now,
+			NotBefore: issuedAt,
+			IssuedAt:  issuedAt,
 		},
-		UserID: userModel.ID,
+		UserID:    model.UserID,
+		SessionID: model.ID,
 	})
 
 	signedToken, err := token.SignedString([]byte(a.cfg.JWTSecret))
@@ -129,10 +392,20 @@ func (a *auth) generateJWT(userModel *user.Model, expiresAt time.Time) (string,
 	return signedToken, nil
 }
 
-func (a *auth) ClearToken(fCtx fiber.Ctx) {
+func (a *auth) setCookie(fCtx fiber.Ctx, name, value string, expiresAt time.Time) {
 	fCtx.Cookie(&fiber.Cookie{
-		Name:     tokenCookie,
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expiresAt,
 		HTTPOnly: true,
-		Expires:  time.Now().Add(-time.Hour),
+		Secure:   a.cfg.CookieSecure,
+		SameSite: a.cfg.CookieSameSite,
 	})
 }
+
+func (a *auth) ClearCookies(fCtx fiber.Ctx) {
+	expiresAt := time.Now().Add(-time.Hour)
+	a.setCookie(fCtx, tokenCookie, "", expiresAt)
+	a.setCookie(fCtx, refreshCookie, "", expiresAt)
+}
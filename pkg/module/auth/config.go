@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"strings"
+	"time"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
 
@@ -8,13 +11,22 @@ import (
 )
 
 type Config struct {
-	JWTSecret string `validate:"required"`
+	JWTSecret       string        `validate:"required" secret:"true"`
+	AccessTokenTTL  time.Duration `validate:"min=1m"`
+	RefreshTokenTTL time.Duration `validate:"gtefield=AccessTokenTTL"`
+	// CookieSecure must be enabled if SameSite is none
+	CookieSecure   bool   `validate:"required_if=CookieSameSite none"`
+	CookieSameSite string `validate:"oneof=lax strict none"`
 }
 
 func init() { //nolint:gochecknoinits
 	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
 		cfg := Config{
-			JWTSecret: v.GetString("jwt-secret"),
+			JWTSecret:       v.GetString("jwt-secret"),
+			AccessTokenTTL:  v.GetDuration("session-access-ttl"),
+			RefreshTokenTTL: v.GetDuration("session-refresh-ttl"),
+			CookieSecure:    v.GetBool("cookie-secure"),
+			CookieSameSite:  strings.ToLower(v.GetString("cookie-same-site")),
 		}
 		if err := va.Struct(cfg); err != nil {
 			return Config{}, err
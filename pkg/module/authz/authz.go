@@ -0,0 +1,145 @@
+// Package authz decides what members of a team can do with the team and its projects.
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/team"
+)
+
+// Permission to perform an action within the team.
+type Permission string
+
+// Permissions
+const (
+	// PermissionProjectRead allows viewing projects, actions and deploys
+	PermissionProjectRead Permission = "project:read"
+	// PermissionSecretRead allows reading secrets of projects and actions
+	PermissionSecretRead Permission = "secret:read"
+	// PermissionActionWrite allows creating, changing and deleting actions
+	PermissionActionWrite Permission = "action:write"
+	// PermissionActionDeploy allows uploading modules
+	PermissionActionDeploy Permission = "action:deploy"
+	// PermissionProjectWrite allows creating projects, changing their settings and creating service tokens
+	PermissionProjectWrite Permission = "project:write"
+	// PermissionProjectDelete allows deleting projects
+	PermissionProjectDelete Permission = "project:delete"
+	// PermissionAuditRead allows reading audit log of projects
+	PermissionAuditRead Permission = "audit:read"
+	// PermissionTeamManage allows renaming the team and managing its members and invitations
+	PermissionTeamManage Permission = "team:manage"
+	// PermissionTeamDelete allows deleting the team
+	PermissionTeamDelete Permission = "team:delete"
+)
+
+// requiredRoles holds the lowest role granted with permission
+//
+//nolint:gochecknoglobals
+var requiredRoles = map[Permission]team.Role{
+	PermissionProjectRead:   team.RoleViewer,
+	PermissionSecretRead:    team.RoleDeveloper,
+	PermissionActionWrite:   team.RoleDeveloper,
+	PermissionActionDeploy:  team.RoleDeveloper,
+	PermissionProjectWrite:  team.RoleAdmin,
+	PermissionProjectDelete: team.RoleAdmin,
+	PermissionAuditRead:     team.RoleAdmin,
+	PermissionTeamManage:    team.RoleAdmin,
+	PermissionTeamDelete:    team.RoleOwner,
+}
+
+// Allows reports whether role grants permission.
+func Allows(role team.Role, permission Permission) bool {
+	required, ok := requiredRoles[permission]
+	return ok && role.Includes(required)
+}
+
+// CanAssign reports whether member with role can invite, change or remove members with another role, only owners
+// can manage admins and other owners.
+func CanAssign(role, other team.Role) bool {
+	if !Allows(role, PermissionTeamManage) {
+		return false
+	}
+	return role == team.RoleOwner || !other.Includes(team.RoleAdmin)
+}
+
+var (
+	// ErrNotFound is returned when resource doesn't exist or user is not a member of its team, so existence of
+	// resources of other teams is not revealed
+	ErrNotFound = errors.New("not found")
+	// ErrForbidden is returned when user is a member of the team, but its role doesn't grant permission
+	ErrForbidden = errors.New("forbidden")
+)
+
+type Authz interface {
+	// Project returns project and membership of the user in its team, if membership grants permission
+	Project(ctx context.Context, userID, projectID id.ID, permission Permission) (*project.Model, *team.Member, error)
+	// Team returns membership of the user in the team, if membership grants permission
+	Team(ctx context.Context, userID, teamID id.ID, permission Permission) (*team.Member, error)
+}
+
+type authz struct {
+	projectRepository project.Repository
+	teamRepository    team.Repository
+}
+
+func NewAuthz(projectRepository project.Repository, teamRepository team.Repository) Authz {
+	return &authz{
+		projectRepository: projectRepository,
+		teamRepository:    teamRepository,
+	}
+}
+
+func (a *authz) Project(
+	ctx context.Context, userID, projectID id.ID, permission Permission,
+) (*project.Model, *team.Member, error) {
+	projectModel, found, err := a.projectRepository.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get project: %w", err)
+	}
+	if !found {
+		return nil, nil, ErrNotFound
+	}
+
+	member, err := a.Team(ctx, userID, projectModel.TeamID, permission)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return projectModel, member, nil
+}
+
+func (a *authz) Team(ctx context.Context, userID, teamID id.ID, permission Permission) (*team.Member, error) {
+	member, found, err := a.teamRepository.GetMember(ctx, teamID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get team member: %w", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	if !Allows(member.Role, permission) {
+		return nil, ErrForbidden
+	}
+
+	return member, nil
+}
+
+// Error converts authorization error into response error, unexpected errors are logged.
+func Error(fCtx fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound)
+	case errors.Is(err, ErrForbidden):
+		return fiber.NewError(fiber.StatusForbidden)
+	default:
+		logger.Errorw(fCtx, "authorize", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,123 @@
+package authz_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/authz"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+func TestAuthzProject(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		teamRepository := team.NewRepository(tx)
+		projectRepository := project.NewRepository(tx)
+		now := time.Now()
+
+		teamModel := &team.Model{ID: id.New(), Name: "Team", CreatedAt: now, UpdatedAt: now}
+		if err := teamRepository.Create(t.Context(), teamModel); err != nil {
+			t.Fatalf("create team: %v", err)
+		}
+
+		users := make(map[team.Role]id.ID)
+		for _, role := range []team.Role{team.RoleViewer, team.RoleDeveloper, team.RoleAdmin, team.RoleOwner} {
+			users[role] = createUser(t, tx, string(role)+"@example.com")
+			err := teamRepository.CreateMember(t.Context(), &team.Member{
+				TeamID: teamModel.ID, UserID: users[role], Role: role, CreatedAt: now,
+			})
+			if err != nil {
+				t.Fatalf("create member: %v", err)
+			}
+		}
+		outsider := createUser(t, tx, "outsider@example.com")
+
+		projectModel := &project.Model{
+			ID:        id.New(),
+			TeamID:    teamModel.ID,
+			OwnerID:   users[team.RoleOwner],
+			Name:      "Project",
+			SubDomain: "project",
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := projectRepository.Create(t.Context(), projectModel); err != nil {
+			t.Fatalf("create project: %v", err)
+		}
+
+		service := authz.NewAuthz(projectRepository, teamRepository)
+
+		tests := []struct {
+			name       string
+			userID     id.ID
+			projectID  id.ID
+			permission authz.Permission
+			err        error
+		}{
+			{"viewer reads", users[team.RoleViewer], projectModel.ID, authz.PermissionProjectRead, nil},
+			{"viewer secrets", users[team.RoleViewer], projectModel.ID, authz.PermissionSecretRead, authz.ErrForbidden},
+			{"viewer deploys", users[team.RoleViewer], projectModel.ID, authz.PermissionActionDeploy, authz.ErrForbidden},
+			{"developer deploys", users[team.RoleDeveloper], projectModel.ID, authz.PermissionActionDeploy, nil},
+			{"developer settings", users[team.RoleDeveloper], projectModel.ID, authz.PermissionProjectWrite,
+				authz.ErrForbidden},
+			{"admin deletes", users[team.RoleAdmin], projectModel.ID, authz.PermissionProjectDelete, nil},
+			{"admin deletes team", users[team.RoleAdmin], projectModel.ID, authz.PermissionTeamDelete,
+				authz.ErrForbidden},
+			{"owner deletes team", users[team.RoleOwner], projectModel.ID, authz.PermissionTeamDelete, nil},
+			{"outsider", outsider, projectModel.ID, authz.PermissionProjectRead, authz.ErrNotFound},
+			{"unknown project", users[team.RoleOwner], id.New(), authz.PermissionProjectRead, authz.ErrNotFound},
+		}
+		for _, tt := range tests {
+			model, member, err := service.Project(t.Context(), tt.userID, tt.projectID, tt.permission)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+				continue
+			}
+			if err == nil && (model.ID != projectModel.ID || member.UserID != tt.userID) {
+				t.Errorf("%s: unexpected result: %+v, %+v", tt.name, model, member)
+			}
+		}
+	})
+}
+
+func TestCanAssign(t *testing.T) {
+	tests := []struct {
+		role, other team.Role
+		expected    bool
+	}{
+		{team.RoleOwner, team.RoleOwner, true},
+		{team.RoleOwner, team.RoleAdmin, true},
+		{team.RoleAdmin, team.RoleDeveloper, true},
+		{team.RoleAdmin, team.RoleViewer, true},
+		{team.RoleAdmin, team.RoleAdmin, false},
+		{team.RoleAdmin, team.RoleOwner, false},
+		{team.RoleDeveloper, team.RoleViewer, false},
+		{team.RoleViewer, team.RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if actual := authz.CanAssign(tt.role, tt.other); actual != tt.expected {
+			t.Errorf("%s assigns %s: expected %t, got %t", tt.role, tt.other, tt.expected, actual)
+		}
+	}
+}
+
+func createUser(t *testing.T, tx db.Transaction, email string) id.ID {
+	t.Helper()
+
+	model := &user.Model{
+		ID:        id.New(),
+		Email:     email,
+		Password:  "hash",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := user.NewRepository(tx).Create(t.Context(), model); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return model.ID
+}
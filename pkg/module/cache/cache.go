@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 type Cache[K comparable, V any] interface {
@@ -11,6 +12,15 @@ type Cache[K comparable, V any] interface {
 	Remove(ctx context.Context, key K) error
 }
 
+// Updater is cache that can change value atomically, concurrent updates of the same key are applied one after
+// another, so none of them is lost.
+type Updater[K comparable, V any] interface {
+	Cache[K, V]
+	// Update sets value returned by update, update is called with current value, ok is false if there is no value.
+	// Update can be called more than once, so it must not have side effects.
+	Update(ctx context.Context, key K, update func(value V, ok bool) V) (V, error)
+}
+
 type InMemory[K comparable, V any] struct {
 	lock   sync.RWMutex
 	values map[K]V
@@ -37,9 +47,94 @@ func (m *InMemory[K, V]) Set(_ context.Context, key K, value V) error {
 	return nil
 }
 
+func (m *InMemory[K, V]) Update(_ context.Context, key K, update func(value V, ok bool) V) (V, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	value, ok := m.values[key]
+	value = update(value, ok)
+	m.values[key] = value
+	return value, nil
+}
+
 func (m *InMemory[K, V]) Remove(_ context.Context, key K) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	delete(m.values, key)
 	return nil
 }
+
+// InMemoryTTL is in-memory cache that forgets values that were not set for longer than TTL.
+type InMemoryTTL[K comparable, V any] struct {
+	ttl       time.Duration
+	lock      sync.Mutex
+	values    map[K]entry[V]
+	lastSweep time.Time
+}
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func NewInMemoryTTL[K comparable, V any](ttl time.Duration) *InMemoryTTL[K, V] {
+	return &InMemoryTTL[K, V]{
+		ttl:       ttl,
+		lock:      sync.Mutex{},
+		values:    make(map[K]entry[V]),
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *InMemoryTTL[K, V]) Get(_ context.Context, key K) (value V, ok bool, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	e, ok := m.values[key]
+	if !ok || !time.Now().Before(e.expiresAt) {
+		return value, false, nil
+	}
+	return e.value, true, nil
+}
+
+func (m *InMemoryTTL[K, V]) Set(_ context.Context, key K, value V) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.set(key, value, time.Now())
+	return nil
+}
+
+func (m *InMemoryTTL[K, V]) Update(_ context.Context, key K, update func(value V, ok bool) V) (V, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := time.Now()
+	e, ok := m.values[key]
+	if ok && !now.Before(e.expiresAt) {
+		e, ok = entry[V]{}, false
+	}
+
+	value := update(e.value, ok)
+	m.set(key, value, now)
+	return value, nil
+}
+
+// set stores value, lock must be held.
+func (m *InMemoryTTL[K, V]) set(key K, value V, now time.Time) {
+	m.values[key] = entry[V]{value: value, expiresAt: now.Add(m.ttl)}
+
+	// Expired values are removed at most once per TTL, so memory doesn't grow with keys that are never set again
+	if now.Sub(m.lastSweep) >= m.ttl {
+		for k, e := range m.values {
+			if !now.Before(e.expiresAt) {
+				delete(m.values, k)
+			}
+		}
+		m.lastSweep = now
+	}
+}
+
+func (m *InMemoryTTL[K, V]) Remove(_ context.Context, key K) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.values, key)
+	return nil
+}
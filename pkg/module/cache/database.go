@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+)
+
+// maxUpdateRetries is how many times update is retried when value is changed concurrently
+const maxUpdateRetries = 16
+
+var errTooManyConflicts = errors.New("value is changed concurrently too often")
+
+type databaseEntry struct {
+	bun.BaseModel `bun:"table:cache"`
+
+	ID    string `bun:"id,pk"`
+	Value string `bun:"value"`
+	// Version is random and changes with each write, so updates can check that value was not changed since it was read
+	Version   int64     `bun:"version"`
+	ExpiresAt time.Time `bun:"expires_at"`
+}
+
+// Database is cache stored in metadata database, so it's shared by all servers. Values are stored as JSON and are
+// forgotten if they were not set for longer than TTL, namespace separates keys of different caches.
+type Database[V any] struct {
+	tx        db.Transaction
+	namespace string
+	ttl       time.Duration
+	lock      sync.Mutex
+	lastSweep time.Time
+}
+
+func NewDatabase[V any](tx db.Transaction, namespace string, ttl time.Duration) *Database[V] {
+	return &Database[V]{
+		tx:        tx,
+		namespace: namespace + ":",
+		ttl:       ttl,
+		lock:      sync.Mutex{},
+		lastSweep: time.Time{},
+	}
+}
+
+func (d *Database[V]) Get(ctx context.Context, key string) (value V, ok bool, err error) {
+	model, found, err := d.entry(ctx, key)
+	if err != nil || !found || !time.Now().Before(model.ExpiresAt) {
+		return value, false, err
+	}
+
+	if err = json.Unmarshal([]byte(model.Value), &value); err != nil {
+		return value, false, fmt.Errorf("decode value: %w", err)
+	}
+	return value, true, nil
+}
+
+func (d *Database[V]) Set(ctx context.Context, key string, value V) error {
+	model, err := d.newEntry(key, value, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, err = d.tx.Extract(ctx).NewInsert().
+		Model(model).
+		On("CONFLICT (id) DO UPDATE").
+		Set("value = EXCLUDED.value").
+		Set("version = EXCLUDED.version").
+		Set("expires_at = EXCLUDED.expires_at").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.sweep(ctx)
+	return nil
+}
+
+// Update reads value and writes it back only if it was not changed in between, otherwise it's retried with new value.
+func (d *Database[V]) Update(ctx context.Context, key string, update func(value V, ok bool) V) (V, error) {
+	var zero V
+	for range maxUpdateRetries {
+		current, found, err := d.entry(ctx, key)
+		if err != nil {
+			return zero, err
+		}
+
+		now := time.Now()
+		var value V
+		ok := found && now.Before(current.ExpiresAt)
+		if ok {
+			if err = json.Unmarshal([]byte(current.Value), &value); err != nil {
+				return zero, fmt.Errorf("decode value: %w", err)
+			}
+		}
+
+		value = update(value, ok)
+		model, err := d.newEntry(key, value, now)
+		if err != nil {
+			return zero, err
+		}
+
+		var result sql.Result
+		if found {
+			result, err = d.tx.Extract(ctx).NewUpdate().
+				Model(model).
+				Column("value", "version", "expires_at").
+				WherePK().
+				Where("version = ?", current.Version).
+				Exec(ctx)
+		} else {
+			result, err = d.tx.Extract(ctx).NewInsert().
+				Model(model).
+				On("CONFLICT DO NOTHING").
+				Exec(ctx)
+		}
+		if err != nil {
+			return zero, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return zero, err
+		}
+		if affected == 1 {
+			d.sweep(ctx)
+			return value, nil
+		}
+	}
+	return zero, errTooManyConflicts
+}
+
+func (d *Database[V]) Remove(ctx context.Context, key string) error {
+	_, err := d.tx.Extract(ctx).NewDelete().
+		Model((*databaseEntry)(nil)).
+		Where("id = ?", d.namespace+key).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// entry returns stored value, including expired one.
+func (d *Database[V]) entry(ctx context.Context, key string) (*databaseEntry, bool, error) {
+	var model databaseEntry
+	err := d.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("id = ?", d.namespace+key).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+func (d *Database[V]) newEntry(key string, value V, now time.Time) (*databaseEntry, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encode value: %w", err)
+	}
+
+	return &databaseEntry{
+		ID:        d.namespace + key,
+		Value:     string(data),
+		Version:   rand.Int64(), //nolint:gosec
+		ExpiresAt: now.Add(d.ttl),
+	}, nil
+}
+
+// sweep removes expired values at most once per TTL, so table doesn't grow with keys that are never set again.
+func (d *Database[V]) sweep(ctx context.Context) {
+	d.lock.Lock()
+	now := time.Now()
+	if now.Sub(d.lastSweep) < d.ttl {
+		d.lock.Unlock()
+		return
+	}
+	d.lastSweep = now
+	d.lock.Unlock()
+
+	_, err := d.tx.Extract(ctx).NewDelete().
+		Model((*databaseEntry)(nil)).
+		Where("id LIKE ?", d.namespace+"%").
+		Where("expires_at <= ?", now).
+		Exec(ctx)
+	if err != nil {
+		logger.Warnw(ctx, "remove expired cache values", "error", err)
+	}
+}
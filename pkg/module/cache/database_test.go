@@ -0,0 +1,68 @@
+package cache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/cache"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+)
+
+func TestDatabase(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		first := cache.NewDatabase[int](tx, "first", time.Minute)
+		second := cache.NewDatabase[int](tx, "second", time.Minute)
+
+		if err := first.Set(t.Context(), "key", 1); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+		if err := first.Set(t.Context(), "key", 2); err != nil {
+			t.Fatalf("set again: %v", err)
+		}
+		if value, ok, err := first.Get(t.Context(), "key"); err != nil || !ok || value != 2 {
+			t.Fatalf("get: value: %d, ok: %t, error: %v", value, ok, err)
+		}
+		if _, ok, err := second.Get(t.Context(), "key"); err != nil || ok {
+			t.Fatalf("get other namespace: ok: %t, error: %v", ok, err)
+		}
+
+		const updates = 20
+		var wg sync.WaitGroup
+		for range updates {
+			wg.Go(func() {
+				_, err := second.Update(t.Context(), "counter", func(value int, _ bool) int { return value + 1 })
+				if err != nil {
+					t.Errorf("update: %v", err)
+				}
+			})
+		}
+		wg.Wait()
+
+		if value, ok, err := second.Get(t.Context(), "counter"); err != nil || !ok || value != updates {
+			t.Errorf("get counter: value: %d, ok: %t, error: %v", value, ok, err)
+		}
+
+		if err := first.Remove(t.Context(), "key"); err != nil {
+			t.Fatalf("remove: %v", err)
+		}
+		if _, ok, err := first.Get(t.Context(), "key"); err != nil || ok {
+			t.Errorf("get removed: ok: %t, error: %v", ok, err)
+		}
+
+		expired := cache.NewDatabase[int](tx, "expired", -time.Second)
+		if err := expired.Set(t.Context(), "key", 1); err != nil {
+			t.Fatalf("set expired: %v", err)
+		}
+		value, err := expired.Update(t.Context(), "key", func(value int, ok bool) int {
+			if ok {
+				t.Error("unexpected expired value")
+			}
+			return value + 1
+		})
+		if err != nil || value != 1 {
+			t.Errorf("update expired: value: %d, error: %v", value, err)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package certificate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrNoEncryptionKey is returned if encryption key is not configured.
+var ErrNoEncryptionKey = errors.New("encryption key is not configured")
+
+// Cipher encrypts secrets stored in database with AES-GCM, key is derived from configured encryption key.
+type Cipher interface {
+	// Enabled reports whether encryption key is configured
+	Enabled() bool
+	Encrypt(plaintext []byte) (string, error)
+	Decrypt(ciphertext string) ([]byte, error)
+}
+
+type aeadCipher struct {
+	aead cipher.AEAD
+}
+
+func NewCipher(cfg Config) (Cipher, error) {
+	if cfg.EncryptionKey == "" {
+		return &aeadCipher{}, nil
+	}
+
+	key := sha256.Sum256([]byte(cfg.EncryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create GCM: %w", err)
+	}
+
+	return &aeadCipher{
+		aead: aead,
+	}, nil
+}
+
+func (c *aeadCipher) Enabled() bool {
+	return c.aead != nil
+}
+
+// Encrypt returns base64 encoded nonce followed by sealed plaintext.
+func (c *aeadCipher) Encrypt(plaintext []byte) (string, error) {
+	if c.aead == nil {
+		return "", ErrNoEncryptionKey
+	}
+
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	_, _ = rand.Read(nonce)
+
+	return base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func (c *aeadCipher) Decrypt(ciphertext string) ([]byte, error) {
+	if c.aead == nil {
+		return nil, ErrNoEncryptionKey
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	plaintext, err := c.aead.Open(nil, data[:c.aead.NonceSize()], data[c.aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,72 @@
+package certificate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+type Config struct {
+	// CertFile and KeyFile are default certificate, listeners serve TLS if it's set
+	CertFile string `validate:"omitempty,file"`
+	KeyFile  string `validate:"omitempty,file"`
+	// Files are certificates selected by server name, names are taken from certificates
+	Files []FileConfig `validate:"dive"`
+	// ReloadInterval is how often certificate files are checked for changes
+	ReloadInterval time.Duration `validate:"min=1s"`
+	// EncryptionKey encrypts private keys of certificates uploaded for custom domains, upload is disabled if empty
+	EncryptionKey string `validate:"omitempty,min=32" secret:"true"`
+}
+
+type FileConfig struct {
+	CertFile string `mapstructure:"cert-file" json:"cert-file" validate:"file"`
+	KeyFile  string `mapstructure:"key-file"  json:"key-file"  validate:"file"`
+}
+
+// Enabled reports whether TLS is configured.
+func (c Config) Enabled() bool {
+	return c.CertFile != ""
+}
+
+func init() { //nolint:gochecknoinits
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+		cfg := Config{
+			CertFile:       v.GetString("tls-cert-file"),
+			KeyFile:        v.GetString("tls-key-file"),
+			ReloadInterval: v.GetDuration("tls-reload-interval"),
+			EncryptionKey:  v.GetString("tls-encryption-key"),
+		}
+
+		// Config files have certificates as a list, environment variable has them as JSON
+		switch files := v.Get("tls-certificates").(type) {
+		case nil:
+		case string:
+			if files != "" {
+				if err := json.Unmarshal([]byte(files), &cfg.Files); err != nil {
+					return Config{}, fmt.Errorf("parse TLS certificates: %w", err)
+				}
+			}
+		default:
+			if err := v.UnmarshalKey("tls-certificates", &cfg.Files); err != nil {
+				return Config{}, fmt.Errorf("parse TLS certificates: %w", err)
+			}
+		}
+
+		if err := va.Struct(cfg); err != nil {
+			return Config{}, err
+		}
+		if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+			return Config{}, errors.New("both TLS certificate and key files are required")
+		}
+		if !cfg.Enabled() && len(cfg.Files) != 0 {
+			return Config{}, errors.New("default TLS certificate is required to use certificates selected by name")
+		}
+		return cfg, nil
+	})
+}
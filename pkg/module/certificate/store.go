@@ -0,0 +1,338 @@
+package certificate
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/cache"
+	"github.com/mymmrac/lithium/pkg/module/domain"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/routing"
+	"github.com/mymmrac/lithium/pkg/module/runner"
+)
+
+const (
+	uploadedCacheTTL = time.Minute
+	// uploadedRefreshInterval is how often names of domains with uploaded certificates are loaded
+	uploadedRefreshInterval = time.Minute
+)
+
+// Parse parses PEM encoded certificate chain and its private key, leaf certificate is parsed as well.
+func Parse(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf == nil {
+		return nil, errors.New("no leaf certificate")
+	}
+	return &cert, nil
+}
+
+// Store selects certificate by server name: certificates from files first, then certificates uploaded for custom
+// domains and default certificate otherwise. Files are reloaded once they change. Uploaded certificates are looked up
+// only for names of domains known to have them, names are refreshed and certificates are cached for a short time, so
+// certificates changed on other servers are picked up.
+type Store interface {
+	runner.Service
+
+	// GetCertificate returns certificate for handshake, it's meant to be used as tls.Config.GetCertificate
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	// Forget drops cached uploaded certificate of domain and reloads names of domains with certificates, so change is
+	// used on this server right away
+	Forget(ctx context.Context, name string)
+}
+
+type certificateFile struct {
+	cfg     FileConfig
+	modTime time.Time
+	cert    *tls.Certificate
+}
+
+type store struct {
+	cfg              Config
+	routingCfg       routing.Config
+	domainRepository domain.Repository
+	cipher           Cipher
+	uploaded         cache.Cache[string, *tls.Certificate]
+
+	// files are loaded only by reload loop, default certificate is the first one
+	files []*certificateFile
+
+	mu          sync.RWMutex
+	defaultCert *tls.Certificate
+	names       map[string]*tls.Certificate
+	// uploadedNames are names of verified domains with uploaded certificates
+	uploadedNames map[string]struct{}
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewStore(
+	ctx context.Context, cfg Config, routingCfg routing.Config, domainRepository domain.Repository, cipher Cipher,
+) (Store, error) {
+	s := &store{
+		cfg:              cfg,
+		routingCfg:       routingCfg,
+		domainRepository: domainRepository,
+		cipher:           cipher,
+		uploaded:         cache.NewInMemoryTTL[string, *tls.Certificate](uploadedCacheTTL),
+		done:             make(chan struct{}),
+	}
+	if !cfg.Enabled() {
+		return s, nil
+	}
+
+	s.files = append(s.files, &certificateFile{cfg: FileConfig{CertFile: cfg.CertFile, KeyFile: cfg.KeyFile}})
+	for _, fileCfg := range cfg.Files {
+		s.files = append(s.files, &certificateFile{cfg: fileCfg})
+	}
+
+	// Certificates must be valid on start, later invalid changes are only logged and previous certificates are kept
+	for _, file := range s.files {
+		if _, err := file.reload(); err != nil {
+			return nil, fmt.Errorf("load certificate %s: %w", file.cfg.CertFile, err)
+		}
+	}
+	s.index(ctx)
+
+	if err := s.refreshUploaded(ctx); err != nil {
+		return nil, fmt.Errorf("load uploaded certificates: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *store) Run(ctx context.Context) error {
+	// Without TLS there are no certificates to reload, but service still runs until stopped
+	var reloadTicks, refreshTicks <-chan time.Time
+	if s.cfg.Enabled() {
+		reloadTicker := time.NewTicker(s.cfg.ReloadInterval)
+		defer reloadTicker.Stop()
+		reloadTicks = reloadTicker.C
+
+		refreshTicker := time.NewTicker(uploadedRefreshInterval)
+		defer refreshTicker.Stop()
+		refreshTicks = refreshTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.done:
+			return nil
+		case <-reloadTicks:
+			s.reload(ctx)
+		case <-refreshTicks:
+			if err := s.refreshUploaded(ctx); err != nil {
+				logger.Errorw(ctx, "refresh uploaded certificates", "error", err)
+			}
+		}
+	}
+}
+
+func (s *store) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *store) reload(ctx context.Context) {
+	changed := false
+	for _, file := range s.files {
+		fileChanged, err := file.reload()
+		if err != nil {
+			logger.Errorw(ctx, "reload certificate", "file", file.cfg.CertFile, "error", err)
+			continue
+		}
+		if fileChanged {
+			logger.Infow(ctx, "certificate reloaded", "file", file.cfg.CertFile)
+			changed = true
+		}
+	}
+
+	if changed {
+		s.index(ctx)
+	}
+}
+
+// reload loads certificate if files were modified since the last load.
+func (f *certificateFile) reload() (bool, error) {
+	modTime, err := latestModTime(f.cfg.CertFile, f.cfg.KeyFile)
+	if err != nil {
+		return false, err
+	}
+	if f.cert != nil && modTime.Equal(f.modTime) {
+		return false, nil
+	}
+
+	// Invalid files are not loaded again until they are modified, so error is reported once
+	f.modTime = modTime
+	cert, err := tls.LoadX509KeyPair(f.cfg.CertFile, f.cfg.KeyFile)
+	if err != nil {
+		return false, err
+	}
+
+	f.cert = &cert
+	return true, nil
+}
+
+func latestModTime(filenames ...string) (time.Time, error) {
+	var latest time.Time
+	for _, filename := range filenames {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// index makes loaded certificates available to handshakes, certificates are indexed by names they are issued for and
+// the first certificate wins if names repeat.
+func (s *store) index(ctx context.Context) {
+	names := make(map[string]*tls.Certificate)
+	for _, file := range s.files[1:] {
+		for _, name := range file.cert.Leaf.DNSNames {
+			name = routing.Normalize(name)
+			if _, ok := names[name]; ok {
+				logger.Warnw(ctx, "certificate name is already used", "file", file.cfg.CertFile, "name", name)
+				continue
+			}
+			names[name] = file.cert
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultCert = s.files[0].cert
+	s.names = names
+}
+
+func (s *store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := routing.Normalize(hello.ServerName)
+	if name != "" {
+		if cert := s.fileCertificate(name); cert != nil {
+			return cert, nil
+		}
+
+		if s.routingCfg.Resolve(name).Custom && s.hasUploaded(name) {
+			ctx := hello.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			if cert := s.uploadedCertificate(ctx, name); cert != nil {
+				return cert, nil
+			}
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.defaultCert == nil {
+		return nil, errors.New("no default certificate")
+	}
+	return s.defaultCert, nil
+}
+
+func (s *store) fileCertificate(name string) *tls.Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if cert, ok := s.names[name]; ok {
+		return cert
+	}
+	if _, parent, ok := strings.Cut(name, "."); ok {
+		return s.names["*."+parent]
+	}
+	return nil
+}
+
+// refreshUploaded loads names of domains with uploaded certificates, handshakes with other names never query database.
+func (s *store) refreshUploaded(ctx context.Context) error {
+	names, err := s.domainRepository.GetCertificateNames(ctx)
+	if err != nil {
+		return err
+	}
+
+	uploadedNames := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		uploadedNames[routing.Normalize(name)] = struct{}{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploadedNames = uploadedNames
+	return nil
+}
+
+func (s *store) hasUploaded(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.uploadedNames[name]
+	return ok
+}
+
+// uploadedCertificate returns certificate uploaded for verified domain, it's called only for names of domains with
+// certificates, so cache doesn't grow with names requested by clients.
+func (s *store) uploadedCertificate(ctx context.Context, name string) *tls.Certificate {
+	cert, ok, err := s.uploaded.Get(ctx, name)
+	if err != nil {
+		logger.Errorw(ctx, "get cached certificate", "domain", name, "error", err)
+	}
+	if ok {
+		return cert
+	}
+
+	cert, err = s.loadUploaded(ctx, name)
+	if err != nil {
+		// Errors are not cached, so certificate is loaded again on the next handshake
+		logger.Errorw(ctx, "load uploaded certificate", "domain", name, "error", err)
+		return nil
+	}
+
+	if err = s.uploaded.Set(ctx, name, cert); err != nil {
+		logger.Errorw(ctx, "cache certificate", "domain", name, "error", err)
+	}
+	return cert
+}
+
+func (s *store) loadUploaded(ctx context.Context, name string) (*tls.Certificate, error) {
+	model, found, err := s.domainRepository.GetVerifiedByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("get domain: %w", err)
+	}
+	if !found || !model.HasCertificate() {
+		return nil, nil //nolint:nilnil
+	}
+
+	keyPEM, err := s.cipher.Decrypt(model.CertificateKey)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt key: %w", err)
+	}
+
+	return Parse([]byte(model.Certificate), keyPEM)
+}
+
+func (s *store) Forget(ctx context.Context, name string) {
+	if err := s.uploaded.Remove(ctx, name); err != nil {
+		logger.Errorw(ctx, "remove cached certificate", "domain", name, "error", err)
+	}
+	if !s.cfg.Enabled() {
+		return
+	}
+	if err := s.refreshUploaded(ctx); err != nil {
+		logger.Errorw(ctx, "refresh uploaded certificates", "error", err)
+	}
+}
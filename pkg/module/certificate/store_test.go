@@ -0,0 +1,211 @@
+package certificate_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/certificate"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+	"github.com/mymmrac/lithium/pkg/module/domain"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/routing"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+func TestStore(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		dir := t.TempDir()
+		cfg := certificate.Config{
+			ReloadInterval: time.Second,
+			EncryptionKey:  "some-very-long-encryption-key-for-tests",
+		}
+		cfg.CertFile, cfg.KeyFile = writeCertificate(t, dir, "default", "localhost")
+		wildcardCert, wildcardKey := writeCertificate(t, dir, "wildcard", "*.example.com", "example.com")
+		cfg.Files = []certificate.FileConfig{{CertFile: wildcardCert, KeyFile: wildcardKey}}
+
+		cipher, err := certificate.NewCipher(cfg)
+		if err != nil {
+			t.Fatalf("new cipher: %v", err)
+		}
+
+		domainRepository := &countingRepository{Repository: domain.NewRepository(tx)}
+		routingCfg := routing.Config{BaseDomains: []string{"example.com"}}
+		store, err := certificate.NewStore(t.Context(), cfg, routingCfg, domainRepository, cipher)
+		if err != nil {
+			t.Fatalf("new store: %v", err)
+		}
+
+		expectCertificate(t, store, "", "localhost")
+		expectCertificate(t, store, "project.example.com", "*.example.com")
+		expectCertificate(t, store, "Example.COM.", "*.example.com")
+		expectCertificate(t, store, "custom.test", "localhost")
+
+		certPEM, keyPEM := newCertificate(t, "custom.test")
+		encryptedKey, err := cipher.Encrypt(keyPEM)
+		if err != nil {
+			t.Fatalf("encrypt: %v", err)
+		}
+
+		now := time.Now()
+		domainModel := &domain.Model{
+			ID: id.New(), ProjectID: createProject(t, tx), Name: "custom.test", Token: "token", VerifiedAt: now,
+			CreatedAt: now, UpdatedAt: now,
+		}
+		if err = domainRepository.Create(t.Context(), domainModel); err != nil {
+			t.Fatalf("create domain: %v", err)
+		}
+		err = domainRepository.UpdateCertificate(t.Context(), domainModel.ID, string(certPEM), encryptedKey, now)
+		if err != nil {
+			t.Fatalf("update certificate: %v", err)
+		}
+
+		// Names of domains with certificates are loaded periodically or when certificate is forgotten
+		expectCertificate(t, store, "custom.test", "localhost")
+		store.Forget(t.Context(), "custom.test")
+		expectCertificate(t, store, "custom.test", "custom.test")
+
+		// Certificates are not looked up for other names
+		lookups := domainRepository.lookups
+		expectCertificate(t, store, "custom.test", "custom.test")
+		expectCertificate(t, store, "random.test", "localhost")
+		expectCertificate(t, store, "other.custom.test", "localhost")
+		if domainRepository.lookups != lookups {
+			t.Errorf("unexpected certificate lookups: %d", domainRepository.lookups-lookups)
+		}
+	})
+}
+
+func TestCipher(t *testing.T) {
+	cipher, err := certificate.NewCipher(certificate.Config{EncryptionKey: "some-very-long-encryption-key-for-tests"})
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+
+	ciphertext, err := cipher.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	plaintext, err := cipher.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if string(plaintext) != "secret" {
+		t.Errorf("unexpected plaintext: %q", plaintext)
+	}
+
+	other, err := certificate.NewCipher(certificate.Config{EncryptionKey: "other-very-long-encryption-key-for-tests"})
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+	if _, err = other.Decrypt(ciphertext); err == nil {
+		t.Error("expected error decrypting with other key")
+	}
+}
+
+func expectCertificate(t *testing.T, store certificate.Store, serverName, expectedName string) {
+	t.Helper()
+
+	cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+	if err != nil {
+		t.Errorf("%q: unexpected error: %v", serverName, err)
+		return
+	}
+	if cert.Leaf.DNSNames[0] != expectedName {
+		t.Errorf("%q: expected certificate of %q, got %q", serverName, expectedName, cert.Leaf.DNSNames[0])
+	}
+}
+
+func newCertificate(t *testing.T, names ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeCertificate(t *testing.T, dir, filename string, names ...string) (certFile, keyFile string) {
+	t.Helper()
+
+	certPEM, keyPEM := newCertificate(t, names...)
+	certFile = filepath.Join(dir, filename+".crt")
+	keyFile = filepath.Join(dir, filename+".key")
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func createProject(t *testing.T, tx db.Transaction) id.ID {
+	t.Helper()
+
+	now := time.Now()
+	userModel := &user.Model{ID: id.New(), Email: "owner@example.com", Password: "hash", CreatedAt: now, UpdatedAt: now}
+	if err := user.NewRepository(tx).Create(t.Context(), userModel); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	teamModel := &team.Model{ID: id.New(), Name: "Team", CreatedAt: now, UpdatedAt: now}
+	if err := team.NewRepository(tx).Create(t.Context(), teamModel); err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+
+	projectModel := &project.Model{
+		ID: id.New(), TeamID: teamModel.ID, OwnerID: userModel.ID, Name: "Project", SubDomain: "project",
+		CreatedAt: now, UpdatedAt: now,
+	}
+	if err := project.NewRepository(tx).Create(t.Context(), projectModel); err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	return projectModel.ID
+}
+
+// countingRepository counts lookups of uploaded certificates.
+type countingRepository struct {
+	domain.Repository
+
+	lookups int
+}
+
+func (r *countingRepository) GetVerifiedByName(ctx context.Context, name string) (*domain.Model, bool, error) {
+	r.lookups++
+	return r.Repository.GetVerifiedByName(ctx, name)
+}
@@ -0,0 +1,196 @@
+// Package cors applies cross-origin resource sharing policies to requests served by projects.
+package cors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// AnyOrigin allows requests from any origin.
+const AnyOrigin = "*"
+
+// Policy of cross-origin requests, cross-origin requests are not allowed without policy.
+type Policy struct {
+	// AllowOrigins are origins like `https://example.com`, `https://*.example.com` matches sub-domains and `*` matches
+	// any origin
+	AllowOrigins []string `json:"allowOrigins" validate:"gt=0,unique,dive,cors_origin"`
+	// AllowMethods default to methods of action
+	AllowMethods []string `json:"allowMethods,omitempty" validate:"unique,dive,oneof=GET HEAD POST PUT PATCH DELETE"`
+	// AllowHeaders default to headers requested by client, `*` allows any header
+	AllowHeaders     []string `json:"allowHeaders,omitempty"     validate:"unique,dive,min=1,max=128,printascii,excludesall=0x2C0x20"`
+	ExposeHeaders    []string `json:"exposeHeaders,omitempty"    validate:"unique,dive,min=1,max=128,printascii,excludesall=0x2C0x20"`
+	AllowCredentials bool     `json:"allowCredentials,omitempty" validate:"-"`
+	// MaxAge is number of seconds preflight response can be cached for, browsers use their default if zero
+	MaxAge int `json:"maxAge,omitempty" validate:"min=0,max=86400"`
+}
+
+// Validate checks rules that can't be expressed with validation tags.
+func (p *Policy) Validate() error {
+	if p.AllowCredentials && slices.Contains(p.AllowOrigins, AnyOrigin) {
+		return errors.New("credentials can't be allowed for any origin")
+	}
+	return nil
+}
+
+// Update of policy sent with config, it tells apart absent field that keeps current policy from `null` that removes it.
+type Update struct {
+	Policy *Policy `validate:"omitempty"`
+	Set    bool    `validate:"-"`
+}
+
+func (u *Update) UnmarshalJSON(data []byte) error {
+	u.Set = true
+	return json.Unmarshal(data, &u.Policy)
+}
+
+// Apply returns policy after update.
+func (u Update) Apply(current *Policy) *Policy {
+	if !u.Set {
+		return current
+	}
+	return u.Policy
+}
+
+// ValidOrigin reports whether origin can be used in policy.
+func ValidOrigin(origin string) bool {
+	if origin == AnyOrigin {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil || u.Opaque != "" ||
+		u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		return false
+	}
+
+	host := strings.TrimPrefix(u.Hostname(), "*.")
+	return host != "" && !strings.Contains(host, "*")
+}
+
+// AllowsOrigin reports whether requests from origin are allowed.
+func (p *Policy) AllowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.AllowOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == AnyOrigin || allowed == origin {
+			return true
+		}
+
+		// Wildcard matches at least one label: `https://*.example.com` matches `https://a.example.com`
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok &&
+			len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) &&
+			strings.HasSuffix(origin, suffix) && !strings.ContainsAny(origin[len(prefix):], "/:") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPreflight reports whether request is a preflight request.
+func IsPreflight(fCtx fiber.Ctx) bool {
+	return fCtx.Method() == fiber.MethodOptions && fCtx.Get(fiber.HeaderOrigin) != "" &&
+		fCtx.Get(fiber.HeaderAccessControlRequestMethod) != ""
+}
+
+// Preflight answers preflight request for action with methods, request is rejected by omitting CORS headers if
+// policy is nil or doesn't allow it.
+func Preflight(fCtx fiber.Ctx, policy *Policy, methods []string) error {
+	fCtx.Vary(fiber.HeaderOrigin, fiber.HeaderAccessControlRequestMethod, fiber.HeaderAccessControlRequestHeaders)
+	origin := fCtx.Get(fiber.HeaderOrigin)
+	if policy == nil || !policy.AllowsOrigin(origin) {
+		return fCtx.SendStatus(fiber.StatusNoContent)
+	}
+
+	allowMethods := policy.AllowMethods
+	if len(allowMethods) == 0 {
+		allowMethods = methods
+	}
+	if !slices.Contains(allowMethods, fCtx.Get(fiber.HeaderAccessControlRequestMethod)) {
+		return fCtx.SendStatus(fiber.StatusNoContent)
+	}
+
+	requestHeaders := fCtx.Get(fiber.HeaderAccessControlRequestHeaders)
+	if !policy.allowsHeaders(requestHeaders) {
+		return fCtx.SendStatus(fiber.StatusNoContent)
+	}
+
+	policy.setOrigin(fCtx, origin)
+	fCtx.Set(fiber.HeaderAccessControlAllowMethods, strings.Join(allowMethods, ", "))
+	if len(policy.AllowHeaders) == 0 {
+		if requestHeaders != "" {
+			fCtx.Set(fiber.HeaderAccessControlAllowHeaders, requestHeaders)
+		}
+	} else {
+		fCtx.Set(fiber.HeaderAccessControlAllowHeaders, strings.Join(policy.AllowHeaders, ", "))
+	}
+	if policy.MaxAge > 0 {
+		fCtx.Set(fiber.HeaderAccessControlMaxAge, strconv.Itoa(policy.MaxAge))
+	}
+
+	return fCtx.SendStatus(fiber.StatusNoContent)
+}
+
+// Decorate replaces CORS headers of response with headers of policy, headers set by module are kept if policy is nil.
+func Decorate(fCtx fiber.Ctx, policy *Policy) {
+	if policy == nil {
+		return
+	}
+
+	resp := &fCtx.Response().Header
+	resp.Del(fiber.HeaderAccessControlAllowOrigin)
+	resp.Del(fiber.HeaderAccessControlAllowCredentials)
+	resp.Del(fiber.HeaderAccessControlExposeHeaders)
+
+	fCtx.Vary(fiber.HeaderOrigin)
+	origin := fCtx.Get(fiber.HeaderOrigin)
+	if !policy.AllowsOrigin(origin) {
+		return
+	}
+
+	policy.setOrigin(fCtx, origin)
+	if len(policy.ExposeHeaders) != 0 {
+		fCtx.Set(fiber.HeaderAccessControlExposeHeaders, strings.Join(policy.ExposeHeaders, ", "))
+	}
+}
+
+// setOrigin sets headers that allow request from origin.
+func (p *Policy) setOrigin(fCtx fiber.Ctx, origin string) {
+	if slices.Contains(p.AllowOrigins, AnyOrigin) {
+		fCtx.Set(fiber.HeaderAccessControlAllowOrigin, AnyOrigin)
+	} else {
+		fCtx.Set(fiber.HeaderAccessControlAllowOrigin, origin)
+	}
+	if p.AllowCredentials {
+		fCtx.Set(fiber.HeaderAccessControlAllowCredentials, "true")
+	}
+}
+
+// allowsHeaders reports whether all requested headers (comma separated) are allowed.
+func (p *Policy) allowsHeaders(requestHeaders string) bool {
+	if len(p.AllowHeaders) == 0 || slices.Contains(p.AllowHeaders, "*") {
+		return true
+	}
+
+	for header := range strings.SplitSeq(requestHeaders, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if !slices.ContainsFunc(p.AllowHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, header)
+		}) {
+			return false
+		}
+	}
+	return true
+}
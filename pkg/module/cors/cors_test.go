@@ -0,0 +1,147 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mymmrac/lithium/pkg/module/cors"
+)
+
+func TestPolicyAllowsOrigin(t *testing.T) {
+	policy := &cors.Policy{AllowOrigins: []string{"https://example.com", "https://*.example.org"}}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://example.com", true},
+		{"https://EXAMPLE.com", true},
+		{"http://example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://a.example.org:8443", false},
+		{"https://evil.com/.example.org", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if allowed := policy.AllowsOrigin(tt.origin); allowed != tt.allowed {
+			t.Errorf("%q: expected allowed %t, got %t", tt.origin, tt.allowed, allowed)
+		}
+	}
+}
+
+func TestValidOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		valid  bool
+	}{
+		{"*", true},
+		{"https://example.com", true},
+		{"http://localhost:3000", true},
+		{"https://*.example.com", true},
+		{"https://example.com/", false},
+		{"ftp://example.com", false},
+		{"https://*", false},
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		if valid := cors.ValidOrigin(tt.origin); valid != tt.valid {
+			t.Errorf("%q: expected valid %t, got %t", tt.origin, tt.valid, valid)
+		}
+	}
+}
+
+func TestPreflightAndDecorate(t *testing.T) {
+	policy := &cors.Policy{
+		AllowOrigins:     []string{"https://example.com"},
+		AllowHeaders:     []string{"Content-Type"},
+		ExposeHeaders:    []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+
+	app := fiber.New()
+	app.Use(func(fCtx fiber.Ctx) error {
+		if cors.IsPreflight(fCtx) {
+			return cors.Preflight(fCtx, policy, []string{"GET", "POST"})
+		}
+		fCtx.Set(fiber.HeaderAccessControlAllowOrigin, "*")
+		cors.Decorate(fCtx, policy)
+		return fCtx.SendString("ok")
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		expected map[string]string
+	}{
+		{
+			name:   "preflight",
+			method: fiber.MethodOptions,
+			headers: map[string]string{
+				"Origin": "https://example.com", "Access-Control-Request-Method": "POST",
+				"Access-Control-Request-Headers": "content-type",
+			},
+			expected: map[string]string{
+				"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods": "GET, POST", "Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age": "600",
+			},
+		},
+		{
+			name:   "preflight with not allowed header",
+			method: fiber.MethodOptions,
+			headers: map[string]string{
+				"Origin": "https://example.com", "Access-Control-Request-Method": "POST",
+				"Access-Control-Request-Headers": "X-Other",
+			},
+			expected: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:   "preflight with not allowed method",
+			method: fiber.MethodOptions,
+			headers: map[string]string{
+				"Origin": "https://example.com", "Access-Control-Request-Method": "DELETE",
+			},
+			expected: map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:    "request",
+			method:  fiber.MethodGet,
+			headers: map[string]string{"Origin": "https://example.com"},
+			expected: map[string]string{
+				"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers": "X-Request-Id", "Vary": "Origin",
+			},
+		},
+		{
+			name:     "request from other origin",
+			method:   fiber.MethodGet,
+			headers:  map[string]string{"Origin": "https://other.com"},
+			expected: map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/", http.NoBody)
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: test request: %v", tt.name, err)
+		}
+		_ = resp.Body.Close()
+
+		for key, value := range tt.expected {
+			if actual := resp.Header.Get(key); actual != value {
+				t.Errorf("%s: %s: expected %q, got %q", tt.name, key, value, actual)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+type Config struct {
+	ConnectionString string `validate:"required" secret:"url"`
+	AutoMigrate      bool   `validate:"-"`
+}
+
+func init() { //nolint:gochecknoinits
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+		cfg := Config{
+			ConnectionString: v.GetString("db-connection-string"),
+			AutoMigrate:      v.GetBool("auto-migrate"),
+		}
+		if cfg.ConnectionString == "" {
+			// Kept for compatibility with installations that were set up before SQLite support
+			cfg.ConnectionString = v.GetString("postgres-connection-string")
+		}
+		if err := va.Struct(cfg); err != nil {
+			return Config{}, err
+		}
+		return cfg, nil
+	})
+}
@@ -0,0 +1,86 @@
+// Package dbtest runs tests against every supported database engine.
+package dbtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+)
+
+// PostgresEnv is the environment variable with Postgres connection string used by tests, Postgres tests are
+// skipped when it's not set
+const PostgresEnv = "POSTGRES_TEST_CONNECTION_STRING"
+
+// Run runs test against migrated SQLite and Postgres databases, each run gets an empty database
+func Run(t *testing.T, test func(t *testing.T, tx db.Transaction)) {
+	t.Helper()
+
+	t.Run("sqlite", func(t *testing.T) {
+		bunDB := open(t, "sqlite://"+filepath.Join(t.TempDir(), "lithium.db"))
+		test(t, db.NewTransaction(bunDB))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		connectionString := os.Getenv(PostgresEnv)
+		if connectionString == "" {
+			t.Skipf("%s is not set", PostgresEnv)
+		}
+
+		bunDB := open(t, withSchema(t, connectionString))
+		test(t, db.NewTransaction(bunDB))
+	})
+}
+
+// withSchema creates a separate schema, so tests don't interfere with each other or existing data
+func withSchema(t *testing.T, connectionString string) string {
+	t.Helper()
+
+	bunDB := connect(t, connectionString)
+	schema := "lithium_test_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	if _, err := bunDB.ExecContext(t.Context(), "CREATE SCHEMA ?", bun.Ident(schema)); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := bunDB.ExecContext(context.Background(), "DROP SCHEMA ? CASCADE", bun.Ident(schema)); err != nil {
+			t.Errorf("drop schema: %v", err)
+		}
+	})
+
+	separator := "?"
+	if strings.Contains(connectionString, "?") {
+		separator = "&"
+	}
+	return connectionString + separator + "search_path=" + schema
+}
+
+func open(t *testing.T, connectionString string) *bun.DB {
+	t.Helper()
+
+	bunDB := connect(t, connectionString)
+	if _, err := db.Migrate(t.Context(), bunDB); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return bunDB
+}
+
+func connect(t *testing.T, connectionString string) *bun.DB {
+	t.Helper()
+
+	bunDB, err := db.Open(t.Context(), connectionString)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = bunDB.Close() })
+
+	return bunDB
+}
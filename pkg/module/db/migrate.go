@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/migrate"
+
+	"github.com/mymmrac/lithium/migrations"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+)
+
+// migrationDirs maps database dialect to directory with its non-portable migrations
+//
+//nolint:gochecknoglobals
+var migrationDirs = map[dialect.Name]string{
+	dialect.PG:     "postgres",
+	dialect.SQLite: "sqlite",
+}
+
+// RunMigrations applies all pending migrations on start, unless auto migration is disabled by config.
+func RunMigrations(ctx context.Context, cfg Config, db *bun.DB) error {
+	if !cfg.AutoMigrate {
+		logger.Info(ctx, "auto migration disabled, skipping")
+		return nil
+	}
+
+	group, err := Migrate(ctx, db)
+	if err != nil {
+		return err
+	}
+	if !group.IsZero() {
+		logger.Infow(ctx, "migrated", "group", group.String())
+	}
+
+	return nil
+}
+
+// NewMigrator creates initialized migrator with embedded migrations for the database dialect.
+func NewMigrator(ctx context.Context, db *bun.DB) (*migrate.Migrator, error) {
+	dir, ok := migrationDirs[db.Dialect().Name()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database dialect: %s", db.Dialect().Name())
+	}
+
+	ms := migrate.NewMigrations()
+	if err := ms.Discover(migrations.Dialect(dir)); err != nil {
+		return nil, fmt.Errorf("discover migrations: %w", err)
+	}
+
+	migrator := migrate.NewMigrator(db, ms,
+		migrate.WithTableName("migrations"),
+		migrate.WithLocksTableName("migrations_locks"),
+	)
+
+	if err := migrator.Init(ctx); err != nil {
+		return nil, fmt.Errorf("migrator init: %w", err)
+	}
+
+	return migrator, nil
+}
+
+// Migrate applies all pending migrations as a new group.
+func Migrate(ctx context.Context, db *bun.DB) (*migrate.MigrationGroup, error) {
+	return withLock(ctx, db, func(migrator *migrate.Migrator) (*migrate.MigrationGroup, error) {
+		group, err := migrator.Migrate(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+		return group, nil
+	})
+}
+
+// Rollback rolls back the last migration group.
+func Rollback(ctx context.Context, db *bun.DB) (*migrate.MigrationGroup, error) {
+	return withLock(ctx, db, func(migrator *migrate.Migrator) (*migrate.MigrationGroup, error) {
+		group, err := migrator.Rollback(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("rollback: %w", err)
+		}
+		return group, nil
+	})
+}
+
+// MarkApplied marks migrations as applied without running them, all pending migrations are marked if no names
+// provided. Migration can be referenced by its timestamp or full name (20250923193846_add_user_table).
+func MarkApplied(ctx context.Context, db *bun.DB, names []string) (*migrate.MigrationGroup, error) {
+	return withLock(ctx, db, func(migrator *migrate.Migrator) (*migrate.MigrationGroup, error) {
+		if len(names) == 0 {
+			group, err := migrator.Migrate(ctx, migrate.WithNopMigration())
+			if err != nil {
+				return nil, fmt.Errorf("mark applied: %w", err)
+			}
+			return group, nil
+		}
+
+		ms, err := migrator.MigrationsWithStatus(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("migrations status: %w", err)
+		}
+
+		group := &migrate.MigrationGroup{
+			ID: ms.LastGroupID() + 1,
+		}
+		for _, name := range names {
+			index := slices.IndexFunc(ms, func(m migrate.Migration) bool {
+				return m.Name == name || m.String() == name
+			})
+			if index == -1 {
+				return nil, fmt.Errorf("migration %q not found", name)
+			}
+
+			migration := ms[index]
+			if migration.IsApplied() {
+				return nil, fmt.Errorf("migration %q already applied", name)
+			}
+
+			migration.GroupID = group.ID
+			if err = migrator.MarkApplied(ctx, &migration); err != nil {
+				return nil, fmt.Errorf("mark applied %q: %w", name, err)
+			}
+			group.Migrations = append(group.Migrations, migration)
+		}
+
+		return group, nil
+	})
+}
+
+func withLock(
+	ctx context.Context, db *bun.DB, fn func(migrator *migrate.Migrator) (*migrate.MigrationGroup, error),
+) (*migrate.MigrationGroup, error) {
+	migrator, err := NewMigrator(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = migrator.Lock(ctx); err != nil {
+		return nil, fmt.Errorf("lock migrations: %w", err)
+	}
+	defer func() {
+		if err := migrator.Unlock(ctx); err != nil {
+			logger.Errorw(ctx, "unlock migrations", "error", err)
+		}
+	}()
+
+	return fn(migrator)
+}
+
+var migrationNameRegexp = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`) //nolint:gochecknoglobals
+
+// CreateMigration creates empty portable up and down migration files in dir and returns their paths. Migration that
+// can't be portable is placed in directory of each database instead.
+func CreateMigration(dir, name string) ([]string, error) {
+	if !migrationNameRegexp.MatchString(name) {
+		return nil, errors.New("migration name must be in snake_case, for example: add_user_table")
+	}
+
+	prefix := time.Now().UTC().Format("20060102150405") + "_" + name
+
+	var files []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, prefix+"."+direction+".sql")
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			return files, fmt.Errorf("create migration file: %w", err)
+		}
+		files = append(files, path)
+	}
+
+	return files, nil
+}
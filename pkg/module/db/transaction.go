@@ -36,6 +36,12 @@ type transaction struct {
 	db *bun.DB
 }
 
+func NewTransaction(db *bun.DB) Transaction {
+	return &transaction{
+		db: db,
+	}
+}
+
 // transactionKey key for transaction in context.
 //
 //nolint:gochecknoglobals
@@ -0,0 +1,33 @@
+package deploy
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+type Config struct {
+	ModuleBucket  string `validate:"required"`
+	MaxModuleSize int64  `validate:"min=1"`
+	Workers       uint   `validate:"min=1"`
+	// LeaseTimeout limits processing of a deploy, deploys claimed earlier are abandoned by their worker
+	LeaseTimeout time.Duration `validate:"min=1s"`
+}
+
+func init() { //nolint:gochecknoinits
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+		cfg := Config{
+			ModuleBucket:  v.GetString("module-bucket"),
+			MaxModuleSize: v.GetInt64("module-max-size"),
+			Workers:       v.GetUint("deploy-workers"),
+			LeaseTimeout:  v.GetDuration("deploy-lease-timeout"),
+		}
+		if err := va.Struct(cfg); err != nil {
+			return Config{}, err
+		}
+		return cfg, nil
+	})
+}
@@ -0,0 +1,33 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/storage"
+)
+
+// DeleteWithModules deletes deploys of action together with modules uploaded by them, including modules of deploys
+// that are not finished yet. Modules are deleted first, so deploys are kept if it fails. Returns paths of deleted modules.
+func DeleteWithModules(
+	ctx context.Context, repository Repository, storage storage.Storage, bucket string, actionID id.ID,
+) ([]string, error) {
+	modulePaths, err := repository.GetModulePathsByActionID(ctx, actionID)
+	if err != nil {
+		return nil, fmt.Errorf("get deploy modules: %w", err)
+	}
+
+	// Modules of finished deploys may be already deleted, deletion of missing module succeeds
+	for _, modulePath := range modulePaths {
+		if err = storage.Delete(ctx, bucket, modulePath); err != nil {
+			return nil, fmt.Errorf("delete deploy module %q: %w", modulePath, err)
+		}
+	}
+
+	if err = repository.DeleteByActionID(ctx, actionID); err != nil {
+		return nil, fmt.Errorf("delete deploys: %w", err)
+	}
+
+	return modulePaths, nil
+}
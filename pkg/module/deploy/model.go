@@ -0,0 +1,39 @@
+package deploy
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+// Status of the deploy.
+type Status string
+
+// Statuses
+const (
+	StatusPending    Status = "pending"
+	StatusValidating Status = "validating"
+	StatusCompiling  Status = "compiling"
+	StatusReady      Status = "ready"
+	StatusFailed     Status = "failed"
+)
+
+// Finished reports whether deploy reached its final status.
+func (s Status) Finished() bool {
+	return s == StatusReady || s == StatusFailed
+}
+
+type Model struct {
+	bun.BaseModel `bun:"table:deploy"`
+
+	ID         id.ID     `bun:"id,pk"`
+	ActionID   id.ID     `bun:"action_id"`
+	Status     Status    `bun:"status"`
+	Error      string    `bun:"error"`
+	ModulePath string    `bun:"module_path"`
+	Attempts   int       `bun:"attempts"`
+	CreatedAt  time.Time `bun:"created_at"`
+	UpdatedAt  time.Time `bun:"updated_at"`
+}
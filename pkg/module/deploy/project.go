@@ -0,0 +1,112 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/domain"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/storage"
+	"github.com/mymmrac/lithium/pkg/module/token"
+)
+
+// ProjectDeleter deletes projects with everything that belongs to them.
+type ProjectDeleter interface {
+	// Delete deletes project with its actions, deploys, API tokens and domains, returns number of deleted modules
+	Delete(ctx context.Context, projectID id.ID) (int, error)
+}
+
+type projectDeleter struct {
+	cfg               Config
+	tx                db.Transaction
+	storage           storage.Storage
+	actionCache       action.Cache
+	actionRepository  action.Repository
+	deployRepository  Repository
+	tokenRepository   token.Repository
+	domainRepository  domain.Repository
+	projectRepository project.Repository
+}
+
+func NewProjectDeleter(
+	cfg Config, tx db.Transaction, storage storage.Storage, actionCache action.Cache,
+	actionRepository action.Repository, deployRepository Repository, tokenRepository token.Repository,
+	domainRepository domain.Repository, projectRepository project.Repository,
+) ProjectDeleter {
+	return &projectDeleter{
+		cfg:               cfg,
+		tx:                tx,
+		storage:           storage,
+		actionCache:       actionCache,
+		actionRepository:  actionRepository,
+		deployRepository:  deployRepository,
+		tokenRepository:   tokenRepository,
+		domainRepository:  domainRepository,
+		projectRepository: projectRepository,
+	}
+}
+
+// Delete removes modules of each action before its rows in the same transaction, so nothing is deleted from database
+// if storage fails and deletion can be retried. Actions are removed from cache after commit.
+func (d *projectDeleter) Delete(ctx context.Context, projectID id.ID) (int, error) {
+	txCtx, err := d.tx.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = d.tx.Rollback(txCtx) }()
+
+	actions, err := d.actionRepository.GetByProjectID(txCtx, projectID)
+	if err != nil {
+		return 0, fmt.Errorf("get actions: %w", err)
+	}
+
+	var deletedModules int
+	for _, actionModel := range actions {
+		modulePaths, err := DeleteWithModules(txCtx, d.deployRepository, d.storage, d.cfg.ModuleBucket, actionModel.ID)
+		if err != nil {
+			return 0, fmt.Errorf("delete action deploys: %w", err)
+		}
+		deletedModules += len(modulePaths)
+
+		if err = d.actionRepository.DeleteByID(txCtx, actionModel.ID); err != nil {
+			return 0, fmt.Errorf("delete action: %w", err)
+		}
+
+		// Active module is uploaded by deploy, so it's usually deleted already
+		if actionModel.ModulePath != "" && !slices.Contains(modulePaths, actionModel.ModulePath) {
+			if err = d.storage.Delete(txCtx, d.cfg.ModuleBucket, actionModel.ModulePath); err != nil {
+				return 0, fmt.Errorf("delete action module: %w", err)
+			}
+			deletedModules++
+		}
+	}
+
+	if err = d.tokenRepository.DeleteByProjectID(txCtx, projectID); err != nil {
+		return 0, fmt.Errorf("delete tokens: %w", err)
+	}
+
+	if err = d.domainRepository.DeleteByProjectID(txCtx, projectID); err != nil {
+		return 0, fmt.Errorf("delete domains: %w", err)
+	}
+
+	if err = d.projectRepository.DeleteByID(txCtx, projectID); err != nil {
+		return 0, fmt.Errorf("delete project: %w", err)
+	}
+
+	if err = d.tx.Commit(txCtx); err != nil {
+		return 0, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	for _, actionModel := range actions {
+		if err = d.actionCache.Remove(ctx, actionModel.ID); err != nil {
+			logger.Warnw(ctx, "remove action from cache", "id", actionModel.ID, "error", err)
+		}
+	}
+
+	return deletedModules, nil
+}
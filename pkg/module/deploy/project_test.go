@@ -0,0 +1,88 @@
+package deploy_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+	"github.com/mymmrac/lithium/pkg/module/deploy"
+	"github.com/mymmrac/lithium/pkg/module/domain"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/storage"
+	"github.com/mymmrac/lithium/pkg/module/token"
+)
+
+func TestProjectDeleter(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		const bucket = "modules"
+
+		s, err := storage.NewFilesystem(storage.FilesystemConfig{Path: t.TempDir()})
+		if err != nil {
+			t.Fatalf("create storage: %v", err)
+		}
+
+		actionCache := action.NewCache()
+		actionRepository := action.NewRepository(tx)
+		deployRepository := deploy.NewRepository(tx)
+		projectRepository := project.NewRepository(tx)
+		deleter := deploy.NewProjectDeleter(
+			deploy.Config{ModuleBucket: bucket}, tx, s, actionCache, actionRepository, deployRepository,
+			token.NewRepository(tx), domain.NewRepository(tx), projectRepository,
+		)
+
+		actionID := createAction(t, tx)
+		actionModel, _, err := actionRepository.GetByID(t.Context(), actionID)
+		if err != nil {
+			t.Fatalf("get action: %v", err)
+		}
+
+		now := time.Now()
+		for _, modulePath := range []string{"first.wasm", "second.wasm"} {
+			if err = s.Upload(t.Context(), bucket, modulePath, strings.NewReader("module"), 6, ""); err != nil {
+				t.Fatalf("upload module: %v", err)
+			}
+			err = deployRepository.Create(t.Context(), &deploy.Model{
+				ID:         id.New(),
+				ActionID:   actionID,
+				Status:     deploy.StatusReady,
+				ModulePath: modulePath,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			})
+			if err != nil {
+				t.Fatalf("create deploy: %v", err)
+			}
+		}
+		if _, err = actionRepository.UpdateModule(t.Context(), actionID, "", "second.wasm", 6, nil); err != nil {
+			t.Fatalf("update module: %v", err)
+		}
+		if err = actionCache.Set(t.Context(), actionID, action.Module{}); err != nil {
+			t.Fatalf("set cache: %v", err)
+		}
+
+		deleted, err := deleter.Delete(t.Context(), actionModel.ProjectID)
+		if err != nil || deleted != 2 {
+			t.Fatalf("delete: deleted: %d, error: %v", deleted, err)
+		}
+
+		if _, found, err := projectRepository.GetByID(t.Context(), actionModel.ProjectID); err != nil || found {
+			t.Errorf("get project: found: %t, error: %v", found, err)
+		}
+		if _, found, err := actionRepository.GetByID(t.Context(), actionID); err != nil || found {
+			t.Errorf("get action: found: %t, error: %v", found, err)
+		}
+		if paths, err := deployRepository.GetModulePathsByActionID(t.Context(), actionID); err != nil || len(paths) != 0 {
+			t.Errorf("get deploy modules: %v, error: %v", paths, err)
+		}
+		if objects, err := s.List(t.Context(), bucket, ""); err != nil || len(objects) != 0 {
+			t.Errorf("list modules: %v, error: %v", objects, err)
+		}
+		if _, found, err := actionCache.Get(t.Context(), actionID); err != nil || found {
+			t.Errorf("get cache: found: %t, error: %v", found, err)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package deploy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type Repository interface {
+	Create(ctx context.Context, model *Model) error
+	GetByID(ctx context.Context, id id.ID) (*Model, bool, error)
+	GetByActionID(ctx context.Context, actionID id.ID, limit int) ([]Model, error)
+	GetByStatus(ctx context.Context, status Status) ([]Model, error)
+	ExistsNewerReady(ctx context.Context, actionID id.ID, id id.ID) (bool, error)
+	GetStale(ctx context.Context, before time.Time) ([]Model, error)
+	GetModulePathsByActionID(ctx context.Context, actionID id.ID) ([]string, error)
+	Claim(ctx context.Context, id id.ID) (bool, error)
+	Release(ctx context.Context, id id.ID, before time.Time, status Status, errorMessage string) (bool, error)
+	UpdateStatus(ctx context.Context, id id.ID, status Status, errorMessage string) error
+	DeleteByActionID(ctx context.Context, actionID id.ID) error
+}
+
+type repository struct {
+	tx db.Transaction
+}
+
+func NewRepository(tx db.Transaction) Repository {
+	return &repository{
+		tx: tx,
+	}
+}
+
+func (r *repository) Create(ctx context.Context, model *Model) error {
+	_, err := r.tx.Extract(ctx).NewInsert().Model(model).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetByID(ctx context.Context, id id.ID) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+func (r *repository) GetByActionID(ctx context.Context, actionID id.ID, limit int) ([]Model, error) {
+	var models []Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&models).
+		Where("action_id = ?", actionID).
+		Order("id DESC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+func (r *repository) GetByStatus(ctx context.Context, status Status) ([]Model, error) {
+	var models []Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&models).
+		Where("status = ?", status).
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+func (r *repository) ExistsNewerReady(ctx context.Context, actionID id.ID, id id.ID) (bool, error) {
+	exists, err := r.tx.Extract(ctx).NewSelect().
+		Model((*Model)(nil)).
+		Where("action_id = ?", actionID).
+		Where("id > ?", id).
+		Where("status = ?", StatusReady).
+		Exists(ctx)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// GetStale returns deploys that are in progress, but weren't updated since before.
+func (r *repository) GetStale(ctx context.Context, before time.Time) ([]Model, error) {
+	var models []Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&models).
+		Where("status IN (?)", bun.In([]Status{StatusValidating, StatusCompiling})).
+		Where("updated_at < ?", before).
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// GetModulePathsByActionID returns paths of modules uploaded by deploys of action.
+func (r *repository) GetModulePathsByActionID(ctx context.Context, actionID id.ID) ([]string, error) {
+	var paths []string
+	err := r.tx.Extract(ctx).NewSelect().
+		Model((*Model)(nil)).
+		Distinct().
+		Column("module_path").
+		Where("action_id = ?", actionID).
+		Where("module_path != ''").
+		Scan(ctx, &paths)
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// Claim moves pending deploy into validating status and counts the attempt, reports false if deploy was already
+// claimed.
+func (r *repository) Claim(ctx context.Context, id id.ID) (bool, error) {
+	result, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("status = ?", StatusValidating).
+		Set("attempts = attempts + 1").
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Where("status = ?", StatusPending).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// Release moves stale deploy into status, reports false if deploy was updated since before or is not in progress.
+func (r *repository) Release(
+	ctx context.Context, id id.ID, before time.Time, status Status, errorMessage string,
+) (bool, error) {
+	result, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("status = ?", status).
+		Set("error = ?", errorMessage).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Where("status IN (?)", bun.In([]Status{StatusValidating, StatusCompiling})).
+		Where("updated_at < ?", before).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *repository) UpdateStatus(ctx context.Context, id id.ID, status Status, errorMessage string) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("status = ?", status).
+		Set("error = ?", errorMessage).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteByActionID(ctx context.Context, actionID id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("action_id = ?", actionID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
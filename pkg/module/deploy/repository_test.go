@@ -0,0 +1,134 @@
+package deploy_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+	"github.com/mymmrac/lithium/pkg/module/deploy"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+func TestRepositoryLease(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		actionID := createAction(t, tx)
+		repository := deploy.NewRepository(tx)
+
+		now := time.Now()
+		model := &deploy.Model{
+			ID:         id.New(),
+			ActionID:   actionID,
+			Status:     deploy.StatusPending,
+			ModulePath: "module.wasm",
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if err := repository.Create(t.Context(), model); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		claimed, err := repository.Claim(t.Context(), model.ID)
+		if err != nil || !claimed {
+			t.Fatalf("claim: claimed: %t, error: %v", claimed, err)
+		}
+		if claimed, err = repository.Claim(t.Context(), model.ID); err != nil || claimed {
+			t.Fatalf("claim again: claimed: %t, error: %v", claimed, err)
+		}
+
+		// Deploy was just claimed, so its lease is not expired yet
+		stale, err := repository.GetStale(t.Context(), time.Now().Add(-time.Minute))
+		if err != nil || len(stale) != 0 {
+			t.Fatalf("get stale: %v, error: %v", stale, err)
+		}
+
+		before := time.Now().Add(time.Minute)
+		stale, err = repository.GetStale(t.Context(), before)
+		if err != nil || len(stale) != 1 || stale[0].ID != model.ID || stale[0].Attempts != 1 {
+			t.Fatalf("get stale: %v, error: %v", stale, err)
+		}
+
+		released, err := repository.Release(t.Context(), model.ID, before, deploy.StatusPending, "")
+		if err != nil || !released {
+			t.Fatalf("release: released: %t, error: %v", released, err)
+		}
+		if released, err = repository.Release(t.Context(), model.ID, before, deploy.StatusFailed, ""); err != nil ||
+			released {
+			t.Fatalf("release pending: released: %t, error: %v", released, err)
+		}
+
+		if claimed, err = repository.Claim(t.Context(), model.ID); err != nil || !claimed {
+			t.Fatalf("claim released: claimed: %t, error: %v", claimed, err)
+		}
+
+		got, found, err := repository.GetByID(t.Context(), model.ID)
+		if err != nil || !found {
+			t.Fatalf("get by id: found: %t, error: %v", found, err)
+		}
+		if got.Status != deploy.StatusValidating || got.Attempts != 2 {
+			t.Errorf("unexpected deploy: %+v", got)
+		}
+
+		paths, err := repository.GetModulePathsByActionID(t.Context(), actionID)
+		if err != nil || len(paths) != 1 || paths[0] != model.ModulePath {
+			t.Errorf("get module paths: %v, error: %v", paths, err)
+		}
+	})
+}
+
+func createAction(t *testing.T, tx db.Transaction) id.ID {
+	t.Helper()
+
+	now := time.Now()
+	owner := &user.Model{
+		ID:        id.New(),
+		Email:     "owner@example.com",
+		Password:  "hash",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := user.NewRepository(tx).Create(t.Context(), owner); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	teamModel := &team.Model{
+		ID:        id.New(),
+		Name:      "Team",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := team.NewRepository(tx).Create(t.Context(), teamModel); err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+
+	projectModel := &project.Model{
+		ID:        id.New(),
+		TeamID:    teamModel.ID,
+		OwnerID:   owner.ID,
+		Name:      "Project",
+		SubDomain: "project",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := project.NewRepository(tx).Create(t.Context(), projectModel); err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+
+	model := &action.Model{
+		ID:        id.New(),
+		ProjectID: projectModel.ID,
+		Name:      "Action",
+		Path:      "/",
+		Methods:   []string{"GET"},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := action.NewRepository(tx).Create(t.Context(), model); err != nil {
+		t.Fatalf("create action: %v", err)
+	}
+	return model.ID
+}
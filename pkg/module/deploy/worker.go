@@ -0,0 +1,377 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/runner"
+	"github.com/mymmrac/lithium/pkg/module/storage"
+	"github.com/mymmrac/lithium/pkg/module/wasm"
+)
+
+const (
+	queueSize      = 128
+	sweepInterval  = 30 * time.Second
+	failureTimeout = 10 * time.Second
+	// maxAttempts limits retries of abandoned deploys, so a module that crashes worker isn't processed forever
+	maxAttempts = 3
+	// maxActivateRetries is how many times activation is retried when action module is changed concurrently
+	maxActivateRetries = 8
+)
+
+// Worker processes deploys in background.
+type Worker interface {
+	runner.Service
+
+	// Enqueue schedules deploy for processing, deploy stays pending and will be picked up later if queue is full
+	Enqueue(ctx context.Context, deployID id.ID)
+}
+
+type worker struct {
+	cfg               Config
+	tx                db.Transaction
+	storage           storage.Storage
+	actionCache       action.Cache
+	actionRepository  action.Repository
+	projectRepository project.Repository
+	deployRepository  Repository
+
+	queue    chan id.ID
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewWorker(
+	cfg Config, tx db.Transaction, storage storage.Storage, actionCache action.Cache,
+	actionRepository action.Repository, projectRepository project.Repository, deployRepository Repository,
+) Worker {
+	return &worker{
+		cfg:               cfg,
+		tx:                tx,
+		storage:           storage,
+		actionCache:       actionCache,
+		actionRepository:  actionRepository,
+		projectRepository: projectRepository,
+		deployRepository:  deployRepository,
+		queue:             make(chan id.ID, queueSize),
+		done:              make(chan struct{}),
+	}
+}
+
+func (w *worker) Enqueue(ctx context.Context, deployID id.ID) {
+	select {
+	case w.queue <- deployID:
+	default:
+		logger.Warnw(ctx, "deploy queue is full, postponing deploy", "deploy-id", deployID)
+	}
+}
+
+func (w *worker) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-w.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for range w.cfg.Workers {
+		wg.Go(func() {
+			w.work(ctx)
+		})
+	}
+
+	w.sweep(ctx)
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case <-ticker.C:
+			w.sweep(ctx)
+		}
+	}
+}
+
+func (w *worker) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+// sweep releases abandoned deploys and enqueues pending ones, it picks up deploys that were postponed or left after
+// restart.
+func (w *worker) sweep(ctx context.Context) {
+	w.releaseStale(ctx)
+
+	deploys, err := w.deployRepository.GetByStatus(ctx, StatusPending)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Errorw(ctx, "get pending deploys", "error", err)
+		}
+		return
+	}
+
+	for _, deployModel := range deploys {
+		w.Enqueue(ctx, deployModel.ID)
+	}
+}
+
+// releaseStale returns deploys abandoned by their worker (for example, after crash or restart) to pending status, or
+// fails them if they were attempted too many times. Processing of deploy is limited by lease timeout, so deploy that
+// wasn't updated for longer is not processed anymore.
+func (w *worker) releaseStale(ctx context.Context) {
+	// Worker that timed out has time to record failure
+	before := time.Now().Add(-w.cfg.LeaseTimeout - failureTimeout)
+
+	deploys, err := w.deployRepository.GetStale(ctx, before)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Errorw(ctx, "get stale deploys", "error", err)
+		}
+		return
+	}
+
+	for _, deployModel := range deploys {
+		ctx := logger.With(ctx, "deploy-id", deployModel.ID)
+
+		if deployModel.Attempts < maxAttempts {
+			released, err := w.deployRepository.Release(ctx, deployModel.ID, before, StatusPending, "")
+			if err != nil {
+				logger.Errorw(ctx, "release stale deploy", "error", err)
+			} else if released {
+				logger.Warnw(ctx, "stale deploy released", "attempts", deployModel.Attempts)
+			}
+			continue
+		}
+
+		released, err := w.deployRepository.Release(ctx, deployModel.ID, before, StatusFailed,
+			fmt.Sprintf("deploy interrupted %d times", deployModel.Attempts),
+		)
+		if err != nil {
+			logger.Errorw(ctx, "fail stale deploy", "error", err)
+			continue
+		}
+		if !released {
+			continue
+		}
+
+		logger.Warnw(ctx, "stale deploy failed", "attempts", deployModel.Attempts)
+		if err = w.storage.Delete(ctx, w.cfg.ModuleBucket, deployModel.ModulePath); err != nil {
+			logger.Warnw(ctx, "delete failed deploy module", "path", deployModel.ModulePath, "error", err)
+		}
+	}
+}
+
+func (w *worker) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case deployID := <-w.queue:
+			w.process(logger.With(ctx, "deploy-id", deployID), deployID)
+		}
+	}
+}
+
+func (w *worker) process(ctx context.Context, deployID id.ID) {
+	// Deploy that takes longer than lease is released by sweep, so it must not be processed after that
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.LeaseTimeout)
+	defer cancel()
+
+	claimed, err := w.deployRepository.Claim(ctx, deployID)
+	if err != nil {
+		logger.Errorw(ctx, "claim deploy", "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	deployModel, found, err := w.deployRepository.GetByID(ctx, deployID)
+	if err != nil || !found {
+		w.fail(ctx, deployID, "", fmt.Errorf("get deploy: found %t, error %w", found, err))
+		return
+	}
+
+	if err = w.deploy(ctx, deployModel); err != nil {
+		w.fail(ctx, deployID, deployModel.ModulePath, err)
+		return
+	}
+
+	logger.Infow(ctx, "deploy ready", "action-id", deployModel.ActionID)
+}
+
+func (w *worker) deploy(ctx context.Context, deployModel *Model) error {
+	actionModel, found, err := w.actionRepository.GetByID(ctx, deployModel.ActionID)
+	if err != nil {
+		return fmt.Errorf("get action: %w", err)
+	}
+	if !found {
+		return errors.New("action was deleted")
+	}
+
+	projectModel, found, err := w.projectRepository.GetByID(ctx, actionModel.ProjectID)
+	if err != nil {
+		return fmt.Errorf("get project: %w", err)
+	}
+	if !found {
+		return errors.New("project was deleted")
+	}
+
+	moduleData, err := storage.ReadAll(ctx, w.storage, w.cfg.ModuleBucket, deployModel.ModulePath,
+		actionModel.Config.ModuleSizeLimit(w.cfg.MaxModuleSize),
+	)
+	if err != nil {
+		return fmt.Errorf("download module: %w", err)
+	}
+
+	report, err := wasm.Inspect(moduleData)
+	if err != nil {
+		return fmt.Errorf("invalid module: %w", err)
+	}
+
+	if err = report.Validate(actionModel.Config.Network); err != nil {
+		return fmt.Errorf("unsupported module: %w", err)
+	}
+
+	if err = w.deployRepository.UpdateStatus(ctx, deployModel.ID, StatusCompiling, ""); err != nil {
+		return fmt.Errorf("update deploy status: %w", err)
+	}
+
+	module, err := action.Compile(ctx, projectModel, actionModel, moduleData)
+	if err != nil {
+		return err
+	}
+
+	previousModulePath, err := w.activate(ctx, deployModel, int64(len(moduleData)), report)
+	if err != nil {
+		return err
+	}
+
+	if err = w.actionCache.Set(ctx, actionModel.ID, module); err != nil {
+		logger.Warnw(ctx, "set action module cache", "error", err)
+	}
+
+	if previousModulePath != "" && previousModulePath != deployModel.ModulePath {
+		if err = w.storage.Delete(ctx, w.cfg.ModuleBucket, previousModulePath); err != nil {
+			logger.Warnw(ctx, "delete previous action module", "path", previousModulePath, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// activate atomically switches action to the deployed module, returns path of previously active module.
+func (w *worker) activate(
+	ctx context.Context, deployModel *Model, moduleSize int64, report *wasm.Report,
+) (string, error) {
+	for range maxActivateRetries {
+		previousModulePath, activated, err := w.tryActivate(ctx, deployModel, moduleSize, report)
+		if err != nil || activated {
+			return previousModulePath, err
+		}
+	}
+	return "", errors.New("action module is changed concurrently too often")
+}
+
+// tryActivate switches action to the deployed module only if module wasn't changed since it was read, so concurrent
+// deploy can't overwrite newer module or make previous module path stale. Reports false if it should be retried.
+func (w *worker) tryActivate(
+	ctx context.Context, deployModel *Model, moduleSize int64, report *wasm.Report,
+) (string, bool, error) {
+	ctx, err := w.tx.Begin(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = w.tx.Rollback(ctx) }()
+
+	actionModel, found, err := w.actionRepository.GetByID(ctx, deployModel.ActionID)
+	if err != nil {
+		return "", false, fmt.Errorf("get action: %w", err)
+	}
+	if !found {
+		return "", false, errors.New("action was deleted")
+	}
+
+	superseded, err := w.deployRepository.ExistsNewerReady(ctx, deployModel.ActionID, deployModel.ID)
+	if err != nil {
+		return "", false, fmt.Errorf("check newer deploys: %w", err)
+	}
+	if superseded {
+		return "", false, errors.New("superseded by a newer deploy")
+	}
+
+	updated, err := w.actionRepository.UpdateModule(
+		ctx, actionModel.ID, actionModel.ModulePath, deployModel.ModulePath, moduleSize, report,
+	)
+	if err != nil {
+		return "", false, fmt.Errorf("update action module: %w", err)
+	}
+	if !updated {
+		// Other deploy activated in between, it may be newer, so it's checked again
+		return "", false, nil
+	}
+
+	if err = w.deployRepository.UpdateStatus(ctx, deployModel.ID, StatusReady, ""); err != nil {
+		return "", false, fmt.Errorf("update deploy status: %w", err)
+	}
+
+	if err = w.tx.Commit(ctx); err != nil {
+		return "", false, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return actionModel.ModulePath, true, nil
+}
+
+// fail records deploy failure and deletes its module, deploy interrupted because worker is stopping is returned to
+// pending status with its module instead, so it's processed again after restart.
+func (w *worker) fail(ctx context.Context, deployID id.ID, modulePath string, deployErr error) {
+	// Lease timeout exceeds deadline, so only stopping worker cancels context
+	stopping := errors.Is(deployErr, context.Canceled) && errors.Is(ctx.Err(), context.Canceled)
+
+	// Record result even if worker is stopping
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), failureTimeout)
+	defer cancel()
+
+	if stopping {
+		logger.Infow(ctx, "deploy interrupted, postponing deploy")
+		if err := w.deployRepository.UpdateStatus(ctx, deployID, StatusPending, ""); err != nil {
+			logger.Errorw(ctx, "update deploy status", "error", err)
+		}
+		return
+	}
+
+	logger.Warnw(ctx, "deploy failed", "error", deployErr)
+
+	if errors.Is(deployErr, context.Canceled) {
+		deployErr = errors.New("deploy interrupted")
+	} else if errors.Is(deployErr, context.DeadlineExceeded) {
+		deployErr = errors.New("deploy timed out")
+	}
+
+	if err := w.deployRepository.UpdateStatus(ctx, deployID, StatusFailed, deployErr.Error()); err != nil {
+		logger.Errorw(ctx, "update deploy status", "error", err)
+	}
+
+	if modulePath != "" {
+		if err := w.storage.Delete(ctx, w.cfg.ModuleBucket, modulePath); err != nil {
+			logger.Warnw(ctx, "delete failed deploy module", "path", modulePath, "error", err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+type Config struct {
+	// DNSServer is address of DNS server used to look up TXT records, system resolver is used if empty
+	DNSServer           string        `validate:"omitempty,hostname_port"`
+	VerificationTimeout time.Duration `validate:"min=1s"`
+	// AllowPrivateAddresses allows HTTP verification of domains that resolve to loopback or private addresses, it's
+	// meant only for development, otherwise requests to internal services can be made on behalf of the server
+	AllowPrivateAddresses bool `validate:"-"`
+}
+
+func init() { //nolint:gochecknoinits
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+		cfg := Config{
+			DNSServer:             v.GetString("domain-dns-server"),
+			VerificationTimeout:   v.GetDuration("domain-verification-timeout"),
+			AllowPrivateAddresses: v.GetBool("domain-verification-allow-private"),
+		}
+		if err := va.Struct(cfg); err != nil {
+			return Config{}, err
+		}
+		return cfg, nil
+	})
+}
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+)
+
+const (
+	// TXTRecordPrefix is prepended to domain name to get name of TXT record with verification token
+	TXTRecordPrefix = "_lithium-challenge."
+	// TXTValuePrefix is prepended to verification token in TXT record
+	TXTValuePrefix = "lithium-verification="
+	// HTTPPath is path where verification token must be served on the domain
+	HTTPPath = "/.well-known/lithium-verification.txt"
+)
+
+// Model is a custom domain of a project, requests to the domain are served by the project once it's verified.
+type Model struct {
+	bun.BaseModel `bun:"table:domain"`
+
+	ID        id.ID  `bun:"id,pk"`
+	ProjectID id.ID  `bun:"project_id"`
+	Name      string `bun:"name"`
+	// Token proves ownership of the domain
+	Token      string    `bun:"token"`
+	VerifiedAt time.Time `bun:"verified_at,nullzero"`
+	// Certificate is PEM encoded certificate chain uploaded for the domain, it's empty if not uploaded
+	Certificate string `bun:"certificate"`
+	// CertificateKey is encrypted PEM encoded private key of the certificate
+	CertificateKey       string    `bun:"certificate_key"`
+	CertificateExpiresAt time.Time `bun:"certificate_expires_at,nullzero"`
+	CreatedAt            time.Time `bun:"created_at"`
+	UpdatedAt            time.Time `bun:"updated_at"`
+
+	Project *project.Model `bun:"rel:belongs-to,join:project_id=id"`
+}
+
+// Verified reports whether ownership of domain is proven.
+func (m *Model) Verified() bool {
+	return !m.VerifiedAt.IsZero()
+}
+
+// HasCertificate reports whether certificate is uploaded for domain.
+func (m *Model) HasCertificate() bool {
+	return m.Certificate != ""
+}
+
+// TXTRecordName returns name of TXT record that proves ownership of domain.
+func (m *Model) TXTRecordName() string {
+	return TXTRecordPrefix + m.Name
+}
+
+// TXTRecordValue returns value of TXT record that proves ownership of domain.
+func (m *Model) TXTRecordValue() string {
+	return TXTValuePrefix + m.Token
+}
+
+// HTTPURL returns URL where token that proves ownership of domain must be served.
+func (m *Model) HTTPURL() string {
+	return "http://" + m.Name + HTTPPath
+}
@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type Repository interface {
+	Create(ctx context.Context, model *Model) error
+	GetByID(ctx context.Context, id id.ID) (*Model, bool, error)
+	GetByProjectID(ctx context.Context, projectID id.ID) ([]Model, error)
+	// GetVerifiedByName returns verified domain with its project
+	GetVerifiedByName(ctx context.Context, name string) (*Model, bool, error)
+	// GetCertificateNames returns names of verified domains with uploaded certificate
+	GetCertificateNames(ctx context.Context) ([]string, error)
+	UpdateVerified(ctx context.Context, id id.ID, verifiedAt time.Time) error
+	// UpdateCertificate sets certificate of domain, empty certificate removes it
+	UpdateCertificate(ctx context.Context, id id.ID, certificate, certificateKey string, expiresAt time.Time) error
+	DeleteByID(ctx context.Context, id id.ID) error
+	DeleteByProjectID(ctx context.Context, projectID id.ID) error
+}
+
+type repository struct {
+	tx db.Transaction
+}
+
+func NewRepository(tx db.Transaction) Repository {
+	return &repository{
+		tx: tx,
+	}
+}
+
+func (r *repository) Create(ctx context.Context, model *Model) error {
+	_, err := r.tx.Extract(ctx).NewInsert().Model(model).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetByID(ctx context.Context, id id.ID) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+func (r *repository) GetByProjectID(ctx context.Context, projectID id.ID) ([]Model, error) {
+	var models []Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&models).
+		Where("project_id = ?", projectID).
+		Order("name").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+func (r *repository) GetVerifiedByName(ctx context.Context, name string) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Relation("Project").
+		Where("?TableAlias.name = ?", name).
+		Where("?TableAlias.verified_at IS NOT NULL").
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+func (r *repository) GetCertificateNames(ctx context.Context) ([]string, error) {
+	var names []string
+	err := r.tx.Extract(ctx).NewSelect().
+		Model((*Model)(nil)).
+		Column("name").
+		Where("verified_at IS NOT NULL").
+		Where("certificate != ''").
+		Scan(ctx, &names)
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (r *repository) UpdateVerified(ctx context.Context, id id.ID, verifiedAt time.Time) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("verified_at = ?", verifiedAt).
+		Set("updated_at = ?", verifiedAt).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) UpdateCertificate(
+	ctx context.Context, id id.ID, certificate, certificateKey string, expiresAt time.Time,
+) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("certificate = ?", certificate).
+		Set("certificate_key = ?", certificateKey).
+		Set("certificate_expires_at = ?", bun.NullTime{Time: expiresAt}).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteByID(ctx context.Context, id id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteByProjectID(ctx context.Context, projectID id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("project_id = ?", projectID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
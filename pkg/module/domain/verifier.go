@@ -0,0 +1,156 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// Method of proving ownership of domain.
+type Method string
+
+// Methods
+const (
+	// MethodDNS expects TXT record with token at `_lithium-challenge.<domain>`
+	MethodDNS Method = "dns"
+	// MethodHTTP expects token served at `http://<domain>/.well-known/lithium-verification.txt`
+	MethodHTTP Method = "http"
+)
+
+// ErrNotVerified is returned when ownership of domain is not proven, error describes what was found instead.
+var ErrNotVerified = errors.New("domain ownership is not proven")
+
+const maxTokenResponseSize = 1024
+
+// Resolver looks up DNS records, it's satisfied by [net.Resolver].
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// NewResolver returns resolver that uses configured DNS server or system resolver.
+func NewResolver(cfg Config) Resolver {
+	if cfg.DNSServer == "" {
+		return net.DefaultResolver
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, cfg.DNSServer)
+		},
+	}
+}
+
+type Verifier interface {
+	// Verify checks that domain ownership is proven by method, [ErrNotVerified] is returned if it's not
+	Verify(ctx context.Context, model *Model, method Method) error
+}
+
+type verifier struct {
+	cfg      Config
+	resolver Resolver
+	client   *http.Client
+}
+
+func NewVerifier(cfg Config, resolver Resolver) Verifier {
+	dialer := &net.Dialer{
+		Timeout: cfg.VerificationTimeout,
+	}
+	if !cfg.AllowPrivateAddresses {
+		dialer.Control = publicAddressOnly
+	}
+
+	return &verifier{
+		cfg:      cfg,
+		resolver: resolver,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext:       dialer.DialContext,
+				DisableKeepAlives: true,
+			},
+			Timeout: cfg.VerificationTimeout,
+		},
+	}
+}
+
+func (v *verifier) Verify(ctx context.Context, model *Model, method Method) error {
+	ctx, cancel := context.WithTimeout(ctx, v.cfg.VerificationTimeout)
+	defer cancel()
+
+	switch method {
+	case MethodDNS:
+		return v.verifyDNS(ctx, model)
+	case MethodHTTP:
+		return v.verifyHTTP(ctx, model)
+	default:
+		return fmt.Errorf("unknown verification method %q", method)
+	}
+}
+
+func (v *verifier) verifyDNS(ctx context.Context, model *Model) error {
+	records, err := v.resolver.LookupTXT(ctx, model.TXTRecordName())
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && (dnsErr.IsNotFound || dnsErr.IsTimeout) {
+			return fmt.Errorf("%w: TXT record %s not found", ErrNotVerified, model.TXTRecordName())
+		}
+		return fmt.Errorf("lookup TXT record: %w", err)
+	}
+
+	if !slices.Contains(records, model.TXTRecordValue()) {
+		return fmt.Errorf("%w: TXT record %s doesn't contain %s", ErrNotVerified, model.TXTRecordName(),
+			model.TXTRecordValue())
+	}
+
+	return nil
+}
+
+func (v *verifier) verifyHTTP(ctx context.Context, model *Model) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, model.HTTPURL(), nil)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	response, err := v.client.Do(request)
+	if err != nil {
+		// Unreachable domain is a problem of domain setup, not of the server
+		return fmt.Errorf("%w: %s is not reachable", ErrNotVerified, model.HTTPURL())
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s responded with status %d", ErrNotVerified, model.HTTPURL(), response.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxTokenResponseSize))
+	if err != nil {
+		return fmt.Errorf("%w: %s response can't be read", ErrNotVerified, model.HTTPURL())
+	}
+	if strings.TrimSpace(string(body)) != model.Token {
+		return fmt.Errorf("%w: %s doesn't contain token", ErrNotVerified, model.HTTPURL())
+	}
+
+	return nil
+}
+
+// publicAddressOnly rejects connections to addresses that are not reachable from the internet.
+func publicAddressOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("address %s is not public", host)
+	}
+
+	return nil
+}
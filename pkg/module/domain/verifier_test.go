@@ -0,0 +1,79 @@
+package domain_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/domain"
+)
+
+type resolver map[string][]string
+
+func (r resolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	records, ok := r[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestVerifyDNS(t *testing.T) {
+	verifier := domain.NewVerifier(domain.Config{VerificationTimeout: time.Second}, resolver{
+		"_lithium-challenge.valid.example.com": {"other", "lithium-verification=token"},
+		"_lithium-challenge.wrong.example.com": {"lithium-verification=other"},
+	})
+
+	tests := []struct {
+		name        string
+		notVerified bool
+	}{
+		{"valid.example.com", false},
+		{"wrong.example.com", true},
+		{"missing.example.com", true},
+	}
+	for _, tt := range tests {
+		err := verifier.Verify(t.Context(), &domain.Model{Name: tt.name, Token: "token"}, domain.MethodDNS)
+		if tt.notVerified != errors.Is(err, domain.ErrNotVerified) || (!tt.notVerified && err != nil) {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+	}
+}
+
+func TestVerifyHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != domain.HTTPPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("token\n"))
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	cfg := domain.Config{VerificationTimeout: time.Second, AllowPrivateAddresses: true}
+
+	err := domain.NewVerifier(cfg, resolver{}).Verify(t.Context(), &domain.Model{Name: host, Token: "token"},
+		domain.MethodHTTP)
+	if err != nil {
+		t.Errorf("valid token: unexpected error: %v", err)
+	}
+
+	err = domain.NewVerifier(cfg, resolver{}).Verify(t.Context(), &domain.Model{Name: host, Token: "other"},
+		domain.MethodHTTP)
+	if !errors.Is(err, domain.ErrNotVerified) {
+		t.Errorf("wrong token: unexpected error: %v", err)
+	}
+
+	cfg.AllowPrivateAddresses = false
+	err = domain.NewVerifier(cfg, resolver{}).Verify(t.Context(), &domain.Model{Name: host, Token: "token"},
+		domain.MethodHTTP)
+	if !errors.Is(err, domain.ErrNotVerified) {
+		t.Errorf("private address: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+// Package forwarded resolves the original client of a request that came through proxies. Headers that describe the
+// client are used only if they were set by trusted proxies, otherwise clients could spoof them.
+package forwarded
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// HeaderXRealIP is set to address of client for requests passed to modules.
+const HeaderXRealIP = "X-Real-Ip"
+
+// Headers describe client of request, they are removed from requests passed to modules and replaced with resolved
+// values.
+var Headers = []string{ //nolint:gochecknoglobals
+	fiber.HeaderForwarded,
+	fiber.HeaderXForwardedFor,
+	fiber.HeaderXForwardedProto,
+	fiber.HeaderXForwardedHost,
+	fiber.HeaderXForwardedProtocol,
+	fiber.HeaderXForwardedSsl,
+	fiber.HeaderXUrlScheme,
+	HeaderXRealIP,
+}
+
+// Client of request as seen by the first trusted proxy.
+type Client struct {
+	IP netip.Addr
+	// Proxies are trusted proxies request passed through, the closest to client is the first
+	Proxies []netip.Addr
+	// Proto is scheme used by client, http or https
+	Proto string
+	// Host requested by client, it may include port
+	Host string
+}
+
+// Hostname returns host without port.
+func (c Client) Hostname() string {
+	if hostname, _, err := net.SplitHostPort(c.Host); err == nil {
+		return hostname
+	}
+	return c.Host
+}
+
+// SetHeaders replaces headers that describe client with resolved values.
+func (c Client) SetHeaders(headers http.Header) {
+	for _, name := range Headers {
+		headers.Del(name)
+	}
+
+	forwardedFor := make([]string, 0, len(c.Proxies)+1)
+	forwardedFor = append(forwardedFor, c.IP.String())
+	for _, proxy := range c.Proxies {
+		forwardedFor = append(forwardedFor, proxy.String())
+	}
+
+	headers.Set(fiber.HeaderXForwardedFor, strings.Join(forwardedFor, ", "))
+	headers.Set(HeaderXRealIP, c.IP.String())
+	headers.Set(fiber.HeaderXForwardedProto, c.Proto)
+	if c.Host != "" {
+		headers.Set(fiber.HeaderXForwardedHost, c.Host)
+	}
+}
+
+// FromContext resolves client of request.
+func FromContext(fCtx fiber.Ctx, trustedProxies []netip.Prefix) Client {
+	requestCtx := fCtx.RequestCtx()
+	remoteIP, _ := netip.AddrFromSlice(requestCtx.RemoteIP())
+	return Resolve(
+		remoteIP, requestCtx.IsTLS(), string(fCtx.Request().Host()), fCtx.GetReqHeaders(), trustedProxies,
+	)
+}
+
+// Resolve returns client of request from remote address, connection and headers. Proxies are followed from the one
+// connected to the server while they are trusted, so addresses added by trusted proxies can't be spoofed by client.
+// `Forwarded` header is preferred over `X-Forwarded-*` headers.
+func Resolve(
+	remoteIP netip.Addr, tls bool, host string, headers http.Header, trustedProxies []netip.Prefix,
+) Client {
+	client := Client{
+		IP:    remoteIP.Unmap(),
+		Proto: "http",
+		Host:  host,
+	}
+	if tls {
+		client.Proto = "https"
+	}
+
+	hops := parseForwarded(headers.Values(fiber.HeaderForwarded))
+	if len(hops) == 0 {
+		hops = parseXForwarded(headers)
+	}
+
+	// Each hop is added by a proxy and describes request it received
+	for i := len(hops) - 1; i >= 0 && trusted(client.IP, trustedProxies); i-- {
+		if hops[i].proto != "" {
+			client.Proto = hops[i].proto
+		}
+		if hops[i].host != "" {
+			client.Host = hops[i].host
+		}
+
+		if !hops[i].ip.IsValid() {
+			// Address is unknown or obfuscated, so the proxy is the closest known client
+			break
+		}
+		client.Proxies = append(client.Proxies, client.IP)
+		client.IP = hops[i].ip
+	}
+	slices.Reverse(client.Proxies)
+
+	return client
+}
+
+func trusted(ip netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type hop struct {
+	ip    netip.Addr
+	proto string
+	host  string
+}
+
+// parseForwarded parses hops from `Forwarded` header (RFC 7239), invalid values are ignored.
+func parseForwarded(values []string) []hop {
+	var hops []hop
+	for _, value := range values {
+		for element := range strings.SplitSeq(value, ",") {
+			var h hop
+			for pair := range strings.SplitSeq(element, ";") {
+				key, pairValue, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+
+				pairValue = strings.Trim(pairValue, `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					h.ip = parseIP(pairValue)
+				case "proto":
+					h.proto = parseProto(pairValue)
+				case "host":
+					h.host = parseHost(pairValue)
+				}
+			}
+			hops = append(hops, h)
+		}
+	}
+	return hops
+}
+
+// parseXForwarded parses hops from `X-Forwarded-*` headers. Usually only `X-Forwarded-For` has value per hop, so
+// values of other headers belong to the closest proxy, unless there are values for every hop.
+func parseXForwarded(headers http.Header) []hop {
+	addresses := splitValues(headers.Values(fiber.HeaderXForwardedFor))
+	protos := splitValues(headers.Values(fiber.HeaderXForwardedProto))
+	hosts := splitValues(headers.Values(fiber.HeaderXForwardedHost))
+	if len(addresses) == 0 && len(protos) == 0 && len(hosts) == 0 {
+		return nil
+	}
+
+	// Proxy can set scheme or host without address, then hop has no address
+	hops := make([]hop, max(len(addresses), 1))
+	for i, address := range addresses {
+		hops[i].ip = parseIP(address)
+	}
+
+	if len(protos) == len(hops) {
+		for i, proto := range protos {
+			hops[i].proto = parseProto(proto)
+		}
+	} else if len(protos) != 0 {
+		hops[len(hops)-1].proto = parseProto(protos[len(protos)-1])
+	}
+
+	if len(hosts) == len(hops) {
+		for i, host := range hosts {
+			hops[i].host = parseHost(host)
+		}
+	} else if len(hosts) != 0 {
+		hops[len(hops)-1].host = parseHost(hosts[len(hosts)-1])
+	}
+
+	return hops
+}
+
+func splitValues(values []string) []string {
+	var split []string
+	for _, value := range values {
+		for part := range strings.SplitSeq(value, ",") {
+			split = append(split, strings.TrimSpace(part))
+		}
+	}
+	return split
+}
+
+// parseIP parses address with optional port, IPv6 address with port is in square brackets.
+func parseIP(value string) netip.Addr {
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(value, "[]")); err == nil {
+		return addr.Unmap()
+	}
+	return netip.Addr{}
+}
+
+func parseProto(value string) string {
+	switch value = strings.ToLower(value); value {
+	case "http", "https":
+		return value
+	default:
+		return ""
+	}
+}
+
+func parseHost(value string) string {
+	if value == "" || len(value) > 261 || strings.ContainsAny(value, " \t/\\@?#") {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,124 @@
+package forwarded_test
+
+import (
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/mymmrac/lithium/pkg/module/forwarded"
+)
+
+func TestResolve(t *testing.T) {
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name          string
+		remoteIP      string
+		headers       http.Header
+		expectedIP    string
+		expectedProto string
+		expectedHost  string
+	}{
+		{
+			name:          "untrusted remote",
+			remoteIP:      "203.0.113.1",
+			headers:       http.Header{"X-Forwarded-For": {"1.1.1.1"}, "X-Forwarded-Proto": {"https"}},
+			expectedIP:    "203.0.113.1",
+			expectedProto: "http",
+			expectedHost:  "example.com",
+		},
+		{
+			name:     "trusted proxy",
+			remoteIP: "10.0.0.1",
+			headers: http.Header{
+				"X-Forwarded-For":   {"1.1.1.1"},
+				"X-Forwarded-Proto": {"https"},
+				"X-Forwarded-Host":  {"project.example.com"},
+			},
+			expectedIP:    "1.1.1.1",
+			expectedProto: "https",
+			expectedHost:  "project.example.com",
+		},
+		{
+			name:          "spoofed by client",
+			remoteIP:      "10.0.0.1",
+			headers:       http.Header{"X-Forwarded-For": {"6.6.6.6, 1.1.1.1", "10.0.0.2"}},
+			expectedIP:    "1.1.1.1",
+			expectedProto: "http",
+			expectedHost:  "example.com",
+		},
+		{
+			name:     "forwarded header",
+			remoteIP: "10.0.0.1",
+			headers: http.Header{
+				"Forwarded":       {`for="[2001:db8::1]:4711";proto=https;host=custom.test, for=10.0.0.2`},
+				"X-Forwarded-For": {"6.6.6.6"},
+			},
+			expectedIP:    "2001:db8::1",
+			expectedProto: "https",
+			expectedHost:  "custom.test",
+		},
+		{
+			name:          "obfuscated address",
+			remoteIP:      "10.0.0.1",
+			headers:       http.Header{"Forwarded": {"for=_hidden;proto=https, for=10.0.0.2"}},
+			expectedIP:    "10.0.0.2",
+			expectedProto: "https",
+			expectedHost:  "example.com",
+		},
+		{
+			name:          "host without address",
+			remoteIP:      "10.0.0.1",
+			headers:       http.Header{"X-Forwarded-Host": {"project.example.com"}},
+			expectedIP:    "10.0.0.1",
+			expectedProto: "http",
+			expectedHost:  "project.example.com",
+		},
+		{
+			name:          "invalid values",
+			remoteIP:      "10.0.0.1",
+			headers:       http.Header{"Forwarded": {"for=1.1.1.1;proto=ftp;host=a/b"}},
+			expectedIP:    "1.1.1.1",
+			expectedProto: "http",
+			expectedHost:  "example.com",
+		},
+	}
+	for _, tt := range tests {
+		client := forwarded.Resolve(
+			netip.MustParseAddr(tt.remoteIP), false, "example.com", tt.headers, trustedProxies,
+		)
+		if client.IP.String() != tt.expectedIP || client.Proto != tt.expectedProto || client.Host != tt.expectedHost {
+			t.Errorf("%s: expected %s %s %s, got %s %s %s", tt.name, tt.expectedIP, tt.expectedProto, tt.expectedHost,
+				client.IP, client.Proto, client.Host)
+		}
+	}
+}
+
+func TestClientSetHeaders(t *testing.T) {
+	client := forwarded.Resolve(
+		netip.MustParseAddr("10.0.0.1"), true, "example.com:8443",
+		http.Header{"X-Forwarded-For": {"1.1.1.1, 10.0.0.2"}}, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	)
+	if client.Hostname() != "example.com" {
+		t.Errorf("unexpected hostname: %s", client.Hostname())
+	}
+
+	headers := http.Header{"Forwarded": {"for=6.6.6.6"}, "X-Real-Ip": {"6.6.6.6"}, "Accept": {"*/*"}}
+	client.SetHeaders(headers)
+
+	expected := http.Header{
+		"X-Forwarded-For":   {"1.1.1.1, 10.0.0.2, 10.0.0.1"},
+		"X-Real-Ip":         {"1.1.1.1"},
+		"X-Forwarded-Proto": {"https"},
+		"X-Forwarded-Host":  {"example.com:8443"},
+		"Accept":            {"*/*"},
+	}
+	if len(headers) != len(expected) {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+	for name := range expected {
+		if headers.Get(name) != expected.Get(name) {
+			t.Errorf("%s: expected %q, got %q", name, expected.Get(name), headers.Get(name))
+		}
+	}
+}
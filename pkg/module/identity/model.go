@@ -0,0 +1,21 @@
+package identity
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+// Model links user to an account of external identity provider.
+type Model struct {
+	bun.BaseModel `bun:"table:user_identity"`
+
+	ID        id.ID     `bun:"id,pk"`
+	UserID    id.ID     `bun:"user_id"`
+	Provider  string    `bun:"provider"`
+	Subject   string    `bun:"subject"`
+	Email     string    `bun:"email"`
+	CreatedAt time.Time `bun:"created_at"`
+}
@@ -0,0 +1,53 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+)
+
+type Repository interface {
+	Create(ctx context.Context, model *Model) error
+	GetBySubject(ctx context.Context, provider, subject string) (*Model, bool, error)
+}
+
+type repository struct {
+	tx db.Transaction
+}
+
+func NewRepository(tx db.Transaction) Repository {
+	return &repository{
+		tx: tx,
+	}
+}
+
+var ErrAlreadyExists = errors.New("identity already exists")
+
+func (r *repository) Create(ctx context.Context, model *Model) error {
+	_, err := r.tx.Extract(ctx).NewInsert().Model(model).Exec(ctx)
+	if err != nil {
+		if db.IsUniqueViolation(err) {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetBySubject(ctx context.Context, provider, subject string) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("provider = ?", provider).
+		Where("subject = ?", subject).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
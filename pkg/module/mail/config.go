@@ -0,0 +1,81 @@
+package mail
+
+import (
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+const (
+	DriverSMTP = "smtp"
+	DriverLog  = "log"
+	DriverFile = "file"
+)
+
+const (
+	TLSModeStartTLS = "starttls"
+	TLSModeTLS      = "tls"
+	TLSModeNone     = "none"
+)
+
+type Config struct {
+	Driver string     `validate:"oneof=smtp log file"`
+	From   string     `validate:"required"`
+	SMTP   SMTPConfig `validate:"-"`
+	File   FileConfig `validate:"-"`
+}
+
+type SMTPConfig struct {
+	Host     string `validate:"hostname|ip"`
+	Port     int    `validate:"min=1,max=65535"`
+	Username string `validate:"-"`
+	Password string `validate:"-" secret:"true"`
+	TLS      string `validate:"oneof=starttls tls none"`
+}
+
+type FileConfig struct {
+	Path string `validate:"required"`
+}
+
+func init() { //nolint:gochecknoinits
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+		cfg := Config{
+			Driver: v.GetString("mail-driver"),
+			From:   v.GetString("mail-from"),
+			SMTP: SMTPConfig{
+				Host:     v.GetString("smtp-host"),
+				Port:     v.GetInt("smtp-port"),
+				Username: v.GetString("smtp-username"),
+				Password: v.GetString("smtp-password"),
+				TLS:      v.GetString("smtp-tls"),
+			},
+			File: FileConfig{
+				Path: v.GetString("mail-path"),
+			},
+		}
+		if err := va.Struct(cfg); err != nil {
+			return Config{}, err
+		}
+
+		// Only settings of the selected driver are required
+		var driverCfg any
+		switch cfg.Driver {
+		case DriverSMTP:
+			driverCfg = cfg.SMTP
+		case DriverFile:
+			driverCfg = cfg.File
+		case DriverLog:
+			return cfg, nil
+		default:
+			return Config{}, fmt.Errorf("unknown mail driver: %q", cfg.Driver)
+		}
+		if err := va.Struct(driverCfg); err != nil {
+			return Config{}, err
+		}
+
+		return cfg, nil
+	})
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type fileMailer struct {
+	from *mail.Address
+	path string
+}
+
+// NewFile creates mailer that writes messages as .eml files into directory, useful for testing.
+func NewFile(cfg FileConfig, from *mail.Address) (Mailer, error) {
+	if err := os.MkdirAll(cfg.Path, 0o750); err != nil {
+		return nil, fmt.Errorf("create mail directory: %w", err)
+	}
+
+	return &fileMailer{
+		from: from,
+		path: cfg.Path,
+	}, nil
+}
+
+func (m *fileMailer) Send(_ context.Context, msg Message) error {
+	now := time.Now()
+	data, err := encode(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102150405"), id.New())
+	if err = os.WriteFile(filepath.Join(m.path, name), data, 0o600); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+
+	return nil
+}
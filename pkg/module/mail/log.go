@@ -0,0 +1,24 @@
+package mail
+
+import (
+	"context"
+	"net/mail"
+
+	"github.com/mymmrac/lithium/pkg/module/logger"
+)
+
+type logMailer struct {
+	from *mail.Address
+}
+
+// NewLog creates mailer that only logs messages, useful for local development.
+func NewLog(from *mail.Address) Mailer {
+	return &logMailer{
+		from: from,
+	}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	logger.Infow(ctx, "mail", "from", m.from.String(), "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
@@ -0,0 +1,83 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	// Body is a plain text content of message
+	Body string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailer creates mailer using configured driver.
+func NewMailer(cfg Config) (Mailer, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("parse from address: %w", err)
+	}
+
+	switch cfg.Driver {
+	case DriverSMTP:
+		return NewSMTP(cfg.SMTP, from), nil
+	case DriverLog:
+		return NewLog(from), nil
+	case DriverFile:
+		return NewFile(cfg.File, from)
+	default:
+		return nil, fmt.Errorf("unknown mail driver: %q", cfg.Driver)
+	}
+}
+
+// encode returns message in RFC 5322 format.
+func encode(from *mail.Address, msg Message, now time.Time) ([]byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("parse to address: %w", err)
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		_, _ = fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", rand.Text(), domain(from.Address)))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	writer := quotedprintable.NewWriter(&buf)
+	if _, err = writer.Write([]byte(msg.Body)); err != nil {
+		return nil, fmt.Errorf("encode body: %w", err)
+	}
+	if err = writer.Close(); err != nil {
+		return nil, fmt.Errorf("encode body: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func domain(address string) string {
+	if i := strings.LastIndex(address, "@"); i != -1 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
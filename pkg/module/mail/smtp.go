@@ -0,0 +1,118 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// sendTimeout limits whole SMTP conversation if context has no deadline
+const sendTimeout = 30 * time.Second
+
+type smtpMailer struct {
+	cfg  SMTPConfig
+	from *mail.Address
+}
+
+// NewSMTP creates mailer that sends messages through SMTP server.
+func NewSMTP(cfg SMTPConfig, from *mail.Address) Mailer {
+	return &smtpMailer{
+		cfg:  cfg,
+		from: from,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+	}
+
+	data, err := encode(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("parse to address: %w", err)
+	}
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if m.cfg.TLS == TLSModeStartTLS {
+		if err = client.StartTLS(&tls.Config{ServerName: m.cfg.Host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("start TLS: %w", err)
+		}
+	}
+
+	if m.cfg.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	if err = client.Mail(m.from.Address); err != nil {
+		return fmt.Errorf("mail from: %w", err)
+	}
+	if err = client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("rcpt to: %w", err)
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+	if _, err = writer.Write(data); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	if err = writer.Close(); err != nil {
+		return fmt.Errorf("close message: %w", err)
+	}
+
+	if err = client.Quit(); err != nil {
+		return fmt.Errorf("quit: %w", err)
+	}
+
+	return nil
+}
+
+func (m *smtpMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	address := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if m.cfg.TLS == TLSModeTLS {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: m.cfg.Host, MinVersion: tls.VersionTLS12}}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	} else {
+		dialer := &net.Dialer{}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial SMTP server: %w", err)
+	}
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("create SMTP client: %w", err)
+	}
+
+	return client, nil
+}
@@ -0,0 +1,61 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+type Config struct {
+	Providers []ProviderConfig `validate:"unique=Name,dive"`
+}
+
+type ProviderConfig struct {
+	// Name is used in callback URL, so it must not change after provider is registered at IdP
+	Name        string `mapstructure:"name"         json:"name"         validate:"required,alphanum,lowercase,max=32"`
+	DisplayName string `mapstructure:"display-name" json:"display-name"  validate:"-"`
+	// Issuer is used for discovery, `/.well-known/openid-configuration` is appended to it
+	Issuer       string `mapstructure:"issuer"        json:"issuer"       validate:"http_url"`
+	ClientID     string `mapstructure:"client-id"     json:"client-id"     validate:"required"`
+	ClientSecret string `mapstructure:"client-secret" json:"client-secret" validate:"-"                    secret:"true"`
+	// AllowedDomains limits email domains that can sign in, any domain is allowed if empty
+	AllowedDomains []string `mapstructure:"allowed-domains" json:"allowed-domains" validate:"dive,fqdn"`
+}
+
+// Label returns name shown to users.
+func (c ProviderConfig) Label() string {
+	if c.DisplayName != "" {
+		return c.DisplayName
+	}
+	return c.Name
+}
+
+func init() { //nolint:gochecknoinits
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+		var cfg Config
+
+		// Config files have providers as a list, environment variable has them as JSON
+		switch providers := v.Get("oidc-providers").(type) {
+		case nil:
+		case string:
+			if providers != "" {
+				if err := json.Unmarshal([]byte(providers), &cfg.Providers); err != nil {
+					return Config{}, fmt.Errorf("parse OIDC providers: %w", err)
+				}
+			}
+		default:
+			if err := v.UnmarshalKey("oidc-providers", &cfg.Providers); err != nil {
+				return Config{}, fmt.Errorf("parse OIDC providers: %w", err)
+			}
+		}
+
+		if err := va.Struct(cfg); err != nil {
+			return Config{}, err
+		}
+		return cfg, nil
+	})
+}
@@ -0,0 +1,167 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrEmailNotVerified = errors.New("email is not verified by provider")
+	ErrDomainNotAllowed = errors.New("email domain is not allowed")
+	ErrInvalidNonce     = errors.New("invalid nonce")
+	ErrMissingIDToken   = errors.New("no ID token in token response")
+	ErrProviderNotFound = errors.New("provider not found")
+	ErrMissingEmail     = errors.New("no email in ID token")
+)
+
+// Claims are verified user details from ID token.
+type Claims struct {
+	Subject string
+	Email   string
+}
+
+type Providers interface {
+	// List returns all configured providers.
+	List() []ProviderConfig
+	// AuthCodeURL returns URL of provider login page.
+	AuthCodeURL(ctx context.Context, name, redirectURL, state, nonce, verifier string) (string, error)
+	// Exchange exchanges authorization code and returns verified claims of user.
+	Exchange(ctx context.Context, name, redirectURL, code, nonce, verifier string) (Claims, error)
+}
+
+type providers struct {
+	ctx     context.Context //nolint:containedctx
+	configs []ProviderConfig
+
+	lock       sync.Mutex
+	discovered map[string]*oidc.Provider
+}
+
+// NewProviders creates providers, discovery is done on first use, so unavailable IdP doesn't prevent startup.
+// Context is used for fetching provider keys, so it must live as long as providers.
+func NewProviders(ctx context.Context, cfg Config) Providers {
+	return &providers{
+		ctx:        ctx,
+		configs:    cfg.Providers,
+		discovered: make(map[string]*oidc.Provider),
+	}
+}
+
+func (p *providers) List() []ProviderConfig {
+	return p.configs
+}
+
+func (p *providers) provider(name string) (ProviderConfig, *oidc.Provider, error) {
+	index := slices.IndexFunc(p.configs, func(cfg ProviderConfig) bool { return cfg.Name == name })
+	if index == -1 {
+		return ProviderConfig{}, nil, ErrProviderNotFound
+	}
+	cfg := p.configs[index]
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if provider, ok := p.discovered[name]; ok {
+		return cfg, provider, nil
+	}
+
+	provider, err := oidc.NewProvider(p.ctx, cfg.Issuer)
+	if err != nil {
+		return ProviderConfig{}, nil, fmt.Errorf("discover provider %q: %w", name, err)
+	}
+	p.discovered[name] = provider
+
+	return cfg, provider, nil
+}
+
+func (p *providers) oauth2Config(cfg ProviderConfig, provider *oidc.Provider, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	}
+}
+
+func (p *providers) AuthCodeURL(_ context.Context, name, redirectURL, state, nonce, verifier string) (string, error) {
+	cfg, provider, err := p.provider(name)
+	if err != nil {
+		return "", err
+	}
+
+	return p.oauth2Config(cfg, provider, redirectURL).AuthCodeURL(
+		state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier),
+	), nil
+}
+
+func (p *providers) Exchange(ctx context.Context, name, redirectURL, code, nonce, verifier string) (Claims, error) {
+	cfg, provider, err := p.provider(name)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	token, err := p.oauth2Config(cfg, provider, redirectURL).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Claims{}, fmt.Errorf("exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return Claims{}, ErrMissingIDToken
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return Claims{}, fmt.Errorf("verify ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return Claims{}, ErrInvalidNonce
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+	}
+	if err = idToken.Claims(&claims); err != nil {
+		return Claims{}, fmt.Errorf("parse ID token claims: %w", err)
+	}
+
+	if claims.Email == "" {
+		return Claims{}, ErrMissingEmail
+	}
+	// Accounts are linked by email, so only emails verified by provider can be trusted
+	if claims.EmailVerified == nil || !*claims.EmailVerified {
+		return Claims{}, ErrEmailNotVerified
+	}
+
+	if !domainAllowed(cfg.AllowedDomains, claims.Email) {
+		return Claims{}, ErrDomainNotAllowed
+	}
+
+	return Claims{
+		Subject: idToken.Subject,
+		Email:   claims.Email,
+	}, nil
+}
+
+func domainAllowed(allowedDomains []string, email string) bool {
+	if len(allowedDomains) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at == -1 {
+		return false
+	}
+
+	domain := email[at+1:]
+	return slices.ContainsFunc(allowedDomains, func(allowed string) bool { return strings.EqualFold(allowed, domain) })
+}
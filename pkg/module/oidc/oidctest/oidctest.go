@@ -0,0 +1,210 @@
+// Package oidctest provides a stand-in OpenID Connect provider for tests.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "test-key"
+
+// User is a user that logs in at the provider.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Provider is an OpenID Connect provider that supports authorization code flow with PKCE, login at provider is
+// simulated with [Provider.Authorize].
+type Provider struct {
+	ClientID     string
+	ClientSecret string
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	lock  sync.Mutex
+	codes map[string]authorization
+}
+
+type authorization struct {
+	user          User
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+// New starts provider, it is stopped when test finishes.
+func New(t *testing.T, clientID, clientSecret string) *Provider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		codes:        make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discoveryHandler)
+	mux.HandleFunc("GET /jwks", p.jwksHandler)
+	mux.HandleFunc("POST /token", p.tokenHandler)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+// Issuer returns issuer URL used for discovery.
+func (p *Provider) Issuer() string {
+	return p.server.URL
+}
+
+// Authorize simulates login of user at provider, it takes URL of provider login page and returns callback URL that
+// provider would redirect user to.
+func (p *Provider) Authorize(t *testing.T, authURL string, user User) string {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth URL: %v", err)
+	}
+	query := u.Query()
+
+	if query.Get("client_id") != p.ClientID {
+		t.Fatalf("unexpected client ID: %q", query.Get("client_id"))
+	}
+	if query.Get("response_type") != "code" {
+		t.Fatalf("unexpected response type: %q", query.Get("response_type"))
+	}
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected code challenge method: %q", query.Get("code_challenge_method"))
+	}
+
+	code := rand.Text()
+	p.lock.Lock()
+	p.codes[code] = authorization{
+		user:          user,
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+	p.lock.Unlock()
+
+	callback, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		t.Fatalf("parse redirect URI: %v", err)
+	}
+	callbackQuery := callback.Query()
+	callbackQuery.Set("code", code)
+	callbackQuery.Set("state", query.Get("state"))
+	callback.RawQuery = callbackQuery.Encode()
+
+	return callback.String()
+}
+
+func (p *Provider) discoveryHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer(),
+		"authorization_endpoint":                p.Issuer() + "/authorize",
+		"token_endpoint":                        p.Issuer() + "/token",
+		"jwks_uri":                              p.Issuer() + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *Provider) jwksHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]any{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *Provider) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.lock.Lock()
+	auth, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.lock.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != auth.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.Issuer(),
+		"aud":            p.ClientID,
+		"sub":            auth.user.Subject,
+		"email":          auth.user.Email,
+		"email_verified": auth.user.EmailVerified,
+		"nonce":          auth.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+	})
+	idToken.Header["kid"] = keyID
+
+	signedIDToken, err := idToken.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signedIDToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
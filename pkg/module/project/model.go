@@ -5,6 +5,7 @@ import (
 
 	"github.com/uptrace/bun"
 
+	"github.com/mymmrac/lithium/pkg/module/cors"
 	"github.com/mymmrac/lithium/pkg/module/id"
 )
 
@@ -12,9 +13,20 @@ type Model struct {
 	bun.BaseModel `bun:"table:project"`
 
 	ID        id.ID     `bun:"id,pk"`
-	OwnerID   id.ID     `bun:"owner_id"`
+	TeamID    id.ID     `bun:"team_id"`
+	OwnerID   id.ID     `bun:"owner_id"` // User who created the project, access is granted by team membership
 	Name      string    `bun:"name"`
 	SubDomain string    `bun:"sub_domain"`
+	Config    Config    `bun:"config,type:jsonb"`
+	Disabled  bool      `bun:"disabled"`
 	CreatedAt time.Time `bun:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at"`
 }
+
+// Config is shared by all actions of the project, action-level values take precedence.
+type Config struct {
+	Envs    map[string]string `json:"envs,omitempty"`
+	Secrets map[string]string `json:"secrets,omitempty"`
+	// CORS policy of actions, action-level policy replaces it
+	CORS *cors.Policy `json:"cors,omitempty"`
+}
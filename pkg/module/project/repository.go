@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 
+	"github.com/uptrace/bun"
+
 	"github.com/mymmrac/lithium/pkg/module/db"
 	"github.com/mymmrac/lithium/pkg/module/id"
 )
@@ -12,9 +14,15 @@ import (
 type Repository interface {
 	Create(ctx context.Context, model *Model) error
 	UpdateName(ctx context.Context, id id.ID, name string) error
+	UpdateConfig(ctx context.Context, id id.ID, config Config) error
+	UpdateTeam(ctx context.Context, id, teamID id.ID) error
+	UpdateDisabled(ctx context.Context, id id.ID, disabled bool) error
 	GetByID(ctx context.Context, id id.ID) (*Model, bool, error)
-	GetByOwnerID(ctx context.Context, ownerID id.ID) ([]Model, error)
+	Lock(ctx context.Context, id id.ID) error
+	GetByTeamIDs(ctx context.Context, teamIDs []id.ID) ([]Model, error)
+	CountByTeamID(ctx context.Context, teamID id.ID) (int, error)
 	GetBySubDomain(ctx context.Context, subDomain string) (*Model, bool, error)
+	GetAll(ctx context.Context) ([]Model, error)
 	DeleteByID(ctx context.Context, id id.ID) error
 }
 
@@ -48,6 +56,55 @@ func (r *repository) UpdateName(ctx context.Context, id id.ID, name string) erro
 	return nil
 }
 
+func (r *repository) UpdateConfig(ctx context.Context, id id.ID, config Config) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("config = ?", config).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) UpdateTeam(ctx context.Context, id, teamID id.ID) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("team_id = ?", teamID).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) UpdateDisabled(ctx context.Context, id id.ID, disabled bool) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("disabled = ?", disabled).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Lock locks project row until the end of transaction, so transactions that lock the same project run one after another.
+func (r *repository) Lock(ctx context.Context, id id.ID) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("updated_at = updated_at").
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (r *repository) GetByID(ctx context.Context, id id.ID) (*Model, bool, error) {
 	var model Model
 	err := r.tx.Extract(ctx).NewSelect().
@@ -63,11 +120,15 @@ func (r *repository) GetByID(ctx context.Context, id id.ID) (*Model, bool, error
 	return &model, true, nil
 }
 
-func (r *repository) GetByOwnerID(ctx context.Context, ownerID id.ID) ([]Model, error) {
+func (r *repository) GetByTeamIDs(ctx context.Context, teamIDs []id.ID) ([]Model, error) {
+	if len(teamIDs) == 0 {
+		return nil, nil
+	}
+
 	var models []Model
 	err := r.tx.Extract(ctx).NewSelect().
 		Model(&models).
-		Where("owner_id = ?", ownerID).
+		Where("team_id IN (?)", bun.In(teamIDs)).
 		Order("created_at DESC").
 		Scan(ctx)
 	if err != nil {
@@ -76,6 +137,17 @@ func (r *repository) GetByOwnerID(ctx context.Context, ownerID id.ID) ([]Model,
 	return models, nil
 }
 
+func (r *repository) CountByTeamID(ctx context.Context, teamID id.ID) (int, error) {
+	count, err := r.tx.Extract(ctx).NewSelect().
+		Model((*Model)(nil)).
+		Where("team_id = ?", teamID).
+		Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (r *repository) GetBySubDomain(ctx context.Context, subDomain string) (*Model, bool, error) {
 	var model Model
 	err := r.tx.Extract(ctx).NewSelect().
@@ -91,6 +163,18 @@ func (r *repository) GetBySubDomain(ctx context.Context, subDomain string) (*Mod
 	return &model, true, nil
 }
 
+func (r *repository) GetAll(ctx context.Context) ([]Model, error) {
+	var models []Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&models).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
 func (r *repository) DeleteByID(ctx context.Context, id id.ID) error {
 	var model Model
 	_, err := r.tx.Extract(ctx).NewDelete().
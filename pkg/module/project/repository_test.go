@@ -0,0 +1,129 @@
+package project_test
+
+import (
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+func TestRepository(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		owner := createUser(t, tx)
+		teamID := createTeam(t, tx)
+		repository := project.NewRepository(tx)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		first := &project.Model{
+			ID:        id.New(),
+			TeamID:    teamID,
+			OwnerID:   owner,
+			Name:      "First",
+			SubDomain: "first",
+			CreatedAt: now.Add(-time.Hour),
+			UpdatedAt: now,
+		}
+		second := &project.Model{
+			ID:        id.New(),
+			TeamID:    teamID,
+			OwnerID:   owner,
+			Name:      "Second",
+			SubDomain: "second",
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		for _, model := range []*project.Model{first, second} {
+			if err := repository.Create(t.Context(), model); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+
+		if err := repository.UpdateName(t.Context(), first.ID, "Renamed"); err != nil {
+			t.Fatalf("update name: %v", err)
+		}
+
+		config := project.Config{
+			Envs:    map[string]string{"A": "1"},
+			Secrets: map[string]string{"JWT_SECRET": "secret"},
+		}
+		if err := repository.UpdateConfig(t.Context(), first.ID, config); err != nil {
+			t.Fatalf("update config: %v", err)
+		}
+
+		got, found, err := repository.GetByID(t.Context(), first.ID)
+		if err != nil || !found {
+			t.Fatalf("get by id: found: %t, error: %v", found, err)
+		}
+		if got.Name != "Renamed" || got.TeamID != teamID || got.OwnerID != owner || got.SubDomain != "first" {
+			t.Errorf("unexpected project: %+v", got)
+		}
+		if !maps.Equal(got.Config.Envs, config.Envs) || !maps.Equal(got.Config.Secrets, config.Secrets) {
+			t.Errorf("unexpected config: %+v", got.Config)
+		}
+
+		got, found, err = repository.GetBySubDomain(t.Context(), "second")
+		if err != nil || !found {
+			t.Fatalf("get by sub domain: found: %t, error: %v", found, err)
+		}
+		if got.ID != second.ID || got.Config.Envs != nil {
+			t.Errorf("unexpected project: %+v", got)
+		}
+
+		models, err := repository.GetByTeamIDs(t.Context(), []id.ID{teamID, id.New()})
+		if err != nil {
+			t.Fatalf("get by teams: %v", err)
+		}
+		if len(models) != 2 || models[0].ID != second.ID || models[1].ID != first.ID {
+			t.Errorf("unexpected projects: %+v", models)
+		}
+
+		count, err := repository.CountByTeamID(t.Context(), teamID)
+		if err != nil || count != 2 {
+			t.Fatalf("count by team: %d, error: %v", count, err)
+		}
+
+		if err = repository.DeleteByID(t.Context(), first.ID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, found, err = repository.GetByID(t.Context(), first.ID); err != nil || found {
+			t.Fatalf("get deleted: found: %t, error: %v", found, err)
+		}
+	})
+}
+
+func createUser(t *testing.T, tx db.Transaction) id.ID {
+	t.Helper()
+
+	model := &user.Model{
+		ID:        id.New(),
+		Email:     "owner@example.com",
+		Password:  "hash",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := user.NewRepository(tx).Create(t.Context(), model); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return model.ID
+}
+
+func createTeam(t *testing.T, tx db.Transaction) id.ID {
+	t.Helper()
+
+	model := &team.Model{
+		ID:        id.New(),
+		Name:      "Team",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := team.NewRepository(tx).Create(t.Context(), model); err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+	return model.ID
+}
@@ -0,0 +1,71 @@
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+type Config struct {
+	// DefaultPlan is used by teams without plan, teams without plan have no limits if it's empty
+	DefaultPlan string `validate:"-"`
+	Plans       []Plan `validate:"unique=Name,dive"`
+}
+
+// Plan limits resources of a team, zero limit means unlimited.
+type Plan struct {
+	Name                 string `mapstructure:"name"                    json:"name"                    validate:"required,max=64"`
+	MaxProjects          int64  `mapstructure:"max-projects"            json:"max-projects"            validate:"min=0"`
+	MaxActionsPerProject int64  `mapstructure:"max-actions-per-project" json:"max-actions-per-project" validate:"min=0"`
+	MaxModuleSize        int64  `mapstructure:"max-module-size"         json:"max-module-size"         validate:"min=0"`
+	// MaxStorage limits total size of active modules of all team projects
+	MaxStorage            int64 `mapstructure:"max-storage"             json:"max-storage"             validate:"min=0"`
+	MaxMonthlyInvocations int64 `mapstructure:"max-monthly-invocations" json:"max-monthly-invocations" validate:"min=0"`
+	// MaxConcurrentExecutions limits executions of one project at a time, it's enforced by each server separately
+	MaxConcurrentExecutions int64 `mapstructure:"max-concurrent-executions" json:"max-concurrent-executions" validate:"min=0"`
+}
+
+// FindPlan returns plan by name.
+func (c Config) FindPlan(name string) (Plan, bool) {
+	i := slices.IndexFunc(c.Plans, func(plan Plan) bool { return plan.Name == name })
+	if i == -1 {
+		return Plan{}, false
+	}
+	return c.Plans[i], true
+}
+
+func init() { //nolint:gochecknoinits
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+		cfg := Config{
+			DefaultPlan: v.GetString("quota-default-plan"),
+		}
+
+		// Config files have plans as a list, environment variable has them as JSON
+		switch plans := v.Get("quota-plans").(type) {
+		case nil:
+		case string:
+			if plans != "" {
+				if err := json.Unmarshal([]byte(plans), &cfg.Plans); err != nil {
+					return Config{}, fmt.Errorf("parse quota plans: %w", err)
+				}
+			}
+		default:
+			if err := v.UnmarshalKey("quota-plans", &cfg.Plans); err != nil {
+				return Config{}, fmt.Errorf("parse quota plans: %w", err)
+			}
+		}
+
+		if err := va.Struct(cfg); err != nil {
+			return Config{}, err
+		}
+		if _, ok := cfg.FindPlan(cfg.DefaultPlan); cfg.DefaultPlan != "" && !ok {
+			return Config{}, fmt.Errorf("default quota plan %q is not configured", cfg.DefaultPlan)
+		}
+		return cfg, nil
+	})
+}
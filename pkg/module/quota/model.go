@@ -0,0 +1,100 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+)
+
+// Limit of a plan.
+type Limit string
+
+// Limits
+const (
+	LimitProjects             Limit = "projects"
+	LimitActionsPerProject    Limit = "actions-per-project"
+	LimitModuleSize           Limit = "module-size"
+	LimitStorage              Limit = "storage"
+	LimitMonthlyInvocations   Limit = "monthly-invocations"
+	LimitConcurrentExecutions Limit = "concurrent-executions"
+)
+
+// ExceededError is returned when operation would exceed limit of a plan.
+type ExceededError struct {
+	Limit Limit
+	Max   int64
+	// RetryAfter is set for limits that are lifted with time
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota %s exceeded, limit is %d", e.Limit, e.Max)
+}
+
+// Message returns description of exceeded limit for users.
+func (e *ExceededError) Message() string {
+	switch e.Limit {
+	case LimitProjects:
+		return fmt.Sprintf("Plan limit of %d projects reached", e.Max)
+	case LimitActionsPerProject:
+		return fmt.Sprintf("Plan limit of %d actions per project reached", e.Max)
+	case LimitModuleSize:
+		return fmt.Sprintf("Module exceeds plan limit of %d bytes", e.Max)
+	case LimitStorage:
+		return fmt.Sprintf("Modules exceed plan storage limit of %d bytes", e.Max)
+	case LimitMonthlyInvocations:
+		return fmt.Sprintf("Plan limit of %d monthly invocations reached", e.Max)
+	case LimitConcurrentExecutions:
+		return fmt.Sprintf("Plan limit of %d concurrent executions reached", e.Max)
+	default:
+		return fmt.Sprintf("Plan limit of %d %s reached", e.Max, e.Limit)
+	}
+}
+
+// Error converts quota error to HTTP error, limits of resources require another plan, so they are reported as 402,
+// limits of invocations are lifted with time, so they are reported as 429 with time to retry after.
+func Error(fCtx fiber.Ctx, err error) error {
+	var exceededErr *ExceededError
+	if !errors.As(err, &exceededErr) {
+		logger.Errorw(fCtx, "check quota", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError)
+	}
+
+	if exceededErr.RetryAfter == 0 {
+		return fiber.NewError(fiber.StatusPaymentRequired, exceededErr.Message())
+	}
+
+	retryAfter := int64(math.Ceil(exceededErr.RetryAfter.Seconds()))
+	fCtx.Set(fiber.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+	return fiber.NewError(fiber.StatusTooManyRequests, exceededErr.Message())
+}
+
+// InvocationUsage is number of invocations of team projects in a period.
+type InvocationUsage struct {
+	bun.BaseModel `bun:"table:invocation_usage"`
+
+	TeamID      id.ID  `bun:"team_id,pk"`
+	Period      string `bun:"period,pk"`
+	Invocations int64  `bun:"invocations"`
+}
+
+const periodLayout = "2006-01"
+
+// Period returns billing period of time, periods are calendar months in UTC.
+func Period(t time.Time) string {
+	return t.UTC().Format(periodLayout)
+}
+
+// nextPeriodStart returns start of period after one of time.
+func nextPeriodStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
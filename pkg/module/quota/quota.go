@@ -0,0 +1,398 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/logger"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/runner"
+	"github.com/mymmrac/lithium/pkg/module/team"
+)
+
+const (
+	flushInterval             = 10 * time.Second
+	concurrentExecutionsRetry = time.Second
+)
+
+// Usage of team resources in the current period.
+type Usage struct {
+	Plan               Plan
+	Period             string
+	Projects           int64
+	Storage            int64
+	MonthlyInvocations int64
+	ProjectUsages      []ProjectUsage
+}
+
+type ProjectUsage struct {
+	ProjectID id.ID
+	Name      string
+	Actions   int64
+	Storage   int64
+	// ConcurrentExecutions is number of executions running on this server
+	ConcurrentExecutions int64
+}
+
+// Quota checks limits of team plans. Invocations are counted in memory and stored periodically, so monthly limit can
+// be exceeded by invocations made on other servers since the last flush.
+type Quota interface {
+	runner.Service
+
+	// Plan returns plan of team, plan without limits is returned if team has no plan and there is no default plan
+	Plan(ctx context.Context, teamID id.ID) (Plan, error)
+	// CheckProjects checks that team can create one more project, team is locked, so check must be done in transaction
+	// that creates project
+	CheckProjects(ctx context.Context, teamID id.ID) error
+	// CheckActions checks that one more action can be created in project, project is locked, so check must be done in
+	// transaction that creates action
+	CheckActions(ctx context.Context, teamID, projectID id.ID) error
+	// CheckModule checks that module of size can replace active module of action
+	CheckModule(ctx context.Context, teamID id.ID, actionModel *action.Model, size int64) error
+	// StartInvocation counts invocation of project, release must be called after execution is finished
+	StartInvocation(ctx context.Context, teamID, projectID id.ID) (release func(), err error)
+	Usage(ctx context.Context, teamID id.ID) (*Usage, error)
+}
+
+type counterKey struct {
+	teamID id.ID
+	period string
+}
+
+type counter struct {
+	plan Plan
+	// stored is number of invocations stored when counter was loaded
+	stored int64
+	// pending is number of invocations that are not stored yet
+	pending int64
+}
+
+type quota struct {
+	cfg               Config
+	teamRepository    team.Repository
+	projectRepository project.Repository
+	actionRepository  action.Repository
+	repository        Repository
+
+	mu         sync.Mutex
+	counters   map[counterKey]*counter
+	executions map[id.ID]int64
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewQuota(
+	cfg Config, teamRepository team.Repository, projectRepository project.Repository,
+	actionRepository action.Repository, repository Repository,
+) Quota {
+	return &quota{
+		cfg:               cfg,
+		teamRepository:    teamRepository,
+		projectRepository: projectRepository,
+		actionRepository:  actionRepository,
+		repository:        repository,
+		counters:          make(map[counterKey]*counter),
+		executions:        make(map[id.ID]int64),
+		done:              make(chan struct{}),
+	}
+}
+
+func (q *quota) Run(ctx context.Context) error {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			q.flush(context.WithoutCancel(ctx))
+			return nil
+		case <-q.done:
+			q.flush(ctx)
+			return nil
+		case <-ticker.C:
+			q.flush(ctx)
+		}
+	}
+}
+
+func (q *quota) Stop() {
+	q.stopOnce.Do(func() {
+		close(q.done)
+	})
+}
+
+// flush stores pending invocations, counters are dropped, so they are reloaded with invocations from other servers
+// and changes of plans.
+func (q *quota) flush(ctx context.Context) {
+	q.mu.Lock()
+	counters := q.counters
+	q.counters = make(map[counterKey]*counter)
+	q.mu.Unlock()
+
+	for key, c := range counters {
+		if c.pending == 0 {
+			continue
+		}
+
+		if err := q.repository.AddInvocations(ctx, key.teamID, key.period, c.pending); err != nil {
+			logger.Errorw(ctx, "store invocations", "team-id", key.teamID, "error", err)
+
+			// Invocations are kept to be stored on the next flush
+			q.mu.Lock()
+			if current, ok := q.counters[key]; ok {
+				current.pending += c.pending
+			} else {
+				q.counters[key] = c
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+func (q *quota) Plan(ctx context.Context, teamID id.ID) (Plan, error) {
+	if len(q.cfg.Plans) == 0 {
+		return Plan{}, nil
+	}
+
+	teamModel, found, err := q.teamRepository.GetByID(ctx, teamID)
+	if err != nil {
+		return Plan{}, fmt.Errorf("get team: %w", err)
+	}
+	if !found {
+		return Plan{}, fmt.Errorf("team %s not found", teamID)
+	}
+
+	if teamModel.Plan != "" {
+		if plan, ok := q.cfg.FindPlan(teamModel.Plan); ok {
+			return plan, nil
+		}
+		logger.Warnw(ctx, "unknown quota plan, using default", "team-id", teamID, "plan", teamModel.Plan)
+	}
+
+	plan, _ := q.cfg.FindPlan(q.cfg.DefaultPlan)
+	return plan, nil
+}
+
+func (q *quota) CheckProjects(ctx context.Context, teamID id.ID) error {
+	plan, err := q.Plan(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	if plan.MaxProjects == 0 {
+		return nil
+	}
+
+	// Concurrent creations wait for the lock, so they count project created by this transaction
+	if err = q.teamRepository.Lock(ctx, teamID); err != nil {
+		return fmt.Errorf("lock team: %w", err)
+	}
+
+	count, err := q.projectRepository.CountByTeamID(ctx, teamID)
+	if err != nil {
+		return fmt.Errorf("get projects count: %w", err)
+	}
+	if int64(count) >= plan.MaxProjects {
+		return &ExceededError{Limit: LimitProjects, Max: plan.MaxProjects}
+	}
+
+	return nil
+}
+
+func (q *quota) CheckActions(ctx context.Context, teamID, projectID id.ID) error {
+	plan, err := q.Plan(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	if plan.MaxActionsPerProject == 0 {
+		return nil
+	}
+
+	if err = q.projectRepository.Lock(ctx, projectID); err != nil {
+		return fmt.Errorf("lock project: %w", err)
+	}
+
+	count, err := q.actionRepository.CountByProjectID(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("get actions count: %w", err)
+	}
+	if int64(count) >= plan.MaxActionsPerProject {
+		return &ExceededError{Limit: LimitActionsPerProject, Max: plan.MaxActionsPerProject}
+	}
+
+	return nil
+}
+
+func (q *quota) CheckModule(ctx context.Context, teamID id.ID, actionModel *action.Model, size int64) error {
+	plan, err := q.Plan(ctx, teamID)
+	if err != nil {
+		return err
+	}
+
+	if plan.MaxModuleSize != 0 && size > plan.MaxModuleSize {
+		return &ExceededError{Limit: LimitModuleSize, Max: plan.MaxModuleSize}
+	}
+	if plan.MaxStorage == 0 {
+		return nil
+	}
+
+	used, err := q.storage(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	if used-actionModel.ModuleSize+size > plan.MaxStorage {
+		return &ExceededError{Limit: LimitStorage, Max: plan.MaxStorage}
+	}
+
+	return nil
+}
+
+// storage returns total size of active modules of team projects.
+func (q *quota) storage(ctx context.Context, teamID id.ID) (int64, error) {
+	projects, err := q.projectRepository.GetByTeamIDs(ctx, []id.ID{teamID})
+	if err != nil {
+		return 0, fmt.Errorf("get projects: %w", err)
+	}
+
+	projectIDs := make([]id.ID, len(projects))
+	for i, projectModel := range projects {
+		projectIDs[i] = projectModel.ID
+	}
+
+	size, err := q.actionRepository.SumModuleSizeByProjectIDs(ctx, projectIDs)
+	if err != nil {
+		return 0, fmt.Errorf("get modules size: %w", err)
+	}
+
+	return size, nil
+}
+
+func (q *quota) StartInvocation(ctx context.Context, teamID, projectID id.ID) (func(), error) {
+	now := time.Now()
+	c, err := q.lockCounter(ctx, counterKey{teamID: teamID, period: Period(now)})
+	if err != nil {
+		return nil, err
+	}
+	defer q.mu.Unlock()
+
+	if limit := c.plan.MaxMonthlyInvocations; limit != 0 && c.stored+c.pending >= limit {
+		return nil, &ExceededError{
+			Limit:      LimitMonthlyInvocations,
+			Max:        limit,
+			RetryAfter: nextPeriodStart(now).Sub(now),
+		}
+	}
+	if limit := c.plan.MaxConcurrentExecutions; limit != 0 && q.executions[projectID] >= limit {
+		return nil, &ExceededError{
+			Limit:      LimitConcurrentExecutions,
+			Max:        limit,
+			RetryAfter: concurrentExecutionsRetry,
+		}
+	}
+
+	c.pending++
+	q.executions[projectID]++
+
+	var releaseOnce sync.Once
+	return func() {
+		releaseOnce.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+
+			q.executions[projectID]--
+			if q.executions[projectID] <= 0 {
+				delete(q.executions, projectID)
+			}
+		})
+	}, nil
+}
+
+// lockCounter returns counter of invocations, counter is loaded if it's missing. Lock is held on successful return,
+// so counter can't be flushed while it's used.
+func (q *quota) lockCounter(ctx context.Context, key counterKey) (*counter, error) {
+	q.mu.Lock()
+	if c, ok := q.counters[key]; ok {
+		return c, nil
+	}
+	q.mu.Unlock()
+
+	plan, err := q.Plan(ctx, key.teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := q.repository.GetInvocations(ctx, key.teamID, key.period)
+	if err != nil {
+		return nil, fmt.Errorf("get invocations: %w", err)
+	}
+
+	q.mu.Lock()
+	c, ok := q.counters[key]
+	if !ok {
+		c = &counter{plan: plan, stored: stored}
+		q.counters[key] = c
+	}
+	return c, nil
+}
+
+func (q *quota) Usage(ctx context.Context, teamID id.ID) (*Usage, error) {
+	plan, err := q.Plan(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	period := Period(time.Now())
+	invocations, err := q.repository.GetInvocations(ctx, teamID, period)
+	if err != nil {
+		return nil, fmt.Errorf("get invocations: %w", err)
+	}
+
+	projects, err := q.projectRepository.GetByTeamIDs(ctx, []id.ID{teamID})
+	if err != nil {
+		return nil, fmt.Errorf("get projects: %w", err)
+	}
+
+	usage := &Usage{
+		Plan:          plan,
+		Period:        period,
+		Projects:      int64(len(projects)),
+		ProjectUsages: make([]ProjectUsage, len(projects)),
+	}
+	for i, projectModel := range projects {
+		actions, err := q.actionRepository.CountByProjectID(ctx, projectModel.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get actions count: %w", err)
+		}
+
+		size, err := q.actionRepository.SumModuleSizeByProjectIDs(ctx, []id.ID{projectModel.ID})
+		if err != nil {
+			return nil, fmt.Errorf("get modules size: %w", err)
+		}
+
+		usage.Storage += size
+		usage.ProjectUsages[i] = ProjectUsage{
+			ProjectID: projectModel.ID,
+			Name:      projectModel.Name,
+			Actions:   int64(actions),
+			Storage:   size,
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if c, ok := q.counters[counterKey{teamID: teamID, period: period}]; ok {
+		invocations += c.pending
+	}
+	usage.MonthlyInvocations = invocations
+
+	for i := range usage.ProjectUsages {
+		usage.ProjectUsages[i].ConcurrentExecutions = q.executions[usage.ProjectUsages[i].ProjectID]
+	}
+
+	return usage, nil
+}
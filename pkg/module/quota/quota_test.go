@@ -0,0 +1,157 @@
+package quota_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/action"
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/project"
+	"github.com/mymmrac/lithium/pkg/module/quota"
+	"github.com/mymmrac/lithium/pkg/module/team"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+func TestQuota(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		teamRepository := team.NewRepository(tx)
+		projectRepository := project.NewRepository(tx)
+		actionRepository := action.NewRepository(tx)
+		repository := quota.NewRepository(tx)
+		now := time.Now()
+
+		cfg := quota.Config{
+			DefaultPlan: "free",
+			Plans: []quota.Plan{
+				{Name: "free", MaxProjects: 1},
+				{
+					Name:                    "small",
+					MaxProjects:             2,
+					MaxActionsPerProject:    2,
+					MaxModuleSize:           60,
+					MaxStorage:              100,
+					MaxMonthlyInvocations:   2,
+					MaxConcurrentExecutions: 1,
+				},
+			},
+		}
+		service := quota.NewQuota(cfg, teamRepository, projectRepository, actionRepository, repository)
+
+		teamModel := &team.Model{ID: id.New(), Name: "Team", CreatedAt: now, UpdatedAt: now}
+		if err := teamRepository.Create(t.Context(), teamModel); err != nil {
+			t.Fatalf("create team: %v", err)
+		}
+		projectID := createProject(t, tx, teamModel.ID)
+
+		expectExceeded(t, "default plan projects", service.CheckProjects(t.Context(), teamModel.ID),
+			quota.LimitProjects)
+
+		if err := teamRepository.UpdatePlan(t.Context(), teamModel.ID, "small"); err != nil {
+			t.Fatalf("update plan: %v", err)
+		}
+		if err := service.CheckProjects(t.Context(), teamModel.ID); err != nil {
+			t.Errorf("projects: unexpected error: %v", err)
+		}
+
+		actions := make([]*action.Model, 2)
+		for i := range actions {
+			actions[i] = &action.Model{
+				ID: id.New(), ProjectID: projectID, Name: "action", Path: "/", Methods: []string{"GET"}, Order: i,
+				CreatedAt: now, UpdatedAt: now,
+			}
+			if err := actionRepository.Create(t.Context(), actions[i]); err != nil {
+				t.Fatalf("create action: %v", err)
+			}
+		}
+		expectExceeded(t, "actions", service.CheckActions(t.Context(), teamModel.ID, projectID),
+			quota.LimitActionsPerProject)
+
+		updated, err := actionRepository.UpdateModule(t.Context(), actions[0].ID, "", "module.wasm", 50, nil)
+		if err != nil || !updated {
+			t.Fatalf("update module: updated: %t, error: %v", updated, err)
+		}
+		actions[0].ModuleSize = 50
+
+		expectExceeded(t, "module size", service.CheckModule(t.Context(), teamModel.ID, actions[1], 70),
+			quota.LimitModuleSize)
+		expectExceeded(t, "storage", service.CheckModule(t.Context(), teamModel.ID, actions[1], 60),
+			quota.LimitStorage)
+		if err := service.CheckModule(t.Context(), teamModel.ID, actions[0], 60); err != nil {
+			t.Errorf("replaced module: unexpected error: %v", err)
+		}
+
+		release, err := service.StartInvocation(t.Context(), teamModel.ID, projectID)
+		if err != nil {
+			t.Fatalf("first invocation: %v", err)
+		}
+		_, err = service.StartInvocation(t.Context(), teamModel.ID, projectID)
+		expectExceeded(t, "concurrent executions", err, quota.LimitConcurrentExecutions)
+		release()
+
+		release, err = service.StartInvocation(t.Context(), teamModel.ID, projectID)
+		if err != nil {
+			t.Fatalf("second invocation: %v", err)
+		}
+		release()
+
+		_, err = service.StartInvocation(t.Context(), teamModel.ID, projectID)
+		expectExceeded(t, "monthly invocations", err, quota.LimitMonthlyInvocations)
+
+		usage, err := service.Usage(t.Context(), teamModel.ID)
+		if err != nil {
+			t.Fatalf("usage: %v", err)
+		}
+		if usage.Plan.Name != "small" || usage.Projects != 1 || usage.Storage != 50 || usage.MonthlyInvocations != 2 ||
+			len(usage.ProjectUsages) != 1 || usage.ProjectUsages[0].Actions != 2 {
+			t.Errorf("unexpected usage: %+v", usage)
+		}
+
+		// Invocations are stored when service stops
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+		service.Stop()
+		if err = service.Run(ctx); err != nil {
+			t.Fatalf("run: %v", err)
+		}
+
+		invocations, err := repository.GetInvocations(t.Context(), teamModel.ID, quota.Period(time.Now()))
+		if err != nil {
+			t.Fatalf("get invocations: %v", err)
+		}
+		if invocations != 2 {
+			t.Errorf("unexpected stored invocations: %d", invocations)
+		}
+	})
+}
+
+func expectExceeded(t *testing.T, name string, err error, limit quota.Limit) {
+	t.Helper()
+
+	var exceededErr *quota.ExceededError
+	if !errors.As(err, &exceededErr) || exceededErr.Limit != limit {
+		t.Errorf("%s: expected %s limit exceeded, got %v", name, limit, err)
+	}
+}
+
+func createProject(t *testing.T, tx db.Transaction, teamID id.ID) id.ID {
+	t.Helper()
+
+	now := time.Now()
+	userModel := &user.Model{ID: id.New(), Email: "owner@example.com", Password: "hash", CreatedAt: now, UpdatedAt: now}
+	if err := user.NewRepository(tx).Create(t.Context(), userModel); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	projectModel := &project.Model{
+		ID: id.New(), TeamID: teamID, OwnerID: userModel.ID, Name: "Project", SubDomain: "project",
+		CreatedAt: now, UpdatedAt: now,
+	}
+	if err := project.NewRepository(tx).Create(t.Context(), projectModel); err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	return projectModel.ID
+}
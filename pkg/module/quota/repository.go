@@ -0,0 +1,58 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type Repository interface {
+	// AddInvocations increases number of invocations of team in period
+	AddInvocations(ctx context.Context, teamID id.ID, period string, invocations int64) error
+	GetInvocations(ctx context.Context, teamID id.ID, period string) (int64, error)
+}
+
+type repository struct {
+	tx db.Transaction
+}
+
+func NewRepository(tx db.Transaction) Repository {
+	return &repository{
+		tx: tx,
+	}
+}
+
+func (r *repository) AddInvocations(ctx context.Context, teamID id.ID, period string, invocations int64) error {
+	_, err := r.tx.Extract(ctx).NewInsert().
+		Model(&InvocationUsage{
+			TeamID:      teamID,
+			Period:      period,
+			Invocations: invocations,
+		}).
+		On("CONFLICT (team_id, period) DO UPDATE").
+		Set("invocations = ?TableAlias.invocations + EXCLUDED.invocations").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetInvocations(ctx context.Context, teamID id.ID, period string) (int64, error) {
+	model := &InvocationUsage{}
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(model).
+		Where("team_id = ?", teamID).
+		Where("period = ?", period).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return model.Invocations, nil
+}
@@ -0,0 +1,60 @@
+package routing
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+type Config struct {
+	// BaseDomains are domains under which projects are served by sub-domain, the longest matching domain is used
+	BaseDomains []string `validate:"unique,dive,hostname_rfc1123"`
+	// ReservedSubDomains of base domains serve dashboard instead of projects
+	ReservedSubDomains []string `validate:"unique,dive,hostname_rfc1123"`
+	// DashboardHost is the only host that serves dashboard and management API, any host does if it's empty
+	DashboardHost string `validate:"omitempty,hostname_rfc1123"`
+}
+
+func init() { //nolint:gochecknoinits
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+		cfg := Config{
+			BaseDomains:        stringList(v, "base-domains"),
+			ReservedSubDomains: stringList(v, "reserved-subdomains"),
+			DashboardHost:      Normalize(v.GetString("dashboard-host")),
+		}
+		if err := va.Struct(cfg); err != nil {
+			return Config{}, err
+		}
+
+		// Base domain can be sub-domain of another one, so longer domains must be matched first
+		slices.SortStableFunc(cfg.BaseDomains, func(a, b string) int {
+			return cmp.Compare(len(b), len(a))
+		})
+
+		return cfg, nil
+	})
+}
+
+// stringList returns normalized list from config, config files have it as a list, environment variable has it as a
+// comma separated string.
+func stringList(v *viper.Viper, key string) []string {
+	var values []string
+	if value, ok := v.Get(key).(string); ok {
+		values = strings.Split(value, ",")
+	} else {
+		values = v.GetStringSlice(key)
+	}
+
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = Normalize(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -0,0 +1,101 @@
+// Package routing decides what is served on a host: project by sub-domain of base domain, project by custom domain
+// or dashboard with management API.
+package routing
+
+import (
+	"net"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Route of a host, host with empty route is not served.
+type Route struct {
+	// SubDomain of project served on host
+	SubDomain string
+	// Custom is set if host can be a custom domain of a project
+	Custom bool
+	// Dashboard is set if dashboard and management API are served on host
+	Dashboard bool
+}
+
+// Resolve returns route of hostname (host without port).
+func (c Config) Resolve(hostname string) Route {
+	hostname = Normalize(hostname)
+	if c.DashboardHost != "" && hostname == c.DashboardHost {
+		return Route{Dashboard: true}
+	}
+	dashboard := c.DashboardHost == ""
+
+	if len(c.BaseDomains) == 0 {
+		return c.resolveWithoutBaseDomains(hostname, dashboard)
+	}
+
+	for _, baseDomain := range c.BaseDomains {
+		if hostname == baseDomain {
+			return Route{Dashboard: dashboard}
+		}
+
+		subDomain, ok := strings.CutSuffix(hostname, "."+baseDomain)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case strings.Contains(subDomain, "."):
+			// Projects have only one label
+			return Route{}
+		case slices.Contains(c.ReservedSubDomains, subDomain):
+			return Route{Dashboard: dashboard}
+		default:
+			return Route{SubDomain: subDomain}
+		}
+	}
+
+	// Dashboard is also served on hosts that are not configured (for example, IP address), unless it has its own host
+	return Route{Custom: true, Dashboard: dashboard}
+}
+
+// resolveWithoutBaseDomains guesses base domain as registrable domain (one label above public suffix), so both
+// `project.example.com` and `project.example.co.uk` are sub-domains. Custom domains are checked first, because
+// custom domain can look like a sub-domain.
+func (c Config) resolveWithoutBaseDomains(hostname string, dashboard bool) Route {
+	route := Route{Custom: true, Dashboard: dashboard}
+	if net.ParseIP(strings.Trim(hostname, "[]")) != nil {
+		return Route{Dashboard: dashboard}
+	}
+
+	baseDomain, err := publicsuffix.EffectiveTLDPlusOne(hostname)
+	if err != nil {
+		return route
+	}
+
+	subDomain, ok := strings.CutSuffix(hostname, "."+baseDomain)
+	if !ok || strings.Contains(subDomain, ".") || slices.Contains(c.ReservedSubDomains, subDomain) {
+		return route
+	}
+
+	route.SubDomain = subDomain
+	route.Dashboard = false
+	return route
+}
+
+// Reserved reports whether domain can't be used as custom domain, because it's served by the server itself.
+func (c Config) Reserved(domain string) bool {
+	domain = Normalize(domain)
+	if c.DashboardHost != "" && domain == c.DashboardHost {
+		return true
+	}
+	for _, baseDomain := range c.BaseDomains {
+		if domain == baseDomain || strings.HasSuffix(domain, "."+baseDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize returns host in canonical form, as it's stored and compared.
+func Normalize(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
@@ -0,0 +1,67 @@
+package routing_test
+
+import (
+	"testing"
+
+	"github.com/mymmrac/lithium/pkg/module/routing"
+)
+
+func TestResolve(t *testing.T) {
+	withBaseDomains := routing.Config{
+		BaseDomains:        []string{"run.example.co.uk", "example.co.uk"},
+		ReservedSubDomains: []string{"www"},
+	}
+	withDashboardHost := routing.Config{
+		BaseDomains:        []string{"example.com"},
+		ReservedSubDomains: []string{"www"},
+		DashboardHost:      "app.example.com",
+	}
+	withoutBaseDomains := routing.Config{
+		ReservedSubDomains: []string{"www"},
+	}
+
+	tests := []struct {
+		name     string
+		cfg      routing.Config
+		hostname string
+		expected routing.Route
+	}{
+		{"base domain", withBaseDomains, "example.co.uk", routing.Route{Dashboard: true}},
+		{"reserved", withBaseDomains, "www.example.co.uk", routing.Route{Dashboard: true}},
+		{"sub-domain", withBaseDomains, "Project.Example.co.uk.", routing.Route{SubDomain: "project"}},
+		{"longest base domain", withBaseDomains, "project.run.example.co.uk", routing.Route{SubDomain: "project"}},
+		{"nested sub-domain", withBaseDomains, "a.b.example.co.uk", routing.Route{}},
+		{"other host", withBaseDomains, "project.org", routing.Route{Custom: true, Dashboard: true}},
+		{"dashboard host", withDashboardHost, "app.example.com", routing.Route{Dashboard: true}},
+		{"base domain without dashboard", withDashboardHost, "example.com", routing.Route{}},
+		{"reserved without dashboard", withDashboardHost, "www.example.com", routing.Route{}},
+		{"other host without dashboard", withDashboardHost, "project.org", routing.Route{Custom: true}},
+		{"guessed sub-domain", withoutBaseDomains, "project.example.co.uk",
+			routing.Route{SubDomain: "project", Custom: true}},
+		{"guessed base domain", withoutBaseDomains, "example.co.uk", routing.Route{Custom: true, Dashboard: true}},
+		{"guessed reserved", withoutBaseDomains, "www.example.com", routing.Route{Custom: true, Dashboard: true}},
+		{"localhost", withoutBaseDomains, "localhost", routing.Route{Custom: true, Dashboard: true}},
+		{"IP address", withoutBaseDomains, "127.0.0.1", routing.Route{Dashboard: true}},
+	}
+	for _, tt := range tests {
+		if actual := tt.cfg.Resolve(tt.hostname); actual != tt.expected {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestReserved(t *testing.T) {
+	cfg := routing.Config{BaseDomains: []string{"example.com"}, DashboardHost: "dashboard.example.org"}
+
+	for domain, expected := range map[string]bool{
+		"example.com":           true,
+		"project.example.com":   true,
+		"dashboard.example.org": true,
+		"example.org":           false,
+		"notexample.com":        false,
+	} {
+		if actual := cfg.Reserved(domain); actual != expected {
+			t.Errorf("%s: expected %t, got %t", domain, expected, actual)
+		}
+	}
+}
@@ -1,26 +1,148 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v3"
 	"github.com/spf13/viper"
 
+	"github.com/mymmrac/lithium/pkg/module/certificate"
 	"github.com/mymmrac/lithium/pkg/module/di"
 )
 
 type Config struct {
-	Host string `validate:"omitempty,hostname"`
-	Port uint   `validate:"port"`
+	// Management listener serves dashboard and management API, it also serves invocations if invocation listener is
+	// disabled
+	Management ListenerConfig
+	// Invocation listener serves only invocations, it's disabled if port is not set
+	Invocation ListenerConfig
+	// Redirect listener redirects plain HTTP requests to HTTPS, it's disabled if port is not set and requires TLS
+	Redirect ListenerConfig
+	// TrustedProxies are addresses of proxies that are trusted to describe client with forwarded headers
+	TrustedProxies []netip.Prefix `validate:"-"`
+}
+
+type ListenerConfig struct {
+	Host      string `validate:"omitempty,hostname|ip"`
+	Port      uint   `validate:"omitempty,port"`
+	BodyLimit int    `validate:"min=1"`
+	// Timeouts are disabled if zero, idle timeout defaults to read timeout
+	ReadTimeout  time.Duration `validate:"min=0"`
+	WriteTimeout time.Duration `validate:"min=0"`
+	IdleTimeout  time.Duration `validate:"min=0"`
+}
+
+// Enabled reports whether listener is configured.
+func (c ListenerConfig) Enabled() bool {
+	return c.Port != 0
+}
+
+// Address returns address to listen on.
+func (c ListenerConfig) Address() string {
+	return net.JoinHostPort(c.Host, strconv.FormatUint(uint64(c.Port), 10))
+}
+
+// FiberConfig returns app config with limits of listener.
+func (c ListenerConfig) FiberConfig() fiber.Config {
+	return fiber.Config{
+		BodyLimit:    c.BodyLimit,
+		ReadTimeout:  c.ReadTimeout,
+		WriteTimeout: c.WriteTimeout,
+		IdleTimeout:  c.IdleTimeout,
+	}
 }
 
 func init() { //nolint:gochecknoinits
-	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate, certificateCfg certificate.Config) (Config, error) {
 		cfg := Config{
-			Host: v.GetString("host"),
-			Port: v.GetUint("port"),
+			Management: ListenerConfig{
+				Host:         v.GetString("host"),
+				Port:         v.GetUint("port"),
+				BodyLimit:    v.GetInt("body-limit"),
+				ReadTimeout:  v.GetDuration("read-timeout"),
+				WriteTimeout: v.GetDuration("write-timeout"),
+				IdleTimeout:  v.GetDuration("idle-timeout"),
+			},
+			Invocation: ListenerConfig{
+				Host:         v.GetString("invocation-host"),
+				Port:         v.GetUint("invocation-port"),
+				BodyLimit:    v.GetInt("invocation-body-limit"),
+				ReadTimeout:  v.GetDuration("invocation-read-timeout"),
+				WriteTimeout: v.GetDuration("invocation-write-timeout"),
+				IdleTimeout:  v.GetDuration("invocation-idle-timeout"),
+			},
+			Redirect: ListenerConfig{
+				Host:      v.GetString("host"),
+				Port:      v.GetUint("tls-redirect-port"),
+				BodyLimit: v.GetInt("body-limit"),
+			},
 		}
-		if err := va.Struct(cfg); err != nil {
+		trustedProxies, err := parsePrefixes(v, "trusted-proxies")
+		if err != nil {
+			return Config{}, fmt.Errorf("parse trusted proxies: %w", err)
+		}
+		cfg.TrustedProxies = trustedProxies
+
+		if err = va.Struct(cfg); err != nil {
 			return Config{}, err
 		}
+		if !cfg.Management.Enabled() {
+			return Config{}, errors.New("management listener port is required")
+		}
+		if cfg.Invocation.Enabled() && cfg.Invocation.Address() == cfg.Management.Address() {
+			return Config{}, errors.New("invocation listener must have address different from management listener")
+		}
+		if cfg.Redirect.Enabled() {
+			if !certificateCfg.Enabled() {
+				return Config{}, errors.New("redirect to HTTPS requires TLS certificate")
+			}
+			if cfg.Redirect.Address() == cfg.Management.Address() ||
+				cfg.Invocation.Enabled() && cfg.Redirect.Address() == cfg.Invocation.Address() {
+				return Config{}, errors.New("redirect listener must have address different from other listeners")
+			}
+		}
 		return cfg, nil
 	})
 }
+
+// parsePrefixes returns list of IP ranges from config, single addresses are ranges of one address. Config files have
+// it as a list, environment variable has it as a comma separated string.
+func parsePrefixes(v *viper.Viper, key string) ([]netip.Prefix, error) {
+	var values []string
+	if value, ok := v.Get(key).(string); ok {
+		values = strings.Split(value, ",")
+	} else {
+		values = v.GetStringSlice(key)
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
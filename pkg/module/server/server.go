@@ -2,57 +2,112 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
-	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
 
+	"github.com/mymmrac/lithium/pkg/module/certificate"
 	"github.com/mymmrac/lithium/pkg/module/di"
 	"github.com/mymmrac/lithium/pkg/module/logger"
 	"github.com/mymmrac/lithium/pkg/module/runner"
+	"github.com/mymmrac/lithium/pkg/module/version"
 )
 
+const shutdownTimeout = 5 * time.Second
+
 type Server runner.Service
 
+// InvocationApp serves invocations on invocation listener, app is nil if invocation listener is disabled.
+type InvocationApp struct {
+	*fiber.App
+}
+
 func init() { //nolint:gochecknoinits
 	di.Base().
-		MustProvide(func(ctx context.Context, app *fiber.App, server Server, runner runner.Runner) fiber.Router {
-			runner.Add(ctx, server)
+		MustProvide(func(
+			ctx context.Context, cfg Config, certificateCfg certificate.Config, certificateStore certificate.Store,
+			app *fiber.App, invocationApp InvocationApp, runner runner.Runner,
+		) fiber.Router {
+			var tlsConfig *tls.Config
+			if certificateCfg.Enabled() {
+				tlsConfig = &tls.Config{
+					GetCertificate: certificateStore.GetCertificate,
+					MinVersion:     tls.VersionTLS12,
+				}
+				runner.Add(ctx, certificateStore)
+			}
+
+			runner.Add(ctx, NewFiberServer("management", cfg.Management, app, tlsConfig))
+			if invocationApp.App != nil {
+				runner.Add(ctx, NewFiberServer("invocation", cfg.Invocation, invocationApp.App, tlsConfig))
+			}
+			if cfg.Redirect.Enabled() {
+				runner.Add(ctx, NewFiberServer("redirect", cfg.Redirect, NewRedirectApp(), nil))
+			}
 			return app
-		}).
-		MustProvide(NewFiberServer)
+		})
+}
+
+// NewRedirectApp returns app that permanently redirects all requests to the same URL over HTTPS on default port.
+func NewRedirectApp() *fiber.App {
+	app := fiber.New(fiber.Config{
+		AppName: version.Name(),
+	})
+	app.Use(func(fCtx fiber.Ctx) error {
+		return fCtx.Redirect().
+			Status(fiber.StatusMovedPermanently).
+			To("https://" + fCtx.Hostname() + fCtx.OriginalURL())
+	})
+	return app
 }
 
 type FiberServer struct {
-	cfg Config
-	app *fiber.App
+	name string
+	cfg  ListenerConfig
+	app  *fiber.App
+	// tlsConfig is nil if listener serves plain HTTP
+	tlsConfig *tls.Config
 }
 
-func NewFiberServer(cfg Config, app *fiber.App) Server {
+func NewFiberServer(name string, cfg ListenerConfig, app *fiber.App, tlsConfig *tls.Config) Server {
 	return &FiberServer{
-		cfg: cfg,
-		app: app,
+		name:      name,
+		cfg:       cfg,
+		app:       app,
+		tlsConfig: tlsConfig,
 	}
 }
 
 func (f *FiberServer) Run(ctx context.Context) error {
-	addr := net.JoinHostPort(f.cfg.Host, strconv.FormatUint(uint64(f.cfg.Port), 10))
-	logger.Infof(ctx, "listening on %s", addr)
+	addr := f.cfg.Address()
+
+	ln, err := net.Listen(fiber.NetworkTCP4, addr)
+	if err != nil {
+		return fmt.Errorf("listen %s server: %w", f.name, err)
+	}
+
+	if f.tlsConfig != nil {
+		ln = tls.NewListener(ln, f.tlsConfig)
+		logger.Infof(ctx, "%s listener on %s (TLS)", f.name, addr)
+	} else {
+		logger.Infof(ctx, "%s listener on %s", f.name, addr)
+	}
 
-	err := f.app.Listen(addr, fiber.ListenConfig{
+	err = f.app.Listener(ln, fiber.ListenConfig{
 		DisableStartupMessage: true,
 	})
 	if err != nil {
-		return fmt.Errorf("start server: %w", err)
+		return fmt.Errorf("start %s server: %w", f.name, err)
 	}
 
 	return nil
 }
 
 func (f *FiberServer) Stop() {
-	if err := f.app.ShutdownWithTimeout(time.Second * 5); err != nil {
-		logger.Errorw(context.Background(), "shutting down server", "error", err)
+	if err := f.app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+		logger.Errorw(context.Background(), "shutting down server", "server", f.name, "error", err)
 	}
 }
@@ -0,0 +1,29 @@
+package session
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type Model struct {
+	bun.BaseModel `bun:"table:session"`
+
+	ID                  id.ID     `bun:"id,pk"`
+	UserID              id.ID     `bun:"user_id"`
+	RefreshHash         string    `bun:"refresh_hash"`
+	PreviousRefreshHash string    `bun:"previous_refresh_hash,nullzero"`
+	UserAgent           string    `bun:"user_agent"`
+	IPAddress           string    `bun:"ip_address"`
+	CreatedAt           time.Time `bun:"created_at"`
+	LastUsedAt          time.Time `bun:"last_used_at"`
+	RotatedAt           time.Time `bun:"rotated_at"`
+	ExpiresAt           time.Time `bun:"expires_at"`
+}
+
+// Expired reports whether session can't be used or refreshed anymore.
+func (m *Model) Expired(now time.Time) bool {
+	return !now.Before(m.ExpiresAt)
+}
@@ -0,0 +1,19 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// GenerateRefreshToken returns new random refresh token and its hash, only hash should be stored.
+func GenerateRefreshToken() (value, hash string) {
+	value = rand.Text() + rand.Text()
+	return value, HashRefreshToken(value)
+}
+
+// HashRefreshToken returns refresh token hash used for lookup.
+func HashRefreshToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,171 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type Repository interface {
+	Create(ctx context.Context, model *Model) error
+	GetByID(ctx context.Context, id id.ID) (*Model, bool, error)
+	GetByRefreshHash(ctx context.Context, hash string) (*Model, bool, error)
+	GetByPreviousRefreshHash(ctx context.Context, hash string) (*Model, bool, error)
+	GetByUserID(ctx context.Context, userID id.ID) ([]Model, error)
+	Rotate(ctx context.Context, id id.ID, oldHash, newHash string, rotatedAt, expiresAt time.Time) (bool, error)
+	UpdateLastUsed(ctx context.Context, id id.ID, lastUsedAt time.Time) error
+	DeleteByID(ctx context.Context, id id.ID) error
+	DeleteByUserID(ctx context.Context, userID id.ID) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+type repository struct {
+	tx db.Transaction
+}
+
+func NewRepository(tx db.Transaction) Repository {
+	return &repository{
+		tx: tx,
+	}
+}
+
+func (r *repository) Create(ctx context.Context, model *Model) error {
+	_, err := r.tx.Extract(ctx).NewInsert().Model(model).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetByID(ctx context.Context, id id.ID) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+func (r *repository) GetByRefreshHash(ctx context.Context, hash string) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("refresh_hash = ?", hash).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+func (r *repository) GetByPreviousRefreshHash(ctx context.Context, hash string) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("previous_refresh_hash = ?", hash).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+func (r *repository) GetByUserID(ctx context.Context, userID id.ID) ([]Model, error) {
+	var models []Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&models).
+		Where("user_id = ?", userID).
+		Order("last_used_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// Rotate replaces refresh token hash only if it still equals old hash, returns false if session was rotated
+// concurrently or doesn't exist.
+func (r *repository) Rotate(
+	ctx context.Context, id id.ID, oldHash, newHash string, rotatedAt, expiresAt time.Time,
+) (bool, error) {
+	result, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("refresh_hash = ?", newHash).
+		Set("previous_refresh_hash = ?", oldHash).
+		Set("rotated_at = ?", rotatedAt).
+		Set("last_used_at = ?", rotatedAt).
+		Set("expires_at = ?", expiresAt).
+		Where("id = ?", id).
+		Where("refresh_hash = ?", oldHash).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *repository) UpdateLastUsed(ctx context.Context, id id.ID, lastUsedAt time.Time) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("last_used_at = ?", lastUsedAt).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteByID(ctx context.Context, id id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteByUserID(ctx context.Context, userID id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteExpired(ctx context.Context, now time.Time) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("expires_at <= ?", now).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,30 +1,68 @@
 package storage
 
 import (
+	"fmt"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
 
 	"github.com/mymmrac/lithium/pkg/module/di"
 )
 
+const (
+	DriverMinio      = "minio"
+	DriverFilesystem = "filesystem"
+)
+
 type Config struct {
+	Driver     string           `validate:"oneof=minio filesystem"`
+	Minio      MinioConfig      `validate:"-"`
+	Filesystem FilesystemConfig `validate:"-"`
+}
+
+type MinioConfig struct {
 	Endpoint string `validate:"hostname_port"`
 	Secure   bool   `validate:"-"`
 	ID       string `validate:"required"`
-	Secret   string `validate:"required"`
+	Secret   string `validate:"required" secret:"true"`
+}
+
+type FilesystemConfig struct {
+	Path string `validate:"required"`
 }
 
 func init() { //nolint:gochecknoinits
 	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
 		cfg := Config{
-			Endpoint: v.GetString("minio-endpoint"),
-			Secure:   v.GetBool("minio-secure"),
-			ID:       v.GetString("minio-id"),
-			Secret:   v.GetString("minio-secret"),
+			Driver: v.GetString("storage-driver"),
+			Minio: MinioConfig{
+				Endpoint: v.GetString("minio-endpoint"),
+				Secure:   v.GetBool("minio-secure"),
+				ID:       v.GetString("minio-id"),
+				Secret:   v.GetString("minio-secret"),
+			},
+			Filesystem: FilesystemConfig{
+				Path: v.GetString("storage-path"),
+			},
 		}
 		if err := va.Struct(cfg); err != nil {
 			return Config{}, err
 		}
+
+		// Only settings of the selected driver are required
+		var driverCfg any
+		switch cfg.Driver {
+		case DriverMinio:
+			driverCfg = cfg.Minio
+		case DriverFilesystem:
+			driverCfg = cfg.Filesystem
+		default:
+			return Config{}, fmt.Errorf("unknown storage driver: %q", cfg.Driver)
+		}
+		if err := va.Struct(driverCfg); err != nil {
+			return Config{}, err
+		}
+
 		return cfg, nil
 	})
 }
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// tempPrefix marks files that are still being written, they are never visible as objects
+const tempPrefix = ".lithium-tmp-"
+
+type filesystemStorage struct {
+	root *os.Root
+}
+
+// NewFilesystem creates storage that keeps objects as files on local disk, each bucket is a directory under the
+// configured path. Paths are resolved through os.Root, so objects can't escape their bucket.
+func NewFilesystem(cfg FilesystemConfig) (Storage, error) {
+	if err := os.MkdirAll(cfg.Path, 0o750); err != nil {
+		return nil, fmt.Errorf("create storage directory: %w", err)
+	}
+
+	root, err := os.OpenRoot(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open storage directory: %w", err)
+	}
+
+	return &filesystemStorage{
+		root: root,
+	}, nil
+}
+
+func (s *filesystemStorage) Upload(
+	ctx context.Context, bucket, path string, reader io.Reader, size int64, _ string,
+) error {
+	name, err := objectName(bucket, path)
+	if err != nil {
+		return err
+	}
+
+	dir := pathpkg.Dir(name)
+	if err = s.root.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("create object directory: %w", err)
+	}
+
+	tempName, err := tempObjectName(dir)
+	if err != nil {
+		return err
+	}
+
+	file, err := s.root.OpenFile(tempName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+
+	// Write to a temp file first and rename it into place, so readers never observe partially written objects
+	written, err := io.Copy(file, contextReader{ctx: ctx, reader: reader})
+	if err == nil && size != UnknownSize && written != size {
+		err = fmt.Errorf("size mismatch: expected %d bytes, got %d", size, written)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = s.root.Rename(tempName, name)
+	}
+	if err != nil {
+		_ = s.root.Remove(tempName)
+		return fmt.Errorf("write object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *filesystemStorage) Download(_ context.Context, bucket, path string) (io.ReadCloser, ObjectInfo, error) {
+	name, err := objectName(bucket, path)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	file, err := s.root.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ObjectInfo{}, ErrNotFound
+		}
+		return nil, ObjectInfo{}, fmt.Errorf("open object: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, ObjectInfo{}, fmt.Errorf("stat object: %w", err)
+	}
+	if stat.IsDir() {
+		_ = file.Close()
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+
+	return file, fileInfo(path, stat), nil
+}
+
+func (s *filesystemStorage) Stat(_ context.Context, bucket, path string) (ObjectInfo, bool, error) {
+	name, err := objectName(bucket, path)
+	if err != nil {
+		return ObjectInfo{}, false, err
+	}
+
+	stat, err := s.root.Stat(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ObjectInfo{}, false, nil
+		}
+		return ObjectInfo{}, false, fmt.Errorf("stat object: %w", err)
+	}
+	if stat.IsDir() {
+		return ObjectInfo{}, false, nil
+	}
+
+	return fileInfo(path, stat), true, nil
+}
+
+func (s *filesystemStorage) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	if err := validateBucket(bucket); err != nil {
+		return nil, err
+	}
+
+	var objects []ObjectInfo
+	err := fs.WalkDir(s.root.FS(), bucket, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if name == bucket && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		path := strings.TrimPrefix(name, bucket+"/")
+		if entry.IsDir() {
+			// Skip directories that can't contain matching objects
+			if name != bucket && !strings.HasPrefix(path+"/", prefix) && !strings.HasPrefix(prefix, path+"/") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), tempPrefix) || !strings.HasPrefix(path, prefix) {
+			return nil
+		}
+
+		stat, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		objects = append(objects, fileInfo(path, stat))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list objects: %w", err)
+	}
+
+	slices.SortFunc(objects, func(a, b ObjectInfo) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return objects, nil
+}
+
+func (s *filesystemStorage) Delete(_ context.Context, bucket, path string) error {
+	name, err := objectName(bucket, path)
+	if err != nil {
+		return err
+	}
+
+	if err = s.root.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("delete object: %w", err)
+	}
+	return nil
+}
+
+// objectName returns slash separated object name relative to storage root
+func objectName(bucket, path string) (string, error) {
+	if err := validateBucket(bucket); err != nil {
+		return "", err
+	}
+	if path == "." || !fs.ValidPath(path) || strings.Contains(path, "\\") ||
+		strings.HasPrefix(pathpkg.Base(path), tempPrefix) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, path)
+	}
+	if _, err := filepath.Localize(path); err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, path)
+	}
+	return bucket + "/" + path, nil
+}
+
+func validateBucket(bucket string) error {
+	if bucket == "" || bucket == "." || bucket == ".." || strings.ContainsAny(bucket, "/\\") {
+		return fmt.Errorf("%w: bucket %q", ErrInvalidPath, bucket)
+	}
+	return nil
+}
+
+func tempObjectName(dir string) (string, error) {
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", fmt.Errorf("generate temp name: %w", err)
+	}
+	return pathpkg.Join(dir, tempPrefix+hex.EncodeToString(suffix[:])), nil
+}
+
+func fileInfo(path string, stat fs.FileInfo) ObjectInfo {
+	contentType := mime.TypeByExtension(pathpkg.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return ObjectInfo{
+		Path:        path,
+		Size:        stat.Size(),
+		ContentType: contentType,
+		ModifiedAt:  stat.ModTime(),
+	}
+}
+
+// contextReader stops reading once context is canceled
+type contextReader struct {
+	ctx    context.Context //nolint:containedctx
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
@@ -0,0 +1,98 @@
+package storage_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mymmrac/lithium/pkg/module/storage"
+	"github.com/mymmrac/lithium/pkg/module/storage/storagetest"
+)
+
+func newFilesystem(t *testing.T) (storage.Storage, string) {
+	t.Helper()
+
+	s, err := storage.NewFilesystem(storage.FilesystemConfig{
+		Path: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+	return s, "test"
+}
+
+func TestFilesystem(t *testing.T) {
+	storagetest.Run(t, newFilesystem)
+}
+
+func TestFilesystemInvalidPath(t *testing.T) {
+	s, bucket := newFilesystem(t)
+
+	paths := []string{"", ".", "..", "../outside.wasm", "a/../../outside.wasm", "/abs.wasm", "a//b.wasm", "a\\b.wasm"}
+	for _, path := range paths {
+		err := s.Upload(t.Context(), bucket, path, bytes.NewReader(nil), 0, "")
+		if !errors.Is(err, storage.ErrInvalidPath) {
+			t.Errorf("upload %q: expected invalid path error, got: %v", path, err)
+		}
+		if _, _, err = s.Stat(t.Context(), bucket, path); !errors.Is(err, storage.ErrInvalidPath) {
+			t.Errorf("stat %q: expected invalid path error, got: %v", path, err)
+		}
+	}
+
+	for _, bucket := range []string{"", ".", "..", "a/b"} {
+		err := s.Upload(t.Context(), bucket, "module.wasm", bytes.NewReader(nil), 0, "")
+		if !errors.Is(err, storage.ErrInvalidPath) {
+			t.Errorf("bucket %q: expected invalid path error, got: %v", bucket, err)
+		}
+	}
+}
+
+func TestFilesystemSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.wasm"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "test")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	s, err := storage.NewFilesystem(storage.FilesystemConfig{Path: dir})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+
+	if _, _, err = s.Download(t.Context(), "test", "secret.wasm"); err == nil {
+		t.Fatal("expected download through symlink outside of root to fail")
+	}
+}
+
+func TestFilesystemBucketDirectory(t *testing.T) {
+	dir := t.TempDir()
+	s, err := storage.NewFilesystem(storage.FilesystemConfig{Path: dir})
+	if err != nil {
+		t.Fatalf("create storage: %v", err)
+	}
+
+	if err = s.Upload(t.Context(), "modules", "a/b.wasm", bytes.NewReader([]byte("b")), 1, ""); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "modules", "a", "b.wasm"))
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if string(data) != "b" {
+		t.Fatalf("unexpected data: %q", data)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "modules", "a"))
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected no leftover temp files, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// LimitReader returns a reader that fails with ErrTooLarge once more than limit bytes are read,
+// unlike io.LimitReader it doesn't silently truncate data.
+func LimitReader(reader io.Reader, limit int64) io.Reader {
+	return &limitReader{
+		reader:    reader,
+		remaining: limit,
+	}
+}
+
+type limitReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+
+	// Allow reading one byte over the limit to detect that data is too large
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrTooLarge
+	}
+
+	return n, err
+}
+
+// ReadAll downloads the whole object, fails with ErrTooLarge if the object is larger than maxSize.
+func ReadAll(ctx context.Context, s Storage, bucket, path string, maxSize int64) ([]byte, error) {
+	reader, info, err := s.Download(ctx, bucket, path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	if info.Size > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d bytes", ErrTooLarge, info.Size, maxSize)
+	}
+
+	data := bytes.NewBuffer(make([]byte, 0, max(info.Size, 0)))
+	if _, err = data.ReadFrom(LimitReader(reader, maxSize)); err != nil {
+		return nil, fmt.Errorf("read object: %w", err)
+	}
+
+	return data.Bytes(), nil
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type minioStorage struct {
+	client *minio.Client
+}
+
+// NewMinio creates storage backed by MinIO or any other S3 compatible object storage.
+func NewMinio(cfg MinioConfig) (Storage, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.ID, cfg.Secret, ""),
+		Secure: cfg.Secure,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create minio: %w", err)
+	}
+
+	return &minioStorage{
+		client: client,
+	}, nil
+}
+
+func (s *minioStorage) Upload(ctx context.Context, bucket, path string, reader io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, bucket, path, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *minioStorage) Download(ctx context.Context, bucket, path string) (io.ReadCloser, ObjectInfo, error) {
+	obj, err := s.client.GetObject(ctx, bucket, path, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("get object: %w", err)
+	}
+
+	info, err := obj.Stat()
+	if err != nil {
+		_ = obj.Close()
+		if isNotFound(err) {
+			return nil, ObjectInfo{}, ErrNotFound
+		}
+		return nil, ObjectInfo{}, fmt.Errorf("stat object: %w", err)
+	}
+
+	return obj, objectInfo(info), nil
+}
+
+func (s *minioStorage) Stat(ctx context.Context, bucket, path string) (ObjectInfo, bool, error) {
+	info, err := s.client.StatObject(ctx, bucket, path, minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return ObjectInfo{}, false, nil
+		}
+		return ObjectInfo{}, false, err
+	}
+	return objectInfo(info), true, nil
+}
+
+func (s *minioStorage) List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for info := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("list objects: %w", info.Err)
+		}
+		objects = append(objects, objectInfo(info))
+	}
+	return objects, nil
+}
+
+func (s *minioStorage) Delete(ctx context.Context, bucket, path string) error {
+	return s.client.RemoveObject(ctx, bucket, path, minio.RemoveObjectOptions{})
+}
+
+func objectInfo(info minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Path:        info.Key,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+		ModifiedAt:  info.LastModified,
+	}
+}
+
+func isNotFound(err error) bool {
+	return minio.ToErrorResponse(err).Code == minio.NoSuchKey
+}
@@ -0,0 +1,56 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/mymmrac/lithium/pkg/module/storage"
+	"github.com/mymmrac/lithium/pkg/module/storage/storagetest"
+)
+
+// TestMinio runs against real MinIO, set MINIO_TEST_ENDPOINT, MINIO_TEST_ID and MINIO_TEST_SECRET to enable it
+func TestMinio(t *testing.T) {
+	cfg := storage.MinioConfig{
+		Endpoint: os.Getenv("MINIO_TEST_ENDPOINT"),
+		ID:       os.Getenv("MINIO_TEST_ID"),
+		Secret:   os.Getenv("MINIO_TEST_SECRET"),
+	}
+	if cfg.Endpoint == "" {
+		t.Skip("MINIO_TEST_ENDPOINT is not set")
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds: credentials.NewStaticV4(cfg.ID, cfg.Secret, ""),
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	storagetest.Run(t, func(t *testing.T) (storage.Storage, string) {
+		t.Helper()
+
+		bucket := "lithium-test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+		if err := client.MakeBucket(t.Context(), bucket, minio.MakeBucketOptions{}); err != nil {
+			t.Fatalf("create bucket: %v", err)
+		}
+		t.Cleanup(func() {
+			ctx := context.Background()
+			for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true}) {
+				_ = client.RemoveObject(ctx, bucket, object.Key, minio.RemoveObjectOptions{})
+			}
+			_ = client.RemoveBucket(ctx, bucket)
+		})
+
+		s, err := storage.NewMinio(cfg)
+		if err != nil {
+			t.Fatalf("create storage: %v", err)
+		}
+		return s, bucket
+	})
+}
@@ -1,59 +1,45 @@
 package storage
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"time"
+)
 
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+var (
+	ErrNotFound    = errors.New("object not found")
+	ErrTooLarge    = errors.New("object too large")
+	ErrInvalidPath = errors.New("invalid object path")
 )
 
+// UnknownSize can be used as upload size when it's not known in advance.
+const UnknownSize = -1
+
 type Storage interface {
-	Upload(ctx context.Context, bucket, path string, data []byte) error
-	Download(ctx context.Context, bucket, path string) ([]byte, error)
+	Upload(ctx context.Context, bucket, path string, reader io.Reader, size int64, contentType string) error
+	Download(ctx context.Context, bucket, path string) (io.ReadCloser, ObjectInfo, error)
+	Stat(ctx context.Context, bucket, path string) (ObjectInfo, bool, error)
+	List(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error)
 	Delete(ctx context.Context, bucket, path string) error
 }
 
-type storage struct {
-	client *minio.Client
+type ObjectInfo struct {
+	Path        string
+	Size        int64
+	ContentType string
+	ModifiedAt  time.Time
 }
 
+// NewStorage creates storage using configured driver.
 func NewStorage(cfg Config) (Storage, error) {
-	client, err := minio.New(cfg.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.ID, cfg.Secret, ""),
-		Secure: cfg.Secure,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("create minio: %w", err)
+	switch cfg.Driver {
+	case DriverMinio:
+		return NewMinio(cfg.Minio)
+	case DriverFilesystem:
+		return NewFilesystem(cfg.Filesystem)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %q", cfg.Driver)
 	}
-
-	return &storage{
-		client: client,
-	}, nil
-}
-
-func (s *storage) Upload(ctx context.Context, bucket, path string, data []byte) error {
-	_, err := s.client.PutObject(ctx, bucket, path, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{})
-	return err
-}
-
-func (s *storage) Download(ctx context.Context, bucket, path string) ([]byte, error) {
-	obj, err := s.client.GetObject(ctx, bucket, path, minio.GetObjectOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("get object: %w", err)
-	}
-	defer func() { _ = obj.Close() }()
-
-	data, err := io.ReadAll(obj)
-	if err != nil {
-		return nil, fmt.Errorf("read object: %w", err)
-	}
-
-	return data, nil
-}
-
-func (s *storage) Delete(ctx context.Context, bucket, path string) error {
-	return s.client.RemoveObject(ctx, bucket, path, minio.RemoveObjectOptions{})
 }
@@ -0,0 +1,265 @@
+// Package storagetest provides conformance tests that every storage.Storage implementation must pass.
+package storagetest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mymmrac/lithium/pkg/module/storage"
+)
+
+// Factory creates empty storage and returns it together with an existing bucket name
+type Factory func(t *testing.T) (storage.Storage, string)
+
+// Run runs conformance suite against storage created by factory, each test gets its own storage
+func Run(t *testing.T, factory Factory) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s storage.Storage, bucket string)
+	}{
+		{name: "upload_download", run: testUploadDownload},
+		{name: "upload_unknown_size", run: testUploadUnknownSize},
+		{name: "upload_overwrite", run: testUploadOverwrite},
+		{name: "upload_too_large", run: testUploadTooLarge},
+		{name: "download_missing", run: testDownloadMissing},
+		{name: "stat", run: testStat},
+		{name: "list", run: testList},
+		{name: "delete", run: testDelete},
+		{name: "read_all", run: testReadAll},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, bucket := factory(t)
+			tt.run(t, s, bucket)
+		})
+	}
+}
+
+func testUploadDownload(t *testing.T, s storage.Storage, bucket string) {
+	data := []byte("hello world")
+	upload(t, s, bucket, "a/b/module.wasm", data)
+
+	reader, info, err := s.Download(t.Context(), bucket, "a/b/module.wasm")
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("unexpected data: %q", got)
+	}
+	if info.Path != "a/b/module.wasm" {
+		t.Errorf("unexpected path: %q", info.Path)
+	}
+	if info.Size != int64(len(data)) {
+		t.Errorf("unexpected size: %d", info.Size)
+	}
+	if info.ContentType != "application/wasm" {
+		t.Errorf("unexpected content type: %q", info.ContentType)
+	}
+	if info.ModifiedAt.IsZero() {
+		t.Error("expected modification time")
+	}
+}
+
+func testUploadUnknownSize(t *testing.T, s storage.Storage, bucket string) {
+	data := bytes.Repeat([]byte("data"), 1024)
+	err := s.Upload(t.Context(), bucket, "unknown.wasm", bytes.NewReader(data), storage.UnknownSize, "application/wasm")
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+
+	if got := readAll(t, s, bucket, "unknown.wasm"); !bytes.Equal(got, data) {
+		t.Fatalf("unexpected data length: %d", len(got))
+	}
+}
+
+func testUploadOverwrite(t *testing.T, s storage.Storage, bucket string) {
+	upload(t, s, bucket, "module.wasm", []byte("first version"))
+	upload(t, s, bucket, "module.wasm", []byte("second"))
+
+	if got := readAll(t, s, bucket, "module.wasm"); string(got) != "second" {
+		t.Fatalf("unexpected data: %q", got)
+	}
+}
+
+func testUploadTooLarge(t *testing.T, s storage.Storage, bucket string) {
+	reader := storage.LimitReader(bytes.NewReader(make([]byte, 1024)), 512)
+	err := s.Upload(t.Context(), bucket, "large.wasm", reader, storage.UnknownSize, "application/wasm")
+	if !errors.Is(err, storage.ErrTooLarge) {
+		t.Fatalf("expected too large error, got: %v", err)
+	}
+
+	// Failed upload must not leave partial object behind
+	if _, found, err := s.Stat(t.Context(), bucket, "large.wasm"); err != nil || found {
+		t.Fatalf("expected no object, found: %t, error: %v", found, err)
+	}
+	if objects := list(t, s, bucket, ""); len(objects) != 0 {
+		t.Fatalf("expected no objects, got: %v", objects)
+	}
+}
+
+func testDownloadMissing(t *testing.T, s storage.Storage, bucket string) {
+	_, _, err := s.Download(t.Context(), bucket, "missing.wasm")
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+}
+
+func testStat(t *testing.T, s storage.Storage, bucket string) {
+	upload(t, s, bucket, "dir/module.wasm", []byte("module"))
+
+	info, found, err := s.Stat(t.Context(), bucket, "dir/module.wasm")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if !found {
+		t.Fatal("expected object to be found")
+	}
+	if info.Path != "dir/module.wasm" || info.Size != 6 {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	for _, path := range []string{"missing.wasm", "dir/missing.wasm"} {
+		_, found, err = s.Stat(t.Context(), bucket, path)
+		if err != nil {
+			t.Fatalf("stat %q: %v", path, err)
+		}
+		if found {
+			t.Errorf("expected %q to be missing", path)
+		}
+	}
+}
+
+func testList(t *testing.T, s storage.Storage, bucket string) {
+	if objects := list(t, s, bucket, ""); len(objects) != 0 {
+		t.Fatalf("expected empty bucket, got: %v", objects)
+	}
+
+	for _, path := range []string{"1/2/a.wasm", "1/2/b.wasm", "1/3/c.wasm", "1/22/d.wasm", "2/e.wasm"} {
+		upload(t, s, bucket, path, []byte(path))
+	}
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{prefix: "", expected: []string{"1/2/a.wasm", "1/2/b.wasm", "1/22/d.wasm", "1/3/c.wasm", "2/e.wasm"}},
+		{prefix: "1/", expected: []string{"1/2/a.wasm", "1/2/b.wasm", "1/22/d.wasm", "1/3/c.wasm"}},
+		{prefix: "1/2/", expected: []string{"1/2/a.wasm", "1/2/b.wasm"}},
+		{prefix: "1/2", expected: []string{"1/2/a.wasm", "1/2/b.wasm", "1/22/d.wasm"}},
+		{prefix: "1/2/a", expected: []string{"1/2/a.wasm"}},
+		{prefix: "3/", expected: nil},
+	}
+	for _, tt := range tests {
+		if got := list(t, s, bucket, tt.prefix); !slices.Equal(got, tt.expected) {
+			t.Errorf("list %q: expected %v, got %v", tt.prefix, tt.expected, got)
+		}
+	}
+
+	objects, err := s.List(t.Context(), bucket, "2/")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Size != int64(len("2/e.wasm")) {
+		t.Errorf("unexpected objects: %+v", objects)
+	}
+}
+
+func testDelete(t *testing.T, s storage.Storage, bucket string) {
+	upload(t, s, bucket, "dir/module.wasm", []byte("module"))
+
+	if err := s.Delete(t.Context(), bucket, "dir/module.wasm"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, found, err := s.Stat(t.Context(), bucket, "dir/module.wasm"); err != nil || found {
+		t.Fatalf("expected deleted object, found: %t, error: %v", found, err)
+	}
+
+	// Deleting missing object is not an error
+	if err := s.Delete(t.Context(), bucket, "dir/module.wasm"); err != nil {
+		t.Fatalf("delete missing: %v", err)
+	}
+}
+
+func testReadAll(t *testing.T, s storage.Storage, bucket string) {
+	upload(t, s, bucket, "module.wasm", make([]byte, 1024))
+
+	data, err := storage.ReadAll(t.Context(), s, bucket, "module.wasm", 1024)
+	if err != nil {
+		t.Fatalf("read all: %v", err)
+	}
+	if len(data) != 1024 {
+		t.Fatalf("unexpected length: %d", len(data))
+	}
+
+	if _, err = storage.ReadAll(t.Context(), s, bucket, "module.wasm", 1023); !errors.Is(err, storage.ErrTooLarge) {
+		t.Fatalf("expected too large error, got: %v", err)
+	}
+	if _, err = storage.ReadAll(t.Context(), s, bucket, "missing.wasm", 1024); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+}
+
+func upload(t *testing.T, s storage.Storage, bucket, path string, data []byte) {
+	t.Helper()
+
+	err := s.Upload(t.Context(), bucket, path, bytes.NewReader(data), int64(len(data)), contentType(path))
+	if err != nil {
+		t.Fatalf("upload %q: %v", path, err)
+	}
+}
+
+func readAll(t *testing.T, s storage.Storage, bucket, path string) []byte {
+	t.Helper()
+
+	reader, _, err := s.Download(t.Context(), bucket, path)
+	if err != nil {
+		t.Fatalf("download %q: %v", path, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read %q: %v", path, err)
+	}
+	return data
+}
+
+func list(t *testing.T, s storage.Storage, bucket, prefix string) []string {
+	t.Helper()
+
+	objects, err := s.List(t.Context(), bucket, prefix)
+	if err != nil {
+		t.Fatalf("list %q: %v", prefix, err)
+	}
+
+	paths := make([]string, 0, len(objects))
+	for _, object := range objects {
+		paths = append(paths, object.Path)
+	}
+	slices.Sort(paths)
+
+	if len(paths) == 0 {
+		return nil
+	}
+	return paths
+}
+
+func contentType(path string) string {
+	if strings.HasSuffix(path, ".wasm") {
+		return "application/wasm"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,81 @@
+package team
+
+import (
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+const (
+	// InvitationTTL is how long invitation can be accepted
+	InvitationTTL = 7 * 24 * time.Hour
+	// PersonalName is the name of team created for user without any team
+	PersonalName = "Personal"
+)
+
+// Role of the team member, each role includes previous ones.
+type Role string
+
+// Roles
+const (
+	// RoleViewer can see projects, actions and deploys, but not secrets
+	RoleViewer Role = "viewer"
+	// RoleDeveloper can manage actions, read secrets and deploy modules
+	RoleDeveloper Role = "developer"
+	// RoleAdmin can manage projects and team members below admin
+	RoleAdmin Role = "admin"
+	// RoleOwner can do anything with the team
+	RoleOwner Role = "owner"
+)
+
+// Includes reports whether role grants everything granted by another role.
+func (r Role) Includes(required Role) bool {
+	order := []Role{RoleViewer, RoleDeveloper, RoleAdmin, RoleOwner}
+	granted, needed := slices.Index(order, r), slices.Index(order, required)
+	return granted != -1 && needed != -1 && granted >= needed
+}
+
+type Model struct {
+	bun.BaseModel `bun:"table:team"`
+
+	ID        id.ID     `bun:"id,pk"`
+	Name      string    `bun:"name"`
+	Plan      string    `bun:"plan"` // Quota plan, default plan is used if empty
+	CreatedAt time.Time `bun:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at"`
+}
+
+type Member struct {
+	bun.BaseModel `bun:"table:team_member"`
+
+	TeamID    id.ID     `bun:"team_id,pk"`
+	UserID    id.ID     `bun:"user_id,pk"`
+	Role      Role      `bun:"role"`
+	CreatedAt time.Time `bun:"created_at"`
+
+	Team *Model      `bun:"rel:belongs-to,join:team_id=id"`
+	User *user.Model `bun:"rel:belongs-to,join:user_id=id"`
+}
+
+type Invitation struct {
+	bun.BaseModel `bun:"table:team_invitation"`
+
+	ID        id.ID     `bun:"id,pk"`
+	TeamID    id.ID     `bun:"team_id"`
+	Email     string    `bun:"email"`
+	Role      Role      `bun:"role"`
+	InvitedBy id.ID     `bun:"invited_by"`
+	ExpiresAt time.Time `bun:"expires_at"`
+	CreatedAt time.Time `bun:"created_at"`
+
+	Team *Model `bun:"rel:belongs-to,join:team_id=id"`
+}
+
+// Expired reports whether invitation can no longer be accepted.
+func (i *Invitation) Expired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,312 @@
+package team
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type Repository interface {
+	Create(ctx context.Context, model *Model) error
+	UpdateName(ctx context.Context, id id.ID, name string) error
+	UpdatePlan(ctx context.Context, id id.ID, plan string) error
+	GetByID(ctx context.Context, id id.ID) (*Model, bool, error)
+	Lock(ctx context.Context, id id.ID) error
+	GetAll(ctx context.Context) ([]Model, error)
+	DeleteByID(ctx context.Context, id id.ID) error
+
+	CreateMember(ctx context.Context, member *Member) error
+	UpdateMemberRole(ctx context.Context, teamID, userID id.ID, role Role) error
+	GetMember(ctx context.Context, teamID, userID id.ID) (*Member, bool, error)
+	GetMembersByTeamID(ctx context.Context, teamID id.ID) ([]Member, error)
+	GetMembersByUserID(ctx context.Context, userID id.ID) ([]Member, error)
+	CountMembersByRole(ctx context.Context, teamID id.ID, role Role) (int, error)
+	DeleteMember(ctx context.Context, teamID, userID id.ID) error
+	DeleteMembersByTeamID(ctx context.Context, teamID id.ID) error
+
+	UpsertInvitation(ctx context.Context, invitation *Invitation) error
+	GetInvitationByID(ctx context.Context, id id.ID) (*Invitation, bool, error)
+	GetInvitationsByTeamID(ctx context.Context, teamID id.ID) ([]Invitation, error)
+	GetInvitationsByEmail(ctx context.Context, email string) ([]Invitation, error)
+	DeleteInvitationByID(ctx context.Context, id id.ID) error
+	DeleteInvitationsByTeamID(ctx context.Context, teamID id.ID) error
+}
+
+type repository struct {
+	tx db.Transaction
+}
+
+func NewRepository(tx db.Transaction) Repository {
+	return &repository{
+		tx: tx,
+	}
+}
+
+func (r *repository) Create(ctx context.Context, model *Model) error {
+	_, err := r.tx.Extract(ctx).NewInsert().Model(model).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) UpdateName(ctx context.Context, id id.ID, name string) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("name = ?", name).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) UpdatePlan(ctx context.Context, id id.ID, plan string) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("plan = ?", plan).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Lock locks team row until the end of transaction, so transactions that lock the same team run one after another.
+func (r *repository) Lock(ctx context.Context, id id.ID) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("updated_at = updated_at").
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetByID(ctx context.Context, id id.ID) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+func (r *repository) GetAll(ctx context.Context) ([]Model, error) {
+	var models []Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&models).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+func (r *repository) DeleteByID(ctx context.Context, id id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) CreateMember(ctx context.Context, member *Member) error {
+	_, err := r.tx.Extract(ctx).NewInsert().Model(member).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) UpdateMemberRole(ctx context.Context, teamID, userID id.ID, role Role) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Member)(nil)).
+		Set("role = ?", role).
+		Where("team_id = ?", teamID).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetMember(ctx context.Context, teamID, userID id.ID) (*Member, bool, error) {
+	var member Member
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&member).
+		Where("team_id = ?", teamID).
+		Where("user_id = ?", userID).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &member, true, nil
+}
+
+// GetMembersByTeamID returns members of the team with their users.
+func (r *repository) GetMembersByTeamID(ctx context.Context, teamID id.ID) ([]Member, error) {
+	var members []Member
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&members).
+		Relation("User").
+		Where("?TableAlias.team_id = ?", teamID).
+		OrderExpr("?TableAlias.created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// GetMembersByUserID returns memberships of the user with their teams.
+func (r *repository) GetMembersByUserID(ctx context.Context, userID id.ID) ([]Member, error) {
+	var members []Member
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&members).
+		Relation("Team").
+		Where("?TableAlias.user_id = ?", userID).
+		OrderExpr("?TableAlias.created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *repository) CountMembersByRole(ctx context.Context, teamID id.ID, role Role) (int, error) {
+	count, err := r.tx.Extract(ctx).NewSelect().
+		Model((*Member)(nil)).
+		Where("team_id = ?", teamID).
+		Where("role = ?", role).
+		Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *repository) DeleteMember(ctx context.Context, teamID, userID id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Member)(nil)).
+		Where("team_id = ?", teamID).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteMembersByTeamID(ctx context.Context, teamID id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Member)(nil)).
+		Where("team_id = ?", teamID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpsertInvitation creates invitation or renews existing invitation of the same email to the team.
+func (r *repository) UpsertInvitation(ctx context.Context, invitation *Invitation) error {
+	_, err := r.tx.Extract(ctx).NewInsert().
+		Model(invitation).
+		On("CONFLICT (team_id, email) DO UPDATE").
+		Set("role = EXCLUDED.role").
+		Set("invited_by = EXCLUDED.invited_by").
+		Set("expires_at = EXCLUDED.expires_at").
+		Set("created_at = EXCLUDED.created_at").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetInvitationByID(ctx context.Context, id id.ID) (*Invitation, bool, error) {
+	var invitation Invitation
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&invitation).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &invitation, true, nil
+}
+
+func (r *repository) GetInvitationsByTeamID(ctx context.Context, teamID id.ID) ([]Invitation, error) {
+	var invitations []Invitation
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&invitations).
+		Where("team_id = ?", teamID).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// GetInvitationsByEmail returns invitations sent to the email with their teams, email is expected in lower case.
+func (r *repository) GetInvitationsByEmail(ctx context.Context, email string) ([]Invitation, error) {
+	var invitations []Invitation
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&invitations).
+		Relation("Team").
+		Where("?TableAlias.email = ?", email).
+		OrderExpr("?TableAlias.created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func (r *repository) DeleteInvitationByID(ctx context.Context, id id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Invitation)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteInvitationsByTeamID(ctx context.Context, teamID id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Invitation)(nil)).
+		Where("team_id = ?", teamID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
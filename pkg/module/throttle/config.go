@@ -0,0 +1,30 @@
+package throttle
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+
+	"github.com/mymmrac/lithium/pkg/module/di"
+)
+
+const (
+	StoreDatabase = "database"
+	StoreMemory   = "memory"
+)
+
+type Config struct {
+	// Store keeps failed attempts, database is shared by all servers, memory is per server
+	Store string `validate:"oneof=database memory"`
+}
+
+func init() { //nolint:gochecknoinits
+	di.Base().MustProvide(func(v *viper.Viper, va *validator.Validate) (Config, error) {
+		cfg := Config{
+			Store: v.GetString("throttle-store"),
+		}
+		if err := va.Struct(cfg); err != nil {
+			return Config{}, err
+		}
+		return cfg, nil
+	})
+}
@@ -0,0 +1,137 @@
+// Package throttle limits repeated failed attempts, for example, password guessing, with exponential backoff and
+// temporary lockout.
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/cache"
+	"github.com/mymmrac/lithium/pkg/module/db"
+)
+
+const (
+	// MaxLockout is the longest lockout, state of failed attempts is not kept for longer
+	MaxLockout  = 24 * time.Hour
+	backoffBase = time.Second
+	maxShift    = 30
+)
+
+type State struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+}
+
+// Cache stores state of throttles, it must update state atomically, so concurrent attempts are all counted.
+type Cache cache.Updater[string, State]
+
+// NewCache returns cache of configured store, state in memory is not shared between servers, so each server allows
+// the same number of attempts.
+func NewCache(cfg Config, tx db.Transaction) Cache {
+	if cfg.Store == StoreMemory {
+		return cache.NewInMemoryTTL[string, State](MaxLockout)
+	}
+	return cache.NewDatabase[State](tx, "throttle", MaxLockout)
+}
+
+type Policy struct {
+	// FreeAttempts is number of failures that don't delay next attempt, after that delay doubles with each failure
+	FreeAttempts int
+	// MaxAttempts is number of failures after which next attempts are locked out
+	MaxAttempts int
+	// Lockout is how long attempts are locked out, failures are forgotten after that
+	Lockout time.Duration
+}
+
+// Delay returns how long next attempt is delayed after failures in a row.
+func (p Policy) Delay(failures int) time.Duration {
+	switch {
+	case failures >= p.MaxAttempts:
+		return p.Lockout
+	case failures <= p.FreeAttempts:
+		return 0
+	}
+
+	shift := failures - p.FreeAttempts - 1
+	if shift >= maxShift {
+		return p.Lockout
+	}
+	return min(backoffBase<<shift, p.Lockout)
+}
+
+type Throttle interface {
+	// Attempt counts attempt as failed in advance, so concurrent attempts can't exceed the limit, and returns zero.
+	// If attempts are throttled, attempt is not counted and it returns how long to wait before next attempt.
+	Attempt(ctx context.Context, key string) (time.Duration, error)
+	// Refund forgets attempt that didn't fail.
+	Refund(ctx context.Context, key string) error
+	// Reset forgets failed attempts.
+	Reset(ctx context.Context, key string) error
+}
+
+type throttle struct {
+	cache  Cache
+	prefix string
+	policy Policy
+}
+
+// New returns throttle that stores state in cache, prefix separates keys of different throttles.
+func New(cache Cache, prefix string, policy Policy) Throttle {
+	return &throttle{
+		cache:  cache,
+		prefix: prefix + ":",
+		policy: policy,
+	}
+}
+
+func (t *throttle) Attempt(ctx context.Context, key string) (time.Duration, error) {
+	var wait time.Duration
+	_, err := t.cache.Update(ctx, t.prefix+key, func(state State, ok bool) State {
+		now := time.Now()
+		state = t.current(state, ok, now)
+
+		wait = 0
+		if state.Failures > 0 {
+			wait = max(state.LastFailure.Add(t.policy.Delay(state.Failures)).Sub(now), 0)
+		}
+		if wait > 0 {
+			return state
+		}
+
+		state.Failures++
+		state.LastFailure = now
+		return state
+	})
+	if err != nil {
+		return 0, fmt.Errorf("update state: %w", err)
+	}
+	return wait, nil
+}
+
+func (t *throttle) Refund(ctx context.Context, key string) error {
+	_, err := t.cache.Update(ctx, t.prefix+key, func(state State, ok bool) State {
+		state = t.current(state, ok, time.Now())
+		state.Failures = max(state.Failures-1, 0)
+		return state
+	})
+	if err != nil {
+		return fmt.Errorf("update state: %w", err)
+	}
+	return nil
+}
+
+func (t *throttle) Reset(ctx context.Context, key string) error {
+	if err := t.cache.Remove(ctx, t.prefix+key); err != nil {
+		return fmt.Errorf("remove state: %w", err)
+	}
+	return nil
+}
+
+// current returns failed attempts in a row, failures that happened more than lockout ago are forgotten.
+func (t *throttle) current(state State, ok bool, now time.Time) State {
+	if !ok || now.Sub(state.LastFailure) >= t.policy.Lockout {
+		return State{}
+	}
+	return state
+}
@@ -0,0 +1,96 @@
+package throttle_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/cache"
+	"github.com/mymmrac/lithium/pkg/module/throttle"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := throttle.Policy{FreeAttempts: 3, MaxAttempts: 10, Lockout: time.Minute}
+
+	tests := []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{failures: 0, delay: 0},
+		{failures: 3, delay: 0},
+		{failures: 4, delay: time.Second},
+		{failures: 5, delay: 2 * time.Second},
+		{failures: 9, delay: 32 * time.Second},
+		{failures: 10, delay: time.Minute},
+		{failures: 100, delay: time.Minute},
+	}
+
+	for _, tt := range tests {
+		if delay := policy.Delay(tt.failures); delay != tt.delay {
+			t.Errorf("unexpected delay after %d failures: %s, expected: %s", tt.failures, delay, tt.delay)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	cache := cache.NewInMemoryTTL[string, throttle.State](throttle.MaxLockout)
+	first := throttle.New(cache, "first", throttle.Policy{FreeAttempts: 1, MaxAttempts: 2, Lockout: time.Minute})
+	second := throttle.New(cache, "second", throttle.Policy{FreeAttempts: 1, MaxAttempts: 2, Lockout: time.Minute})
+
+	assertAttempt := func(th throttle.Throttle, key string, locked bool) {
+		t.Helper()
+
+		wait, err := th.Attempt(t.Context(), key)
+		if err != nil {
+			t.Fatalf("attempt: %v", err)
+		}
+		if locked != (wait > 0) {
+			t.Errorf("unexpected wait for %q: %s", key, wait)
+		}
+	}
+
+	assertAttempt(first, "key", false)
+	assertAttempt(first, "key", false)
+	assertAttempt(first, "key", true)
+	assertAttempt(first, "other", false)
+	assertAttempt(second, "key", false)
+
+	// Refunded attempt is not counted
+	if err := first.Refund(t.Context(), "other"); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	assertAttempt(first, "other", false)
+	assertAttempt(first, "other", false)
+
+	if err := first.Reset(t.Context(), "key"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	assertAttempt(first, "key", false)
+}
+
+func TestThrottleConcurrent(t *testing.T) {
+	const maxAttempts = 5
+
+	th := throttle.New(cache.NewInMemoryTTL[string, throttle.State](throttle.MaxLockout), "test",
+		throttle.Policy{FreeAttempts: maxAttempts, MaxAttempts: maxAttempts, Lockout: time.Minute})
+
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			wait, err := th.Attempt(t.Context(), "key")
+			if err != nil {
+				t.Errorf("attempt: %v", err)
+			}
+			if wait == 0 {
+				allowed.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+
+	if allowed.Load() != maxAttempts {
+		t.Errorf("unexpected allowed attempts: %d", allowed.Load())
+	}
+}
@@ -0,0 +1,62 @@
+package token
+
+import (
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+// Kind of the token.
+type Kind string
+
+// Kinds
+const (
+	// KindPersonal acts on behalf of the user, optionally limited to one project
+	KindPersonal Kind = "personal"
+	// KindService is meant for automation (CI deploys), always limited to one project
+	KindService Kind = "service"
+)
+
+// Scope limits what token can do, each scope includes previous ones.
+type Scope string
+
+// Scopes
+const (
+	// ScopeRead allows reading projects, actions and deploys
+	ScopeRead Scope = "read"
+	// ScopeDeploy allows uploading modules
+	ScopeDeploy Scope = "deploy"
+	// ScopeAdmin allows any change to projects and actions
+	ScopeAdmin Scope = "admin"
+)
+
+// Includes reports whether scope grants access required by another scope.
+func (s Scope) Includes(required Scope) bool {
+	order := []Scope{ScopeRead, ScopeDeploy, ScopeAdmin}
+	granted, needed := slices.Index(order, s), slices.Index(order, required)
+	return granted != -1 && needed != -1 && granted >= needed
+}
+
+type Model struct {
+	bun.BaseModel `bun:"table:api_token"`
+
+	ID         id.ID     `bun:"id,pk"`
+	UserID     id.ID     `bun:"user_id"`
+	ProjectID  id.ID     `bun:"project_id,nullzero"`
+	Kind       Kind      `bun:"kind"`
+	Name       string    `bun:"name"`
+	Prefix     string    `bun:"prefix"`
+	Hash       string    `bun:"hash"`
+	Scopes     []Scope   `bun:"scopes,type:jsonb"`
+	ExpiresAt  time.Time `bun:"expires_at,nullzero"`
+	LastUsedAt time.Time `bun:"last_used_at,nullzero"`
+	CreatedAt  time.Time `bun:"created_at"`
+}
+
+// Expired reports whether token has expiry in the past.
+func (m *Model) Expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
+}
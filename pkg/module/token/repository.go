@@ -0,0 +1,100 @@
+package token
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type Repository interface {
+	Create(ctx context.Context, model *Model) error
+	GetByHash(ctx context.Context, hash string) (*Model, bool, error)
+	GetByUserID(ctx context.Context, userID id.ID) ([]Model, error)
+	UpdateLastUsed(ctx context.Context, id id.ID, lastUsedAt time.Time) error
+	DeleteByID(ctx context.Context, id id.ID) error
+	DeleteByProjectID(ctx context.Context, projectID id.ID) error
+}
+
+type repository struct {
+	tx db.Transaction
+}
+
+func NewRepository(tx db.Transaction) Repository {
+	return &repository{
+		tx: tx,
+	}
+}
+
+func (r *repository) Create(ctx context.Context, model *Model) error {
+	_, err := r.tx.Extract(ctx).NewInsert().Model(model).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetByHash(ctx context.Context, hash string) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("hash = ?", hash).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+func (r *repository) GetByUserID(ctx context.Context, userID id.ID) ([]Model, error) {
+	var models []Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&models).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+func (r *repository) UpdateLastUsed(ctx context.Context, id id.ID, lastUsedAt time.Time) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("last_used_at = ?", lastUsedAt).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteByID(ctx context.Context, id id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteByProjectID(ctx context.Context, projectID id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("project_id = ?", projectID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
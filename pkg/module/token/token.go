@@ -0,0 +1,32 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// tokenPrefix makes tokens recognizable, for example, by secret scanners
+	tokenPrefix = "lit_"
+	// displayPrefixLen is length of token beginning that is stored in plain text to help identify tokens
+	displayPrefixLen = len(tokenPrefix) + 6
+)
+
+// Generate returns new random token, its display prefix and hash, only hash and prefix should be stored.
+func Generate() (value, prefix, hash string) {
+	value = tokenPrefix + strings.ToLower(rand.Text()+rand.Text())
+	return value, value[:displayPrefixLen], Hash(value)
+}
+
+// Hash returns token hash used for lookup, tokens have enough entropy, so slow hashing is not needed.
+func Hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// LooksLikeToken reports whether value has API token format.
+func LooksLikeToken(value string) bool {
+	return strings.HasPrefix(value, tokenPrefix) && len(value) > displayPrefixLen
+}
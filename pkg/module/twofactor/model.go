@@ -0,0 +1,35 @@
+package twofactor
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type Model struct {
+	bun.BaseModel `bun:"table:two_factor"`
+
+	UserID id.ID  `bun:"user_id,pk"`
+	Secret string `bun:"secret"`
+	// EnabledAt is zero while setup is not confirmed with valid code
+	EnabledAt time.Time `bun:"enabled_at,nullzero"`
+	// LastStep is time step of the last accepted code
+	LastStep  int64     `bun:"last_step"`
+	CreatedAt time.Time `bun:"created_at"`
+}
+
+// Enabled reports whether second factor is required to log in.
+func (m *Model) Enabled() bool {
+	return !m.EnabledAt.IsZero()
+}
+
+type RecoveryCode struct {
+	bun.BaseModel `bun:"table:recovery_code"`
+
+	ID        id.ID     `bun:"id,pk"`
+	UserID    id.ID     `bun:"user_id"`
+	Hash      string    `bun:"hash"`
+	CreatedAt time.Time `bun:"created_at"`
+}
@@ -0,0 +1,29 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// RecoveryCodeCount is number of recovery codes generated at once
+const RecoveryCodeCount = 10
+
+// GenerateRecoveryCodes returns new recovery codes and their hashes, only hashes should be stored.
+func GenerateRecoveryCodes() (values, hashes []string) {
+	values = make([]string, RecoveryCodeCount)
+	hashes = make([]string, RecoveryCodeCount)
+	for i := range RecoveryCodeCount {
+		text := strings.ToLower(rand.Text())
+		values[i] = text[:5] + "-" + text[5:10]
+		hashes[i] = HashRecoveryCode(values[i])
+	}
+	return values, hashes
+}
+
+// HashRecoveryCode returns recovery code hash used for lookup, code is normalized, so it's not case-sensitive.
+func HashRecoveryCode(value string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return hex.EncodeToString(sum[:])
+}
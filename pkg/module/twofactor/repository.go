@@ -0,0 +1,163 @@
+package twofactor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type Repository interface {
+	Upsert(ctx context.Context, model *Model) error
+	GetByUserID(ctx context.Context, userID id.ID) (*Model, bool, error)
+	Enable(ctx context.Context, userID id.ID, step int64, enabledAt time.Time) (bool, error)
+	UpdateLastStep(ctx context.Context, userID id.ID, step int64) (bool, error)
+	DeleteByUserID(ctx context.Context, userID id.ID) error
+
+	CreateRecoveryCodes(ctx context.Context, models []RecoveryCode) error
+	CountRecoveryCodes(ctx context.Context, userID id.ID) (int, error)
+	ConsumeRecoveryCode(ctx context.Context, userID id.ID, hash string) (bool, error)
+	DeleteRecoveryCodesByUserID(ctx context.Context, userID id.ID) error
+}
+
+type repository struct {
+	tx db.Transaction
+}
+
+func NewRepository(tx db.Transaction) Repository {
+	return &repository{
+		tx: tx,
+	}
+}
+
+// Upsert creates or replaces second factor of user.
+func (r *repository) Upsert(ctx context.Context, model *Model) error {
+	_, err := r.tx.Extract(ctx).NewInsert().
+		Model(model).
+		On("CONFLICT (user_id) DO UPDATE").
+		Set("secret = EXCLUDED.secret").
+		Set("enabled_at = EXCLUDED.enabled_at").
+		Set("last_step = EXCLUDED.last_step").
+		Set("created_at = EXCLUDED.created_at").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetByUserID(ctx context.Context, userID id.ID) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("user_id = ?", userID).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+// Enable confirms setup of second factor, reports false if it was already enabled.
+func (r *repository) Enable(ctx context.Context, userID id.ID, step int64, enabledAt time.Time) (bool, error) {
+	result, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("enabled_at = ?", enabledAt).
+		Set("last_step = ?", step).
+		Where("user_id = ?", userID).
+		Where("enabled_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// UpdateLastStep marks code of time step as used, reports false if the same or later code was already used.
+func (r *repository) UpdateLastStep(ctx context.Context, userID id.ID, step int64) (bool, error) {
+	result, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("last_step = ?", step).
+		Where("user_id = ?", userID).
+		Where("last_step < ?", step).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *repository) DeleteByUserID(ctx context.Context, userID id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) CreateRecoveryCodes(ctx context.Context, models []RecoveryCode) error {
+	_, err := r.tx.Extract(ctx).NewInsert().Model(&models).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) CountRecoveryCodes(ctx context.Context, userID id.ID) (int, error) {
+	count, err := r.tx.Extract(ctx).NewSelect().
+		Model((*RecoveryCode)(nil)).
+		Where("user_id = ?", userID).
+		Count(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ConsumeRecoveryCode deletes recovery code, reports false if code doesn't exist or was already used.
+func (r *repository) ConsumeRecoveryCode(ctx context.Context, userID id.ID, hash string) (bool, error) {
+	result, err := r.tx.Extract(ctx).NewDelete().
+		Model((*RecoveryCode)(nil)).
+		Where("user_id = ?", userID).
+		Where("hash = ?", hash).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *repository) DeleteRecoveryCodesByUserID(ctx context.Context, userID id.ID) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*RecoveryCode)(nil)).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Package twofactor implements second authentication factor with time-based one-time passwords (RFC 6238) and
+// single-use recovery codes.
+package twofactor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	period     = 30
+	digits     = 6
+	secretSize = 20
+	// skew is number of periods before and after current one, codes of which are accepted to tolerate clock drift
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding) //nolint:gochecknoglobals
+
+// GenerateSecret returns new random base32 encoded secret.
+func GenerateSecret() string {
+	secret := make([]byte, secretSize)
+	_, _ = rand.Read(secret)
+	return encoding.EncodeToString(secret)
+}
+
+// ProvisioningURI returns URI that authenticator apps use to add account, usually shown as QR code.
+func ProvisioningURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(digits))
+	query.Set("period", fmt.Sprint(period))
+
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// Step returns time step of time.
+func Step(now time.Time) int64 {
+	return now.Unix() / period
+}
+
+// Code returns code for time step.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("decode secret: %w", err)
+	}
+
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(step)) //nolint:gosec
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", digits, value%1_000_000), nil
+}
+
+// Validate checks code against time steps around now and returns matched step, steps not after last used one are
+// rejected, so the same code can't be used twice.
+func Validate(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != digits {
+		return 0, false
+	}
+
+	current := Step(now)
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,61 @@
+package twofactor_test
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/twofactor"
+)
+
+func TestCode(t *testing.T) {
+	// Test vectors from RFC 6238 (SHA1), truncated to 6 digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 20000000000, code: "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := twofactor.Code(secret, twofactor.Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("code: %v", err)
+		}
+		if code != tt.code {
+			t.Errorf("unexpected code at %d: %s, expected: %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := twofactor.GenerateSecret()
+	now := time.Now()
+	current := twofactor.Step(now)
+
+	code, err := twofactor.Code(secret, current-1)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+
+	step, ok := twofactor.Validate(secret, code, now, 0)
+	if !ok || step != current-1 {
+		t.Fatalf("expected previous code to be valid, step: %d, ok: %t", step, ok)
+	}
+
+	if _, ok = twofactor.Validate(secret, code, now, step); ok {
+		t.Errorf("expected used code to be rejected")
+	}
+
+	code, err = twofactor.Code(secret, current-2)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	if _, ok = twofactor.Validate(secret, code, now, 0); ok {
+		t.Errorf("expected expired code to be rejected")
+	}
+}
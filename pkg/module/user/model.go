@@ -12,15 +12,27 @@ import (
 type Model struct {
 	bun.BaseModel `bun:"table:user"`
 
-	ID        id.ID     `bun:"id,pk"`
-	Email     string    `bun:"email"`
-	Password  string    `bun:"password"`
-	CreatedAt time.Time `bun:"created_at"`
-	UpdatedAt time.Time `bun:"updated_at"`
+	ID              id.ID     `bun:"id,pk"`
+	Email           string    `bun:"email"`
+	Password        string    `bun:"password"`
+	EmailVerifiedAt time.Time `bun:"email_verified_at,nullzero"`
+	CreatedAt       time.Time `bun:"created_at"`
+	UpdatedAt       time.Time `bun:"updated_at"`
+}
+
+// HasPassword reports whether user can log in with password, users created by identity provider have no password.
+func (m *Model) HasPassword() bool {
+	return m.Password != ""
+}
+
+// EmailVerified reports whether user confirmed ownership of email.
+func (m *Model) EmailVerified() bool {
+	return !m.EmailVerifiedAt.IsZero()
 }
 
 type Claims struct {
 	jwt.RegisteredClaims
 
-	UserID id.ID `json:"userId"`
+	UserID    id.ID `json:"userId"`
+	SessionID id.ID `json:"sessionId"`
 }
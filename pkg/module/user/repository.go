@@ -4,7 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"strings"
+	"time"
 
 	"github.com/mymmrac/lithium/pkg/module/db"
 	"github.com/mymmrac/lithium/pkg/module/id"
@@ -14,6 +14,9 @@ type Repository interface {
 	Create(ctx context.Context, model *Model) error
 	GetByID(ctx context.Context, id id.ID) (*Model, bool, error)
 	GetByEmail(ctx context.Context, email string) (*Model, bool, error)
+	GetAll(ctx context.Context) ([]Model, error)
+	UpdatePassword(ctx context.Context, id id.ID, password string) error
+	UpdateEmailVerified(ctx context.Context, id id.ID, verifiedAt time.Time) error
 }
 
 type repository struct {
@@ -31,7 +34,7 @@ var ErrAlreadyExists = errors.New("user already exists")
 func (r *repository) Create(ctx context.Context, model *Model) error {
 	_, err := r.tx.Extract(ctx).NewInsert().Model(model).Exec(ctx)
 	if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "duplicate key value violates unique constraint") {
+		if db.IsUniqueViolation(err) {
 			return ErrAlreadyExists
 		}
 		return err
@@ -60,3 +63,41 @@ func (r *repository) GetByEmail(ctx context.Context, email string) (*Model, bool
 	}
 	return &model, true, nil
 }
+
+func (r *repository) GetAll(ctx context.Context) ([]Model, error) {
+	var models []Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&models).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+func (r *repository) UpdatePassword(ctx context.Context, id id.ID, password string) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("password = ?", password).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) UpdateEmailVerified(ctx context.Context, id id.ID, verifiedAt time.Time) error {
+	_, err := r.tx.Extract(ctx).NewUpdate().
+		Model((*Model)(nil)).
+		Set("email_verified_at = ?", verifiedAt).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package user_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/db/dbtest"
+	"github.com/mymmrac/lithium/pkg/module/id"
+	"github.com/mymmrac/lithium/pkg/module/user"
+)
+
+func TestRepository(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T, tx db.Transaction) {
+		repository := user.NewRepository(tx)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		model := &user.Model{
+			ID:        id.New(),
+			Email:     "test@example.com",
+			Password:  "hash",
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := repository.Create(t.Context(), model); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		duplicate := *model
+		duplicate.ID = id.New()
+		if err := repository.Create(t.Context(), &duplicate); !errors.Is(err, user.ErrAlreadyExists) {
+			t.Fatalf("expected already exists error, got: %v", err)
+		}
+
+		got, found, err := repository.GetByID(t.Context(), model.ID)
+		if err != nil || !found {
+			t.Fatalf("get by id: found: %t, error: %v", found, err)
+		}
+		if got.Email != model.Email || got.Password != model.Password || !got.CreatedAt.Equal(now) {
+			t.Errorf("unexpected user: %+v", got)
+		}
+
+		got, found, err = repository.GetByEmail(t.Context(), model.Email)
+		if err != nil || !found {
+			t.Fatalf("get by email: found: %t, error: %v", found, err)
+		}
+		if got.ID != model.ID {
+			t.Errorf("unexpected user: %+v", got)
+		}
+
+		if _, found, err = repository.GetByEmail(t.Context(), "missing@example.com"); err != nil || found {
+			t.Fatalf("get missing: found: %t, error: %v", found, err)
+		}
+		if _, found, err = repository.GetByID(t.Context(), id.New()); err != nil || found {
+			t.Fatalf("get missing: found: %t, error: %v", found, err)
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 
+	"github.com/mymmrac/lithium/pkg/module/cors"
 	"github.com/mymmrac/lithium/pkg/module/di"
 )
 
@@ -28,5 +29,16 @@ func NewValidate() (*validator.Validate, error) {
 		return nil, fmt.Errorf("register alphanum_text validator: %w", err)
 	}
 
+	err = v.RegisterValidation("cors_origin", func(fl validator.FieldLevel) bool {
+		value, ok := fl.Field().Interface().(string)
+		if !ok {
+			return false
+		}
+		return cors.ValidOrigin(value)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("register cors_origin validator: %w", err)
+	}
+
 	return v, nil
 }
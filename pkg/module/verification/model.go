@@ -0,0 +1,50 @@
+package verification
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+// Purpose of the token, token can be used only for the purpose it was issued for.
+type Purpose string
+
+// Purposes
+const (
+	PurposeEmailVerification Purpose = "email_verification"
+	PurposePasswordReset     Purpose = "password_reset"
+	// PurposeTwoFactorLogin is issued after password check and allows to finish login with second factor
+	PurposeTwoFactorLogin Purpose = "two_factor_login"
+)
+
+// TTL returns how long token with purpose is valid.
+func (p Purpose) TTL() time.Duration {
+	switch p {
+	case PurposeEmailVerification:
+		return 48 * time.Hour
+	case PurposePasswordReset:
+		return time.Hour
+	case PurposeTwoFactorLogin:
+		return 10 * time.Minute
+	default:
+		return 0
+	}
+}
+
+type Model struct {
+	bun.BaseModel `bun:"table:verification_token"`
+
+	ID        id.ID     `bun:"id,pk"`
+	UserID    id.ID     `bun:"user_id"`
+	Purpose   Purpose   `bun:"purpose"`
+	Hash      string    `bun:"hash"`
+	ExpiresAt time.Time `bun:"expires_at"`
+	CreatedAt time.Time `bun:"created_at"`
+}
+
+// Expired reports whether token can't be used anymore.
+func (m *Model) Expired(now time.Time) bool {
+	return !now.Before(m.ExpiresAt)
+}
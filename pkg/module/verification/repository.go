@@ -0,0 +1,111 @@
+package verification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mymmrac/lithium/pkg/module/db"
+	"github.com/mymmrac/lithium/pkg/module/id"
+)
+
+type Repository interface {
+	Create(ctx context.Context, model *Model) error
+	GetByHash(ctx context.Context, purpose Purpose, hash string) (*Model, bool, error)
+	Consume(ctx context.Context, purpose Purpose, hash string, now time.Time) (*Model, bool, error)
+	DeleteByUserID(ctx context.Context, userID id.ID, purpose Purpose) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+type repository struct {
+	tx db.Transaction
+}
+
+func NewRepository(tx db.Transaction) Repository {
+	return &repository{
+		tx: tx,
+	}
+}
+
+func (r *repository) Create(ctx context.Context, model *Model) error {
+	_, err := r.tx.Extract(ctx).NewInsert().Model(model).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetByHash(ctx context.Context, purpose Purpose, hash string) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("purpose = ?", purpose).
+		Where("hash = ?", hash).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &model, true, nil
+}
+
+// Consume deletes token and returns it, reports false if token doesn't exist, expired or was already used.
+func (r *repository) Consume(ctx context.Context, purpose Purpose, hash string, now time.Time) (*Model, bool, error) {
+	var model Model
+	err := r.tx.Extract(ctx).NewSelect().
+		Model(&model).
+		Where("purpose = ?", purpose).
+		Where("hash = ?", hash).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	result, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("id = ?", model.ID).
+		Exec(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Token was consumed concurrently
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if affected != 1 || model.Expired(now) {
+		return nil, false, nil
+	}
+
+	return &model, true, nil
+}
+
+func (r *repository) DeleteByUserID(ctx context.Context, userID id.ID, purpose Purpose) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("user_id = ?", userID).
+		Where("purpose = ?", purpose).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteExpired(ctx context.Context, now time.Time) error {
+	_, err := r.tx.Extract(ctx).NewDelete().
+		Model((*Model)(nil)).
+		Where("expires_at <= ?", now).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package verification
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// GenerateToken returns new random token and its hash, only hash should be stored.
+func GenerateToken() (value, hash string) {
+	value = rand.Text() + rand.Text()
+	return value, HashToken(value)
+}
+
+// HashToken returns token hash used for lookup.
+func HashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,302 @@
+package wasm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+var magic = []byte{0x00, 0x61, 0x73, 0x6d} //nolint:gochecknoglobals
+
+const binaryVersion = 1
+
+// Section IDs
+const (
+	sectionCustom = 0
+	sectionImport = 2
+	sectionMemory = 5
+	sectionExport = 7
+)
+
+// Inspect parses WASM module binary and builds its report.
+func Inspect(data []byte) (*Report, error) {
+	if len(data) < 8 || !bytes.Equal(data[:4], magic) {
+		return nil, errors.New("not a WASM binary")
+	}
+	if version := uint32(data[4]) | uint32(data[5])<<8 | uint32(data[6])<<16 | uint32(data[7])<<24; version != binaryVersion {
+		return nil, fmt.Errorf("unsupported WASM binary version: %d", version)
+	}
+
+	report := &Report{
+		Size: len(data),
+	}
+
+	imports := make(map[string][]Import)
+	var namespaces []string
+
+	r := &reader{data: data, pos: 8}
+	for !r.eof() {
+		sectionID, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := r.u32()
+		if err != nil {
+			return nil, fmt.Errorf("section %d size: %w", sectionID, err)
+		}
+
+		offset := r.pos
+		content, err := r.bytes(uint64(size))
+		if err != nil {
+			return nil, fmt.Errorf("section %d at offset %d: %w", sectionID, offset, err)
+		}
+		sr := &reader{data: content}
+
+		switch sectionID {
+		case sectionCustom:
+			if err = inspectCustomSection(sr, report); err != nil {
+				return nil, fmt.Errorf("custom section at offset %d: %w", offset, err)
+			}
+		case sectionImport:
+			if err = inspectImportSection(sr, report, imports, &namespaces); err != nil {
+				return nil, fmt.Errorf("import section: %w", err)
+			}
+		case sectionMemory:
+			if err = inspectMemorySection(sr, report); err != nil {
+				return nil, fmt.Errorf("memory section: %w", err)
+			}
+		case sectionExport:
+			if err = inspectExportSection(sr, report); err != nil {
+				return nil, fmt.Errorf("export section: %w", err)
+			}
+		default:
+			if sectionID > 13 {
+				return nil, fmt.Errorf("unknown section %d at offset %d", sectionID, offset)
+			}
+		}
+	}
+
+	for _, namespace := range namespaces {
+		report.Imports = append(report.Imports, ImportNamespace{
+			Namespace: namespace,
+			Group:     NamespaceGroupOf(namespace),
+			Imports:   imports[namespace],
+		})
+	}
+
+	report.Toolchain = detectToolchain(report)
+
+	return report, nil
+}
+
+func inspectCustomSection(r *reader, report *Report) error {
+	name, err := r.name()
+	if err != nil {
+		return err
+	}
+
+	report.CustomSections = append(report.CustomSections, CustomSection{
+		Name: name,
+		Size: len(r.data) - r.pos,
+	})
+
+	if name == "producers" {
+		// Producers section is informational, malformed one should not fail inspection
+		report.producers, _ = parseProducers(r)
+	}
+
+	return nil
+}
+
+func inspectImportSection(r *reader, report *Report, imports map[string][]Import, namespaces *[]string) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+
+	for range count {
+		var namespace, name string
+		if namespace, err = r.name(); err != nil {
+			return err
+		}
+		if name, err = r.name(); err != nil {
+			return err
+		}
+
+		var kind byte
+		if kind, err = r.byte(); err != nil {
+			return err
+		}
+
+		var externalKind Kind
+		switch kind {
+		case 0x00: // Function: type index
+			externalKind = KindFunction
+			_, err = r.u32()
+		case 0x01: // Table: reference type + limits
+			externalKind = KindTable
+			if _, err = r.byte(); err == nil {
+				_, err = r.limits()
+			}
+		case 0x02: // Memory: limits
+			externalKind = KindMemory
+			var limits Limits
+			if limits, err = r.limits(); err == nil {
+				report.Memories = append(report.Memories, Memory{Limits: limits, Imported: true})
+			}
+		case 0x03: // Global: value type + mutability
+			externalKind = KindGlobal
+			if _, err = r.byte(); err == nil {
+				_, err = r.byte()
+			}
+		case 0x04: // Tag: attribute + type index
+			externalKind = KindTag
+			if _, err = r.byte(); err == nil {
+				_, err = r.u32()
+			}
+		default:
+			return fmt.Errorf("import %q.%q: unknown kind 0x%02x", namespace, name, kind)
+		}
+		if err != nil {
+			return fmt.Errorf("import %q.%q: %w", namespace, name, err)
+		}
+
+		if _, ok := imports[namespace]; !ok {
+			*namespaces = append(*namespaces, namespace)
+		}
+		imports[namespace] = append(imports[namespace], Import{
+			Name: name,
+			Kind: externalKind,
+		})
+	}
+
+	return nil
+}
+
+func inspectMemorySection(r *reader, report *Report) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+
+	for range count {
+		limits, limitsErr := r.limits()
+		if limitsErr != nil {
+			return limitsErr
+		}
+		report.Memories = append(report.Memories, Memory{Limits: limits, Imported: false})
+	}
+
+	return nil
+}
+
+func inspectExportSection(r *reader, report *Report) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+
+	for range count {
+		var name string
+		if name, err = r.name(); err != nil {
+			return err
+		}
+
+		var kind byte
+		if kind, err = r.byte(); err != nil {
+			return err
+		}
+		if _, err = r.u32(); err != nil {
+			return fmt.Errorf("export %q: %w", name, err)
+		}
+
+		var externalKind Kind
+		switch kind {
+		case 0x00:
+			externalKind = KindFunction
+		case 0x01:
+			externalKind = KindTable
+		case 0x02:
+			externalKind = KindMemory
+		case 0x03:
+			externalKind = KindGlobal
+		case 0x04:
+			externalKind = KindTag
+		default:
+			return fmt.Errorf("export %q: unknown kind 0x%02x", name, kind)
+		}
+
+		report.Exports = append(report.Exports, Export{
+			Name: name,
+			Kind: externalKind,
+		})
+	}
+
+	return nil
+}
+
+// parseProducers parses producers section, see https://github.com/WebAssembly/tool-conventions/blob/main/ProducersSection.md
+func parseProducers(r *reader) (map[string]map[string]string, error) {
+	fieldCount, err := r.u32()
+	if err != nil {
+		return nil, err
+	}
+
+	producers := make(map[string]map[string]string, fieldCount)
+	for range fieldCount {
+		var field string
+		if field, err = r.name(); err != nil {
+			return nil, err
+		}
+
+		var valueCount uint32
+		if valueCount, err = r.u32(); err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]string, valueCount)
+		for range valueCount {
+			var name, version string
+			if name, err = r.name(); err != nil {
+				return nil, err
+			}
+			if version, err = r.name(); err != nil {
+				return nil, err
+			}
+			values[name] = version
+		}
+		producers[field] = values
+	}
+
+	return producers, nil
+}
+
+func detectToolchain(report *Report) Toolchain {
+	processedBy := report.producers["processed-by"]
+	for _, name := range slices.Sorted(maps.Keys(processedBy)) {
+		if strings.Contains(strings.ToLower(name), "tinygo") {
+			return Toolchain{Name: ToolchainTinyGo, Version: processedBy[name]}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(processedBy)) {
+		if strings.HasPrefix(name, "Go") {
+			return Toolchain{Name: ToolchainGo, Version: processedBy[name]}
+		}
+	}
+
+	languages := report.producers["language"]
+	if version, ok := languages["Go"]; ok || report.HasCustomSection("go:buildid") {
+		return Toolchain{Name: ToolchainGo, Version: version}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(languages)) {
+		return Toolchain{Name: name, Version: languages[name]}
+	}
+
+	return Toolchain{Name: ToolchainUnknown, Version: ""}
+}
@@ -0,0 +1,392 @@
+package wasm_test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mymmrac/lithium/pkg/module/wasm"
+)
+
+func TestInspect(t *testing.T) {
+	maxMemory := uint64(16)
+
+	tests := []struct {
+		name          string
+		data          []byte
+		expectedErr   string
+		expectedMem   []wasm.Memory
+		expectedSizes map[string]int
+	}{
+		{
+			name: "empty module",
+			data: module(),
+		},
+		{
+			name:        "not WASM",
+			data:        []byte("\x7fELF\x02\x01\x01\x00"),
+			expectedErr: "not a WASM binary",
+		},
+		{
+			name:        "unsupported version",
+			data:        []byte{0x00, 0x61, 0x73, 0x6d, 0x02, 0x00, 0x00, 0x00},
+			expectedErr: "unsupported WASM binary version: 2",
+		},
+		{
+			name:        "unknown section",
+			data:        module(section(14)),
+			expectedErr: "unknown section 14",
+		},
+		{
+			name:        "truncated section size",
+			data:        module([]byte{7}),
+			expectedErr: "section 7 size: unexpected EOF",
+		},
+		{
+			name:        "truncated section",
+			data:        module([]byte{7, 10, 0x01}),
+			expectedErr: "section 7 at offset 10: unexpected EOF",
+		},
+		{
+			name:        "truncated export",
+			data:        module(section(7, uleb(1), name("handler"))),
+			expectedErr: "export section: unexpected EOF",
+		},
+		{
+			name:        "truncated import",
+			data:        module(section(2, uleb(2), importFunc("env", "log"))),
+			expectedErr: "import section: unexpected EOF",
+		},
+		{
+			name:        "truncated name",
+			data:        module(section(0, uleb(10), []byte("short"))),
+			expectedErr: "custom section at offset 10: unexpected EOF",
+		},
+		{
+			name:        "invalid name",
+			data:        module(section(0, uleb(2), []byte{0xff, 0xfe})),
+			expectedErr: "invalid UTF-8 name",
+		},
+		{
+			name:        "LEB128 too long",
+			data:        module([]byte{7, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}),
+			expectedErr: "integer representation too long",
+		},
+		{
+			name:        "LEB128 exceeds 32 bits",
+			data:        module([]byte{7, 0xff, 0xff, 0xff, 0xff, 0x10}),
+			expectedErr: "integer too large",
+		},
+		{
+			name:        "LEB128 exceeds 64 bits",
+			data:        module(section(5, uleb(1), []byte{0x04}, bytes.Repeat([]byte{0xff}, 9), []byte{0x02})),
+			expectedErr: "integer too large",
+		},
+		{
+			name: "LEB128 of max 64-bit value",
+			data: module(section(5, uleb(1), []byte{0x04}, bytes.Repeat([]byte{0xff}, 9), []byte{0x01})),
+			expectedMem: []wasm.Memory{
+				{Limits: wasm.Limits{Min: math.MaxUint64, Memory64: true}},
+			},
+		},
+		{
+			name: "memory limits",
+			data: module(
+				section(2, uleb(1), name("env"), name("memory"), []byte{0x02, 0x00}, uleb(2)),
+				section(5, uleb(2), []byte{0x01}, uleb(1), uleb(16), []byte{0x03}, uleb(1), uleb(16)),
+			),
+			expectedMem: []wasm.Memory{
+				{Limits: wasm.Limits{Min: 2}, Imported: true},
+				{Limits: wasm.Limits{Min: 1, Max: &maxMemory}},
+				{Limits: wasm.Limits{Min: 1, Max: &maxMemory, Shared: true}},
+			},
+		},
+		{
+			name:        "invalid limits flags",
+			data:        module(section(5, uleb(1), []byte{0x08}, uleb(1))),
+			expectedErr: "invalid limits flags: 0x08",
+		},
+		{
+			name:        "unknown import kind",
+			data:        module(section(2, uleb(1), name("env"), name("thing"), []byte{0x05})),
+			expectedErr: `import "env"."thing": unknown kind 0x05`,
+		},
+		{
+			name:        "unknown export kind",
+			data:        module(section(7, uleb(1), name("handler"), []byte{0x05}, uleb(0))),
+			expectedErr: `export "handler": unknown kind 0x05`,
+		},
+		{
+			name:          "custom sections",
+			data:          module(section(0, name("go:buildid"), []byte("id")), section(0, name("name"))),
+			expectedSizes: map[string]int{"go:buildid": 2, "name": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		report, err := wasm.Inspect(tt.data)
+		if tt.expectedErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("%s: expected error %q, got: %v", tt.name, tt.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+
+		if report.Size != len(tt.data) {
+			t.Errorf("%s: unexpected size: %d", tt.name, report.Size)
+		}
+		if !reflect.DeepEqual(report.Memories, tt.expectedMem) {
+			t.Errorf("%s: unexpected memories: %+v", tt.name, report.Memories)
+		}
+		if len(report.CustomSections) != len(tt.expectedSizes) {
+			t.Errorf("%s: unexpected custom sections: %+v", tt.name, report.CustomSections)
+		}
+		for _, customSection := range report.CustomSections {
+			if size, ok := tt.expectedSizes[customSection.Name]; !ok || size != customSection.Size {
+				t.Errorf("%s: unexpected custom section: %+v", tt.name, customSection)
+			}
+		}
+	}
+}
+
+func TestInspectImportsAndExports(t *testing.T) {
+	data := module(
+		section(2, uleb(6),
+			importFunc("wasi_snapshot_preview1", "fd_write"),
+			importFunc("extism:host/env", "input_length"),
+			importFunc("wape", "dial"),
+			importFunc("wasi_snapshot_preview1", "proc_exit"),
+			name("env"), name("counter"), []byte{0x03, 0x7f, 0x01},
+			name("env"), name("table"), []byte{0x01, 0x70, 0x00}, uleb(1),
+		),
+		section(7, uleb(2),
+			name("handler"), []byte{0x00}, uleb(3),
+			name("memory"), []byte{0x02}, uleb(0),
+		),
+	)
+
+	report, err := wasm.Inspect(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedImports := []wasm.ImportNamespace{
+		{
+			Namespace: "wasi_snapshot_preview1",
+			Group:     wasm.NamespaceGroupWASI,
+			Imports: []wasm.Import{
+				{Name: "fd_write", Kind: wasm.KindFunction},
+				{Name: "proc_exit", Kind: wasm.KindFunction},
+			},
+		},
+		{
+			Namespace: "extism:host/env",
+			Group:     wasm.NamespaceGroupExtism,
+			Imports:   []wasm.Import{{Name: "input_length", Kind: wasm.KindFunction}},
+		},
+		{
+			Namespace: "wape",
+			Group:     wasm.NamespaceGroupNetwork,
+			Imports:   []wasm.Import{{Name: "dial", Kind: wasm.KindFunction}},
+		},
+		{
+			Namespace: "env",
+			Group:     wasm.NamespaceGroupUnknown,
+			Imports: []wasm.Import{
+				{Name: "counter", Kind: wasm.KindGlobal},
+				{Name: "table", Kind: wasm.KindTable},
+			},
+		},
+	}
+	if !reflect.DeepEqual(report.Imports, expectedImports) {
+		t.Errorf("unexpected imports: %+v", report.Imports)
+	}
+
+	if !report.HasExport("handler", wasm.KindFunction) || !report.HasExport("memory", wasm.KindMemory) ||
+		report.HasExport("memory", wasm.KindFunction) || len(report.Exports) != 2 {
+		t.Errorf("unexpected exports: %+v", report.Exports)
+	}
+	if !report.RequiresNetwork() {
+		t.Error("expected network to be required")
+	}
+}
+
+func TestInspectToolchain(t *testing.T) {
+	tests := []struct {
+		name     string
+		sections [][]byte
+		expected wasm.Toolchain
+	}{
+		{
+			name:     "no producers",
+			expected: wasm.Toolchain{Name: wasm.ToolchainUnknown},
+		},
+		{
+			name:     "TinyGo",
+			sections: [][]byte{producers("processed-by", "Go", "go1.24.0", "TinyGo", "0.38.0")},
+			expected: wasm.Toolchain{Name: wasm.ToolchainTinyGo, Version: "0.38.0"},
+		},
+		{
+			name:     "Go processed by",
+			sections: [][]byte{producers("processed-by", "Go cmd/compile", "go1.25.1")},
+			expected: wasm.Toolchain{Name: wasm.ToolchainGo, Version: "go1.25.1"},
+		},
+		{
+			name:     "Go language",
+			sections: [][]byte{producers("language", "Go", "1.25")},
+			expected: wasm.Toolchain{Name: wasm.ToolchainGo, Version: "1.25"},
+		},
+		{
+			name:     "Go build ID",
+			sections: [][]byte{section(0, name("go:buildid"), []byte("id"))},
+			expected: wasm.Toolchain{Name: wasm.ToolchainGo},
+		},
+		{
+			name:     "other language",
+			sections: [][]byte{producers("language", "Rust", "", "C11", "")},
+			expected: wasm.Toolchain{Name: "C11"},
+		},
+		{
+			name:     "malformed producers",
+			sections: [][]byte{section(0, name("producers"), uleb(1), name("language"), uleb(3))},
+			expected: wasm.Toolchain{Name: wasm.ToolchainUnknown},
+		},
+	}
+
+	for _, tt := range tests {
+		report, err := wasm.Inspect(module(tt.sections...))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if report.Toolchain != tt.expected {
+			t.Errorf("%s: unexpected toolchain: %+v", tt.name, report.Toolchain)
+		}
+	}
+}
+
+func TestReportValidate(t *testing.T) {
+	handler := []wasm.Export{{Name: "handler", Kind: wasm.KindFunction}}
+	network := wasm.ImportNamespace{
+		Namespace: "wape",
+		Group:     wasm.NamespaceGroupNetwork,
+		Imports:   []wasm.Import{{Name: "dial", Kind: wasm.KindFunction}},
+	}
+
+	tests := []struct {
+		name           string
+		report         wasm.Report
+		networkEnabled bool
+		expectedErrs   []string
+	}{
+		{
+			name: "valid",
+			report: wasm.Report{Exports: handler, Imports: []wasm.ImportNamespace{
+				{Namespace: "wasi_snapshot_preview1", Group: wasm.NamespaceGroupWASI},
+				{Namespace: "extism:host/env", Group: wasm.NamespaceGroupExtism},
+			}},
+		},
+		{
+			name:         "handler not exported",
+			report:       wasm.Report{Exports: []wasm.Export{{Name: "handler", Kind: wasm.KindGlobal}}},
+			expectedErrs: []string{"handler function is not exported"},
+		},
+		{
+			name:           "network enabled",
+			report:         wasm.Report{Exports: handler, Imports: []wasm.ImportNamespace{network}},
+			networkEnabled: true,
+		},
+		{
+			name:         "network disabled",
+			report:       wasm.Report{Exports: handler, Imports: []wasm.ImportNamespace{network}},
+			expectedErrs: []string{`network functions from "wape"`},
+		},
+		{
+			name: "unknown imports",
+			report: wasm.Report{Exports: handler, Imports: []wasm.ImportNamespace{{
+				Namespace: "env",
+				Group:     wasm.NamespaceGroupUnknown,
+				Imports: []wasm.Import{
+					{Name: "log", Kind: wasm.KindFunction},
+					{Name: "memory", Kind: wasm.KindMemory},
+				},
+			}}},
+			expectedErrs: []string{
+				`unsupported host import "env"."log" (function)`,
+				`unsupported host import "env"."memory" (memory)`,
+			},
+		},
+		{
+			name: "64-bit memory",
+			report: wasm.Report{
+				Memories: []wasm.Memory{{Limits: wasm.Limits{Min: 1, Memory64: true}}},
+			},
+			expectedErrs: []string{"handler function is not exported", "64-bit memory is not supported"},
+		},
+	}
+
+	for _, tt := range tests {
+		err := tt.report.Validate(tt.networkEnabled)
+		if len(tt.expectedErrs) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%s: expected error", tt.name)
+			continue
+		}
+		for _, expectedErr := range tt.expectedErrs {
+			if !strings.Contains(err.Error(), expectedErr) {
+				t.Errorf("%s: expected error %q, got: %v", tt.name, expectedErr, err)
+			}
+		}
+	}
+}
+
+func module(sections ...[]byte) []byte {
+	return slices.Concat(append([][]byte{{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}}, sections...)...)
+}
+
+func section(id byte, content ...[]byte) []byte {
+	data := slices.Concat(content...)
+	return slices.Concat([]byte{id}, uleb(uint64(len(data))), data)
+}
+
+func importFunc(namespace, field string) []byte {
+	return slices.Concat(name(namespace), name(field), []byte{0x00}, uleb(0))
+}
+
+// producers returns producers section with single field and values as name and version pairs.
+func producers(field string, values ...string) []byte {
+	content := [][]byte{name("producers"), uleb(1), name(field), uleb(uint64(len(values) / 2))}
+	for _, value := range values {
+		content = append(content, name(value))
+	}
+	return section(0, content...)
+}
+
+func name(value string) []byte {
+	return slices.Concat(uleb(uint64(len(value))), []byte(value))
+}
+
+func uleb(value uint64) []byte {
+	var data []byte
+	for {
+		b := byte(value & 0x7f)
+		value >>= 7
+		if value == 0 {
+			return append(data, b)
+		}
+		data = append(data, b|0x80)
+	}
+}
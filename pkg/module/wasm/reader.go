@@ -0,0 +1,123 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// reader reads WASM binary encoding primitives.
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) eof() bool {
+	return r.pos >= len(r.data)
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.eof() {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func (r *reader) u32() (uint32, error) {
+	value, err := r.uleb(32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(value), nil
+}
+
+func (r *reader) u64() (uint64, error) {
+	return r.uleb(64)
+}
+
+func (r *reader) uleb(bits uint) (uint64, error) {
+	var result uint64
+	var shift uint
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		if shift >= bits {
+			return 0, errors.New("integer representation too long")
+		}
+		// The last byte can carry only remaining bits, otherwise value would be truncated
+		value := uint64(b & 0x7f)
+		if remaining := bits - shift; remaining < 7 && value>>remaining != 0 {
+			return 0, errors.New("integer too large")
+		}
+		result |= value << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+		shift += 7
+	}
+}
+
+func (r *reader) name() (string, error) {
+	size, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	data, err := r.bytes(uint64(size))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("invalid UTF-8 name at offset %d", r.pos-len(data))
+	}
+	return string(data), nil
+}
+
+func (r *reader) limits() (Limits, error) {
+	flags, err := r.byte()
+	if err != nil {
+		return Limits{}, err
+	}
+	if flags > 0x07 {
+		return Limits{}, fmt.Errorf("invalid limits flags: 0x%02x", flags)
+	}
+
+	limits := Limits{
+		Shared:   flags&0x02 != 0,
+		Memory64: flags&0x04 != 0,
+	}
+
+	read := r.u64
+	if !limits.Memory64 {
+		read = func() (uint64, error) {
+			value, readErr := r.u32()
+			return uint64(value), readErr
+		}
+	}
+
+	if limits.Min, err = read(); err != nil {
+		return Limits{}, err
+	}
+	if flags&0x01 != 0 {
+		maximum, readErr := read()
+		if readErr != nil {
+			return Limits{}, readErr
+		}
+		limits.Max = &maximum
+	}
+
+	return limits, nil
+}
@@ -0,0 +1,162 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PageSize is the size of WASM memory page in bytes.
+const PageSize = 64 * 1024
+
+// Kind of imported or exported entity.
+type Kind string
+
+// Kinds
+const (
+	KindFunction Kind = "function"
+	KindTable    Kind = "table"
+	KindMemory   Kind = "memory"
+	KindGlobal   Kind = "global"
+	KindTag      Kind = "tag"
+)
+
+// NamespaceGroup describes which host provides functions of the import namespace.
+type NamespaceGroup string
+
+// Namespace groups
+const (
+	NamespaceGroupWASI    NamespaceGroup = "wasi"
+	NamespaceGroupExtism  NamespaceGroup = "extism"
+	NamespaceGroupNetwork NamespaceGroup = "network"
+	NamespaceGroupUnknown NamespaceGroup = "unknown"
+)
+
+// NamespaceGroupOf returns group of import namespace.
+func NamespaceGroupOf(namespace string) NamespaceGroup {
+	switch {
+	case namespace == "wasi_snapshot_preview1":
+		return NamespaceGroupWASI
+	case strings.HasPrefix(namespace, "extism:"):
+		return NamespaceGroupExtism
+	case strings.HasPrefix(namespace, "wape"):
+		return NamespaceGroupNetwork
+	default:
+		return NamespaceGroupUnknown
+	}
+}
+
+// Toolchain names
+const (
+	ToolchainGo      = "Go"
+	ToolchainTinyGo  = "TinyGo"
+	ToolchainUnknown = "unknown"
+)
+
+// Report describes WASM module.
+type Report struct {
+	Size           int               `json:"size"`
+	Toolchain      Toolchain         `json:"toolchain"`
+	Exports        []Export          `json:"exports,omitempty"`
+	Imports        []ImportNamespace `json:"imports,omitempty"`
+	Memories       []Memory          `json:"memories,omitempty"`
+	CustomSections []CustomSection   `json:"customSections,omitempty"`
+
+	producers map[string]map[string]string
+}
+
+type Toolchain struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type Export struct {
+	Name string `json:"name"`
+	Kind Kind   `json:"kind"`
+}
+
+type ImportNamespace struct {
+	Namespace string         `json:"namespace"`
+	Group     NamespaceGroup `json:"group"`
+	Imports   []Import       `json:"imports"`
+}
+
+type Import struct {
+	Name string `json:"name"`
+	Kind Kind   `json:"kind"`
+}
+
+type Memory struct {
+	Limits
+
+	Imported bool `json:"imported,omitempty"`
+}
+
+// Limits of memory in pages.
+type Limits struct {
+	Min      uint64  `json:"min"`
+	Max      *uint64 `json:"max,omitempty"`
+	Shared   bool    `json:"shared,omitempty"`
+	Memory64 bool    `json:"memory64,omitempty"`
+}
+
+type CustomSection struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+// HasExport reports whether module exports entity with specified name and kind.
+func (r *Report) HasExport(name string, kind Kind) bool {
+	return slices.Contains(r.Exports, Export{Name: name, Kind: kind})
+}
+
+// HasCustomSection reports whether module contains custom section with specified name.
+func (r *Report) HasCustomSection(name string) bool {
+	return slices.ContainsFunc(r.CustomSections, func(section CustomSection) bool {
+		return section.Name == name
+	})
+}
+
+// RequiresNetwork reports whether module imports network host functions.
+func (r *Report) RequiresNetwork() bool {
+	return slices.ContainsFunc(r.Imports, func(namespace ImportNamespace) bool {
+		return namespace.Group == NamespaceGroupNetwork
+	})
+}
+
+// Validate checks that module can be run by Lithium, all problems are reported at once.
+func (r *Report) Validate(networkEnabled bool) error {
+	var errs []error
+
+	if !r.HasExport("handler", KindFunction) {
+		errs = append(errs, errors.New("handler function is not exported"))
+	}
+
+	for _, namespace := range r.Imports {
+		switch namespace.Group {
+		case NamespaceGroupWASI, NamespaceGroupExtism:
+			// Always provided
+		case NamespaceGroupNetwork:
+			if !networkEnabled {
+				errs = append(errs, fmt.Errorf("module imports network functions from %q, "+
+					"but network access is disabled in action configuration", namespace.Namespace))
+			}
+		case NamespaceGroupUnknown:
+			for _, imp := range namespace.Imports {
+				errs = append(errs, fmt.Errorf("unsupported host import %q.%q (%s)",
+					namespace.Namespace, imp.Name, imp.Kind))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unsupported import namespace %q", namespace.Namespace))
+		}
+	}
+
+	for _, memory := range r.Memories {
+		if memory.Memory64 {
+			errs = append(errs, errors.New("64-bit memory is not supported"))
+		}
+	}
+
+	return errors.Join(errs...)
+}